@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"crypto/sha1"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/database64128/modpack-dl-go/precheck"
+)
+
+func TestCancelLeavesNoEmptyFiles(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var cancelOnce sync.Once
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Cancel the run as soon as the first download starts, and stall until the client gives up.
+		cancelOnce.Do(cancel)
+		<-r.Context().Done()
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	var pjs []precheck.Job
+	for i := range 20 {
+		content := fmt.Appendf(nil, "content of file %d\n", i)
+		sum := sha1.Sum(content)
+		name := fmt.Sprintf("%d.jar", i)
+		pj := precheck.Job{
+			DownloadURL:     srv.URL + "/" + name,
+			DestinationPath: filepath.Join(dir, "client", name),
+			NewHash:         sha1.New,
+			Sum:             sum[:],
+			Size:            int64(len(content)),
+		}
+		if i%2 == 0 {
+			pj.SecondaryDestinationPath = filepath.Join(dir, "server", name)
+		}
+		pjs = append(pjs, pj)
+	}
+
+	runFleets(ctx, pjs, nil, nil)
+
+	if err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		fi, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if fi.Size() == 0 {
+			t.Errorf("zero-byte file left behind: %s", path)
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+}
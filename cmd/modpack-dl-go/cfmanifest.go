@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"os"
+	"path/filepath"
+
+	cfapi "github.com/database64128/modpack-dl-go/curseforge"
+	"github.com/database64128/modpack-dl-go/modpacksch"
+	"github.com/lmittmann/tint"
+)
+
+// Target types in modpack version manifests.
+const (
+	targetTypeGame      = "game"
+	targetTypeModLoader = "modloader"
+)
+
+// newCurseForgeManifest builds a CurseForge manifest from the given modpack and version manifests.
+// Only CurseForge files that are installed on the client are listed.
+func newCurseForgeManifest(modpack *modpacksch.ModpackManifest, version *modpacksch.ModpackVersionManifest) cfapi.Manifest {
+	m := cfapi.Manifest{
+		ManifestType:    cfapi.ManifestType,
+		ManifestVersion: cfapi.ManifestVersion,
+		Minecraft: cfapi.ManifestMinecraft{
+			ModLoaders: []cfapi.ManifestLoader{},
+		},
+		Name:      modpack.Name,
+		Version:   version.Name,
+		Files:     []cfapi.ManifestFile{},
+		Overrides: cfapi.DefaultOverrides,
+	}
+
+	if len(modpack.Authors) > 0 {
+		m.Author = modpack.Authors[0].Name
+	}
+
+	for _, t := range version.Targets {
+		switch t.Type {
+		case targetTypeGame:
+			m.Minecraft.Version = t.Version
+		case targetTypeModLoader:
+			m.Minecraft.ModLoaders = append(m.Minecraft.ModLoaders, cfapi.ManifestLoader{
+				ID:      t.Name + "-" + t.Version,
+				Primary: len(m.Minecraft.ModLoaders) == 0,
+			})
+		}
+	}
+
+	for i := range version.Files {
+		f := &version.Files[i]
+		if f.CurseForge == nil || f.ServerOnly {
+			continue
+		}
+		m.Files = append(m.Files, cfapi.ManifestFile{
+			ProjectID: f.CurseForge.Project,
+			FileID:    f.CurseForge.File,
+			Required:  !f.Optional,
+		})
+	}
+
+	return m
+}
+
+// writeCurseForgeManifest writes the CurseForge manifest to dir.
+func writeCurseForgeManifest(dir string, m *cfapi.Manifest) error {
+	b, err := json.MarshalIndent(m, "", "    ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, cfapi.ManifestFileName), append(b, '\n'), 0644)
+}
+
+// writeCurseForgeManifests writes a CurseForge manifest to the client path of each pack installed to one.
+func writeCurseForgeManifests(ctx context.Context, logger *slog.Logger, packs []*pack) {
+	for _, p := range packs {
+		if p.clientPath == "" {
+			continue
+		}
+		m := newCurseForgeManifest(&p.modpackManifest, &p.versionManifest)
+		if err := writeCurseForgeManifest(p.clientPath, &m); err != nil {
+			logger.LogAttrs(ctx, slog.LevelWarn, "Failed to write CurseForge manifest",
+				slog.String("path", p.clientPath),
+				tint.Err(err),
+			)
+		}
+	}
+}
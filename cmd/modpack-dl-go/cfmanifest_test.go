@@ -0,0 +1,92 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	cfapi "github.com/database64128/modpack-dl-go/curseforge"
+	"github.com/database64128/modpack-dl-go/modpacksch"
+)
+
+func TestWriteCurseForgeManifest(t *testing.T) {
+	var (
+		modpack modpacksch.ModpackManifest
+		version modpacksch.ModpackVersionManifest
+	)
+	if err := json.Unmarshal([]byte(`{"id": 42, "name": "Example Pack", "authors": [{"name": "Author"}, {"name": "Other"}]}`), &modpack); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal([]byte(`{
+		"id": 100,
+		"name": "1.2.0",
+		"targets": [
+			{"name": "minecraft", "type": "game", "version": "1.20.1"},
+			{"name": "forge", "type": "modloader", "version": "47.2.0"},
+			{"name": "java", "type": "runtime", "version": "17"}
+		],
+		"files": [
+			{"path": "./mods/", "name": "a.jar", "curseforge": {"project": 1, "file": 10}},
+			{"path": "./mods/", "name": "b.jar", "optional": true, "curseforge": {"project": 2, "file": 20}},
+			{"path": "./mods/", "name": "server.jar", "serveronly": true, "curseforge": {"project": 3, "file": 30}},
+			{"path": "./config/", "name": "a.cfg", "url": "https://example.com/a.cfg"}
+		]
+	}`), &version); err != nil {
+		t.Fatal(err)
+	}
+
+	m := newCurseForgeManifest(&modpack, &version)
+	dir := t.TempDir()
+	if err := writeCurseForgeManifest(dir, &m); err != nil {
+		t.Fatalf("writeCurseForgeManifest() error = %v", err)
+	}
+
+	b, err := os.ReadFile(filepath.Join(dir, "manifest.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got map[string]any
+	if err = json.Unmarshal(b, &got); err != nil {
+		t.Fatalf("manifest.json is not valid JSON: %v\n%s", err, b)
+	}
+
+	want := map[string]any{
+		"minecraft": map[string]any{
+			"version": "1.20.1",
+			"modLoaders": []any{
+				map[string]any{"id": "forge-47.2.0", "primary": true},
+			},
+		},
+		"manifestType":    "minecraftModpack",
+		"manifestVersion": 1.0,
+		"name":            "Example Pack",
+		"version":         "1.2.0",
+		"author":          "Author",
+		"files": []any{
+			map[string]any{"projectID": 1.0, "fileID": 10.0, "required": true},
+			map[string]any{"projectID": 2.0, "fileID": 20.0, "required": false},
+		},
+		"overrides": "overrides",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("manifest.json = %s\nwant %v", b, want)
+	}
+}
+
+func TestNewCurseForgeManifestEmptyLists(t *testing.T) {
+	m := newCurseForgeManifest(&modpacksch.ModpackManifest{}, &modpacksch.ModpackVersionManifest{})
+	b, err := json.Marshal(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got cfapi.Manifest
+	if err = json.Unmarshal(b, &got); err != nil {
+		t.Fatal(err)
+	}
+	// Importers expect arrays, not null.
+	if got.Files == nil || got.Minecraft.ModLoaders == nil {
+		t.Errorf("manifest = %s, want empty files and mod loaders arrays", b)
+	}
+}
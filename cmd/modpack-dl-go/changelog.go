@@ -0,0 +1,124 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+
+	"github.com/database64128/modpack-dl-go/modpacksch"
+)
+
+// printChangelog prints the markdown changelog to w, optionally rendered to plain text.
+func printChangelog(w io.Writer, changelog string, plain bool) error {
+	if plain {
+		changelog = markdownToPlainText(changelog)
+	}
+	changelog = strings.TrimSpace(changelog)
+	if changelog == "" {
+		_, err := fmt.Fprintln(w, "(no changelog)")
+		return err
+	}
+	_, err := fmt.Fprintln(w, changelog)
+	return err
+}
+
+// versionChangelog is the changelog of a version.
+type versionChangelog struct {
+	ID        int64  `json:"id"`
+	Name      string `json:"name"`
+	Changelog string `json:"changelog"`
+}
+
+// printVersionChangelogs fetches the changelog of each of the given versions in order,
+// and prints them to w, either as text or as JSON.
+func printVersionChangelogs(ctx context.Context, w io.Writer, client modpacksch.ModpackClient, modpackID int64, versions []modpacksch.ModpackVersion, plain, asJSON bool) error {
+	var changelogs []versionChangelog
+	if asJSON {
+		changelogs = make([]versionChangelog, 0, len(versions))
+	}
+
+	for i, v := range versions {
+		m, err := client.GetModpackVersionManifest(ctx, modpackID, v.ID)
+		if err != nil {
+			return fmt.Errorf("failed to get manifest of version %d: %w", v.ID, err)
+		}
+
+		changelog := m.Changelog
+		if plain {
+			changelog = markdownToPlainText(changelog)
+		}
+
+		if asJSON {
+			changelogs = append(changelogs, versionChangelog{
+				ID:        v.ID,
+				Name:      v.Name,
+				Changelog: changelog,
+			})
+			continue
+		}
+
+		if i > 0 {
+			if _, err = fmt.Fprintln(w); err != nil {
+				return err
+			}
+		}
+		if _, err = fmt.Fprintf(w, "==> %s (%d) <==\n", v.Name, v.ID); err != nil {
+			return err
+		}
+		if err = printChangelog(w, changelog, false); err != nil {
+			return err
+		}
+	}
+
+	if asJSON {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "    ")
+		return enc.Encode(changelogs)
+	}
+	return nil
+}
+
+var (
+	mdImageRegexp    = regexp.MustCompile(`!\[([^\]]*)\]\([^)]*\)`)
+	mdLinkRegexp     = regexp.MustCompile(`\[([^\]]*)\]\(([^)]*)\)`)
+	mdEmphasisRegexp = regexp.MustCompile(`(\*\*|__|~~|\*|` + "`" + `)(\S(?:.*?\S)?)(\*\*|__|~~|\*|` + "`" + `)`)
+	mdHeadingRegexp  = regexp.MustCompile(`^\s{0,3}#{1,6}\s+`)
+	mdListRegexp     = regexp.MustCompile(`^(\s*)[*+-]\s+`)
+	mdRuleRegexp     = regexp.MustCompile(`^\s{0,3}([-*_])(\s*[-*_]){2,}\s*$`)
+	htmlTagRegexp    = regexp.MustCompile(`</?[A-Za-z][^>]*>`)
+)
+
+// markdownToPlainText renders the markdown text to plain text on a best-effort basis.
+//
+// Headings, emphasis, code fences, horizontal rules, and HTML tags are stripped.
+// Links are rendered as "text (url)", images as their alt text, and list items as bullets.
+func markdownToPlainText(md string) string {
+	var b strings.Builder
+	s := bufio.NewScanner(strings.NewReader(md))
+	for s.Scan() {
+		line := strings.TrimRight(s.Text(), " \t\r")
+		if strings.HasPrefix(strings.TrimSpace(line), "```") || mdRuleRegexp.MatchString(line) {
+			continue
+		}
+		line = mdHeadingRegexp.ReplaceAllString(line, "")
+		line = mdListRegexp.ReplaceAllString(line, "${1}• ")
+		line = strings.TrimPrefix(line, "> ")
+		line = htmlTagRegexp.ReplaceAllString(line, "")
+		line = mdImageRegexp.ReplaceAllString(line, "$1")
+		line = mdLinkRegexp.ReplaceAllString(line, "$1 ($2)")
+		line = mdEmphasisRegexp.ReplaceAllStringFunc(line, func(m string) string {
+			sm := mdEmphasisRegexp.FindStringSubmatch(m)
+			if sm[1] != sm[3] {
+				return m
+			}
+			return sm[2]
+		})
+		b.WriteString(line)
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/database64128/modpack-dl-go/modpacksch"
+)
+
+const testChangelog = "## What's new\n\n- Added **Create** ([link](https://example.com/create))\n- Removed `OptiFine`\n"
+
+func TestPrintChangelog(t *testing.T) {
+	var m modpacksch.ModpackVersionManifest
+	if err := json.Unmarshal([]byte(`{"id": 100, "name": "1.0.0", "changelog": `+jsonString(t, testChangelog)+`}`), &m); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, c := range []struct {
+		name  string
+		plain bool
+		want  string
+	}{
+		{"Markdown", false, strings.TrimSpace(testChangelog) + "\n"},
+		{"Plain", true, "What's new\n\n• Added Create (link (https://example.com/create))\n• Removed OptiFine\n"},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := printChangelog(&buf, m.Changelog, c.plain); err != nil {
+				t.Fatal(err)
+			}
+			if got := buf.String(); got != c.want {
+				t.Errorf("printChangelog() wrote %q, want %q", got, c.want)
+			}
+		})
+	}
+}
+
+func TestPrintChangelogEmpty(t *testing.T) {
+	var buf bytes.Buffer
+	if err := printChangelog(&buf, " \n", false); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := buf.String(), "(no changelog)\n"; got != want {
+		t.Errorf("printChangelog() wrote %q, want %q", got, want)
+	}
+}
+
+// changelogClient serves version manifests with changelogs, and records the versions fetched.
+type changelogClient struct {
+	modpacksch.ModpackClient
+	changelogs map[int64]string
+	fetched    []int64
+}
+
+func (c *changelogClient) GetModpackVersionManifest(ctx context.Context, modpackID, versionID int64) (modpacksch.ModpackVersionManifest, error) {
+	c.fetched = append(c.fetched, versionID)
+	var m modpacksch.ModpackVersionManifest
+	m.ID = versionID
+	m.Changelog = c.changelogs[versionID]
+	return m, nil
+}
+
+func TestPrintVersionChangelogs(t *testing.T) {
+	m := testModpackManifest(t, "")
+	client := &changelogClient{changelogs: map[int64]string{
+		1: "Initial release",
+		3: "# Fixes\nFixed a crash",
+	}}
+
+	var buf bytes.Buffer
+	if err := printVersionChangelogs(context.Background(), &buf, client, m.ID, m.VersionsNewestFirst(), true, false); err != nil {
+		t.Fatal(err)
+	}
+	want := "==> 1.2.0 (3) <==\nFixes\nFixed a crash\n\n==> 1.1.0 (2) <==\n(no changelog)\n\n==> 1.0.0 (1) <==\nInitial release\n"
+	if got := buf.String(); got != want {
+		t.Errorf("printVersionChangelogs() wrote %q, want %q", got, want)
+	}
+	if len(client.fetched) != 3 {
+		t.Errorf("fetched versions %v, want all 3", client.fetched)
+	}
+}
+
+func TestPrintVersionChangelogsJSON(t *testing.T) {
+	m := testModpackManifest(t, "")
+	client := &changelogClient{changelogs: map[int64]string{2: "**Beta**"}}
+
+	var buf bytes.Buffer
+	if err := printVersionChangelogs(context.Background(), &buf, client, m.ID, m.VersionsNewestFirst(), false, true); err != nil {
+		t.Fatal(err)
+	}
+	var got []versionChangelog
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("output is not valid JSON: %v\n%s", err, buf.String())
+	}
+	if len(got) != 3 {
+		t.Fatalf("got %d changelogs, want 3", len(got))
+	}
+	if got[1] != (versionChangelog{ID: 2, Name: "1.1.0", Changelog: "**Beta**"}) {
+		t.Errorf("changelogs[1] = %+v", got[1])
+	}
+}
+
+// jsonString returns s encoded as a JSON string.
+func jsonString(t *testing.T, s string) string {
+	t.Helper()
+	b, err := json.Marshal(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+)
+
+// applyConfigFile sets the flags in fs that were not set on the command line
+// to the values in the config file at path.
+//
+// The config file is a JSON object whose keys are flag names without the leading dash,
+// e.g. {"modpackID": 120, "clientPath": "client", "dryRun": true}. Values are strings, numbers,
+// or booleans, given as they would be on the command line, e.g. "30s" for durations
+// and "10MiB" for sizes. List flags also take arrays, whose elements are added in order.
+//
+// Flags set on the command line take precedence over the config file, which takes precedence
+// over the defaults. Unknown keys, and the flag that names the config file, are rejected.
+func applyConfigFile(fs *flag.FlagSet, path, configFlag string) error {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	var values map[string]any
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	if err = dec.Decode(&values); err != nil {
+		return fmt.Errorf("failed to decode config file %q: %w", path, err)
+	}
+
+	setOnCommandLine := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) {
+		setOnCommandLine[f.Name] = true
+	})
+
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	for _, name := range names {
+		if name == configFlag || fs.Lookup(name) == nil {
+			return fmt.Errorf("config file %q: unknown flag %q", path, name)
+		}
+		args, err := configFlagArgs(values[name])
+		if err != nil {
+			return fmt.Errorf("config file %q: flag %q: %w", path, name, err)
+		}
+		if setOnCommandLine[name] {
+			continue
+		}
+		for _, arg := range args {
+			if err = fs.Set(name, arg); err != nil {
+				return fmt.Errorf("config file %q: invalid value %q for flag %q: %w", path, arg, name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// configFlagArgs returns the command-line arguments equivalent to a config file value.
+func configFlagArgs(v any) ([]string, error) {
+	if elems, ok := v.([]any); ok {
+		args := make([]string, len(elems))
+		for i, elem := range elems {
+			arg, err := configFlagArg(elem)
+			if err != nil {
+				return nil, err
+			}
+			args[i] = arg
+		}
+		return args, nil
+	}
+
+	arg, err := configFlagArg(v)
+	if err != nil {
+		return nil, err
+	}
+	return []string{arg}, nil
+}
+
+// configFlagArg returns the command-line argument equivalent to a scalar config file value.
+func configFlagArg(v any) (string, error) {
+	switch v := v.(type) {
+	case string:
+		return v, nil
+	case json.Number:
+		return v.String(), nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	default:
+		return "", fmt.Errorf("unsupported value %v, expected a string, number, boolean, or array of them", v)
+	}
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testConfigFlags is a set of flags of each kind, as defined by newTestConfigFlagSet.
+type testConfigFlags struct {
+	modpackID  int64
+	clientPath string
+	dryRun     bool
+	timeout    time.Duration
+	rateLimit  byteSize
+	ignore     int64s
+	pruneDirs  strs
+	config     string
+}
+
+// newTestConfigFlagSet returns a flag set with a flag of each kind, set from args.
+func newTestConfigFlagSet(t *testing.T, args ...string) (*flag.FlagSet, *testConfigFlags) {
+	t.Helper()
+	var f testConfigFlags
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	fs.Int64Var(&f.modpackID, "modpackID", 0, "")
+	fs.StringVar(&f.clientPath, "clientPath", "", "")
+	fs.BoolVar(&f.dryRun, "dryRun", false, "")
+	fs.DurationVar(&f.timeout, "timeout", time.Minute, "")
+	fs.Var(&f.rateLimit, "rateLimit", "")
+	fs.Var(&f.ignore, "ignoreCurseForgeProjects", "")
+	fs.Var(&f.pruneDirs, "pruneDirs", "")
+	fs.StringVar(&f.config, "config", "", "")
+	if err := fs.Parse(args); err != nil {
+		t.Fatal(err)
+	}
+	return fs, &f
+}
+
+// writeConfigFile writes content to a config file, and returns its path.
+func writeConfigFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestApplyConfigFile(t *testing.T) {
+	path := writeConfigFile(t, `{
+		"modpackID": 120,
+		"clientPath": "client",
+		"dryRun": true,
+		"timeout": "30s",
+		"rateLimit": "10MiB",
+		"ignoreCurseForgeProjects": [1, 2],
+		"pruneDirs": ["mods", "config,scripts"]
+	}`)
+	fs, f := newTestConfigFlagSet(t)
+	if err := applyConfigFile(fs, path, "config"); err != nil {
+		t.Fatalf("applyConfigFile() error = %v", err)
+	}
+
+	if f.modpackID != 120 {
+		t.Errorf("modpackID = %d, want 120", f.modpackID)
+	}
+	if f.clientPath != "client" {
+		t.Errorf("clientPath = %q, want %q", f.clientPath, "client")
+	}
+	if !f.dryRun {
+		t.Error("dryRun = false, want true")
+	}
+	if f.timeout != 30*time.Second {
+		t.Errorf("timeout = %v, want 30s", f.timeout)
+	}
+	if f.rateLimit != 10<<20 {
+		t.Errorf("rateLimit = %d, want %d", f.rateLimit, 10<<20)
+	}
+	if want := (int64s{1, 2}); !slices.Equal(f.ignore, want) {
+		t.Errorf("ignoreCurseForgeProjects = %v, want %v", f.ignore, want)
+	}
+	if want := (strs{"mods", "config", "scripts"}); !slices.Equal(f.pruneDirs, want) {
+		t.Errorf("pruneDirs = %q, want %q", f.pruneDirs, want)
+	}
+}
+
+func TestApplyConfigFileCommandLineTakesPrecedence(t *testing.T) {
+	path := writeConfigFile(t, `{"modpackID": 120, "clientPath": "client", "dryRun": true, "pruneDirs": ["mods"]}`)
+	fs, f := newTestConfigFlagSet(t, "-modpackID", "7", "-dryRun=false", "-pruneDirs", "config")
+	if err := applyConfigFile(fs, path, "config"); err != nil {
+		t.Fatalf("applyConfigFile() error = %v", err)
+	}
+
+	if f.modpackID != 7 {
+		t.Errorf("modpackID = %d, want 7 from the command line", f.modpackID)
+	}
+	if f.dryRun {
+		t.Error("dryRun = true, want false from the command line")
+	}
+	// List flags on the command line replace the config file's list, instead of adding to it.
+	if want := (strs{"config"}); !slices.Equal(f.pruneDirs, want) {
+		t.Errorf("pruneDirs = %q, want %q", f.pruneDirs, want)
+	}
+	// Flags not on the command line still come from the config file.
+	if f.clientPath != "client" {
+		t.Errorf("clientPath = %q, want %q", f.clientPath, "client")
+	}
+	// Flags in neither keep their defaults.
+	if f.timeout != time.Minute {
+		t.Errorf("timeout = %v, want the default 1m", f.timeout)
+	}
+}
+
+func TestApplyConfigFileErrors(t *testing.T) {
+	for _, c := range []struct {
+		name    string
+		content string
+		wantErr string
+	}{
+		{"UnknownFlag", `{"modpackId": 120}`, `unknown flag "modpackId"`},
+		{"ConfigFlag", `{"config": "other.json"}`, `unknown flag "config"`},
+		{"InvalidValue", `{"modpackID": "latest"}`, `invalid value "latest" for flag "modpackID"`},
+		{"InvalidListElement", `{"ignoreCurseForgeProjects": [1, "two"]}`, `invalid value "two" for flag "ignoreCurseForgeProjects"`},
+		{"Object", `{"clientPath": {"path": "client"}}`, `flag "clientPath": unsupported value`},
+		{"Null", `{"clientPath": null}`, `flag "clientPath": unsupported value`},
+		{"NestedArray", `{"pruneDirs": [["mods"]]}`, `flag "pruneDirs": unsupported value`},
+		{"NotAnObject", `["modpackID", 120]`, "failed to decode config file"},
+		{"Malformed", `{"modpackID": 120,}`, "failed to decode config file"},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			path := writeConfigFile(t, c.content)
+			fs, _ := newTestConfigFlagSet(t)
+			err := applyConfigFile(fs, path, "config")
+			if err == nil || !strings.Contains(err.Error(), c.wantErr) {
+				t.Errorf("applyConfigFile() error = %v, want one containing %q", err, c.wantErr)
+			}
+		})
+	}
+}
+
+func TestApplyConfigFileMissing(t *testing.T) {
+	fs, _ := newTestConfigFlagSet(t)
+	err := applyConfigFile(fs, filepath.Join(t.TempDir(), "missing.json"), "config")
+	if !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("applyConfigFile() error = %v, want %v", err, os.ErrNotExist)
+	}
+}
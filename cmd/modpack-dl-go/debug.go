@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"log/slog"
+	"net"
+	"net/http"
+
+	"github.com/database64128/modpack-dl-go/download"
+	"github.com/database64128/modpack-dl-go/precheck"
+	"github.com/lmittmann/tint"
+)
+
+// fleetMetrics returns the aggregate counters of the worker fleets, for publishing under [expvar].
+func fleetMetrics(pwf *precheck.WorkerFleet, dwf *download.WorkerFleet) map[string]int64 {
+	ps := pwf.Stats()
+	ds := dwf.Stats()
+	return map[string]int64{
+		"filesDownloaded":   ds.Downloaded,
+		"bytesDownloaded":   ds.Bytes,
+		"filesSkipped":      ps.Skipped,
+		"filesMoved":        ps.Moved,
+		"filesCopied":       ps.Copied,
+		"filesLinked":       ps.Linked,
+		"filesQueued":       ps.Queued,
+		"retries":           ds.Retries,
+		"precheckFailures":  ps.Failed,
+		"downloadFailures":  ds.Failed,
+		"downloadsInFlight": int64(dwf.InFlight()),
+	}
+}
+
+// serveDebug publishes the metrics of the worker fleets under [expvar] as "modpack-dl-go",
+// and serves them at /debug/vars on the given address until the returned function is called.
+func serveDebug(ctx context.Context, logger *slog.Logger, addr string, pwf *precheck.WorkerFleet, dwf *download.WorkerFleet) (stop func(), err error) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	expvar.Publish("modpack-dl-go", expvar.Func(func() any {
+		return fleetMetrics(pwf, dwf)
+	}))
+
+	mux := http.NewServeMux()
+	mux.Handle("GET /debug/vars", expvar.Handler())
+	srv := http.Server{Handler: mux}
+
+	logger.LogAttrs(ctx, slog.LevelInfo, "Serving debug endpoint",
+		slog.String("url", "http://"+ln.Addr().String()+"/debug/vars"),
+	)
+
+	go func() {
+		if err := srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.LogAttrs(ctx, slog.LevelWarn, "Failed to serve debug endpoint", tint.Err(err))
+		}
+	}()
+
+	return func() { srv.Close() }, nil
+}
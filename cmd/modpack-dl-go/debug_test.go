@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"encoding/json"
+	"expvar"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/database64128/modpack-dl-go/precheck"
+)
+
+func TestServeDebugPublishesFleetMetrics(t *testing.T) {
+	if expvar.Get("modpack-dl-go") != nil {
+		t.Skip("metrics already published by an earlier run of the test, and expvar cannot unpublish them")
+	}
+
+	files := map[string][]byte{
+		"/a.jar": []byte("content of a\n"),
+		"/b.jar": []byte("content of b\n"),
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		content, ok := files[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write(content)
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	job := func(name string, content []byte) precheck.Job {
+		sum := sha1.Sum(content)
+		return precheck.Job{
+			DownloadURL:     srv.URL + "/" + name,
+			DestinationPath: filepath.Join(dir, name),
+			NewHash:         sha1.New,
+			Sum:             sum[:],
+			Size:            int64(len(content)),
+		}
+	}
+
+	skipped := []byte("already in place\n")
+	if err := os.WriteFile(filepath.Join(dir, "skipped.jar"), skipped, 0644); err != nil {
+		t.Fatal(err)
+	}
+	retried := job("retried.jar", files["/b.jar"])
+	retried.Mirrors = []string{srv.URL + "/b.jar"}
+	pjs := []precheck.Job{
+		job("a.jar", files["/a.jar"]),
+		retried,
+		job("skipped.jar", skipped),
+		job("missing.jar", []byte("never served\n")),
+	}
+	pwf, dwf := runFleets(context.Background(), pjs, nil, nil)
+
+	var logs bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&logs, nil))
+	stop, err := serveDebug(context.Background(), logger, "127.0.0.1:0", pwf, dwf)
+	if err != nil {
+		t.Fatalf("serveDebug() error = %v", err)
+	}
+	defer stop()
+
+	want := map[string]int64{
+		"filesDownloaded":   2,
+		"bytesDownloaded":   int64(len(files["/a.jar"]) + len(files["/b.jar"])),
+		"filesSkipped":      1,
+		"filesMoved":        0,
+		"filesCopied":       0,
+		"filesLinked":       0,
+		"filesQueued":       3,
+		"retries":           1,
+		"precheckFailures":  0,
+		"downloadFailures":  1,
+		"downloadsInFlight": 0,
+	}
+	assertMetrics := func(t *testing.T, source string, data []byte) {
+		t.Helper()
+		var got map[string]int64
+		if err := json.Unmarshal(data, &got); err != nil {
+			t.Fatalf("%s: %q is not JSON: %v", source, data, err)
+		}
+		for k, v := range want {
+			if got[k] != v {
+				t.Errorf("%s: %s = %d, want %d", source, k, got[k], v)
+			}
+		}
+	}
+
+	v := expvar.Get("modpack-dl-go")
+	if v == nil {
+		t.Fatal("metrics are not published under expvar")
+	}
+	assertMetrics(t, "expvar", []byte(v.String()))
+
+	var entry struct {
+		URL string `json:"url"`
+	}
+	if err := json.Unmarshal(logs.Bytes(), &entry); err != nil || entry.URL == "" {
+		t.Fatalf("debug endpoint URL not logged: %q, %v", logs.String(), err)
+	}
+	resp, err := http.Get(entry.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var vars map[string]json.RawMessage
+	if err := json.NewDecoder(resp.Body).Decode(&vars); err != nil {
+		t.Fatalf("decoding /debug/vars: %v", err)
+	}
+	assertMetrics(t, "/debug/vars", vars["modpack-dl-go"])
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/database64128/modpack-dl-go/modpacksch"
+)
+
+// Kinds of version changes.
+const (
+	changeAdded   = "added"
+	changeRemoved = "removed"
+	changeUpdated = "updated"
+)
+
+// Sides a file is installed on.
+const (
+	sideBoth   = "both"
+	sideClient = "client"
+	sideServer = "server"
+)
+
+// versionFile is the content of a path in a version manifest,
+// merged from all files at the path.
+type versionFile struct {
+	sha1s []string
+	side  string
+}
+
+// versionFiles returns the files of the version manifest keyed by path,
+// and the paths in manifest order.
+//
+// Files are keyed by path alone, so that a file moving between client-only and server-only
+// is reported as updated rather than removed and added.
+func versionFiles(manifest *modpacksch.ModpackVersionManifest) (map[string]*versionFile, []string) {
+	files := make(map[string]*versionFile, len(manifest.Files))
+	paths := make([]string, 0, len(manifest.Files))
+
+	for i := range manifest.Files {
+		f := &manifest.Files[i]
+		side := sideBoth
+		switch {
+		case f.ClientOnly:
+			side = sideClient
+		case f.ServerOnly:
+			side = sideServer
+		}
+
+		p := f.ManifestPath()
+		vf, ok := files[p]
+		if !ok {
+			files[p] = &versionFile{sha1s: []string{f.SHA1}, side: side}
+			paths = append(paths, p)
+			continue
+		}
+		if !slices.Contains(vf.sha1s, f.SHA1) {
+			vf.sha1s = append(vf.sha1s, f.SHA1)
+			slices.Sort(vf.sha1s)
+		}
+		if vf.side != side {
+			vf.side = sideBoth
+		}
+	}
+
+	return files, paths
+}
+
+// versionChange is a difference in a file between two versions.
+type versionChange struct {
+	Change  string `json:"change"`
+	Path    string `json:"path"`
+	OldSide string `json:"oldSide,omitempty"`
+	NewSide string `json:"newSide,omitempty"`
+	OldSHA1 string `json:"oldSHA1,omitempty"`
+	NewSHA1 string `json:"newSHA1,omitempty"`
+}
+
+// versionDiff is the differences between two versions.
+type versionDiff struct {
+	From    int64           `json:"from"`
+	To      int64           `json:"to"`
+	Changes []versionChange `json:"changes"`
+}
+
+// newVersionDiff returns the differences from the old version to the new version,
+// with added and updated files in the order of the new version, followed by removed files.
+func newVersionDiff(oldManifest, newManifest *modpacksch.ModpackVersionManifest) versionDiff {
+	oldFiles, oldPaths := versionFiles(oldManifest)
+	newFiles, newPaths := versionFiles(newManifest)
+
+	d := versionDiff{
+		From:    oldManifest.ID,
+		To:      newManifest.ID,
+		Changes: []versionChange{},
+	}
+
+	for _, p := range newPaths {
+		nf := newFiles[p]
+		of, ok := oldFiles[p]
+		switch {
+		case !ok:
+			d.Changes = append(d.Changes, versionChange{
+				Change:  changeAdded,
+				Path:    p,
+				NewSide: nf.side,
+				NewSHA1: strings.Join(nf.sha1s, ","),
+			})
+		case of.side != nf.side || !slices.Equal(of.sha1s, nf.sha1s):
+			d.Changes = append(d.Changes, versionChange{
+				Change:  changeUpdated,
+				Path:    p,
+				OldSide: of.side,
+				NewSide: nf.side,
+				OldSHA1: strings.Join(of.sha1s, ","),
+				NewSHA1: strings.Join(nf.sha1s, ","),
+			})
+		}
+	}
+
+	for _, p := range oldPaths {
+		if _, ok := newFiles[p]; ok {
+			continue
+		}
+		of := oldFiles[p]
+		d.Changes = append(d.Changes, versionChange{
+			Change:  changeRemoved,
+			Path:    p,
+			OldSide: of.side,
+			OldSHA1: strings.Join(of.sha1s, ","),
+		})
+	}
+
+	return d
+}
+
+// print prints the differences to w, either as a table or as JSON.
+func (d *versionDiff) print(w io.Writer, asJSON bool) error {
+	if asJSON {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "    ")
+		return enc.Encode(d)
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "CHANGE\tPATH\tSIDE\tOLD SHA1\tNEW SHA1")
+	for _, c := range d.Changes {
+		side := c.NewSide
+		switch {
+		case side == "":
+			side = c.OldSide
+		case c.OldSide != "" && c.OldSide != side:
+			side = c.OldSide + " -> " + side
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", c.Change, c.Path, side, dashIfEmpty(c.OldSHA1), dashIfEmpty(c.NewSHA1))
+	}
+	return tw.Flush()
+}
+
+// dashIfEmpty returns s, or "-" if s is empty.
+func dashIfEmpty(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// diffVersions fetches the manifest of the old version, and prints the differences
+// from it to the new version manifest to w, either as a table or as JSON.
+func diffVersions(ctx context.Context, w io.Writer, client modpacksch.ModpackClient, modpackID, oldVersionID int64, newManifest *modpacksch.ModpackVersionManifest, asJSON bool) error {
+	oldManifest, err := client.GetModpackVersionManifest(ctx, modpackID, oldVersionID)
+	if err != nil {
+		return fmt.Errorf("failed to get manifest of version %d: %w", oldVersionID, err)
+	}
+	d := newVersionDiff(&oldManifest, newManifest)
+	return d.print(w, asJSON)
+}
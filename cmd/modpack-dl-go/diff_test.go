@@ -0,0 +1,96 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestNewVersionDiff(t *testing.T) {
+	oldManifest := testVersionManifest(t, `{"id": 1, "files": [
+		{"path": "./mods/", "name": "kept.jar", "sha1": "11"},
+		{"path": "./mods/", "name": "updated.jar", "sha1": "22"},
+		{"path": "./mods/", "name": "moved-side.jar", "sha1": "33", "clientonly": true},
+		{"path": "./mods/", "name": "removed.jar", "sha1": "44", "serveronly": true}
+	]}`)
+	newManifest := testVersionManifest(t, `{"id": 2, "files": [
+		{"path": "./mods/", "name": "added.jar", "sha1": "55"},
+		{"path": "./mods/", "name": "kept.jar", "sha1": "11"},
+		{"path": "./mods/", "name": "updated.jar", "sha1": "66"},
+		{"path": "./mods/", "name": "moved-side.jar", "sha1": "33", "serveronly": true}
+	]}`)
+
+	d := newVersionDiff(oldManifest, newManifest)
+	want := versionDiff{
+		From: 1,
+		To:   2,
+		Changes: []versionChange{
+			{Change: changeAdded, Path: "mods/added.jar", NewSide: sideBoth, NewSHA1: "55"},
+			{Change: changeUpdated, Path: "mods/updated.jar", OldSide: sideBoth, NewSide: sideBoth, OldSHA1: "22", NewSHA1: "66"},
+			{Change: changeUpdated, Path: "mods/moved-side.jar", OldSide: sideClient, NewSide: sideServer, OldSHA1: "33", NewSHA1: "33"},
+			{Change: changeRemoved, Path: "mods/removed.jar", OldSide: sideServer, OldSHA1: "44"},
+		},
+	}
+	if !reflect.DeepEqual(d, want) {
+		t.Errorf("newVersionDiff() = %+v\nwant %+v", d, want)
+	}
+}
+
+func TestNewVersionDiffMergesVariants(t *testing.T) {
+	// Separate client and server variants at the same path are one file on both sides.
+	oldManifest := testVersionManifest(t, `{"id": 1, "files": [
+		{"path": "./mods/", "name": "a.jar", "sha1": "bb", "serveronly": true},
+		{"path": "./mods/", "name": "a.jar", "sha1": "aa", "clientonly": true}
+	]}`)
+	newManifest := testVersionManifest(t, `{"id": 2, "files": [
+		{"path": "./mods/", "name": "a.jar", "sha1": "aa", "clientonly": true},
+		{"path": "./mods/", "name": "a.jar", "sha1": "bb", "serveronly": true}
+	]}`)
+	if d := newVersionDiff(oldManifest, newManifest); len(d.Changes) != 0 {
+		t.Errorf("newVersionDiff() = %+v, want no changes", d.Changes)
+	}
+}
+
+func TestVersionDiffPrint(t *testing.T) {
+	d := versionDiff{
+		From: 1,
+		To:   2,
+		Changes: []versionChange{
+			{Change: changeAdded, Path: "mods/added.jar", NewSide: sideBoth, NewSHA1: "55"},
+			{Change: changeUpdated, Path: "mods/moved.jar", OldSide: sideClient, NewSide: sideServer, OldSHA1: "33", NewSHA1: "33"},
+			{Change: changeRemoved, Path: "mods/removed.jar", OldSide: sideServer, OldSHA1: "44"},
+		},
+	}
+
+	var buf bytes.Buffer
+	if err := d.print(&buf, false); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimRight(buf.String(), "\n"), "\n")
+	if len(lines) != 4 {
+		t.Fatalf("got %d lines, want header and 3 rows:\n%s", len(lines), buf.String())
+	}
+	for i, want := range [][]string{
+		{"added", "mods/added.jar", "both", "-", "55"},
+		{"updated", "mods/moved.jar", "client", "->", "server", "33", "33"},
+		{"removed", "mods/removed.jar", "server", "44", "-"},
+	} {
+		if got := strings.Fields(lines[i+1]); !reflect.DeepEqual(got, want) {
+			t.Errorf("row %d = %q, want fields %q", i, lines[i+1], want)
+		}
+	}
+
+	buf.Reset()
+	if err := d.print(&buf, true); err != nil {
+		t.Fatal(err)
+	}
+	var got versionDiff
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("output is not valid JSON: %v\n%s", err, buf.String())
+	}
+	if !reflect.DeepEqual(got, d) {
+		t.Errorf("JSON round trip = %+v, want %+v", got, d)
+	}
+}
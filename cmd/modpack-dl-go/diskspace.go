@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"path/filepath"
+
+	"github.com/database64128/modpack-dl-go/precheck"
+	"github.com/lmittmann/tint"
+)
+
+// requiredSpace estimates the number of additional bytes needed under each root
+// to put the jobs' files in place. A file already at its destination path takes up
+// the space of its existing content, so only the growth is counted.
+func requiredSpace(pjs []precheck.Job, roots []string) map[string]int64 {
+	required := make(map[string]int64, len(roots))
+	for i := range pjs {
+		pj := &pjs[i]
+		for _, path := range [...]string{pj.DestinationPath, pj.SecondaryDestinationPath} {
+			if path == "" {
+				continue
+			}
+			root, ok := containingRoot(path, roots)
+			if !ok {
+				continue
+			}
+			need := pj.Size
+			if fi, err := os.Stat(path); err == nil {
+				need -= fi.Size()
+			}
+			if need > 0 {
+				required[root] += need
+			}
+		}
+	}
+	return required
+}
+
+// containingRoot returns the innermost of roots that contains path.
+func containingRoot(path string, roots []string) (string, bool) {
+	var (
+		best  string
+		found bool
+	)
+	for _, root := range roots {
+		if root == "" {
+			continue
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil || !filepath.IsLocal(rel) {
+			continue
+		}
+		if !found || len(root) > len(best) {
+			best = root
+			found = true
+		}
+	}
+	return best, found
+}
+
+// existingAncestor returns the closest ancestor of path, including path itself, that exists.
+func existingAncestor(path string) string {
+	for {
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+		parent := filepath.Dir(path)
+		if parent == path {
+			return path
+		}
+		path = parent
+	}
+}
+
+// checkDiskSpace checks that there's enough free space under each root for the jobs' files,
+// as reported by freeSpace, which is [freeSpace] outside of tests.
+// It logs and returns false if any root is short on space. Roots whose free space cannot be
+// determined are skipped with a warning.
+func checkDiskSpace(ctx context.Context, logger *slog.Logger, pjs []precheck.Job, roots []string, freeSpace func(path string) (uint64, error)) bool {
+	ok := true
+	for root, need := range requiredSpace(pjs, roots) {
+		free, err := freeSpace(existingAncestor(root))
+		if err != nil {
+			logger.LogAttrs(ctx, slog.LevelWarn, "Failed to get free disk space",
+				slog.String("path", root),
+				tint.Err(err),
+			)
+			continue
+		}
+		if uint64(need) > free {
+			logger.LogAttrs(ctx, slog.LevelError, "Not enough free disk space, use '-ignoreDiskSpace' to proceed anyway",
+				slog.String("path", root),
+				slog.Int64("required", need),
+				slog.Uint64("free", free),
+			)
+			ok = false
+		}
+	}
+	return ok
+}
@@ -0,0 +1,10 @@
+//go:build !darwin && !freebsd && !linux && !windows
+
+package main
+
+import "errors"
+
+// freeSpace is not implemented on this platform.
+func freeSpace(path string) (uint64, error) {
+	return 0, errors.ErrUnsupported
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/database64128/modpack-dl-go/precheck"
+)
+
+// fixedFreeSpace returns a free space function that reports free bytes for every path,
+// and records the paths asked about.
+func fixedFreeSpace(free uint64, err error, asked *[]string) func(string) (uint64, error) {
+	return func(path string) (uint64, error) {
+		*asked = append(*asked, path)
+		return free, err
+	}
+}
+
+func TestCheckDiskSpace(t *testing.T) {
+	root := t.TempDir()
+	client := filepath.Join(root, "client")
+	existing := filepath.Join(client, "config", "existing.cfg")
+	if err := os.MkdirAll(filepath.Dir(existing), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(existing, make([]byte, 400), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	pjs := []precheck.Job{
+		{DestinationPath: filepath.Join(client, "mods", "a.jar"), Size: 1000},
+		// Only the growth of existing files is counted.
+		{DestinationPath: existing, Size: 500},
+	}
+	if got := requiredSpace(pjs, []string{client})[client]; got != 1100 {
+		t.Fatalf("requiredSpace() = %d, want 1100", got)
+	}
+
+	for _, c := range []struct {
+		name   string
+		free   uint64
+		err    error
+		wantOK bool
+	}{
+		{"Enough", 1100, nil, true},
+		{"NotEnough", 1099, nil, false},
+		// Roots whose free space is unknown are not held against the run.
+		{"Unknown", 0, errors.New("statfs failed"), true},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			var asked []string
+			if ok := checkDiskSpace(context.Background(), testLogger, pjs, []string{client}, fixedFreeSpace(c.free, c.err, &asked)); ok != c.wantOK {
+				t.Errorf("checkDiskSpace() = %v, want %v", ok, c.wantOK)
+			}
+			if len(asked) != 1 || asked[0] != client {
+				t.Errorf("free space asked for %q, want [%q]", asked, client)
+			}
+		})
+	}
+}
+
+func TestCheckDiskSpaceAsksExistingAncestor(t *testing.T) {
+	root := t.TempDir()
+	client := filepath.Join(root, "not", "created", "yet")
+	pjs := []precheck.Job{{DestinationPath: filepath.Join(client, "a.jar"), Size: 1}}
+
+	var asked []string
+	if !checkDiskSpace(context.Background(), testLogger, pjs, []string{client}, fixedFreeSpace(1, nil, &asked)) {
+		t.Error("checkDiskSpace() = false, want true")
+	}
+	if len(asked) != 1 || asked[0] != root {
+		t.Errorf("free space asked for %q, want [%q]", asked, root)
+	}
+}
+
+func TestContainingRoot(t *testing.T) {
+	roots := []string{"", filepath.Join("a"), filepath.Join("a", "b")}
+	for _, c := range []struct {
+		path, want string
+		ok         bool
+	}{
+		{filepath.Join("a", "b", "c.jar"), filepath.Join("a", "b"), true},
+		{filepath.Join("a", "c.jar"), "a", true},
+		{filepath.Join("other", "c.jar"), "", false},
+	} {
+		got, ok := containingRoot(c.path, roots)
+		if got != c.want || ok != c.ok {
+			t.Errorf("containingRoot(%q) = %q, %v, want %q, %v", c.path, got, ok, c.want, c.ok)
+		}
+	}
+}
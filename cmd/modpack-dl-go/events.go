@@ -0,0 +1,34 @@
+package main
+
+import "github.com/database64128/modpack-dl-go/download"
+
+// eventHandlers is a [download.EventHandler] that notifies each of its handlers in order.
+type eventHandlers []download.EventHandler
+
+// OnDownloadStart implements [download.EventHandler.OnDownloadStart].
+func (hs eventHandlers) OnDownloadStart(e download.Event) {
+	for _, h := range hs {
+		h.OnDownloadStart(e)
+	}
+}
+
+// OnDownloadComplete implements [download.EventHandler.OnDownloadComplete].
+func (hs eventHandlers) OnDownloadComplete(e download.Event) {
+	for _, h := range hs {
+		h.OnDownloadComplete(e)
+	}
+}
+
+// OnSkip implements [download.EventHandler.OnSkip].
+func (hs eventHandlers) OnSkip(e download.Event) {
+	for _, h := range hs {
+		h.OnSkip(e)
+	}
+}
+
+// OnError implements [download.EventHandler.OnError].
+func (hs eventHandlers) OnError(e download.Event) {
+	for _, h := range hs {
+		h.OnError(e)
+	}
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"crypto/sha1"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+
+	"github.com/database64128/modpack-dl-go/download"
+	"github.com/database64128/modpack-dl-go/precheck"
+)
+
+// recordedEvent is an event recorded by eventRecorder, with the name of the method called.
+type recordedEvent struct {
+	method string
+	download.Event
+}
+
+// eventRecorder is a [download.EventHandler] that records every event.
+type eventRecorder struct {
+	mu     sync.Mutex
+	events []recordedEvent
+}
+
+func (r *eventRecorder) record(method string, e download.Event) {
+	r.mu.Lock()
+	r.events = append(r.events, recordedEvent{method, e})
+	r.mu.Unlock()
+}
+
+func (r *eventRecorder) OnDownloadStart(e download.Event)    { r.record("OnDownloadStart", e) }
+func (r *eventRecorder) OnDownloadComplete(e download.Event) { r.record("OnDownloadComplete", e) }
+func (r *eventRecorder) OnSkip(e download.Event)             { r.record("OnSkip", e) }
+func (r *eventRecorder) OnError(e download.Event)            { r.record("OnError", e) }
+
+// byPath returns the names of the methods called for the file at path, in order.
+func (r *eventRecorder) byPath(path string) (methods []string, events []download.Event) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, e := range r.events {
+		if e.Path == path {
+			methods = append(methods, e.method)
+			events = append(events, e.Event)
+		}
+	}
+	return methods, events
+}
+
+func TestEventHandlerReceivesFileMetadata(t *testing.T) {
+	content := []byte("file content\n")
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing.jar" {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write(content)
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	sum := sha1.Sum(content)
+	newJob := func(name string) precheck.Job {
+		return precheck.Job{
+			DownloadURL:     srv.URL + "/" + name,
+			DestinationPath: filepath.Join(dir, name),
+			NewHash:         sha1.New,
+			Sum:             sum[:],
+			Size:            int64(len(content)),
+		}
+	}
+	skipped, downloaded, missing := newJob("skipped.jar"), newJob("downloaded.jar"), newJob("missing.jar")
+	// A distinct sum, so that the download fleet does not reuse the downloaded content.
+	missingSum := sha1.Sum([]byte("missing content\n"))
+	missing.Sum = missingSum[:]
+	if err := os.WriteFile(skipped.DestinationPath, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+	blocked := newJob("blocked.jar")
+	blocked.DestinationPath = filepath.Join(skipped.DestinationPath, "blocked.jar")
+
+	var r eventRecorder
+	runFleets(context.Background(), []precheck.Job{skipped, downloaded, missing, blocked},
+		[]precheck.Option{precheck.WithEventHandler(&r)},
+		[]download.Option{download.WithEventHandler(&r)},
+	)
+
+	for _, c := range []struct {
+		job         precheck.Job
+		wantMethods []string
+	}{
+		{skipped, []string{"OnSkip"}},
+		{downloaded, []string{"OnDownloadStart", "OnDownloadComplete"}},
+		{missing, []string{"OnDownloadStart", "OnError"}},
+		{blocked, []string{"OnError"}},
+	} {
+		methods, events := r.byPath(c.job.DestinationPath)
+		if !slices.Equal(methods, c.wantMethods) {
+			t.Errorf("events of %s = %q, want %q", filepath.Base(c.job.DestinationPath), methods, c.wantMethods)
+			continue
+		}
+		for i, e := range events {
+			if e.URL != c.job.DownloadURL || e.Size != c.job.Size {
+				t.Errorf("%s event of %s has URL %q, size %d, want %q, %d", methods[i], filepath.Base(c.job.DestinationPath), e.URL, e.Size, c.job.DownloadURL, c.job.Size)
+			}
+		}
+		last := events[len(events)-1]
+		switch methods[len(methods)-1] {
+		case "OnSkip":
+			if last.Reason != "skipped" {
+				t.Errorf("OnSkip reason = %q, want %q", last.Reason, "skipped")
+			}
+		case "OnDownloadComplete":
+			if last.Bytes != int64(len(content)) {
+				t.Errorf("OnDownloadComplete bytes = %d, want %d", last.Bytes, len(content))
+			}
+		case "OnError":
+			if !errors.Is(last.Err, download.ErrDownloadFailed) && !errors.Is(last.Err, precheck.ErrPrecheckFailed) {
+				t.Errorf("OnError err = %v, want %v or %v", last.Err, download.ErrDownloadFailed, precheck.ErrPrecheckFailed)
+			}
+		}
+	}
+}
+
+func TestEventHandlersNotifiesAll(t *testing.T) {
+	var a, b eventRecorder
+	hs := eventHandlers{&a, &b}
+	e := download.Event{Path: "a.jar", URL: "https://example.com/a.jar", Size: 1}
+	hs.OnDownloadStart(e)
+	hs.OnDownloadComplete(e)
+	hs.OnSkip(e)
+	hs.OnError(e)
+
+	want := []string{"OnDownloadStart", "OnDownloadComplete", "OnSkip", "OnError"}
+	for _, r := range []*eventRecorder{&a, &b} {
+		if methods, _ := r.byPath("a.jar"); !slices.Equal(methods, want) {
+			t.Errorf("events = %q, want %q", methods, want)
+		}
+	}
+}
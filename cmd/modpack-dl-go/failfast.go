@@ -0,0 +1,31 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/database64128/modpack-dl-go/download"
+)
+
+var errFailFast = errors.New("aborted on first failure")
+
+// failFastHandler is a [download.EventHandler] that cancels the run on the first failed file.
+type failFastHandler struct {
+	cancel context.CancelCauseFunc
+}
+
+// OnDownloadStart implements [download.EventHandler.OnDownloadStart].
+func (failFastHandler) OnDownloadStart(download.Event) {}
+
+// OnDownloadComplete implements [download.EventHandler.OnDownloadComplete].
+func (failFastHandler) OnDownloadComplete(download.Event) {}
+
+// OnSkip implements [download.EventHandler.OnSkip].
+func (failFastHandler) OnSkip(download.Event) {}
+
+// OnError implements [download.EventHandler.OnError].
+// Only the first failure is recorded as the cause of the cancellation.
+func (h failFastHandler) OnError(e download.Event) {
+	h.cancel(fmt.Errorf("%w: %s: %w", errFailFast, e.Path, e.Err))
+}
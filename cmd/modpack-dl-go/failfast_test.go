@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"crypto/sha1"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"github.com/database64128/modpack-dl-go/download"
+	"github.com/database64128/modpack-dl-go/precheck"
+)
+
+// failFastJobs returns precheck jobs for files downloaded from srv into dir.
+// The first job is for /broken.jar, and the others for /<i>.jar.
+func failFastJobs(srvURL, dir string, n int) []precheck.Job {
+	pjs := make([]precheck.Job, n)
+	for i := range pjs {
+		content := fmt.Appendf(nil, "content of file %d\n", i)
+		sum := sha1.Sum(content)
+		name := fmt.Sprintf("%d.jar", i)
+		if i == 0 {
+			name = "broken.jar"
+		}
+		pjs[i] = precheck.Job{
+			DownloadURL:     srvURL + "/" + name,
+			DestinationPath: filepath.Join(dir, name),
+			NewHash:         sha1.New,
+			Sum:             sum[:],
+			Size:            int64(len(content)),
+		}
+	}
+	return pjs
+}
+
+func TestFailFastStopsOnDownloadFailure(t *testing.T) {
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/broken.jar" {
+			http.NotFound(w, r)
+			return
+		}
+		requests.Add(1)
+		_, _ = w.Write([]byte("whatever"))
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancelCause(context.Background())
+	defer cancel(nil)
+	h := failFastHandler{cancel}
+
+	pjs := failFastJobs(srv.URL, t.TempDir(), 10)
+	pjch := make(chan precheck.Job)
+	pwf := precheck.NewWorkerFleet(ctx, testLogger, 1, pjch, precheck.WithEventHandler(h))
+	dwf := download.NewWorkerFleet(ctx, testLogger, http.DefaultClient, 1, pwf.DownloadJobChannel(), download.WithEventHandler(h))
+	for _, pj := range pjs {
+		pjch <- pj
+	}
+	close(pjch)
+	pwf.Wait()
+	dwf.Wait()
+
+	if !errors.Is(context.Cause(ctx), errFailFast) {
+		t.Errorf("context.Cause() = %v, want %v", context.Cause(ctx), errFailFast)
+	}
+	if got := requests.Load(); got != 0 {
+		t.Errorf("%d downloads were started after the first failure", got)
+	}
+}
+
+func TestFailFastStopsOnPrecheckFailure(t *testing.T) {
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		http.NotFound(w, r)
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	// A file where the first job's parent directory should be fails its precheck.
+	blocker := filepath.Join(dir, "blocked")
+	if err := os.WriteFile(blocker, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	pjs := failFastJobs(srv.URL, dir, 10)
+	pjs[0].DestinationPath = filepath.Join(blocker, "broken.jar")
+
+	ctx, cancel := context.WithCancelCause(context.Background())
+	defer cancel(nil)
+	h := failFastHandler{cancel}
+	runFleets(ctx, pjs, []precheck.Option{precheck.WithEventHandler(h)}, []download.Option{download.WithEventHandler(h)})
+
+	if !errors.Is(context.Cause(ctx), errFailFast) {
+		t.Errorf("context.Cause() = %v, want %v", context.Cause(ctx), errFailFast)
+	}
+	// runFleets runs two workers for each fleet, so at most one other job may have been picked up.
+	if got := requests.Load(); got > 1 {
+		t.Errorf("%d downloads were started after the first failure", got)
+	}
+}
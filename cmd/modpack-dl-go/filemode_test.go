@@ -0,0 +1,41 @@
+package main
+
+import (
+	"os"
+	"testing"
+)
+
+func TestFileModeFlag(t *testing.T) {
+	for _, c := range []struct {
+		value   string
+		want    os.FileMode
+		wantStr string
+	}{
+		{"0644", 0644, "0644"},
+		{"775", 0775, "0775"},
+		{"0600", 0600, "0600"},
+		{"0777", 0777, "0777"},
+	} {
+		var m fileMode
+		if err := m.Set(c.value); err != nil {
+			t.Errorf("Set(%q) error = %v", c.value, err)
+			continue
+		}
+		if os.FileMode(m) != c.want {
+			t.Errorf("Set(%q) = %#o, want %#o", c.value, uint32(m), uint32(c.want))
+		}
+		if got := m.String(); got != c.wantStr {
+			t.Errorf("String() = %q, want %q", got, c.wantStr)
+		}
+	}
+
+	for _, bad := range []string{"", "0", "0888", "rw-r--r--", "01777", "-644"} {
+		m := fileMode(0644)
+		if err := m.Set(bad); err == nil {
+			t.Errorf("Set(%q) error = nil, want error", bad)
+		}
+		if m != 0644 {
+			t.Errorf("Set(%q) changed the mode to %#o", bad, uint32(m))
+		}
+	}
+}
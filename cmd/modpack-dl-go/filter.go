@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/database64128/modpack-dl-go/modpacksch"
+)
+
+// pathFilter selects files by their relative paths in the manifest.
+type pathFilter struct {
+	only    []string
+	exclude []string
+}
+
+// newPathFilter returns a filter that selects files matching any of the only patterns,
+// or all files if there are none, unless they match any of the exclude patterns.
+//
+// Patterns use [path.Match] syntax, extended with "**" path elements
+// that match zero or more path elements.
+func newPathFilter(only, exclude []string) (*pathFilter, error) {
+	for _, patterns := range [...][]string{only, exclude} {
+		for _, pattern := range patterns {
+			if err := validateGlob(pattern); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return &pathFilter{only: only, exclude: exclude}, nil
+}
+
+// match returns whether the file at the given slash-separated relative path is selected.
+func (pf *pathFilter) match(name string) bool {
+	for _, pattern := range pf.exclude {
+		if matchGlob(pattern, name) {
+			return false
+		}
+	}
+	if len(pf.only) == 0 {
+		return true
+	}
+	for _, pattern := range pf.only {
+		if matchGlob(pattern, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// pathMapper returns a [modpacksch.PathMapper] that excludes files not selected by the filter,
+// and puts the others at their paths in the manifest.
+func (pf *pathFilter) pathMapper() modpacksch.PathMapper {
+	return func(file *modpacksch.ModpackVersionFile) (string, bool) {
+		relPath, ok := modpacksch.DefaultPathMapper(file)
+		if !ok || !pf.match(filepath.ToSlash(relPath)) {
+			return "", false
+		}
+		return relPath, true
+	}
+}
+
+// validateGlob returns an error if the pattern is malformed.
+func validateGlob(pattern string) error {
+	for _, elem := range strings.Split(pattern, "/") {
+		if _, err := path.Match(elem, ""); err != nil {
+			return fmt.Errorf("invalid pattern %q: %w", pattern, err)
+		}
+	}
+	return nil
+}
+
+// matchGlob reports whether the slash-separated name matches the pattern.
+// The pattern is validated by validateGlob.
+func matchGlob(pattern, name string) bool {
+	return matchGlobElems(strings.Split(pattern, "/"), strings.Split(name, "/"))
+}
+
+// matchGlobElems reports whether the name elements match the pattern elements.
+func matchGlobElems(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			pattern = pattern[1:]
+			if len(pattern) == 0 {
+				return true
+			}
+			for i := range name {
+				if matchGlobElems(pattern, name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+
+		if len(name) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], name[0]); !ok {
+			return false
+		}
+		pattern = pattern[1:]
+		name = name[1:]
+	}
+	return len(name) == 0
+}
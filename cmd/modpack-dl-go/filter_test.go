@@ -0,0 +1,79 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/database64128/modpack-dl-go/modpacksch"
+)
+
+func TestPathFilter(t *testing.T) {
+	names := []string{
+		"config/a.toml",
+		"config/sub/b.toml",
+		"mods/optifine-1.2.jar",
+		"mods/jei.jar",
+		"options.txt",
+	}
+	for _, c := range []struct {
+		name    string
+		only    []string
+		exclude []string
+		want    []string
+	}{
+		{"All", nil, nil, names},
+		{"Only", []string{"config/**"}, nil, []string{"config/a.toml", "config/sub/b.toml"}},
+		{"OnlyMultiple", []string{"config/*", "*.txt"}, nil, []string{"config/a.toml", "options.txt"}},
+		{"Exclude", nil, []string{"mods/optifine*"}, []string{"config/a.toml", "config/sub/b.toml", "mods/jei.jar", "options.txt"}},
+		{"ExcludeWinsOverOnly", []string{"mods/**", "**/*.toml"}, []string{"mods/optifine*", "config/sub/**"}, []string{"config/a.toml", "mods/jei.jar"}},
+		{"DoubleStarMatchesNoElements", []string{"**/options.txt"}, nil, []string{"options.txt"}},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			pf, err := newPathFilter(c.only, c.exclude)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := make(map[string]bool)
+			for _, name := range c.want {
+				want[name] = true
+			}
+			for _, name := range names {
+				if got := pf.match(name); got != want[name] {
+					t.Errorf("match(%q) = %v, want %v", name, got, want[name])
+				}
+			}
+		})
+	}
+}
+
+func TestNewPathFilterRejectsInvalidPattern(t *testing.T) {
+	if _, err := newPathFilter([]string{"config/[a"}, nil); err == nil {
+		t.Error("newPathFilter() with a malformed only pattern succeeded")
+	}
+	if _, err := newPathFilter(nil, []string{"mods/\\"}); err == nil {
+		t.Error("newPathFilter() with a malformed exclude pattern succeeded")
+	}
+}
+
+func TestPathFilterPathMapper(t *testing.T) {
+	pf, err := newPathFilter([]string{"mods/**"}, []string{"mods/optifine*"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mapPath := pf.pathMapper()
+	for _, c := range []struct {
+		path, name string
+		want       string
+		wantOK     bool
+	}{
+		{"./mods/", "jei.jar", filepath.Join("mods", "jei.jar"), true},
+		{"./mods/", "optifine-1.2.jar", "", false},
+		{"./config/", "a.toml", "", false},
+	} {
+		f := modpacksch.ModpackVersionFile{Path: c.path, ResourceBase: modpacksch.ResourceBase{Name: c.name}}
+		got, ok := mapPath(&f)
+		if got != c.want || ok != c.wantOK {
+			t.Errorf("mapPath(%s%s) = %q, %v, want %q, %v", c.path, c.name, got, ok, c.want, c.wantOK)
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+)
+
+// httpClientConfig configures the HTTP client for API requests and downloads.
+type httpClientConfig struct {
+	// ProxyURL is the URL of the proxy to send all requests through.
+	// If empty, the proxy is configured from the environment.
+	ProxyURL string
+
+	// CACertPath is the path to a PEM bundle of CA certificates
+	// to trust in addition to the system roots.
+	CACertPath string
+
+	// InsecureSkipVerify disables TLS certificate verification.
+	InsecureSkipVerify bool
+}
+
+// newHTTPClient returns the HTTP client for API requests and downloads.
+//
+// If the config is the zero value, [http.DefaultClient] is returned, which uses the proxy
+// configured by the HTTP_PROXY, HTTPS_PROXY, and NO_PROXY environment variables.
+// Otherwise, a client with its own transport is returned, leaving [http.DefaultTransport]
+// and clients that use it unaffected.
+func newHTTPClient(cfg httpClientConfig) (*http.Client, error) {
+	if cfg == (httpClientConfig{}) {
+		return http.DefaultClient, nil
+	}
+
+	t, err := newTransport(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &http.Client{Transport: t}, nil
+}
+
+// downloadPoolConfig tunes the connection pool of the HTTP client for downloads.
+type downloadPoolConfig struct {
+	// MaxIdleConnsPerHost is the maximum number of idle connections kept for reuse per host.
+	MaxIdleConnsPerHost int
+
+	// MaxConnsPerHost, if positive, is the maximum number of connections per host,
+	// including those in use.
+	MaxConnsPerHost int
+
+	// ResponseHeaderTimeout, if positive, is how long to wait for the response headers
+	// after sending a request.
+	ResponseHeaderTimeout time.Duration
+}
+
+// newDownloadClient returns the HTTP client for downloads, with its own transport
+// configured by cfg and tuned by pool, so that concurrent downloads from the same hosts
+// reuse connections instead of opening new ones.
+func newDownloadClient(cfg httpClientConfig, pool downloadPoolConfig) (*http.Client, error) {
+	t, err := newTransport(cfg)
+	if err != nil {
+		return nil, err
+	}
+	t.MaxIdleConnsPerHost = pool.MaxIdleConnsPerHost
+	t.MaxIdleConns = max(t.MaxIdleConns, pool.MaxIdleConnsPerHost)
+	t.MaxConnsPerHost = pool.MaxConnsPerHost
+	t.ResponseHeaderTimeout = pool.ResponseHeaderTimeout
+	return &http.Client{Transport: t}, nil
+}
+
+// newTransport returns a clone of [http.DefaultTransport] configured by cfg.
+func newTransport(cfg httpClientConfig) (*http.Transport, error) {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+
+	if cfg.ProxyURL != "" {
+		u, err := url.Parse(cfg.ProxyURL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy URL: %w", err)
+		}
+		switch u.Scheme {
+		case "http", "https", "socks5", "socks5h":
+		default:
+			return nil, fmt.Errorf("unsupported proxy URL scheme %q, expected 'http', 'https', 'socks5', or 'socks5h'", u.Scheme)
+		}
+		if u.Host == "" {
+			return nil, fmt.Errorf("invalid proxy URL %q: missing host", cfg.ProxyURL)
+		}
+		t.Proxy = http.ProxyURL(u)
+	}
+
+	if cfg.CACertPath != "" || cfg.InsecureSkipVerify {
+		tlsConfig := &tls.Config{
+			InsecureSkipVerify: cfg.InsecureSkipVerify,
+		}
+
+		if cfg.CACertPath != "" {
+			pool, err := caCertPool(cfg.CACertPath)
+			if err != nil {
+				return nil, err
+			}
+			tlsConfig.RootCAs = pool
+		}
+
+		t.TLSClientConfig = tlsConfig
+	}
+
+	return t, nil
+}
+
+// caCertPool returns a copy of the system certificate pool
+// with the certificates in the PEM bundle at path appended.
+func caCertPool(path string) (*x509.CertPool, error) {
+	pem, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CA certificates: %w", err)
+	}
+
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, errNoCACerts
+	}
+	return pool, nil
+}
+
+var errNoCACerts = errors.New("no PEM-encoded CA certificates found")
@@ -0,0 +1,427 @@
+package main
+
+import (
+	"encoding/binary"
+	"encoding/pem"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httptrace"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newTargetServer returns a test server that responds with "ok".
+func newTargetServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, "ok")
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// newHTTPProxy returns a test server acting as a forward HTTP proxy,
+// which counts the requests it forwards.
+func newHTTPProxy(t *testing.T, requests *atomic.Int32) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		req := r.Clone(r.Context())
+		req.RequestURI = ""
+		resp, err := http.DefaultTransport.RoundTrip(req)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		defer resp.Body.Close()
+		w.WriteHeader(resp.StatusCode)
+		_, _ = io.Copy(w, resp.Body)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// newSOCKS5Proxy starts a minimal SOCKS5 proxy that supports no authentication and CONNECT,
+// and returns its address. The connections it proxies are counted.
+func newSOCKS5Proxy(t *testing.T, conns *atomic.Int32) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer c.Close()
+				_ = serveSOCKS5(c, conns)
+			}()
+		}
+	}()
+	return ln.Addr().String()
+}
+
+// serveSOCKS5 serves a single SOCKS5 CONNECT request on c,
+// and counts the connection once it's established.
+func serveSOCKS5(c net.Conn, conns *atomic.Int32) error {
+	// Greeting: version, number of methods, methods.
+	var hdr [2]byte
+	if _, err := io.ReadFull(c, hdr[:]); err != nil {
+		return err
+	}
+	if hdr[0] != 5 {
+		return errors.New("not SOCKS5")
+	}
+	if _, err := io.ReadFull(c, make([]byte, hdr[1])); err != nil {
+		return err
+	}
+	if _, err := c.Write([]byte{5, 0}); err != nil {
+		return err
+	}
+
+	// Request: version, command, reserved, address type, address, port.
+	var req [4]byte
+	if _, err := io.ReadFull(c, req[:]); err != nil {
+		return err
+	}
+	if req[1] != 1 {
+		return errors.New("not CONNECT")
+	}
+	var host string
+	switch req[3] {
+	case 1:
+		var ip [4]byte
+		if _, err := io.ReadFull(c, ip[:]); err != nil {
+			return err
+		}
+		host = net.IP(ip[:]).String()
+	case 3:
+		var n [1]byte
+		if _, err := io.ReadFull(c, n[:]); err != nil {
+			return err
+		}
+		name := make([]byte, n[0])
+		if _, err := io.ReadFull(c, name); err != nil {
+			return err
+		}
+		host = string(name)
+	default:
+		return errors.New("unsupported address type")
+	}
+	var port [2]byte
+	if _, err := io.ReadFull(c, port[:]); err != nil {
+		return err
+	}
+
+	upstream, err := net.Dial("tcp", net.JoinHostPort(host, strconv.Itoa(int(binary.BigEndian.Uint16(port[:])))))
+	if err != nil {
+		_, _ = c.Write([]byte{5, 5, 0, 1, 0, 0, 0, 0, 0, 0})
+		return err
+	}
+	defer upstream.Close()
+	if _, err = c.Write([]byte{5, 0, 0, 1, 0, 0, 0, 0, 0, 0}); err != nil {
+		return err
+	}
+	conns.Add(1)
+
+	go func() {
+		_, _ = io.Copy(upstream, c)
+		upstream.Close()
+	}()
+	_, err = io.Copy(c, upstream)
+	return err
+}
+
+// getOK gets url with client, and fails the test unless the response is "ok".
+func getOK(t *testing.T, client *http.Client, url string) {
+	t.Helper()
+	resp, err := client.Get(url)
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	defer resp.Body.Close()
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK || string(b) != "ok" {
+		t.Fatalf("Get() = %d %q, want 200 %q", resp.StatusCode, b, "ok")
+	}
+}
+
+func TestNewHTTPClientUsesHTTPProxy(t *testing.T) {
+	target := newTargetServer(t)
+	var requests atomic.Int32
+	proxy := newHTTPProxy(t, &requests)
+
+	for _, c := range []struct {
+		name      string
+		newClient func(httpClientConfig) (*http.Client, error)
+	}{
+		{"API", newHTTPClient},
+		{"Download", func(cfg httpClientConfig) (*http.Client, error) {
+			return newDownloadClient(cfg, downloadPoolConfig{MaxIdleConnsPerHost: 2})
+		}},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			requests.Store(0)
+			client, err := c.newClient(httpClientConfig{ProxyURL: proxy.URL})
+			if err != nil {
+				t.Fatal(err)
+			}
+			getOK(t, client, target.URL)
+			if got := requests.Load(); got != 1 {
+				t.Errorf("proxied requests = %d, want 1", got)
+			}
+		})
+	}
+}
+
+func TestNewHTTPClientUsesSOCKS5Proxy(t *testing.T) {
+	target := newTargetServer(t)
+	var conns atomic.Int32
+	addr := newSOCKS5Proxy(t, &conns)
+
+	client, err := newHTTPClient(httpClientConfig{ProxyURL: "socks5://" + addr})
+	if err != nil {
+		t.Fatal(err)
+	}
+	getOK(t, client, target.URL)
+	if got := conns.Load(); got != 1 {
+		t.Errorf("proxied connections = %d, want 1", got)
+	}
+}
+
+func TestNewHTTPClientDefault(t *testing.T) {
+	client, err := newHTTPClient(httpClientConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if client != http.DefaultClient {
+		t.Error("newHTTPClient() with the zero config did not return http.DefaultClient")
+	}
+}
+
+func TestNewHTTPClientRejectsInvalidProxyURL(t *testing.T) {
+	for _, proxyURL := range []string{
+		"ftp://proxy.example.com",
+		"http://",
+		"://missing-scheme",
+	} {
+		if _, err := newHTTPClient(httpClientConfig{ProxyURL: proxyURL}); err == nil {
+			t.Errorf("newHTTPClient() with proxy URL %q succeeded, want error", proxyURL)
+		}
+	}
+}
+
+// writeServerCert writes the certificate of the TLS test server to a PEM file, and returns its path.
+func writeServerCert(t *testing.T, srv *httptest.Server) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "ca.pem")
+	b := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+	if err := os.WriteFile(path, b, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestNewHTTPClientTrustsCACert(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, "ok")
+	}))
+	defer srv.Close()
+
+	client, err := newHTTPClient(httpClientConfig{CACertPath: writeServerCert(t, srv)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	getOK(t, client, srv.URL)
+
+	// The self-signed certificate is not trusted by default.
+	if resp, err := http.Get(srv.URL); err == nil {
+		resp.Body.Close()
+		t.Error("http.Get() succeeded without the CA certificate")
+	}
+	if c := http.DefaultTransport.(*http.Transport).TLSClientConfig; c != nil && (c.RootCAs != nil || c.InsecureSkipVerify) {
+		t.Error("http.DefaultTransport was modified")
+	}
+}
+
+func TestNewHTTPClientInsecureSkipVerify(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, "ok")
+	}))
+	defer srv.Close()
+
+	client, err := newDownloadClient(httpClientConfig{InsecureSkipVerify: true}, downloadPoolConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	getOK(t, client, srv.URL)
+}
+
+func TestNewHTTPClientRejectsInvalidCACert(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ca.pem")
+	if err := os.WriteFile(path, []byte("not a certificate\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := newHTTPClient(httpClientConfig{CACertPath: path}); !errors.Is(err, errNoCACerts) {
+		t.Errorf("newHTTPClient() error = %v, want %v", err, errNoCACerts)
+	}
+	if _, err := newHTTPClient(httpClientConfig{CACertPath: filepath.Join(t.TempDir(), "missing.pem")}); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("newHTTPClient() error = %v, want %v", err, os.ErrNotExist)
+	}
+}
+
+// connCountingTransport is an [http.RoundTripper] that counts the new and reused connections
+// that its requests are sent over.
+type connCountingTransport struct {
+	http.RoundTripper
+	newConns    atomic.Int32
+	reusedConns atomic.Int32
+}
+
+// RoundTrip implements [http.RoundTripper.RoundTrip].
+func (t *connCountingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	trace := &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			if info.Reused {
+				t.reusedConns.Add(1)
+			} else {
+				t.newConns.Add(1)
+			}
+		},
+	}
+	return t.RoundTripper.RoundTrip(req.WithContext(httptrace.WithClientTrace(req.Context(), trace)))
+}
+
+// newBarrierServer returns a test server that holds each request until the given number
+// of requests are in flight, so that each round of concurrent requests needs that many connections.
+func newBarrierServer(t *testing.T, concurrency int) *httptest.Server {
+	t.Helper()
+	var (
+		mu      sync.Mutex
+		waiting int
+		release = make(chan struct{})
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		ch := release
+		if waiting++; waiting == concurrency {
+			close(release)
+			release = make(chan struct{})
+			waiting = 0
+		}
+		mu.Unlock()
+
+		select {
+		case <-ch:
+		case <-time.After(5 * time.Second):
+		}
+		_, _ = io.WriteString(w, "ok")
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// countConns sends rounds of concurrent requests to url through client,
+// and returns the numbers of new and reused connections they were sent over.
+func countConns(t *testing.T, client *http.Client, url string, concurrency, rounds int) (newConns, reusedConns int32) {
+	t.Helper()
+	transport := &connCountingTransport{RoundTripper: client.Transport}
+	client = &http.Client{Transport: transport}
+	for range rounds {
+		var wg sync.WaitGroup
+		for range concurrency {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				resp, err := client.Get(url)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				// Drain the body so that the connection can be reused.
+				_, _ = io.Copy(io.Discard, resp.Body)
+				resp.Body.Close()
+			}()
+		}
+		wg.Wait()
+	}
+	if c, ok := transport.RoundTripper.(*http.Transport); ok {
+		c.CloseIdleConnections()
+	}
+	return transport.newConns.Load(), transport.reusedConns.Load()
+}
+
+func TestDownloadClientReusesConnections(t *testing.T) {
+	const (
+		concurrency = 8
+		rounds      = 4
+	)
+	srv := newBarrierServer(t, concurrency)
+
+	client, err := newDownloadClient(httpClientConfig{}, downloadPoolConfig{MaxIdleConnsPerHost: concurrency})
+	if err != nil {
+		t.Fatal(err)
+	}
+	newConns, reusedConns := countConns(t, client, srv.URL, concurrency, rounds)
+	if newConns != concurrency {
+		t.Errorf("new connections = %d, want %d", newConns, concurrency)
+	}
+	if want := int32(concurrency * (rounds - 1)); reusedConns != want {
+		t.Errorf("reused connections = %d, want %d", reusedConns, want)
+	}
+
+	// The default pool keeps only a couple of idle connections per host,
+	// so most connections are closed after each round, and opened again in the next.
+	defaultTransport, err := newTransport(httpClientConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defaultNewConns, _ := countConns(t, &http.Client{Transport: defaultTransport}, srv.URL, concurrency, rounds)
+	if want := int32(concurrency + (concurrency-http.DefaultMaxIdleConnsPerHost)*(rounds-1)); defaultNewConns < want {
+		t.Errorf("new connections with the default pool = %d, want at least %d", defaultNewConns, want)
+	}
+}
+
+func TestNewDownloadClientTunesPool(t *testing.T) {
+	client, err := newDownloadClient(httpClientConfig{}, downloadPoolConfig{
+		MaxIdleConnsPerHost:   200,
+		MaxConnsPerHost:       16,
+		ResponseHeaderTimeout: time.Minute,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr := client.Transport.(*http.Transport)
+	if tr.MaxIdleConnsPerHost != 200 {
+		t.Errorf("MaxIdleConnsPerHost = %d, want 200", tr.MaxIdleConnsPerHost)
+	}
+	// The overall idle pool grows to fit the per-host pool.
+	if tr.MaxIdleConns != 200 {
+		t.Errorf("MaxIdleConns = %d, want 200", tr.MaxIdleConns)
+	}
+	if tr.MaxConnsPerHost != 16 {
+		t.Errorf("MaxConnsPerHost = %d, want 16", tr.MaxConnsPerHost)
+	}
+	if tr.ResponseHeaderTimeout != time.Minute {
+		t.Errorf("ResponseHeaderTimeout = %v, want %v", tr.ResponseHeaderTimeout, time.Minute)
+	}
+	if tr == http.DefaultTransport {
+		t.Error("download client shares http.DefaultTransport")
+	}
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+)
+
+// Supported values of the -target flag.
+const (
+	installTargetClient = "client"
+	installTargetServer = "server"
+	installTargetBoth   = "both"
+)
+
+// installPaths returns the client and server paths for installing the given target to installDir.
+//
+// A single target is installed to installDir itself. When installing both,
+// the client and server are installed to the "client" and "server" subdirectories.
+func installPaths(installDir, target string) (clientPath, serverPath string, err error) {
+	switch target {
+	case installTargetClient:
+		return installDir, "", nil
+	case installTargetServer:
+		return "", installDir, nil
+	case installTargetBoth:
+		return filepath.Join(installDir, installTargetClient), filepath.Join(installDir, installTargetServer), nil
+	default:
+		return "", "", fmt.Errorf("unknown install target %q, expected '%s', '%s', or '%s'", target, installTargetClient, installTargetServer, installTargetBoth)
+	}
+}
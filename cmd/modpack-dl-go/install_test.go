@@ -0,0 +1,31 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestInstallPaths(t *testing.T) {
+	installDir := filepath.Join("games", "pack")
+	for _, c := range []struct {
+		target                 string
+		clientPath, serverPath string
+	}{
+		{installTargetClient, installDir, ""},
+		{installTargetServer, "", installDir},
+		{installTargetBoth, filepath.Join(installDir, "client"), filepath.Join(installDir, "server")},
+	} {
+		clientPath, serverPath, err := installPaths(installDir, c.target)
+		if err != nil {
+			t.Errorf("installPaths(%q) error = %v", c.target, err)
+			continue
+		}
+		if clientPath != c.clientPath || serverPath != c.serverPath {
+			t.Errorf("installPaths(%q) = %q, %q, want %q, %q", c.target, clientPath, serverPath, c.clientPath, c.serverPath)
+		}
+	}
+
+	if _, _, err := installPaths(installDir, "desktop"); err == nil {
+		t.Error("installPaths() with an unknown target succeeded")
+	}
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/database64128/modpack-dl-go/modpacksch"
+	"github.com/lmittmann/tint"
+)
+
+// printVersions prints the given versions to w, either as a table or as JSON.
+func printVersions(w io.Writer, versions []modpacksch.ModpackVersion, asJSON bool) error {
+	if asJSON {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "    ")
+		return enc.Encode(versions)
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tNAME\tTYPE\tUPDATED")
+	for _, v := range versions {
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\n", v.ID, v.Name, v.Type, v.Updated.Time.Format(time.DateTime))
+	}
+	return tw.Flush()
+}
+
+// searchResult is a modpack search result.
+type searchResult struct {
+	ID       int64  `json:"id"`
+	Name     string `json:"name"`
+	Synopsis string `json:"synopsis"`
+}
+
+// searchModpacks searches for modpacks matching the given term,
+// and prints the names and IDs of the matching modpacks to w,
+// either as a table or as JSON.
+func searchModpacks(ctx context.Context, w io.Writer, client modpacksch.ModpackClient, term string, limit int, asJSON bool) error {
+	ids, err := client.SearchModpacks(ctx, term, limit)
+	if err != nil {
+		return err
+	}
+
+	results := make([]searchResult, len(ids))
+	for i, id := range ids {
+		m, err := client.GetModpackManifest(ctx, id)
+		if err != nil {
+			return fmt.Errorf("failed to get manifest of modpack %d: %w", id, err)
+		}
+		results[i] = searchResult{
+			ID:       id,
+			Name:     m.Name,
+			Synopsis: m.Synopsis,
+		}
+	}
+
+	if asJSON {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "    ")
+		return enc.Encode(results)
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tNAME\tSYNOPSIS")
+	for _, r := range results {
+		fmt.Fprintf(tw, "%d\t%s\t%s\n", r.ID, r.Name, r.Synopsis)
+	}
+	return tw.Flush()
+}
+
+// manifestFile is a file in a dumped version manifest,
+// with its resolved download URL.
+type manifestFile struct {
+	modpacksch.ModpackVersionFile
+	DownloadURL string `json:"downloadURL,omitempty"`
+}
+
+// manifestDump is a dumped version manifest.
+type manifestDump struct {
+	*modpacksch.ModpackVersionManifest
+	Files []manifestFile `json:"files"`
+}
+
+// printVersionManifest prints the given version manifest to w as pretty-printed JSON,
+// including the download URL resolved for each file.
+func printVersionManifest(w io.Writer, manifest *modpacksch.ModpackVersionManifest) error {
+	files := make([]manifestFile, len(manifest.Files))
+	for i := range manifest.Files {
+		f := &manifest.Files[i]
+		files[i].ModpackVersionFile = *f
+		// Files without a resolvable URL are dumped as is.
+		files[i].DownloadURL, _ = f.DownloadURL()
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "    ")
+	return enc.Encode(manifestDump{
+		ModpackVersionManifest: manifest,
+		Files:                  files,
+	})
+}
+
+// runVersionCommand runs the command selected by '-listVersions', '-manifestOnly',
+// '-validateManifest', or '-diffVersion' on the modpack version.
+// It returns false if the command failed, after logging the error.
+func runVersionCommand(ctx context.Context, logger *slog.Logger, selected, other modpackProvider, fallback bool, modpackID, versionID int64) bool {
+	provider, modpackManifest, ok := fetchModpackManifest(ctx, logger, selected, other, fallback, modpackID)
+	if !ok {
+		return false
+	}
+	client := provider.client
+
+	if listVersions {
+		if showChangelog {
+			if err := printVersionChangelogs(ctx, os.Stdout, client, modpackID, modpackManifest.VersionsNewestFirst(), plainChangelog, jsonOutput); err != nil {
+				logger.LogAttrs(ctx, slog.LevelError, "Failed to print changelogs", tint.Err(err))
+				logAuthHint(ctx, logger, err)
+				return false
+			}
+			return true
+		}
+		if err := printVersions(os.Stdout, modpackManifest.VersionsNewestFirst(), jsonOutput); err != nil {
+			logger.LogAttrs(ctx, slog.LevelError, "Failed to print versions", tint.Err(err))
+			return false
+		}
+		return true
+	}
+
+	versionManifest, ok := fetchVersionManifest(ctx, logger, client, &modpackManifest, modpackID, versionID)
+	if !ok {
+		return false
+	}
+
+	switch {
+	case manifestOnly:
+		if err := writeVersionManifest(manifestOutput, &versionManifest); err != nil {
+			logger.LogAttrs(ctx, slog.LevelError, "Failed to write version manifest",
+				slog.String("path", manifestOutput),
+				tint.Err(err),
+			)
+			return false
+		}
+
+	case validateManifest:
+		problems := versionManifest.Validate()
+		if err := printManifestProblems(os.Stdout, problems, jsonOutput); err != nil {
+			logger.LogAttrs(ctx, slog.LevelError, "Failed to print manifest problems", tint.Err(err))
+			return false
+		}
+		if len(problems) > 0 {
+			logger.LogAttrs(ctx, slog.LevelError, "Version manifest is invalid",
+				slog.Int64("modpackID", versionManifest.Parent),
+				slog.Int64("versionID", versionManifest.ID),
+				slog.Int("problems", len(problems)),
+			)
+			return false
+		}
+		logger.LogAttrs(ctx, slog.LevelInfo, "Version manifest is valid",
+			slog.Int64("modpackID", versionManifest.Parent),
+			slog.Int64("versionID", versionManifest.ID),
+		)
+
+	case diffVersion != 0:
+		if err := diffVersions(ctx, os.Stdout, client, modpackID, diffVersion, &versionManifest, jsonOutput); err != nil {
+			logger.LogAttrs(ctx, slog.LevelError, "Failed to diff versions",
+				slog.Int64("modpackID", modpackID),
+				slog.Int64("fromVersionID", diffVersion),
+				slog.Int64("toVersionID", versionManifest.ID),
+				tint.Err(err),
+			)
+			logAuthHint(ctx, logger, err)
+			return false
+		}
+	}
+	return true
+}
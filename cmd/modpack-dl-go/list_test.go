@@ -0,0 +1,145 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/database64128/modpack-dl-go/modpacksch"
+)
+
+// testModpackManifest returns a canned manifest of a modpack from the given provider,
+// with versions 1 to 3 in the order the provider lists them.
+func testModpackManifest(t *testing.T, provider string) modpacksch.ModpackManifest {
+	t.Helper()
+	versions := `[
+		{"id": 1, "name": "1.0.0", "type": "release", "updated": 1600000000},
+		{"id": 2, "name": "1.1.0", "type": "beta", "updated": 1610000000},
+		{"id": 3, "name": "1.2.0", "type": "release", "updated": 1620000000}
+	]`
+	if provider == "curseforge" {
+		versions = `[
+			{"id": 3, "name": "1.2.0", "type": "release", "updated": 1620000000},
+			{"id": 2, "name": "1.1.0", "type": "beta", "updated": 1610000000},
+			{"id": 1, "name": "1.0.0", "type": "release", "updated": 1600000000}
+		]`
+	}
+	var m modpacksch.ModpackManifest
+	if err := json.Unmarshal([]byte(`{"id": 42, "name": "Example Pack", "provider": "`+provider+`", "versions": `+versions+`}`), &m); err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func TestPrintVersionsNewestFirst(t *testing.T) {
+	for _, provider := range []string{"", "curseforge"} {
+		t.Run("Provider="+provider, func(t *testing.T) {
+			m := testModpackManifest(t, provider)
+			var buf bytes.Buffer
+			if err := printVersions(&buf, m.VersionsNewestFirst(), false); err != nil {
+				t.Fatal(err)
+			}
+
+			lines := strings.Split(strings.TrimRight(buf.String(), "\n"), "\n")
+			if len(lines) != 4 {
+				t.Fatalf("got %d lines, want header and 3 rows:\n%s", len(lines), buf.String())
+			}
+			if fields := strings.Fields(lines[0]); !slices.Equal(fields, []string{"ID", "NAME", "TYPE", "UPDATED"}) {
+				t.Errorf("header = %q", lines[0])
+			}
+			for i, want := range []struct {
+				id, name, typ string
+				updated       int64
+			}{
+				{"3", "1.2.0", "release", 1620000000},
+				{"2", "1.1.0", "beta", 1610000000},
+				{"1", "1.0.0", "release", 1600000000},
+			} {
+				wantFields := append([]string{want.id, want.name, want.typ}, strings.Fields(time.Unix(want.updated, 0).Format(time.DateTime))...)
+				if fields := strings.Fields(lines[i+1]); !slices.Equal(fields, wantFields) {
+					t.Errorf("row %d = %q, want fields %q", i, lines[i+1], wantFields)
+				}
+			}
+		})
+	}
+}
+
+func TestPrintVersionsJSON(t *testing.T) {
+	m := testModpackManifest(t, "")
+	var buf bytes.Buffer
+	if err := printVersions(&buf, m.VersionsNewestFirst(), true); err != nil {
+		t.Fatal(err)
+	}
+
+	var versions []modpacksch.ModpackVersion
+	if err := json.Unmarshal(buf.Bytes(), &versions); err != nil {
+		t.Fatalf("output is not valid JSON: %v\n%s", err, buf.String())
+	}
+	var ids []int64
+	for _, v := range versions {
+		ids = append(ids, v.ID)
+	}
+	if want := []int64{3, 2, 1}; !slices.Equal(ids, want) {
+		t.Errorf("version IDs = %v, want %v", ids, want)
+	}
+}
+
+func TestPrintVersionManifest(t *testing.T) {
+	var manifest modpacksch.ModpackVersionManifest
+	if err := json.Unmarshal([]byte(`{
+		"id": 100,
+		"name": "1.0.0",
+		"files": [
+			{"path": "./mods/", "name": "direct.jar", "url": "https://example.com/direct.jar", "sha1": "00", "size": 1, "clientonly": true},
+			{"path": "./mods/", "name": "cf mod.jar", "sha1": "11", "size": 2, "serveronly": true, "curseforge": {"project": 1234, "file": 5678901}},
+			{"path": "./config/", "name": "nowhere.cfg", "sha1": "22", "size": 3}
+		]
+	}`), &manifest); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := printVersionManifest(&buf, &manifest); err != nil {
+		t.Fatal(err)
+	}
+
+	var got struct {
+		ID    int64  `json:"id"`
+		Name  string `json:"name"`
+		Files []struct {
+			modpacksch.ModpackVersionFile
+			DownloadURL string `json:"downloadURL"`
+		} `json:"files"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("output is not valid JSON: %v\n%s", err, buf.String())
+	}
+	if got.ID != 100 || got.Name != "1.0.0" {
+		t.Errorf("id, name = %d, %q, want 100, %q", got.ID, got.Name, "1.0.0")
+	}
+	if len(got.Files) != len(manifest.Files) {
+		t.Fatalf("got %d files, want %d", len(got.Files), len(manifest.Files))
+	}
+	for i, want := range []struct {
+		downloadURL            string
+		clientOnly, serverOnly bool
+	}{
+		{"https://example.com/direct.jar", true, false},
+		{"https://edge.forgecdn.net/files/1234/5678901/cf%20mod.jar", false, true},
+		{"", false, false},
+	} {
+		f := got.Files[i]
+		if f.DownloadURL != want.downloadURL {
+			t.Errorf("files[%d].downloadURL = %q, want %q", i, f.DownloadURL, want.downloadURL)
+		}
+		if f.ClientOnly != want.clientOnly || f.ServerOnly != want.serverOnly {
+			t.Errorf("files[%d] clientonly, serveronly = %v, %v, want %v, %v", i, f.ClientOnly, f.ServerOnly, want.clientOnly, want.serverOnly)
+		}
+		if f.Name != manifest.Files[i].Name || f.SHA1 != manifest.Files[i].SHA1 || f.Size != manifest.Files[i].Size {
+			t.Errorf("files[%d] = %+v, want %+v", i, f.ModpackVersionFile, manifest.Files[i])
+		}
+	}
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+
+	"github.com/database64128/modpack-dl-go/modpacksch"
+	"github.com/lmittmann/tint"
+)
+
+// lockFileName is the name of the lockfile written to the client path.
+const lockFileName = "modpack-dl.lock.json"
+
+// lockFile records the files of an installed modpack version.
+type lockFile struct {
+	ModpackID int64       `json:"modpackID"`
+	VersionID int64       `json:"versionID"`
+	Files     []lockEntry `json:"files"`
+}
+
+// lockEntry is a file in a lockfile.
+type lockEntry struct {
+	Path   string `json:"path"`
+	URL    string `json:"url"`
+	SHA1   string `json:"sha1"`
+	SHA256 string `json:"sha256,omitempty"`
+	Size   int64  `json:"size"`
+}
+
+// newLockFile returns the lockfile for the given version manifest.
+func newLockFile(modpackID int64, manifest *modpacksch.ModpackVersionManifest) lockFile {
+	lf := lockFile{
+		ModpackID: modpackID,
+		VersionID: manifest.ID,
+		Files:     make([]lockEntry, 0, len(manifest.Files)),
+	}
+	for i := range manifest.Files {
+		f := &manifest.Files[i]
+		url, _ := f.DownloadURL()
+		lf.Files = append(lf.Files, lockEntry{
+			Path:   f.ManifestPath(),
+			URL:    url,
+			SHA1:   f.SHA1,
+			SHA256: f.SHA256,
+			Size:   f.Size,
+		})
+	}
+	return lf
+}
+
+// readLockFile reads the lockfile in dir.
+func readLockFile(dir string) (lockFile, error) {
+	var lf lockFile
+	b, err := os.ReadFile(filepath.Join(dir, lockFileName))
+	if err != nil {
+		return lf, err
+	}
+	if err = json.Unmarshal(b, &lf); err != nil {
+		return lf, fmt.Errorf("failed to decode lockfile: %w", err)
+	}
+	return lf, nil
+}
+
+// write writes the lockfile to dir.
+func (lf *lockFile) write(dir string) error {
+	b, err := json.MarshalIndent(lf, "", "    ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, lockFileName), append(b, '\n'), 0644)
+}
+
+// Kinds of lockfile drift.
+const (
+	driftAdded   = "added"
+	driftRemoved = "removed"
+	driftChanged = "changed"
+)
+
+// lockDrift is a difference between a lockfile and a newer lockfile built from the live manifest.
+type lockDrift struct {
+	Kind string
+	Path string
+}
+
+// drift returns the differences between lf and the newer lockfile other,
+// with added and changed files in the order of other, followed by removed files.
+func (lf *lockFile) drift(other *lockFile) []lockDrift {
+	locked := make(map[string]*lockEntry, len(lf.Files))
+	for i := range lf.Files {
+		locked[lf.Files[i].Path] = &lf.Files[i]
+	}
+
+	var drifts []lockDrift
+	for i := range other.Files {
+		e := &other.Files[i]
+		le, ok := locked[e.Path]
+		switch {
+		case !ok:
+			drifts = append(drifts, lockDrift{driftAdded, e.Path})
+		case *le != *e:
+			drifts = append(drifts, lockDrift{driftChanged, e.Path})
+		}
+		delete(locked, e.Path)
+	}
+
+	for i := range lf.Files {
+		if p := lf.Files[i].Path; locked[p] != nil {
+			drifts = append(drifts, lockDrift{driftRemoved, p})
+		}
+	}
+	return drifts
+}
+
+// checkLockFiles returns false if the version manifest of any pack does not match
+// the lockfile in its lock directory, after logging the differences.
+func checkLockFiles(ctx context.Context, logger *slog.Logger, packs []*pack) bool {
+	for _, p := range packs {
+		lockDir := p.lockDir()
+		prevLock, err := readLockFile(lockDir)
+		if err != nil {
+			logger.LogAttrs(ctx, slog.LevelError, "Failed to read lockfile",
+				slog.String("path", lockDir),
+				tint.Err(err),
+			)
+			return false
+		}
+
+		drifts := prevLock.drift(&p.lock)
+		for _, d := range drifts {
+			logger.LogAttrs(ctx, slog.LevelWarn, "File drifted from lockfile",
+				slog.String("kind", d.Kind),
+				slog.String("path", d.Path),
+			)
+		}
+		if len(drifts) > 0 || prevLock.ModpackID != p.lock.ModpackID || prevLock.VersionID != p.lock.VersionID {
+			logger.LogAttrs(ctx, slog.LevelError, "Version manifest does not match lockfile",
+				slog.String("path", lockDir),
+				slog.Int64("lockedModpackID", prevLock.ModpackID),
+				slog.Int64("lockedVersionID", prevLock.VersionID),
+				slog.Int("driftedFiles", len(drifts)),
+			)
+			return false
+		}
+	}
+	return true
+}
+
+// writeLockFiles writes the lockfile of each pack to its lock directory.
+func writeLockFiles(ctx context.Context, logger *slog.Logger, packs []*pack) {
+	for _, p := range packs {
+		if err := p.lock.write(p.lockDir()); err != nil {
+			logger.LogAttrs(ctx, slog.LevelWarn, "Failed to write lockfile",
+				slog.String("path", p.lockDir()),
+				tint.Err(err),
+			)
+		}
+	}
+}
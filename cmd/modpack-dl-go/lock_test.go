@@ -0,0 +1,79 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/database64128/modpack-dl-go/modpacksch"
+)
+
+// testVersionManifest returns a version manifest unmarshaled from the given JSON.
+func testVersionManifest(t *testing.T, s string) *modpacksch.ModpackVersionManifest {
+	t.Helper()
+	var m modpacksch.ModpackVersionManifest
+	if err := json.Unmarshal([]byte(s), &m); err != nil {
+		t.Fatal(err)
+	}
+	return &m
+}
+
+func TestLockFileWriteAndRead(t *testing.T) {
+	m := testVersionManifest(t, `{"id": 100, "files": [
+		{"path": "./mods/", "name": "a.jar", "url": "https://example.com/a.jar", "sha1": "aa", "size": 1},
+		{"path": "./mods/", "name": "b.jar", "sha1": "bb", "sha256": "bbbb", "size": 2, "curseforge": {"project": 1, "file": 2}}
+	]}`)
+	lf := newLockFile(42, m)
+
+	dir := t.TempDir()
+	if err := lf.write(dir); err != nil {
+		t.Fatalf("write() error = %v", err)
+	}
+	got, err := readLockFile(dir)
+	if err != nil {
+		t.Fatalf("readLockFile() error = %v", err)
+	}
+
+	want := lockFile{
+		ModpackID: 42,
+		VersionID: 100,
+		Files: []lockEntry{
+			{Path: "mods/a.jar", URL: "https://example.com/a.jar", SHA1: "aa", Size: 1},
+			{Path: "mods/b.jar", URL: "https://edge.forgecdn.net/files/1/2/b.jar", SHA1: "bb", SHA256: "bbbb", Size: 2},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("readLockFile() = %+v, want %+v", got, want)
+	}
+}
+
+func TestReadLockFileMissing(t *testing.T) {
+	if _, err := readLockFile(t.TempDir()); err == nil {
+		t.Error("readLockFile() error = nil, want error for missing lockfile")
+	}
+}
+
+func TestLockFileDrift(t *testing.T) {
+	locked := newLockFile(42, testVersionManifest(t, `{"id": 100, "files": [
+		{"path": "./mods/", "name": "kept.jar", "url": "https://example.com/kept.jar", "sha1": "11", "size": 1},
+		{"path": "./mods/", "name": "changed.jar", "url": "https://example.com/changed.jar", "sha1": "22", "size": 2},
+		{"path": "./mods/", "name": "removed.jar", "url": "https://example.com/removed.jar", "sha1": "33", "size": 3}
+	]}`))
+	live := newLockFile(42, testVersionManifest(t, `{"id": 100, "files": [
+		{"path": "./mods/", "name": "added.jar", "url": "https://example.com/added.jar", "sha1": "44", "size": 4},
+		{"path": "./mods/", "name": "kept.jar", "url": "https://example.com/kept.jar", "sha1": "11", "size": 1},
+		{"path": "./mods/", "name": "changed.jar", "url": "https://example.com/changed.jar", "sha1": "99", "size": 2}
+	]}`))
+
+	want := []lockDrift{
+		{driftAdded, "mods/added.jar"},
+		{driftChanged, "mods/changed.jar"},
+		{driftRemoved, "mods/removed.jar"},
+	}
+	if got := locked.drift(&live); !reflect.DeepEqual(got, want) {
+		t.Errorf("drift() = %v, want %v", got, want)
+	}
+	if got := live.drift(&live); len(got) != 0 {
+		t.Errorf("drift() against itself = %v, want none", got)
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFanoutHandlerLogsToAllSinks(t *testing.T) {
+	var stderr bytes.Buffer
+	logFilePath := filepath.Join(t.TempDir(), "run.log")
+	logFile, err := openLogFile(logFilePath)
+	if err != nil {
+		t.Fatalf("openLogFile() error = %v", err)
+	}
+	defer logFile.Close()
+
+	logger := slog.New(fanoutHandler{
+		slog.NewTextHandler(&stderr, nil),
+		slog.NewJSONHandler(logFile, nil),
+	})
+	logger.With("modpack", 42).WithGroup("file").Info("Downloaded file", "path", "mods/a.jar")
+
+	got, err := os.ReadFile(logFilePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []struct {
+		name string
+		log  string
+		want []string
+	}{
+		{"stderr", stderr.String(), []string{"msg=\"Downloaded file\"", "modpack=42", "file.path=mods/a.jar"}},
+		{"log file", string(got), []string{`"msg":"Downloaded file"`, `"modpack":42`, `"file":{"path":"mods/a.jar"}`}},
+	} {
+		for _, want := range c.want {
+			if !strings.Contains(c.log, want) {
+				t.Errorf("%s log %q does not contain %q", c.name, c.log, want)
+			}
+		}
+	}
+}
+
+func TestFanoutHandlerRespectsEachLevel(t *testing.T) {
+	var quiet, verbose bytes.Buffer
+	h := fanoutHandler{
+		slog.NewTextHandler(&quiet, &slog.HandlerOptions{Level: slog.LevelWarn}),
+		slog.NewTextHandler(&verbose, &slog.HandlerOptions{Level: slog.LevelDebug}),
+	}
+	logger := slog.New(h)
+	logger.Debug("Debug message")
+	logger.Warn("Warning message")
+
+	if got := quiet.String(); strings.Contains(got, "Debug message") || !strings.Contains(got, "Warning message") {
+		t.Errorf("warn-level sink got %q, want only the warning", got)
+	}
+	if got := verbose.String(); !strings.Contains(got, "Debug message") || !strings.Contains(got, "Warning message") {
+		t.Errorf("debug-level sink got %q, want both messages", got)
+	}
+
+	none := fanoutHandler{slog.NewTextHandler(&quiet, &slog.HandlerOptions{Level: slog.LevelError})}
+	if none.Enabled(context.Background(), slog.LevelWarn) {
+		t.Error("Enabled(Warn) = true with only an error-level sink")
+	}
+}
+
+func TestOpenLogFileAppends(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "run.log")
+	for _, line := range []string{"first run\n", "second run\n"} {
+		f, err := openLogFile(path)
+		if err != nil {
+			t.Fatalf("openLogFile() error = %v", err)
+		}
+		if _, err = f.WriteString(line); err != nil {
+			t.Fatal(err)
+		}
+		if err = f.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got, err := os.ReadFile(path); err != nil || string(got) != "first run\nsecond run\n" {
+		t.Errorf("log file = %q, %v, want both runs", got, err)
+	}
+}
@@ -2,55 +2,273 @@ package main
 
 import (
 	"context"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"log/slog"
-	"net/http"
+	"math"
 	"os"
 	"os/signal"
+	"runtime"
 	"slices"
 	"strconv"
 	"strings"
 	"syscall"
+	"time"
 	"unsafe"
 
+	cfapi "github.com/database64128/modpack-dl-go/curseforge"
 	"github.com/database64128/modpack-dl-go/download"
 	"github.com/database64128/modpack-dl-go/modpacksch"
 	"github.com/database64128/modpack-dl-go/precheck"
+	"github.com/database64128/modpack-dl-go/prune"
 	"github.com/lmittmann/tint"
+	"golang.org/x/time/rate"
 )
 
 var (
+	configPath                     string
 	modpackID                      int64
 	versionID                      int64
+	modpacks                       packRefs
 	clientPath                     string
 	serverPath                     string
 	migrateFromPath                string
+	installDir                     string
+	installTarget                  string
+	optionalFiles                  string
+	downloadArt                    bool
+	artPath                        string
 	preserveMigrationSource        bool
+	migrationMode                  precheck.MigrationMode
+	dryRun                         bool
+	verifyOnly                     bool
+	ignoreDiskSpace                bool
+	listVersions                   bool
+	checkUpdate                    int64
+	manifestOnly                   bool
+	manifestOutput                 string
+	validateManifest               bool
+	diffVersion                    int64
+	showChangelog                  bool
+	plainChangelog                 bool
+	searchTerm                     string
+	searchLimit                    int
+	pruneExtraneous                bool
+	pruneDirs                      strs
+	writeManifest                  bool
+	zipOutput                      string
+	useLock                        bool
+	journalPath                    string
+	onlyPatterns                   strs
+	excludePatterns                strs
+	noClobberPatterns              strs
+	jsonOutput                     bool
 	curseforge                     bool
+	detectProvider                 bool
+	apiToken                       string
+	apiBaseURL                     string
+	curseforgeAPIKey               string
+	userAgent                      string
+	proxyURL                       string
+	caCertPath                     string
+	insecureSkipVerify             bool
+	cacheDir                       string
+	cacheTTL                       time.Duration
+	precheckConcurrency            int
+	maxOpenFiles                   int
 	downloadConcurrency            int
+	perHostConcurrency             int
+	maxIdleConnsPerHost            int
+	maxConnsPerHost                int
+	responseHeaderTimeout          time.Duration
+	requestJitter                  time.Duration
+	maxRedirects                   int
+	httpsOnly                      bool
+	timeout                        time.Duration
+	shutdownGrace                  time.Duration
+	downloadTimeout                time.Duration
+	minDownloadSpeed               byteSize
+	chunkThreshold                 byteSize
+	downloadChunks                 int
+	fullRetries                    int
+	downloadAttempts               int
+	retryBudget                    int
+	preallocate                    bool
+	headCheck                      bool
+	httpTrace                      bool
+	maxFileSize                    byteSize
+	usePartFiles                   bool
+	verifyKey                      string
+	savePlanPath                   string
+	resumePlanPath                 string
+	fileModeFlag                   fileMode
+	dirModeFlag                    fileMode
+	rateLimit                      byteSize
+	clientIgnoreCurseForgeProjects int64s
 	serverIgnoreCurseForgeProjects int64s
+	ignoreCurseForgeProjects       int64s
 	logLevel                       slog.Level
+	logFormat                      string
+	logFilePath                    string
+	progressInterval               time.Duration
+	quiet                          bool
+	failFast                       bool
+	reportPath                     string
+	reportFormat                   string
+	xattrCache                     bool
+	paranoid                       bool
+	touchExisting                  bool
+	debugAddr                      string
 )
 
 func init() {
+	flag.StringVar(&configPath, "config", "", "Optional. Read flags from the specified JSON file, an object mapping flag names without the leading dash to their values. Flags on the command line take precedence")
 	flag.Int64Var(&modpackID, "modpackID", 0, "ID of the modpack to download")
 	flag.Int64Var(&versionID, "versionID", 0, "Optional. Download the specified version of the modpack, instead of the latest version")
+	flag.Var(&modpacks, "modpacks", "Optional. Comma-separated list of modpacks to download in one run, each as 'id' for the latest version or 'id:version'. Each modpack is installed to its own subdirectory, named after the modpack, of the client, server, migration source, and art paths")
 	flag.StringVar(&clientPath, "clientPath", "", "Optional. Download the modpack client to the specified path")
 	flag.StringVar(&serverPath, "serverPath", "", "Optional. Download the modpack server to the specified path")
+	flag.StringVar(&installDir, "installDir", "", "Optional. Install the modpack to the specified directory, instead of specifying '-clientPath' and '-serverPath'. See '-target'")
+	flag.StringVar(&installTarget, "target", installTargetClient, "What to install to '-installDir': 'client', 'server', or 'both'. A single target is installed to the directory itself, both to its 'client' and 'server' subdirectories")
 	flag.StringVar(&migrateFromPath, "migrateFromPath", "", "Optional. Migrate the modpack from the specified path")
-	flag.BoolVar(&preserveMigrationSource, "preserveMigrationSource", false, "Migrate by copying instead of moving files")
+	flag.BoolVar(&downloadArt, "downloadArt", false, "Also download the modpack's artwork, such as its icon and splash images")
+	flag.StringVar(&artPath, "artPath", "", "Optional. Download the modpack's artwork to the specified path. Defaults to '.art' under the client path, or the server path if no client path is specified")
+	flag.BoolVar(&preserveMigrationSource, "preserveMigrationSource", false, "Migrate by copying instead of moving files. Shorthand for '-migrationMode copy'")
+	flag.TextVar(&migrationMode, "migrationMode", precheck.MigrationModeMove, "How to migrate existing files: 'move', 'copy', 'hardlink', or 'reflink'")
+	flag.StringVar(&searchTerm, "search", "", "Search for modpacks matching the specified term, print their names and IDs, and exit")
+	flag.IntVar(&searchLimit, "searchLimit", 20, "Maximum number of search results")
+	flag.BoolVar(&listVersions, "listVersions", false, "List the modpack's versions, newest first, and exit")
+	flag.Int64Var(&checkUpdate, "checkUpdate", 0, "Optional. Print the modpack's versions newer than the specified installed version ID, newest first, and exit")
+	flag.BoolVar(&manifestOnly, "manifestOnly", false, "Print the version manifest as JSON, including each file's resolved download URL, and exit")
+	flag.StringVar(&manifestOutput, "manifestOutput", "", "Optional. Write the version manifest to the specified file instead of stdout. Used with '-manifestOnly'")
+	flag.BoolVar(&validateManifest, "validateManifest", false, "Check that every file in the version manifest has a download URL, valid hash sums, a non-negative size, and a safe path, print all problems found, and exit. Exits with a non-zero status if there are any")
+	flag.Int64Var(&diffVersion, "diffVersion", 0, "Optional. Print the files added, removed, or updated from the specified version to the version specified by '-versionID' or the latest version, and exit")
+	flag.BoolVar(&showChangelog, "showChangelog", false, "Print the version's changelog to stdout. With '-listVersions', print the changelog of each version")
+	flag.BoolVar(&plainChangelog, "plainChangelog", false, "Render changelogs from markdown to plain text. Used with '-showChangelog'")
+	flag.BoolVar(&jsonOutput, "json", false, "Print listings as JSON instead of a table")
+	flag.BoolVar(&dryRun, "dryRun", false, "Only print what would be done, without creating, migrating, or downloading any files")
+	flag.BoolVar(&verifyOnly, "verifyOnly", false, "Only verify the existing files at the client and server paths, reporting any that are missing or corrupt, without creating, migrating, or downloading any files")
+	flag.BoolVar(&ignoreDiskSpace, "ignoreDiskSpace", false, "Start downloading even if there does not seem to be enough free disk space")
+	flag.BoolVar(&pruneExtraneous, "prune", false, "Remove files in managed directories that are not part of the modpack version")
+	flag.Var(&pruneDirs, "pruneDirs", "Optional. Comma-separated list of managed directories to prune (default \"mods\")")
+	flag.BoolVar(&writeManifest, "writeManifest", false, "Write a CurseForge-style manifest.json to the client path after downloading, for importing into compatible launchers")
+	flag.StringVar(&zipOutput, "zipOutput", "", "Optional. After a successful run, also package the files in the client path into a zip archive at the specified path")
+	flag.BoolVar(&useLock, "useLock", false, "Abort if the version manifest has drifted from the lockfile written by a previous successful run")
+	flag.StringVar(&journalPath, "journal", "", "Optional. Record verified files in the specified journal file, and skip hashing files recorded in it whose size and modification time are unchanged")
+	flag.StringVar(&optionalFiles, "optionalFiles", optionalFilesDefault, "Which optional files to download: 'default' for those selected by default in the launcher, 'all', or 'none'")
+	flag.BoolVar(&paranoid, "paranoid", false, "Hash files again after copying them between the client and server paths, and download them instead if the copy is corrupt")
+	flag.BoolVar(&touchExisting, "touchExisting", false, "Set the modification times of existing valid files to the times in the manifest, without downloading them again, e.g. after restoring them from a backup")
+	flag.BoolVar(&xattrCache, "xattrCache", false, "Record verified files in an extended attribute of each file, and skip hashing files that have not been modified since. Only supported on Linux and macOS")
+	flag.Var(&onlyPatterns, "only", "Optional. Comma-separated list of glob patterns. Only download files whose paths in the manifest match any of them, e.g. 'config/**'")
+	flag.Var(&excludePatterns, "exclude", "Optional. Comma-separated list of glob patterns. Do not download files whose paths in the manifest match any of them, e.g. 'mods/optifine*'. Takes precedence over '-only'")
 	flag.BoolVar(&curseforge, "curseforge", false, "ID is a CurseForge project ID instead of a modpacks.ch public modpack ID")
+	flag.BoolVar(&detectProvider, "detectProvider", true, "If the modpack is not found with the provider selected by '-curseforge', try the other provider")
+	flag.Var(&noClobberPatterns, "noClobber", "Optional. Comma-separated list of glob patterns. Keep existing files whose paths in the manifest match any of them as is when they fail the hash check, instead of downloading them again, so that local edits survive, e.g. 'config/**,options.txt'. Matching files are downloaded as with '-partFiles', so that interrupted downloads are not kept")
+	flag.StringVar(&apiToken, "apiToken", "", "Optional. API token for accessing private modpacks. Defaults to the value of the "+apiTokenEnv+" environment variable")
+	flag.StringVar(&apiBaseURL, "apiBaseURL", "", "Optional. Send API requests to the specified base URL instead of "+modpacksch.APIBaseURL)
+	flag.StringVar(&curseforgeAPIKey, "curseforgeAPIKey", "", "Optional. CurseForge API key for resolving accurate download URLs of CurseForge files. Defaults to the value of the "+curseforgeAPIKeyEnv+" environment variable")
+	flag.StringVar(&userAgent, "userAgent", "", "Optional. Send the specified user agent with API and download requests instead of "+modpacksch.APIUserAgent)
+	flag.StringVar(&proxyURL, "proxy", "", "Optional. Send API and download requests through the specified proxy, e.g. 'http://127.0.0.1:8080' or 'socks5://127.0.0.1:1080'. Defaults to the proxy configured by the HTTP_PROXY, HTTPS_PROXY, and NO_PROXY environment variables")
+	flag.StringVar(&caCertPath, "caCert", "", "Optional. Trust the CA certificates in the specified PEM bundle in addition to the system roots")
+	flag.BoolVar(&insecureSkipVerify, "insecureSkipVerify", false, "Disable TLS certificate verification. This is insecure and should only be used for troubleshooting")
+	flag.StringVar(&cacheDir, "cacheDir", "", "Optional. Cache API responses in the specified directory")
+	flag.DurationVar(&cacheTTL, "cacheTTL", time.Hour, "How long cached API responses are used before being revalidated")
+	flag.IntVar(&precheckConcurrency, "precheckConcurrency", runtime.NumCPU(), "Optional. Number of concurrent precheck workers, which verify existing files")
+	flag.IntVar(&maxOpenFiles, "maxOpenFiles", 0, "Optional. Maximum number of files created for download that are open at the same time. Zero means unlimited")
 	flag.IntVar(&downloadConcurrency, "downloadConcurrency", 32, "Optional. Number of concurrent downloads")
+	flag.IntVar(&perHostConcurrency, "perHostConcurrency", 0, "Optional. Maximum number of concurrent download requests to each host. Zero means unlimited")
+	flag.IntVar(&maxIdleConnsPerHost, "maxIdleConnsPerHost", 0, "Optional. Maximum number of idle download connections kept for reuse per host. Zero means '-perHostConcurrency' if set, or '-downloadConcurrency'")
+	flag.IntVar(&maxConnsPerHost, "maxConnsPerHost", 0, "Optional. Maximum number of download connections per host, including those in use. Zero means unlimited")
+	flag.DurationVar(&responseHeaderTimeout, "responseHeaderTimeout", time.Minute, "How long to wait for the response headers of each download request. Zero means no limit")
+	flag.DurationVar(&requestJitter, "requestJitter", 0, "Optional. Delay each download request by a random duration of up to the specified duration, e.g. '200ms'")
+	flag.IntVar(&maxRedirects, "maxRedirects", 10, "Maximum number of redirects to follow for each download request")
+	flag.BoolVar(&httpsOnly, "httpsOnly", false, "Reject download redirects to URLs that are not HTTPS, such as plain HTTP mirrors")
+	flag.DurationVar(&timeout, "timeout", 0, "Optional. Abort the run if it takes longer than the specified duration, e.g. '30m'")
+	flag.DurationVar(&shutdownGrace, "shutdownGrace", 30*time.Second, "How long to let in-flight downloads finish after the first exit signal, before aborting them. A second signal aborts them right away. Zero aborts on the first signal")
+	flag.StringVar(&reportPath, "report", "", "Optional. At the end of the run, write an integrity report to the specified file, with each file's path, expected size and hash sum, whether it is present and valid, and the action taken")
+	flag.StringVar(&reportFormat, "reportFormat", reportFormatCSV, "Format of the report written by '-report': 'csv' or 'json'")
+	flag.BoolVar(&failFast, "failFast", false, "Abort the run and exit with a non-zero status on the first file that fails to be prechecked or downloaded")
+	flag.DurationVar(&downloadTimeout, "downloadTimeout", 0, "Optional. Abort each download attempt that takes longer than the specified duration, and try the next mirror, if any")
+	flag.Var(&minDownloadSpeed, "minDownloadSpeed", "Optional. Abort each download attempt that runs slower on average than the specified rate in bytes per second, e.g. '100KiB', by giving it a timeout proportional to the file's size. '-downloadTimeout', or 30s if not set, is the minimum timeout")
+	chunkThreshold = 200 << 20
+	flag.Var(&chunkThreshold, "chunkThreshold", "Minimum size of files to download in concurrent chunks. Used with '-downloadChunks'")
+	flag.IntVar(&downloadChunks, "downloadChunks", 1, "Optional. Download large files in the specified number of concurrent range requests, if the server supports them")
+	flag.IntVar(&downloadAttempts, "downloadAttempts", 1, "Maximum number of attempts at downloading a file from each URL. Attempts that fail with a network error or a 429 or 5xx response are retried with backoff")
+	flag.IntVar(&fullRetries, "fullRetries", 1, "Maximum number of times to download a file from scratch after a resumed download fails the hash check")
+	flag.Var(&maxFileSize, "maxFileSize", "Optional. Refuse to download files larger than the specified size, e.g. '2GiB', whether advertised by the manifest or received. Zero means unlimited")
+	flag.IntVar(&retryBudget, "retryBudget", -1, "Optional. Maximum total number of download retries at the same URL, and full retries after failed resumes, across the whole run. Mirrors are tried regardless. Negative means unlimited")
+	flag.BoolVar(&preallocate, "preallocate", false, "Allocate disk space for each file up to its expected size before downloading it")
+	flag.BoolVar(&headCheck, "headCheck", false, "Send a HEAD request before each download, and skip URLs whose advertised checksum header or ETag does not match the expected hash sum")
+	flag.BoolVar(&httpTrace, "trace", false, "Log DNS, connect, TLS handshake, and time-to-first-byte timings of each download request at debug level")
+	flag.StringVar(&verifyKey, "verifyKey", "", "Optional. Verify each file against the minisign signature published at its download URL with a '"+signatureSuffix+"' suffix, using the specified public key, given as the key itself or the path to a public key file. Files failing verification are downloaded again. Files without a published signature are not checked")
+	flag.StringVar(&savePlanPath, "savePlan", "", "Optional. Save the planned files, with their URLs, destination paths, hash sums, and sizes, to the specified file, for resuming the install later with '-resumePlan'")
+	flag.StringVar(&resumePlanPath, "resumePlan", "", "Optional. Install the files planned in the specified file saved by '-savePlan', without fetching any manifests from the API. Pruning, lockfiles, and CurseForge manifests are skipped, as they need the manifests")
+	flag.BoolVar(&usePartFiles, "partFiles", false, "Download each file to a temporary '"+precheck.PartFileSuffix+"' file next to it, and rename it into place only after it has been verified")
+	fileModeFlag = 0644
+	flag.Var(&fileModeFlag, "fileMode", "Permission bits of created files in octal, e.g. '0664', subject to the umask")
+	dirModeFlag = 0755
+	flag.Var(&dirModeFlag, "dirMode", "Permission bits of created directories in octal, e.g. '0775', subject to the umask")
+	flag.Var(&rateLimit, "rateLimit", "Optional. Limit the total download rate in bytes per second, e.g. '10MiB'. Zero means unlimited")
+	flag.Var(&clientIgnoreCurseForgeProjects, "clientIgnoreCurseForgeProjects", "Optional. Comma-separated list of CurseForge project IDs to ignore when downloading the client")
 	flag.Var(&serverIgnoreCurseForgeProjects, "serverIgnoreCurseForgeProjects", "Optional. Comma-separated list of CurseForge project IDs to ignore when downloading the server")
+	flag.Var(&ignoreCurseForgeProjects, "ignoreCurseForgeProjects", "Optional. Comma-separated list of CurseForge project IDs to ignore when downloading both the client and the server")
 	flag.TextVar(&logLevel, "logLevel", slog.LevelInfo, "Log level")
+	flag.StringVar(&logFormat, "logFormat", logFormatText, "Log format: 'text' or 'json'. In JSON mode, a run summary is printed to stdout as JSON")
+	flag.StringVar(&logFilePath, "logFile", "", "Optional. Also append logs to the specified file, in the format specified by '-logFormat'")
+	flag.StringVar(&debugAddr, "debugAddr", "", "Optional. Serve aggregate download metrics via expvar at /debug/vars on the specified address, e.g. 'localhost:6060'")
+	flag.BoolVar(&quiet, "quiet", false, "Only log warnings and errors, and with text logs on a terminal, show a single updating line with the overall progress instead. Does not apply to '-logFile'")
+	flag.DurationVar(&progressInterval, "progressInterval", 5*time.Second, "Interval between logs of the overall download progress, throughput, and estimated time remaining. Only used with text logs on a terminal. Zero disables progress logs")
 }
 
 func main() {
 	flag.Parse()
 
-	if modpackID == 0 {
-		fmt.Println("Please specify a modpack ID with '-modpackID'.")
+	if configPath != "" {
+		if err := applyConfigFile(flag.CommandLine, configPath, "config"); err != nil {
+			fmt.Println(err)
+			flag.Usage()
+			os.Exit(1)
+		}
+	}
+
+	if resumePlanPath != "" && (modpackID != 0 || len(modpacks) > 0 || searchTerm != "" || savePlanPath != "") {
+		fmt.Println("'-resumePlan' cannot be combined with '-modpackID', '-modpacks', '-search', or '-savePlan'.")
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	if modpackID == 0 && len(modpacks) == 0 && searchTerm == "" && resumePlanPath == "" {
+		fmt.Println("Please specify a modpack ID with '-modpackID', or modpacks with '-modpacks'.")
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	if len(modpacks) > 0 {
+		if err := checkMultiPackFlags(modpacks); err != nil {
+			fmt.Println(err)
+			flag.Usage()
+			os.Exit(1)
+		}
+	}
+
+	if installDir != "" {
+		if clientPath != "" || serverPath != "" {
+			fmt.Println("'-installDir' cannot be combined with '-clientPath' or '-serverPath'.")
+			flag.Usage()
+			os.Exit(1)
+		}
+		var err error
+		if clientPath, serverPath, err = installPaths(installDir, installTarget); err != nil {
+			fmt.Println(err)
+			flag.Usage()
+			os.Exit(1)
+		}
+	}
+
+	if precheckConcurrency <= 0 {
+		fmt.Println("Precheck concurrency must be positive.")
 		flag.Usage()
 		os.Exit(1)
 	}
@@ -61,93 +279,467 @@ func main() {
 		os.Exit(1)
 	}
 
-	logger := slog.New(tint.NewHandler(os.Stderr, &tint.Options{
-		Level: logLevel,
-	}))
+	if maxIdleConnsPerHost < 0 || maxConnsPerHost < 0 {
+		fmt.Println("Connection limits must not be negative.")
+		flag.Usage()
+		os.Exit(1)
+	}
 
-	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
-	go func() {
-		<-ctx.Done()
-		logger.LogAttrs(ctx, slog.LevelInfo, "Received exit signal")
-		stop()
-	}()
+	if maxIdleConnsPerHost == 0 {
+		maxIdleConnsPerHost = downloadConcurrency
+		if perHostConcurrency > 0 {
+			maxIdleConnsPerHost = min(perHostConcurrency, downloadConcurrency)
+		}
+	}
 
-	var client modpacksch.ModpackClient
-	if !curseforge {
-		client = modpacksch.DefaultPublicModpackClient
-	} else {
-		client = modpacksch.DefaultCurseForgeModpackClient
+	if apiToken == "" {
+		apiToken = os.Getenv(apiTokenEnv)
 	}
 
-	modpackManifest, err := client.GetModpackManifest(ctx, modpackID)
-	if err != nil {
-		logger.LogAttrs(ctx, slog.LevelError, "Failed to get modpack manifest",
-			slog.Int64("modpackID", modpackID),
-			tint.Err(err),
-		)
+	if curseforgeAPIKey == "" {
+		curseforgeAPIKey = os.Getenv(curseforgeAPIKeyEnv)
+	}
+
+	if preserveMigrationSource && migrationMode == precheck.MigrationModeMove {
+		migrationMode = precheck.MigrationModeCopy
+	}
+
+	if len(pruneDirs) == 0 {
+		pruneDirs = prune.DefaultManagedDirs
+	}
+
+	if err := prune.ValidateManagedDirs(pruneDirs); err != nil {
+		fmt.Println(err)
+		flag.Usage()
 		os.Exit(1)
 	}
 
-	logger.LogAttrs(ctx, slog.LevelInfo, "Got modpack manifest",
-		slog.Int64("modpackID", modpackManifest.ID),
-		slog.String("name", modpackManifest.Name),
-		slog.String("synopsis", modpackManifest.Synopsis),
-		slog.Any("versions", modpackManifest.Versions),
-	)
+	if err := validateOptionalFiles(optionalFiles); err != nil {
+		fmt.Println(err)
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	var mapPath modpacksch.PathMapper
+	var filter *pathFilter
+	if len(onlyPatterns) > 0 || len(excludePatterns) > 0 {
+		var err error
+		if filter, err = newPathFilter(onlyPatterns, excludePatterns); err != nil {
+			fmt.Println(err)
+			flag.Usage()
+			os.Exit(1)
+		}
+		mapPath = filter.pathMapper()
+	}
 
-	if versionID == 0 {
-		version, ok := modpackManifest.LatestVersion()
-		if !ok {
-			logger.LogAttrs(ctx, slog.LevelError, "Modpack has no versions")
+	var noClobberFilter *pathFilter
+	if len(noClobberPatterns) > 0 {
+		var err error
+		if noClobberFilter, err = newPathFilter(noClobberPatterns, nil); err != nil {
+			fmt.Println(err)
+			flag.Usage()
 			os.Exit(1)
 		}
-		versionID = version.ID
 	}
 
-	versionManifest, err := client.GetModpackVersionManifest(ctx, modpackID, versionID)
+	httpConfig := httpClientConfig{
+		ProxyURL:           proxyURL,
+		CACertPath:         caCertPath,
+		InsecureSkipVerify: insecureSkipVerify,
+	}
+	httpClient, err := newHTTPClient(httpConfig)
 	if err != nil {
-		logger.LogAttrs(ctx, slog.LevelError, "Failed to get modpack version manifest",
-			slog.Int64("modpackID", modpackID),
-			slog.Int64("versionID", versionID),
-			tint.Err(err),
-		)
+		fmt.Println(err)
+		flag.Usage()
+		os.Exit(1)
+	}
+	downloadClient, err := newDownloadClient(httpConfig, downloadPoolConfig{
+		MaxIdleConnsPerHost:   maxIdleConnsPerHost,
+		MaxConnsPerHost:       maxConnsPerHost,
+		ResponseHeaderTimeout: responseHeaderTimeout,
+	})
+	if err != nil {
+		fmt.Println(err)
+		flag.Usage()
 		os.Exit(1)
 	}
 
-	logger.LogAttrs(ctx, slog.LevelInfo, "Got modpack version manifest",
-		slog.Int64("modpackID", versionManifest.Parent),
-		slog.Int64("versionID", versionManifest.ID),
-		slog.String("name", versionManifest.Name),
-		slog.String("type", versionManifest.Type),
-		slog.Time("updated", versionManifest.Updated.Time),
-		slog.Int("fileCount", len(versionManifest.Files)),
-		slog.Any("targets", versionManifest.Targets),
-	)
+	// progress, if not nil, is the progress line shown in quiet mode below the logs on stderr.
+	logHandler, progress, err := newStderrHandler(os.Stderr, logFormat, logLevel, quiet, isTerminal(os.Stderr))
+	if err != nil {
+		fmt.Println(err)
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	switch reportFormat {
+	case reportFormatCSV, reportFormatJSON:
+	default:
+		fmt.Printf("Unknown report format: %q\n", reportFormat)
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	if logFilePath != "" {
+		logFile, err := openLogFile(logFilePath)
+		if err != nil {
+			fmt.Println(err)
+			flag.Usage()
+			os.Exit(1)
+		}
+		defer logFile.Close()
+
+		var fileHandler slog.Handler
+		if logFormat == logFormatText {
+			fileHandler = tint.NewHandler(logFile, &tint.Options{
+				Level:   logLevel,
+				NoColor: true,
+			})
+		} else {
+			fileHandler = slog.NewJSONHandler(logFile, &slog.HandlerOptions{
+				Level: logLevel,
+			})
+		}
+		logHandler = fanoutHandler{logHandler, fileHandler}
+	}
+	logger := slog.New(logHandler)
+
+	sigCh := make(chan os.Signal, 2)
+	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(sigCh)
+
+	// workCtx is canceled to abort in-flight work, ctx as soon as the run is to wind down.
+	workCtx, draining, stopShutdown := handleShutdown(context.Background(), logger, sigCh, shutdownGrace)
+	defer stopShutdown()
 
-	if clientPath == "" && serverPath == "" {
+	if insecureSkipVerify {
+		logger.LogAttrs(workCtx, slog.LevelWarn, "TLS certificate verification is disabled! API responses and downloaded files may be tampered with")
+	}
+
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		workCtx, cancel = context.WithTimeoutCause(workCtx, timeout, errRunTimeout)
+		defer cancel()
+	}
+
+	var cancelRun context.CancelCauseFunc
+	if failFast {
+		workCtx, cancelRun = context.WithCancelCause(workCtx)
+		defer cancelRun(nil)
+	}
+
+	ctx, stopDrain := withDrain(workCtx, draining)
+	defer stopDrain()
+
+	var sigVerifier *signatureVerifier
+	if verifyKey != "" {
+		if sigVerifier, err = newSignatureVerifier(ctx, downloadClient, verifyKey); err != nil {
+			fmt.Println("Invalid -verifyKey:", err)
+			flag.Usage()
+			os.Exit(1)
+		}
+	}
+
+	clientOpts := []modpacksch.ClientOption{
+		modpacksch.WithHTTPClient(httpClient),
+		modpacksch.WithRetryPolicy(modpacksch.DefaultRetryPolicy),
+		modpacksch.WithBaseURL(apiBaseURL),
+		modpacksch.WithUserAgent(userAgent),
+	}
+	if apiToken != "" {
+		clientOpts = append(clientOpts, modpacksch.WithAuthToken(apiToken))
+	}
+	if cacheDir != "" {
+		clientOpts = append(clientOpts, modpacksch.WithCache(modpacksch.NewCache(cacheDir, cacheTTL)))
+	}
+
+	provider, otherProvider := newModpackProviders(curseforge, clientOpts)
+	client := provider.client
+
+	if searchTerm != "" {
+		if err := searchModpacks(ctx, os.Stdout, client, searchTerm, searchLimit, jsonOutput); err != nil {
+			logger.LogAttrs(ctx, slog.LevelError, "Failed to search modpacks",
+				slog.String("term", searchTerm),
+				tint.Err(err),
+			)
+			logAuthHint(ctx, logger, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if checkUpdate != 0 {
+		_, newer, err := withProviderFallback(ctx, logger, provider, otherProvider, detectProvider, modpackID, func(client modpacksch.ModpackClient) ([]modpacksch.ModpackVersion, error) {
+			return client.CheckForUpdate(ctx, modpackID, checkUpdate)
+		})
+		if err != nil {
+			logger.LogAttrs(ctx, slog.LevelError, "Failed to check for update",
+				slog.Int64("modpackID", modpackID),
+				slog.Int64("currentVersionID", checkUpdate),
+				tint.Err(err),
+			)
+			logAuthHint(ctx, logger, err)
+			os.Exit(1)
+		}
+		if len(newer) == 0 {
+			logger.LogAttrs(ctx, slog.LevelInfo, "Modpack is up to date",
+				slog.Int64("modpackID", modpackID),
+				slog.Int64("currentVersionID", checkUpdate),
+			)
+		} else {
+			logger.LogAttrs(ctx, slog.LevelInfo, "Update available",
+				slog.Int64("modpackID", modpackID),
+				slog.Int64("currentVersionID", checkUpdate),
+				slog.Int64("latestVersionID", newer[0].ID),
+				slog.String("latestVersionName", newer[0].Name),
+				slog.Int("newerVersions", len(newer)),
+			)
+		}
+		if err = printVersions(os.Stdout, newer, jsonOutput); err != nil {
+			logger.LogAttrs(ctx, slog.LevelError, "Failed to print versions", tint.Err(err))
+			os.Exit(1)
+		}
+		return
+	}
+
+	var plan *precheck.Plan
+	if resumePlanPath != "" {
+		p, err := precheck.LoadPlan(resumePlanPath)
+		if err != nil {
+			logger.LogAttrs(ctx, slog.LevelError, "Failed to load plan",
+				slog.String("path", resumePlanPath),
+				tint.Err(err),
+			)
+			os.Exit(1)
+		}
+		plan = &p
+	}
+
+	refs := modpacks
+	if len(refs) == 0 && plan == nil {
+		refs = packRefs{{ModpackID: modpackID, VersionID: versionID}}
+	}
+
+	if plan == nil && (listVersions || manifestOnly || validateManifest || diffVersion != 0) {
+		if !runVersionCommand(ctx, logger, provider, otherProvider, detectProvider, modpackID, versionID) {
+			os.Exit(1)
+		}
+		return
+	}
+
+	packs, ok := fetchPacks(ctx, logger, provider, otherProvider, detectProvider, refs)
+	if !ok {
+		os.Exit(1)
+	}
+
+	if plan == nil && clientPath == "" && serverPath == "" {
 		logger.LogAttrs(ctx, slog.LevelInfo, "User did not ask to download anything")
 		return
 	}
 
+	clientIgnoreCurseForgeProjects = append(clientIgnoreCurseForgeProjects, ignoreCurseForgeProjects...)
+	serverIgnoreCurseForgeProjects = append(serverIgnoreCurseForgeProjects, ignoreCurseForgeProjects...)
+
+	setPaths(packs, clientPath, serverPath, migrateFromPath, artPath)
+
+	if useLock && !checkLockFiles(ctx, logger, packs) {
+		os.Exit(1)
+	}
+
 	pjch := make(chan precheck.Job)
-	pwf := precheck.NewWorkerFleet(ctx, logger, pjch)
-	dwf := download.NewWorkerFleet(ctx, logger, http.DefaultClient, downloadConcurrency, pwf.DownloadJobChannel())
+	precheckOpts := []precheck.Option{
+		precheck.WithDrain(ctx),
+		precheck.WithFileModes(os.FileMode(fileModeFlag), os.FileMode(dirModeFlag)),
+	}
+	if maxOpenFiles > 0 {
+		precheckOpts = append(precheckOpts, precheck.WithMaxOpenFiles(maxOpenFiles))
+	}
+	var journal *precheck.Journal
+	if journalPath != "" {
+		if journal, err = precheck.LoadJournal(journalPath); err != nil {
+			logger.LogAttrs(ctx, slog.LevelError, "Failed to load journal",
+				slog.String("path", journalPath),
+				tint.Err(err),
+			)
+			os.Exit(1)
+		}
+		precheckOpts = append(precheckOpts, precheck.WithJournal(journal))
+	}
+	if xattrCache {
+		precheckOpts = append(precheckOpts, precheck.WithXattrCache())
+	}
+	if paranoid {
+		precheckOpts = append(precheckOpts, precheck.WithCopyVerification())
+	}
+	if touchExisting {
+		precheckOpts = append(precheckOpts, precheck.WithTouchExisting())
+	}
+
+	var (
+		handlers eventHandlers
+		report   *reportCollector
+	)
+	if failFast {
+		handlers = append(handlers, failFastHandler{cancelRun})
+	}
+	if reportPath != "" {
+		report = newReportCollector()
+		handlers = append(handlers, report)
+	}
+	if len(handlers) > 0 {
+		precheckOpts = append(precheckOpts, precheck.WithEventHandler(handlers))
+	}
+	pwf := precheck.NewWorkerFleet(workCtx, logger, precheckConcurrency, pjch, precheckOpts...)
+	downloadOpts := []download.Option{
+		download.WithDrain(ctx),
+		download.WithFullRetries(fullRetries),
+		download.WithMaxAttempts(downloadAttempts),
+		download.WithRedirectPolicy(maxRedirects, httpsOnly),
+	}
+	if len(handlers) > 0 {
+		downloadOpts = append(downloadOpts, download.WithEventHandler(handlers))
+	}
+	if rateLimit > 0 {
+		downloadOpts = append(downloadOpts, download.WithRateLimiter(rate.NewLimiter(rate.Limit(rateLimit), int(min(rateLimit, math.MaxInt32)))))
+	}
+	if downloadTimeout > 0 {
+		downloadOpts = append(downloadOpts, download.WithTimeout(downloadTimeout))
+	}
+	if minDownloadSpeed > 0 {
+		downloadOpts = append(downloadOpts, download.WithMinThroughput(int64(minDownloadSpeed)))
+	}
+	if retryBudget >= 0 {
+		downloadOpts = append(downloadOpts, download.WithRetryBudget(retryBudget))
+	}
+	if perHostConcurrency > 0 {
+		downloadOpts = append(downloadOpts, download.WithPerHostConcurrency(perHostConcurrency))
+	}
+	if requestJitter > 0 {
+		downloadOpts = append(downloadOpts, download.WithRequestJitter(requestJitter))
+	}
+	if maxFileSize > 0 {
+		downloadOpts = append(downloadOpts, download.WithMaxFileSize(int64(maxFileSize)))
+	}
+	if preallocate {
+		downloadOpts = append(downloadOpts, download.WithPreallocation())
+	}
+	if headCheck {
+		downloadOpts = append(downloadOpts, download.WithHeadCheck())
+	}
+	if httpTrace {
+		downloadOpts = append(downloadOpts, download.WithHTTPTrace())
+	}
+	if downloadChunks > 1 {
+		downloadOpts = append(downloadOpts, download.WithChunkedDownload(int64(chunkThreshold), downloadChunks))
+	}
+	if progressInterval > 0 && !quiet && logFormat == logFormatText && isTerminal(os.Stderr) {
+		downloadOpts = append(downloadOpts, download.WithProgressLog(progressInterval, func() int64 {
+			return pwf.Stats().QueuedDownloadSize
+		}))
+	}
+	downloadStart := time.Now()
+	dwf := download.NewWorkerFleet(workCtx, logger, downloadClient, downloadConcurrency, pwf.DownloadJobChannel(), downloadOpts...)
+	if debugAddr != "" {
+		stopDebug, err := serveDebug(ctx, logger, debugAddr, pwf, dwf)
+		if err != nil {
+			logger.LogAttrs(ctx, slog.LevelWarn, "Failed to start debug endpoint",
+				slog.String("addr", debugAddr),
+				tint.Err(err),
+			)
+		} else {
+			defer stopDebug()
+		}
+	}
+	planOpts := planOptions{
+		optionalFiles:                  optionalFiles,
+		clientIgnoreCurseForgeProjects: clientIgnoreCurseForgeProjects,
+		serverIgnoreCurseForgeProjects: serverIgnoreCurseForgeProjects,
+		migrationMode:                  migrationMode,
+		userAgent:                      userAgent,
+		mapPath:                        mapPath,
+		filter:                         filter,
+		noClobberFilter:                noClobberFilter,
+		maxFileSize:                    int64(maxFileSize),
+		pruneDirs:                      pruneDirs,
+		dryRun:                         dryRun,
+		downloadArt:                    downloadArt,
+	}
+	if curseforgeAPIKey != "" {
+		planOpts.curseForge = cfapi.NewClient(curseforgeAPIKey, cfapi.WithHTTPClient(httpClient))
+	}
+	if failFast {
+		planOpts.failFast = cancelRun
+	}
+	pjs, roots, conflicts := planPacks(ctx, logger, packs, planOpts)
+
+	if plan != nil {
+		if pjs, err = plan.PrecheckJobs(); err != nil {
+			logger.LogAttrs(ctx, slog.LevelError, "Invalid plan",
+				slog.String("path", resumePlanPath),
+				tint.Err(err),
+			)
+			os.Exit(1)
+		}
+		roots = plan.Roots
+		logger.LogAttrs(ctx, slog.LevelInfo, "Resuming planned install",
+			slog.String("path", resumePlanPath),
+			slog.Int("fileCount", len(pjs)),
+		)
+	}
+
+	if conflicts > 0 {
+		logger.LogAttrs(ctx, slog.LevelError, "Refusing to install files with conflicting destination paths", slog.Int("conflicts", conflicts))
+		os.Exit(1)
+	}
 
-	for i := range versionManifest.Files {
-		file := &versionManifest.Files[i]
-		pj, ok, err := file.PrecheckJob(migrateFromPath, clientPath, serverPath, serverIgnoreCurseForgeProjects, preserveMigrationSource)
+	if savePlanPath != "" {
+		p, err := precheck.NewPlan(pjs, roots)
+		if err == nil {
+			err = p.Save(savePlanPath)
+		}
 		if err != nil {
-			logger.LogAttrs(ctx, slog.LevelWarn, "Failed to create precheck job",
-				slog.Int64("modpackID", versionManifest.Parent),
-				slog.Int64("versionID", versionManifest.ID),
-				slog.String("name", file.Name),
-				slog.String("path", file.Path),
+			logger.LogAttrs(ctx, slog.LevelError, "Failed to save plan",
+				slog.String("path", savePlanPath),
 				tint.Err(err),
 			)
-			continue
+			os.Exit(1)
+		}
+		logger.LogAttrs(ctx, slog.LevelInfo, "Saved plan",
+			slog.String("path", savePlanPath),
+			slog.Int("fileCount", len(pjs)),
+		)
+	}
+
+	if !dryRun && !verifyOnly && !ignoreDiskSpace {
+		if !checkDiskSpace(ctx, logger, pjs, roots, freeSpace) {
+			os.Exit(1)
+		}
+	}
+
+	stopProgress := func() {}
+	if progress != nil {
+		stop := make(chan struct{})
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			runProgressLine(progress, stop, len(pjs), pwf, dwf)
+		}()
+		stopProgress = func() {
+			close(stop)
+			<-done
 		}
-		if !ok {
-			continue
+	}
+
+	if report != nil {
+		report.add(pjs)
+	}
+
+	for _, pj := range pjs {
+		pj.DryRun = dryRun
+		pj.VerifyOnly = verifyOnly
+		pj.UsePartFile = usePartFiles
+		if sigVerifier != nil {
+			pj.Verify = sigVerifier.verifyFunc(pj.DownloadURL, pj.UserAgent)
 		}
 		pjch <- pj
 	}
@@ -155,6 +747,150 @@ func main() {
 	close(pjch)
 	pwf.Wait()
 	dwf.Wait()
+	stopProgress()
+	downloadElapsed := time.Since(downloadStart)
+
+	if journal != nil {
+		if err = journal.Save(journalPath); err != nil {
+			logger.LogAttrs(ctx, slog.LevelWarn, "Failed to save journal",
+				slog.String("path", journalPath),
+				tint.Err(err),
+			)
+		}
+	}
+
+	if pruneExtraneous && !verifyOnly && ctx.Err() == nil {
+		prunePacks(ctx, logger, packs)
+	}
+
+	completed := !dryRun && !verifyOnly && ctx.Err() == nil
+	if writeManifest && completed {
+		writeCurseForgeManifests(ctx, logger, packs)
+	}
+
+	// Failures are not tracked per pack, so no lockfile is written if any file failed.
+	succeeded := completed && pwf.Failures() == 0 && dwf.Failures() == 0
+	if succeeded {
+		writeLockFiles(ctx, logger, packs)
+	}
+
+	reportFailed := report != nil && !writeReport(ctx, logger, report, reportPath, reportFormat, len(pjs))
+	zipFailed := zipOutput != "" && clientPath != "" && succeeded && !writeClientZip(ctx, logger, zipOutput, clientPath, pjs)
+
+	summary := newRunSummary(pwf.Stats(), dwf.Stats(), downloadElapsed)
+	if logFormat == logFormatJSON {
+		if err = summary.writeJSON(os.Stdout); err != nil {
+			logger.LogAttrs(ctx, slog.LevelError, "Failed to write run summary", tint.Err(err))
+		}
+	} else {
+		summary.log(ctx, logger)
+	}
+
+	if verifyOnly {
+		stats := pwf.Stats()
+		logger.LogAttrs(ctx, slog.LevelInfo, "Verification complete",
+			slog.Int64("passed", stats.Skipped),
+			slog.Int64("failed", stats.Failed),
+		)
+	} else if dryRun {
+		logger.LogAttrs(ctx, slog.LevelInfo, "Dry run complete",
+			slog.Int64("downloadSize", pwf.Stats().QueuedDownloadSize),
+		)
+	}
+
+	if errors.Is(context.Cause(ctx), errRunTimeout) {
+		logger.LogAttrs(ctx, slog.LevelError, "Run timed out before completion", slog.Duration("timeout", timeout))
+		os.Exit(1)
+	}
+
+	if err := context.Cause(ctx); errors.Is(err, errFailFast) {
+		logger.LogAttrs(ctx, slog.LevelError, "Run aborted on first failure", tint.Err(err))
+		os.Exit(1)
+	}
+
+	if zipFailed || reportFailed {
+		os.Exit(1)
+	}
+
+	if precheckFailures, downloadFailures := pwf.Failures(), dwf.Failures(); precheckFailures > 0 || downloadFailures > 0 {
+		logger.LogAttrs(ctx, slog.LevelError, "Some files failed",
+			slog.Int("precheckFailures", precheckFailures),
+			slog.Int("downloadFailures", downloadFailures),
+		)
+		os.Exit(1)
+	}
+}
+
+var errRunTimeout = errors.New("run timed out")
+
+// isTerminal returns whether f is a terminal.
+func isTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
+// writeVersionManifest writes the version manifest to the file at path,
+// or to stdout if path is empty.
+func writeVersionManifest(path string, manifest *modpacksch.ModpackVersionManifest) error {
+	if path == "" {
+		return printVersionManifest(os.Stdout, manifest)
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err = printVersionManifest(f, manifest); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Supported values of the -logFormat flag.
+const (
+	logFormatText = "text"
+	logFormatJSON = "json"
+)
+
+// newStderrHandler returns the handler for the logs written to w, which is stderr, in the given format.
+//
+// In quiet mode, only warnings and errors are logged. If the logs are text on a terminal,
+// they are then written through the returned progress line, for the caller to keep updated.
+// JSON logs are for machines, so no progress line is ever mixed into them.
+func newStderrHandler(w io.Writer, format string, level slog.Level, quiet, terminal bool) (slog.Handler, *progressLine, error) {
+	if quiet {
+		level = max(level, slog.LevelWarn)
+	}
+
+	switch format {
+	case logFormatText:
+		var progress *progressLine
+		if quiet && terminal {
+			progress = &progressLine{w: w}
+			w = progress
+		}
+		return tint.NewHandler(w, &tint.Options{Level: level}), progress, nil
+	case logFormatJSON:
+		return slog.NewJSONHandler(w, &slog.HandlerOptions{Level: level}), nil, nil
+	default:
+		return nil, nil, fmt.Errorf("unknown log format: %q", format)
+	}
+}
+
+const (
+	// apiTokenEnv is the environment variable that supplies the default API token.
+	apiTokenEnv = "MODPACKSCH_TOKEN"
+
+	// curseforgeAPIKeyEnv is the environment variable that supplies the default CurseForge API key.
+	curseforgeAPIKeyEnv = "CURSEFORGE_API_KEY"
+)
+
+// logAuthHint logs how to supply an API token if err indicates that one is required.
+func logAuthHint(ctx context.Context, logger *slog.Logger, err error) {
+	if errors.Is(err, modpacksch.ErrAuthRequired) {
+		logger.LogAttrs(ctx, slog.LevelError, "Supply an API token with '-apiToken' or the "+apiTokenEnv+" environment variable")
+	}
 }
 
 // int64s implements [flag.Value].
@@ -202,3 +938,98 @@ func (i *int64s) Set(value string) error {
 	*i = dst
 	return nil
 }
+
+// strs implements [flag.Value].
+type strs []string
+
+// String returns the strs as a comma-separated list.
+func (s strs) String() string {
+	return strings.Join(s, ",")
+}
+
+// Set parses value as a comma-separated list of strings.
+// Empty elements are ignored.
+func (s *strs) Set(value string) error {
+	for _, elem := range strings.Split(value, ",") {
+		if elem = strings.TrimSpace(elem); elem != "" {
+			*s = append(*s, elem)
+		}
+	}
+	return nil
+}
+
+// byteSize implements [flag.Value].
+type byteSize int64
+
+// byteSizeUnits maps unit suffixes to their sizes in bytes.
+var byteSizeUnits = [...]struct {
+	suffix string
+	size   int64
+}{
+	{"KiB", 1 << 10},
+	{"MiB", 1 << 20},
+	{"GiB", 1 << 30},
+	{"TiB", 1 << 40},
+	{"KB", 1e3},
+	{"MB", 1e6},
+	{"GB", 1e9},
+	{"TB", 1e12},
+	{"K", 1 << 10},
+	{"M", 1 << 20},
+	{"G", 1 << 30},
+	{"T", 1 << 40},
+	{"B", 1},
+}
+
+// String returns the size in bytes.
+func (b byteSize) String() string {
+	return strconv.FormatInt(int64(b), 10)
+}
+
+// Set parses value as a size in bytes with an optional unit suffix, e.g. "10MiB".
+func (b *byteSize) Set(value string) error {
+	value = strings.TrimSpace(value)
+	unit := int64(1)
+	for _, u := range byteSizeUnits {
+		if s, ok := strings.CutSuffix(value, u.suffix); ok {
+			value = strings.TrimSpace(s)
+			unit = u.size
+			break
+		}
+	}
+
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return err
+	}
+	if n < 0 {
+		return fmt.Errorf("negative size: %d", n)
+	}
+	if n > math.MaxInt64/unit {
+		return fmt.Errorf("size too large: %d * %d", n, unit)
+	}
+
+	*b = byteSize(n * unit)
+	return nil
+}
+
+// fileMode implements [flag.Value].
+type fileMode os.FileMode
+
+// String returns the permission bits in octal.
+func (m fileMode) String() string {
+	return fmt.Sprintf("%#o", uint32(m))
+}
+
+// Set parses value as permission bits in octal, e.g. "0644".
+func (m *fileMode) Set(value string) error {
+	n, err := strconv.ParseUint(value, 8, 32)
+	if err != nil {
+		return err
+	}
+	if n == 0 || n > uint64(os.ModePerm) {
+		return fmt.Errorf("invalid permission bits: %#o", n)
+	}
+	*m = fileMode(n)
+	return nil
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/database64128/modpack-dl-go/download"
+	"github.com/database64128/modpack-dl-go/modpacksch"
+	"github.com/database64128/modpack-dl-go/precheck"
+)
+
+// testLogger discards all logs.
+var testLogger = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+// runFleets runs the precheck jobs through a precheck fleet and a download fleet
+// wired up as in main, and returns the fleets once both are done.
+func runFleets(ctx context.Context, pjs []precheck.Job, precheckOpts []precheck.Option, downloadOpts []download.Option) (*precheck.WorkerFleet, *download.WorkerFleet) {
+	pjch := make(chan precheck.Job)
+	pwf := precheck.NewWorkerFleet(ctx, testLogger, 2, pjch, precheckOpts...)
+	dwf := download.NewWorkerFleet(ctx, testLogger, http.DefaultClient, 2, pwf.DownloadJobChannel(), downloadOpts...)
+	for _, pj := range pjs {
+		pjch <- pj
+	}
+	close(pjch)
+	pwf.Wait()
+	dwf.Wait()
+	return pwf, dwf
+}
+
+func TestRunTimeoutAbortsSlowDownloads(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header()["Content-Length"] = []string{"10"}
+		w.WriteHeader(http.StatusOK)
+		w.(http.Flusher).Flush()
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer srv.Close()
+
+	sum := sha1.Sum(make([]byte, 10))
+	pj := precheck.Job{
+		DownloadURL:     srv.URL,
+		DestinationPath: filepath.Join(t.TempDir(), "slow.bin"),
+		NewHash:         sha1.New,
+		Sum:             sum[:],
+		Size:            10,
+	}
+
+	const timeout = 100 * time.Millisecond
+	ctx, cancel := context.WithTimeoutCause(context.Background(), timeout, errRunTimeout)
+	defer cancel()
+
+	start := time.Now()
+	_, dwf := runFleets(ctx, []precheck.Job{pj}, nil, nil)
+	if elapsed := time.Since(start); elapsed > 10*time.Second {
+		t.Errorf("run took %v with a timeout of %v", elapsed, timeout)
+	}
+	if !errors.Is(context.Cause(ctx), errRunTimeout) {
+		t.Errorf("context.Cause() = %v, want %v", context.Cause(ctx), errRunTimeout)
+	}
+	if got := dwf.Failures(); got != 1 {
+		t.Errorf("download failures = %d, want 1", got)
+	}
+}
+
+func TestUserAgentReachesAPIAndDownloads(t *testing.T) {
+	const userAgent = "test-agent/1.0"
+	content := []byte("mod content\n")
+	sum := sha1.Sum(content)
+
+	var (
+		mu         sync.Mutex
+		userAgents = make(map[string]string)
+		srv        *httptest.Server
+	)
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		userAgents[r.URL.Path] = r.UserAgent()
+		mu.Unlock()
+		switch r.URL.Path {
+		case "/public/modpack/42/100":
+			w.Header()["Content-Type"] = []string{"application/json"}
+			_, _ = w.Write([]byte(`{"id":100,"files":[{"path":"./mods/","name":"a.jar","url":"` + srv.URL + `/files/a.jar","sha1":"` + hex.EncodeToString(sum[:]) + `","size":` + fmt.Sprint(len(content)) + `}]}`))
+		case "/files/a.jar":
+			_, _ = w.Write(content)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	client := modpacksch.NewPublicModpackClient(modpacksch.WithBaseURL(srv.URL), modpacksch.WithUserAgent(userAgent))
+	manifest, err := client.GetModpackVersionManifest(context.Background(), 42, 100)
+	if err != nil {
+		t.Fatalf("GetModpackVersionManifest() error = %v", err)
+	}
+	if len(manifest.Files) != 1 {
+		t.Fatalf("got %d files, want 1", len(manifest.Files))
+	}
+
+	clientPath := t.TempDir()
+	pj, ok, err := manifest.Files[0].PrecheckJob(modpacksch.PrecheckOptions{ClientPath: clientPath, MigrationMode: precheck.MigrationModeMove, UserAgent: userAgent})
+	if err != nil || !ok {
+		t.Fatalf("PrecheckJob() = %v, %v", ok, err)
+	}
+	if _, dwf := runFleets(context.Background(), []precheck.Job{pj}, nil, nil); dwf.Failures() != 0 {
+		t.Fatalf("download failures = %d, want 0", dwf.Failures())
+	}
+	if got, err := os.ReadFile(filepath.Join(clientPath, "mods", "a.jar")); err != nil || string(got) != string(content) {
+		t.Errorf("downloaded file = %q, %v, want %q", got, err, content)
+	}
+
+	for _, path := range []string{"/public/modpack/42/100", "/files/a.jar"} {
+		if got := userAgents[path]; got != userAgent {
+			t.Errorf("User-Agent of %s = %q, want %q", path, got, userAgent)
+		}
+	}
+}
+
+func TestDownloadArt(t *testing.T) {
+	icon := []byte("icon image\n")
+	splash := []byte("splash image\n")
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/art/icon.png":
+			_, _ = w.Write(icon)
+		case "/art/splash.jpg":
+			// Not what the manifest says.
+			_, _ = w.Write([]byte("tampered image\n"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	iconSum := sha1.Sum(icon)
+	splashSum := sha1.Sum(splash)
+	var m modpacksch.ModpackManifest
+	if err := json.Unmarshal([]byte(`{"id": 42, "art": [
+		{"id": 1, "type": "square", "url": "`+srv.URL+`/art/icon.png", "sha1": "`+hex.EncodeToString(iconSum[:])+`", "size": `+fmt.Sprint(len(icon))+`},
+		{"id": 2, "type": "splash", "url": "`+srv.URL+`/art/splash.jpg", "sha1": "`+hex.EncodeToString(splashSum[:])+`", "size": `+fmt.Sprint(len(splash))+`}
+	]}`), &m); err != nil {
+		t.Fatal(err)
+	}
+
+	artPath := filepath.Join(t.TempDir(), ".art")
+	var pjs []precheck.Job
+	for i := range m.Art {
+		pj, err := m.Art[i].PrecheckJob(artPath, "")
+		if err != nil {
+			t.Fatalf("PrecheckJob() error = %v", err)
+		}
+		pjs = append(pjs, pj)
+	}
+
+	if _, dwf := runFleets(context.Background(), pjs, nil, nil); dwf.Failures() != 1 {
+		t.Errorf("download failures = %d, want 1 for the tampered art", dwf.Failures())
+	}
+	if got, err := os.ReadFile(filepath.Join(artPath, "square-1.png")); err != nil || string(got) != string(icon) {
+		t.Errorf("square-1.png = %q, %v, want %q", got, err, icon)
+	}
+	if got, err := os.ReadFile(filepath.Join(artPath, "splash-2.jpg")); err == nil && string(got) == "tampered image\n" {
+		t.Error("tampered art was kept")
+	}
+}
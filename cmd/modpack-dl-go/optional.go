@@ -0,0 +1,40 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/database64128/modpack-dl-go/modpacksch"
+)
+
+// Supported values of the -optionalFiles flag.
+const (
+	optionalFilesDefault = "default"
+	optionalFilesAll     = "all"
+	optionalFilesNone    = "none"
+)
+
+// validateOptionalFiles returns an error if mode is not a supported value of the -optionalFiles flag.
+func validateOptionalFiles(mode string) error {
+	switch mode {
+	case optionalFilesDefault, optionalFilesAll, optionalFilesNone:
+		return nil
+	default:
+		return fmt.Errorf("unknown optional files mode %q, expected '%s', '%s', or '%s'", mode, optionalFilesDefault, optionalFilesAll, optionalFilesNone)
+	}
+}
+
+// optionalFileSelected returns whether the file is selected for download in the given -optionalFiles mode.
+// Required files are always selected.
+func optionalFileSelected(mode string, file *modpacksch.ModpackVersionFile) bool {
+	if !file.Optional {
+		return true
+	}
+	switch mode {
+	case optionalFilesAll:
+		return true
+	case optionalFilesNone:
+		return false
+	default:
+		return file.SelectedByDefault()
+	}
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestOptionalFileSelected(t *testing.T) {
+	manifest := testVersionManifest(t, `{
+		"id": 100,
+		"files": [
+			{"path": "./mods/", "name": "required.jar", "sha1": "00", "size": 1},
+			{"path": "./mods/", "name": "optional-on.jar", "sha1": "11", "size": 1, "optional": true, "default": true},
+			{"path": "./mods/", "name": "optional-off.jar", "sha1": "22", "size": 1, "optional": true, "default": false},
+			{"path": "./mods/", "name": "optional-unspecified.jar", "sha1": "33", "size": 1, "optional": true}
+		]
+	}`)
+
+	for _, c := range []struct {
+		mode string
+		want []bool
+	}{
+		{optionalFilesDefault, []bool{true, true, false, true}},
+		{optionalFilesAll, []bool{true, true, true, true}},
+		{optionalFilesNone, []bool{true, false, false, false}},
+	} {
+		t.Run(c.mode, func(t *testing.T) {
+			for i := range manifest.Files {
+				f := &manifest.Files[i]
+				if got := optionalFileSelected(c.mode, f); got != c.want[i] {
+					t.Errorf("optionalFileSelected(%q, %s) = %v, want %v", c.mode, f.Name, got, c.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestValidateOptionalFiles(t *testing.T) {
+	for _, mode := range []string{optionalFilesDefault, optionalFilesAll, optionalFilesNone} {
+		if err := validateOptionalFiles(mode); err != nil {
+			t.Errorf("validateOptionalFiles(%q) error = %v", mode, err)
+		}
+	}
+	if err := validateOptionalFiles("some"); err == nil {
+		t.Error("validateOptionalFiles() with an unknown mode succeeded")
+	}
+}
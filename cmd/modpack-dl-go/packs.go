@@ -0,0 +1,499 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/database64128/modpack-dl-go/modpacksch"
+	"github.com/database64128/modpack-dl-go/precheck"
+	"github.com/database64128/modpack-dl-go/prune"
+	"github.com/lmittmann/tint"
+)
+
+// packRef identifies a modpack version to install.
+type packRef struct {
+	ModpackID int64
+
+	// VersionID is zero for the latest version.
+	VersionID int64
+}
+
+// packRefs implements [flag.Value].
+type packRefs []packRef
+
+// String returns the packRefs as a comma-separated list of 'id:version' pairs.
+func (r packRefs) String() string {
+	var b strings.Builder
+	for i, ref := range r {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(strconv.FormatInt(ref.ModpackID, 10))
+		if ref.VersionID != 0 {
+			b.WriteByte(':')
+			b.WriteString(strconv.FormatInt(ref.VersionID, 10))
+		}
+	}
+	return b.String()
+}
+
+// Set parses value as a comma-separated list of modpack IDs, each optionally followed
+// by a colon and a version ID, and appends them to the list.
+func (r *packRefs) Set(value string) error {
+	for _, s := range strings.Split(value, ",") {
+		id, version, hasVersion := strings.Cut(s, ":")
+
+		var (
+			ref packRef
+			err error
+		)
+		if ref.ModpackID, err = strconv.ParseInt(id, 10, 64); err != nil || ref.ModpackID <= 0 {
+			return fmt.Errorf("invalid modpack ID %q", id)
+		}
+		if hasVersion {
+			if ref.VersionID, err = strconv.ParseInt(version, 10, 64); err != nil || ref.VersionID <= 0 {
+				return fmt.Errorf("invalid version ID %q for modpack %d", version, ref.ModpackID)
+			}
+		}
+		*r = append(*r, ref)
+	}
+	return nil
+}
+
+// checkMultiPackFlags returns an error if a modpack is listed more than once,
+// or flags that only apply to a single modpack are combined with '-modpacks'.
+func checkMultiPackFlags(refs packRefs) error {
+	seen := make(map[int64]struct{}, len(refs))
+	for _, ref := range refs {
+		if _, ok := seen[ref.ModpackID]; ok {
+			return fmt.Errorf("modpack %d is listed more than once in '-modpacks'", ref.ModpackID)
+		}
+		seen[ref.ModpackID] = struct{}{}
+	}
+
+	for _, f := range [...]struct {
+		name string
+		set  bool
+	}{
+		{"modpackID", modpackID != 0},
+		{"versionID", versionID != 0},
+		{"search", searchTerm != ""},
+		{"checkUpdate", checkUpdate != 0},
+		{"listVersions", listVersions},
+		{"manifestOnly", manifestOnly},
+		{"validateManifest", validateManifest},
+		{"diffVersion", diffVersion != 0},
+		{"zipOutput", zipOutput != ""},
+	} {
+		if f.set {
+			return fmt.Errorf("'-%s' cannot be combined with '-modpacks'", f.name)
+		}
+	}
+	return nil
+}
+
+// pack is a modpack version being installed, along with where it is installed to.
+type pack struct {
+	modpackManifest modpacksch.ModpackManifest
+	versionManifest modpacksch.ModpackVersionManifest
+
+	clientPath      string
+	serverPath      string
+	migrateFromPath string
+	artPath         string
+
+	lock   lockFile
+	pruner prune.Pruner
+	pjs    []precheck.Job
+}
+
+// roots returns the non-empty client and server paths of the pack.
+func (p *pack) roots() []string {
+	roots := make([]string, 0, 2)
+	for _, root := range [...]string{p.clientPath, p.serverPath} {
+		if root != "" {
+			roots = append(roots, root)
+		}
+	}
+	return roots
+}
+
+// lockDir returns the directory the pack's lockfile is kept in.
+func (p *pack) lockDir() string {
+	if p.clientPath != "" {
+		return p.clientPath
+	}
+	return p.serverPath
+}
+
+// fetchModpackManifest gets the manifest of the modpack from the selected provider,
+// falling back to the other provider if fallback is true and the modpack is not found.
+// It returns the provider the manifest came from, and false if it failed, after logging the error.
+func fetchModpackManifest(ctx context.Context, logger *slog.Logger, selected, other modpackProvider, fallback bool, modpackID int64) (modpackProvider, modpacksch.ModpackManifest, bool) {
+	provider, modpackManifest, err := withProviderFallback(ctx, logger, selected, other, fallback, modpackID, func(client modpacksch.ModpackClient) (modpacksch.ModpackManifest, error) {
+		return client.GetModpackManifest(ctx, modpackID)
+	})
+	if err != nil {
+		if errors.As(err, new(*modpacksch.NotFoundError)) {
+			logger.LogAttrs(ctx, slog.LevelError, "Modpack ID not found", slog.Int64("modpackID", modpackID))
+			return provider, modpackManifest, false
+		}
+		logger.LogAttrs(ctx, slog.LevelError, "Failed to get modpack manifest",
+			slog.Int64("modpackID", modpackID),
+			tint.Err(err),
+		)
+		logAuthHint(ctx, logger, err)
+		return provider, modpackManifest, false
+	}
+
+	logger.LogAttrs(ctx, slog.LevelInfo, "Got modpack manifest",
+		slog.Int64("modpackID", modpackManifest.ID),
+		slog.String("provider", provider.name),
+		slog.String("name", modpackManifest.Name),
+		slog.String("synopsis", modpackManifest.Synopsis),
+		slog.Any("versions", modpackManifest.Versions),
+	)
+	return provider, modpackManifest, true
+}
+
+// fetchVersionManifest gets the manifest of the modpack version, or of the latest version
+// in modpackManifest if versionID is zero. It returns false if it failed, after logging the error.
+func fetchVersionManifest(ctx context.Context, logger *slog.Logger, client modpacksch.ModpackClient, modpackManifest *modpacksch.ModpackManifest, modpackID, versionID int64) (modpacksch.ModpackVersionManifest, bool) {
+	if versionID == 0 {
+		version, ok := modpackManifest.LatestVersion()
+		if !ok {
+			logger.LogAttrs(ctx, slog.LevelError, "Modpack has no versions")
+			return modpacksch.ModpackVersionManifest{}, false
+		}
+		versionID = version.ID
+	}
+
+	versionManifest, err := client.GetModpackVersionManifest(ctx, modpackID, versionID)
+	if err != nil {
+		if errors.As(err, new(*modpacksch.NotFoundError)) {
+			logger.LogAttrs(ctx, slog.LevelError, "Modpack version ID not found",
+				slog.Int64("modpackID", modpackID),
+				slog.Int64("versionID", versionID),
+			)
+			return versionManifest, false
+		}
+		logger.LogAttrs(ctx, slog.LevelError, "Failed to get modpack version manifest",
+			slog.Int64("modpackID", modpackID),
+			slog.Int64("versionID", versionID),
+			tint.Err(err),
+		)
+		logAuthHint(ctx, logger, err)
+		return versionManifest, false
+	}
+
+	logger.LogAttrs(ctx, slog.LevelInfo, "Got modpack version manifest",
+		slog.Int64("modpackID", versionManifest.Parent),
+		slog.Int64("versionID", versionManifest.ID),
+		slog.String("name", versionManifest.Name),
+		slog.String("type", versionManifest.Type),
+		slog.Time("updated", versionManifest.Updated.Time),
+		slog.Int("fileCount", len(versionManifest.Files)),
+		slog.Any("targets", versionManifest.Targets),
+	)
+	return versionManifest, true
+}
+
+// fetchPacks gets the manifests of each modpack version to install, and prints their changelogs
+// if '-showChangelog' is set. It returns false if any of them failed, after logging the error.
+func fetchPacks(ctx context.Context, logger *slog.Logger, selected, other modpackProvider, fallback bool, refs packRefs) ([]*pack, bool) {
+	packs := make([]*pack, 0, len(refs))
+	for _, ref := range refs {
+		provider, modpackManifest, ok := fetchModpackManifest(ctx, logger, selected, other, fallback, ref.ModpackID)
+		if !ok {
+			return nil, false
+		}
+
+		versionManifest, ok := fetchVersionManifest(ctx, logger, provider.client, &modpackManifest, ref.ModpackID, ref.VersionID)
+		if !ok {
+			return nil, false
+		}
+
+		if showChangelog {
+			if err := printChangelog(os.Stdout, versionManifest.Changelog, plainChangelog); err != nil {
+				logger.LogAttrs(ctx, slog.LevelError, "Failed to print changelog", tint.Err(err))
+				return nil, false
+			}
+		}
+
+		packs = append(packs, &pack{
+			modpackManifest: modpackManifest,
+			versionManifest: versionManifest,
+			lock:            newLockFile(ref.ModpackID, &versionManifest),
+		})
+	}
+	return packs, true
+}
+
+// setPaths sets the paths of each pack.
+//
+// A single pack uses the given paths as is. When installing multiple packs, each non-empty path
+// is treated as a root, and each pack is installed to its own subdirectory of each root, as
+// named by [packDirNames].
+func setPaths(packs []*pack, clientPath, serverPath, migrateFromPath, artPath string) {
+	if len(packs) == 1 {
+		p := packs[0]
+		p.clientPath = clientPath
+		p.serverPath = serverPath
+		p.migrateFromPath = migrateFromPath
+		p.artPath = artPath
+		return
+	}
+
+	join := func(root, dir string) string {
+		if root == "" {
+			return ""
+		}
+		return filepath.Join(root, dir)
+	}
+
+	manifests := make([]*modpacksch.ModpackManifest, len(packs))
+	for i, p := range packs {
+		manifests[i] = &p.modpackManifest
+	}
+	for i, dir := range packDirNames(manifests) {
+		p := packs[i]
+		p.clientPath = join(clientPath, dir)
+		p.serverPath = join(serverPath, dir)
+		p.migrateFromPath = join(migrateFromPath, dir)
+		p.artPath = join(artPath, dir)
+	}
+}
+
+// packDirNames returns the name of the directory to install each modpack to.
+//
+// The name is derived from the modpack's name, with characters that are not safe in file names
+// on any platform replaced with underscores. Modpacks without a usable name are named by their ID.
+// If several modpacks end up with the same name, the ID is appended to all of them
+// but the first, so the directories stay distinct.
+func packDirNames(manifests []*modpacksch.ModpackManifest) []string {
+	names := make([]string, len(manifests))
+	seen := make(map[string]struct{}, len(manifests))
+	for i, m := range manifests {
+		id := strconv.FormatInt(m.ID, 10)
+		name := sanitizeDirName(m.Name)
+		if name == "" {
+			name = id
+		}
+		if _, ok := seen[strings.ToLower(name)]; ok {
+			name += "-" + id
+		}
+		seen[strings.ToLower(name)] = struct{}{}
+		names[i] = name
+	}
+	return names
+}
+
+// sanitizeDirName returns name with characters other than letters, digits, spaces,
+// and "-_.+()" replaced with underscores, and leading and trailing spaces and dots removed.
+// It returns an empty string if nothing is left, or if the result is not a local path,
+// such as a reserved device name on Windows.
+func sanitizeDirName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		case strings.ContainsRune(" -_.+()", r):
+			return r
+		case r > 0x7f && strconv.IsPrint(r):
+			return r
+		default:
+			return '_'
+		}
+	}, name)
+	name = strings.Trim(name, " .")
+	if !filepath.IsLocal(name) {
+		return ""
+	}
+	return name
+}
+
+// planOptions controls how [planPacks] turns the files of packs into precheck jobs.
+type planOptions struct {
+	optionalFiles                  string
+	clientIgnoreCurseForgeProjects []int64
+	serverIgnoreCurseForgeProjects []int64
+	migrationMode                  precheck.MigrationMode
+	userAgent                      string
+	mapPath                        modpacksch.PathMapper
+	maxFileSize                    int64
+	pruneDirs                      []string
+	dryRun                         bool
+	downloadArt                    bool
+
+	// filter, if not nil, selects the files to install.
+	// Files it filters out are protected from pruning.
+	filter *pathFilter
+
+	// noClobberFilter, if not nil, selects the files that are never overwritten.
+	noClobberFilter *pathFilter
+
+	// curseForge, if not nil, resolves the download URLs of CurseForge files.
+	curseForge modpacksch.CurseForgeURLResolver
+
+	// failFast, if not nil, is called on the first file that cannot be planned for,
+	// and stops planning the rest of its pack.
+	failFast context.CancelCauseFunc
+}
+
+// planPacks sets up the pruner and precheck jobs of each pack, whose paths must have been set.
+// It returns the precheck jobs of all packs, the roots they install to, and the number of
+// files that conflict at their destination paths, which are logged and left out.
+func planPacks(ctx context.Context, logger *slog.Logger, packs []*pack, opts planOptions) (pjs []precheck.Job, roots []string, conflicts int) {
+	for _, p := range packs {
+		p.pruner = prune.Pruner{
+			ManagedDirs: opts.pruneDirs,
+			DryRun:      opts.dryRun,
+		}
+
+		// keepManifestPath protects the file at its path in the manifest from being pruned.
+		keepManifestPath := func(file *modpacksch.ModpackVersionFile) {
+			if filepath.IsLocal(file.Path) && filepath.IsLocal(file.Name) {
+				for _, root := range p.roots() {
+					p.pruner.Keep(filepath.Join(root, file.Path, file.Name))
+				}
+			}
+		}
+
+		// Errors are logged as each file is planned.
+		p.pjs, _ = modpacksch.BuildPrecheckJobs(&p.versionManifest, modpacksch.PrecheckOptions{
+			MigrateFromPath:                p.migrateFromPath,
+			ClientPath:                     p.clientPath,
+			ServerPath:                     p.serverPath,
+			ClientIgnoreCurseForgeProjects: opts.clientIgnoreCurseForgeProjects,
+			ServerIgnoreCurseForgeProjects: opts.serverIgnoreCurseForgeProjects,
+			MigrationMode:                  opts.migrationMode,
+			UserAgent:                      opts.userAgent,
+			MapPath:                        opts.mapPath,
+			MaxSize:                        opts.maxFileSize,
+			Select: func(file *modpacksch.ModpackVersionFile) bool {
+				if !optionalFileSelected(opts.optionalFiles, file) {
+					logger.LogAttrs(ctx, slog.LevelDebug, "Skipping optional file",
+						slog.String("path", file.Path),
+						slog.String("name", file.Name),
+					)
+					// Never prune what the user deselected.
+					keepManifestPath(file)
+					return false
+				}
+				if opts.curseForge != nil {
+					if err := file.ResolveCurseForgeURL(ctx, opts.curseForge); err != nil {
+						logger.LogAttrs(ctx, slog.LevelWarn, "Failed to resolve CurseForge download URL, falling back to guessed URL",
+							slog.String("path", file.Path),
+							slog.String("name", file.Name),
+							tint.Err(err),
+						)
+					}
+				}
+				return true
+			},
+			Planned: func(pf *modpacksch.PlannedFile) bool {
+				file := pf.File
+				switch {
+				case pf.Previous != nil && pf.Err != nil:
+					logger.LogAttrs(ctx, slog.LevelError, "Files in manifest conflict at destination path",
+						slog.Int64("modpackID", p.versionManifest.Parent),
+						slog.Int64("versionID", p.versionManifest.ID),
+						slog.String("path", file.ManifestPath()),
+						slog.String("sum", file.ExpectedSum()),
+						slog.String("otherPath", pf.Previous.ManifestPath()),
+						slog.String("otherSum", pf.Previous.ExpectedSum()),
+						tint.Err(pf.Err),
+					)
+					conflicts++
+
+				case pf.Err != nil:
+					logger.LogAttrs(ctx, slog.LevelWarn, "Failed to create precheck job",
+						slog.Int64("modpackID", p.versionManifest.Parent),
+						slog.Int64("versionID", p.versionManifest.ID),
+						slog.String("name", file.Name),
+						slog.String("path", file.Path),
+						tint.Err(pf.Err),
+					)
+					// Never prune what we failed to plan for.
+					keepManifestPath(file)
+					if opts.failFast != nil {
+						opts.failFast(fmt.Errorf("%w: %s: %w", errFailFast, file.ManifestPath(), pf.Err))
+						return false
+					}
+
+				case !pf.OK:
+					// Never prune what the user filtered out.
+					if opts.filter != nil && !opts.filter.match(file.ManifestPath()) {
+						keepManifestPath(file)
+					}
+
+				case pf.Previous != nil:
+					logger.LogAttrs(ctx, slog.LevelWarn, "Skipping duplicate file in manifest",
+						slog.String("path", file.ManifestPath()),
+						slog.String("duplicateOf", pf.Previous.ManifestPath()),
+					)
+
+				default:
+					pf.Job.NoClobber = opts.noClobberFilter != nil && opts.noClobberFilter.match(file.ManifestPath())
+					p.pruner.Keep(pf.Job.DestinationPath)
+					if pf.Job.SecondaryDestinationPath != "" {
+						p.pruner.Keep(pf.Job.SecondaryDestinationPath)
+					}
+				}
+				return true
+			},
+		})
+
+		if opts.downloadArt {
+			if p.artPath == "" {
+				p.artPath = filepath.Join(p.lockDir(), ".art")
+			}
+
+			for i := range p.modpackManifest.Art {
+				art := &p.modpackManifest.Art[i]
+				if art.Compressed {
+					continue
+				}
+
+				pj, err := art.PrecheckJob(p.artPath, opts.userAgent)
+				if err != nil {
+					logger.LogAttrs(ctx, slog.LevelWarn, "Failed to create precheck job for art",
+						slog.Int64("modpackID", p.modpackManifest.ID),
+						slog.Int64("artID", art.ID),
+						slog.String("type", art.Type),
+						tint.Err(err),
+					)
+					continue
+				}
+				p.pjs = append(p.pjs, pj)
+			}
+		}
+
+		pjs = append(pjs, p.pjs...)
+		roots = append(roots, p.clientPath, p.serverPath)
+		if opts.downloadArt {
+			roots = append(roots, p.artPath)
+		}
+	}
+	return pjs, roots, conflicts
+}
+
+// prunePacks removes the files in each root of each pack that its pruner was not told to keep.
+func prunePacks(ctx context.Context, logger *slog.Logger, packs []*pack) {
+	for _, p := range packs {
+		for _, root := range p.roots() {
+			if err := p.pruner.Prune(ctx, logger, root); err != nil {
+				logger.LogAttrs(ctx, slog.LevelWarn, "Failed to prune extraneous files",
+					slog.String("root", root),
+					tint.Err(err),
+				)
+			}
+		}
+	}
+}
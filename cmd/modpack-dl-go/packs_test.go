@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/database64128/modpack-dl-go/modpacksch"
+)
+
+// newMultiPackServer returns a test server that serves modpack 1 "Alpha" with versions 10 and 11,
+// and modpack 2 "Beta" with versions 20 and 21. Each version has a single client and server file
+// named after the version, and the paths of all requests are appended to requests.
+func newMultiPackServer(t *testing.T, requests *[]string) *httptest.Server {
+	t.Helper()
+	var mu sync.Mutex
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		*requests = append(*requests, r.URL.Path)
+		mu.Unlock()
+
+		var body string
+		switch r.URL.Path {
+		case "/public/modpack/1":
+			body = `{"id":1,"name":"Alpha","versions":[{"id":10},{"id":11}]}`
+		case "/public/modpack/2":
+			body = `{"id":2,"name":"Beta","versions":[{"id":20},{"id":21}]}`
+		case "/public/modpack/1/10", "/public/modpack/1/11", "/public/modpack/2/20", "/public/modpack/2/21":
+			var modpackID, versionID int64
+			fmt.Sscanf(r.URL.Path, "/public/modpack/%d/%d", &modpackID, &versionID)
+			content := fmt.Sprintf("version %d\n", versionID)
+			sum := sha1.Sum([]byte(content))
+			body = fmt.Sprintf(`{"id":%d,"parent":%d,"files":[{"path":"./mods/","name":"v%d.jar","url":"https://example.com/v%d.jar","sha1":"%s","size":%d}]}`,
+				versionID, modpackID, versionID, versionID, hex.EncodeToString(sum[:]), len(content))
+		default:
+			http.NotFound(w, r)
+			return
+		}
+		w.Header()["Content-Type"] = []string{"application/json"}
+		_, _ = w.Write([]byte(body))
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestPlanMultiplePacks(t *testing.T) {
+	var requests []string
+	srv := newMultiPackServer(t, &requests)
+	selected, other := newModpackProviders(false, []modpacksch.ClientOption{modpacksch.WithBaseURL(srv.URL)})
+
+	ctx := context.Background()
+	refs := packRefs{{ModpackID: 1}, {ModpackID: 2, VersionID: 20}}
+	packs, ok := fetchPacks(ctx, testLogger, selected, other, false, refs)
+	if !ok {
+		t.Fatal("fetchPacks() failed")
+	}
+	if len(packs) != 2 {
+		t.Fatalf("got %d packs, want 2", len(packs))
+	}
+	// The latest version of Alpha, and the pinned version of Beta.
+	for _, path := range []string{"/public/modpack/1/11", "/public/modpack/2/20"} {
+		if !slices.Contains(requests, path) {
+			t.Errorf("%s was not requested, requests = %q", path, requests)
+		}
+	}
+
+	dir := t.TempDir()
+	clientRoot := filepath.Join(dir, "client")
+	serverRoot := filepath.Join(dir, "server")
+	setPaths(packs, clientRoot, serverRoot, "", "")
+
+	pjs, roots, conflicts := planPacks(ctx, testLogger, packs, planOptions{optionalFiles: optionalFilesAll})
+	if conflicts != 0 {
+		t.Errorf("conflicts = %d, want 0", conflicts)
+	}
+
+	wantRoots := []string{
+		filepath.Join(clientRoot, "Alpha"),
+		filepath.Join(serverRoot, "Alpha"),
+		filepath.Join(clientRoot, "Beta"),
+		filepath.Join(serverRoot, "Beta"),
+	}
+	if !slices.Equal(roots, wantRoots) {
+		t.Errorf("roots = %q, want %q", roots, wantRoots)
+	}
+
+	if len(pjs) != 2 {
+		t.Fatalf("got %d precheck jobs, want 2", len(pjs))
+	}
+	for i, want := range []struct {
+		pack string
+		file string
+	}{
+		{"Alpha", "v11.jar"},
+		{"Beta", "v20.jar"},
+	} {
+		if got, want := pjs[i].DestinationPath, filepath.Join(clientRoot, want.pack, "mods", want.file); got != want {
+			t.Errorf("pjs[%d].DestinationPath = %q, want %q", i, got, want)
+		}
+		if got, want := pjs[i].SecondaryDestinationPath, filepath.Join(serverRoot, want.pack, "mods", want.file); got != want {
+			t.Errorf("pjs[%d].SecondaryDestinationPath = %q, want %q", i, got, want)
+		}
+		if got := packs[i].pjs; len(got) != 1 || got[0].DestinationPath != pjs[i].DestinationPath {
+			t.Errorf("packs[%d].pjs = %v, want only the job of %s", i, got, want.file)
+		}
+	}
+
+	if got, want := packs[1].lock.VersionID, int64(20); got != want {
+		t.Errorf("lockfile version of Beta = %d, want %d", got, want)
+	}
+}
+
+func TestFetchPacksFailsOnMissingModpack(t *testing.T) {
+	var requests []string
+	srv := newMultiPackServer(t, &requests)
+	selected, other := newModpackProviders(false, []modpacksch.ClientOption{modpacksch.WithBaseURL(srv.URL)})
+
+	refs := packRefs{{ModpackID: 1}, {ModpackID: 3}}
+	if packs, ok := fetchPacks(context.Background(), testLogger, selected, other, false, refs); ok {
+		t.Errorf("fetchPacks() = %d packs, want failure", len(packs))
+	}
+}
+
+func TestPlanPacksCountsConflicts(t *testing.T) {
+	// The second file conflicts with the first, and the third is a duplicate of it.
+	var m modpacksch.ModpackVersionManifest
+	if err := json.Unmarshal([]byte(`{"id":10,"parent":1,"files":[
+		{"path":"./mods/","name":"a.jar","url":"https://example.com/1/a.jar","sha1":"`+strings.Repeat("00", sha1.Size)+`","size":1},
+		{"path":"./mods/","name":"a.jar","url":"https://example.com/2/a.jar","sha1":"`+strings.Repeat("ff", sha1.Size)+`","size":1},
+		{"path":"./mods/","name":"a.jar","url":"https://example.com/3/a.jar","sha1":"`+strings.Repeat("00", sha1.Size)+`","size":1}
+	]}`), &m); err != nil {
+		t.Fatal(err)
+	}
+	packs := []*pack{{versionManifest: m}}
+	setPaths(packs, t.TempDir(), "", "", "")
+
+	pjs, _, conflicts := planPacks(context.Background(), testLogger, packs, planOptions{optionalFiles: optionalFilesAll})
+	if conflicts != 1 {
+		t.Errorf("conflicts = %d, want 1", conflicts)
+	}
+	if len(pjs) != 1 || pjs[0].DownloadURL != "https://example.com/1/a.jar" {
+		t.Errorf("precheck jobs = %v, want only the first file", pjs)
+	}
+}
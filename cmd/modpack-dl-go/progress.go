@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/database64128/modpack-dl-go/download"
+	"github.com/database64128/modpack-dl-go/precheck"
+)
+
+// progressLine is a single line at the bottom of a terminal that is redrawn in place.
+//
+// It is also the writer that logs are written through, so that log lines are printed
+// above the progress line instead of over it.
+type progressLine struct {
+	mu   sync.Mutex
+	w    io.Writer
+	line string
+}
+
+// clearLine returns the cursor to the start of the line and clears it.
+const clearLine = "\r\x1b[K"
+
+// Write implements [io.Writer].
+func (p *progressLine) Write(b []byte) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.line == "" {
+		return p.w.Write(b)
+	}
+	io.WriteString(p.w, clearLine)
+	n, err := p.w.Write(b)
+	io.WriteString(p.w, p.line)
+	return n, err
+}
+
+// set replaces the progress line.
+func (p *progressLine) set(line string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.line = line
+	io.WriteString(p.w, clearLine+line)
+}
+
+// finish leaves the progress line as is, and moves subsequent output to the next line.
+func (p *progressLine) finish() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.line != "" {
+		io.WriteString(p.w, "\n")
+		p.line = ""
+	}
+}
+
+// progressRefreshInterval is the interval between redraws of the progress line.
+const progressRefreshInterval = 250 * time.Millisecond
+
+// runProgressLine redraws the progress line with the progress of the given number of jobs
+// through the worker fleets until stop is closed, and then draws it one last time.
+func runProgressLine(p *progressLine, stop <-chan struct{}, total int, pwf *precheck.WorkerFleet, dwf *download.WorkerFleet) {
+	ticker := time.NewTicker(progressRefreshInterval)
+	defer ticker.Stop()
+
+	for {
+		p.set(formatProgress(total, pwf.Stats(), dwf.Stats(), dwf.BytesReceived()))
+		select {
+		case <-stop:
+			p.set(formatProgress(total, pwf.Stats(), dwf.Stats(), dwf.BytesReceived()))
+			p.finish()
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// formatProgress returns the progress line for the given number of jobs.
+func formatProgress(total int, ps precheck.Stats, ds download.Stats, received int64) string {
+	done := ps.Skipped + ps.Migrated() + ps.Failed + ds.Downloaded + ds.Failed
+	line := fmt.Sprintf("%d/%d files, %s/%s downloaded", done, total, formatBytes(received), formatBytes(ps.QueuedDownloadSize))
+	if failed := ps.Failed + ds.Failed; failed > 0 {
+		line += fmt.Sprintf(", %d failed", failed)
+	}
+	return line
+}
+
+// formatBytes returns n in the largest binary unit it is at least one of, with one decimal place.
+func formatBytes(n int64) string {
+	const units = "KMGTPE"
+	if n < 1<<10 {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(1<<10), 0
+	for m := n >> 10; m >= 1<<10 && exp < len(units)-1; m >>= 10 {
+		div <<= 10
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), units[exp])
+}
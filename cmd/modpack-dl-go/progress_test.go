@@ -0,0 +1,123 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/database64128/modpack-dl-go/download"
+	"github.com/database64128/modpack-dl-go/precheck"
+)
+
+func TestNewStderrHandlerQuiet(t *testing.T) {
+	for _, c := range []struct {
+		name         string
+		format       string
+		quiet        bool
+		terminal     bool
+		wantInfo     bool
+		wantProgress bool
+	}{
+		{"Text", logFormatText, false, true, true, false},
+		{"QuietText", logFormatText, true, false, false, false},
+		{"QuietTextOnTerminal", logFormatText, true, true, false, true},
+		{"JSON", logFormatJSON, false, true, true, false},
+		// JSON logs get no progress line, even on a terminal.
+		{"QuietJSONOnTerminal", logFormatJSON, true, true, false, false},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			h, progress, err := newStderrHandler(&buf, c.format, slog.LevelInfo, c.quiet, c.terminal)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := progress != nil; got != c.wantProgress {
+				t.Errorf("got progress line %t, want %t", got, c.wantProgress)
+			}
+
+			logger := slog.New(h)
+			logger.LogAttrs(context.Background(), slog.LevelInfo, "Downloaded file")
+			logger.LogAttrs(context.Background(), slog.LevelWarn, "Failed to download file")
+			logger.LogAttrs(context.Background(), slog.LevelError, "Some files failed")
+
+			out := buf.String()
+			if got := strings.Contains(out, "Downloaded file"); got != c.wantInfo {
+				t.Errorf("info logged = %t, want %t, output:\n%s", got, c.wantInfo, out)
+			}
+			for _, msg := range []string{"Failed to download file", "Some files failed"} {
+				if !strings.Contains(out, msg) {
+					t.Errorf("%q not logged, output:\n%s", msg, out)
+				}
+			}
+		})
+	}
+}
+
+func TestNewStderrHandlerQuietKeepsDebugLevelAtWarn(t *testing.T) {
+	h, _, err := newStderrHandler(&bytes.Buffer{}, logFormatText, slog.LevelDebug, true, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h.Enabled(context.Background(), slog.LevelInfo) {
+		t.Error("info logs enabled in quiet mode with -logLevel debug")
+	}
+}
+
+func TestNewStderrHandlerUnknownFormat(t *testing.T) {
+	if _, _, err := newStderrHandler(&bytes.Buffer{}, "xml", slog.LevelInfo, false, false); err == nil {
+		t.Error("newStderrHandler() error = nil, want an error for an unknown format")
+	}
+}
+
+func TestProgressLineKeepsLogsAbove(t *testing.T) {
+	var buf bytes.Buffer
+	p := &progressLine{w: &buf}
+	p.Write([]byte("before\n"))
+	p.set("1/2 files")
+	p.Write([]byte("warning\n"))
+	p.set("2/2 files")
+	p.finish()
+	p.Write([]byte("after\n"))
+
+	want := "before\n" +
+		clearLine + "1/2 files" +
+		clearLine + "warning\n" + "1/2 files" +
+		clearLine + "2/2 files" +
+		"\n" + "after\n"
+	if got := buf.String(); got != want {
+		t.Errorf("output = %q, want %q", got, want)
+	}
+}
+
+func TestFormatProgress(t *testing.T) {
+	ps := precheck.Stats{Skipped: 3, Moved: 1, Queued: 4, QueuedDownloadSize: 3 << 20}
+	ds := download.Stats{Downloaded: 2}
+	if got, want := formatProgress(10, ps, ds, 1536<<10), "6/10 files, 1.5 MiB/3.0 MiB downloaded"; got != want {
+		t.Errorf("formatProgress() = %q, want %q", got, want)
+	}
+
+	ps.Failed = 1
+	ds.Failed = 1
+	if got, want := formatProgress(10, ps, ds, 0), "8/10 files, 0 B/3.0 MiB downloaded, 2 failed"; got != want {
+		t.Errorf("formatProgress() = %q, want %q", got, want)
+	}
+}
+
+func TestFormatBytes(t *testing.T) {
+	for _, c := range []struct {
+		n    int64
+		want string
+	}{
+		{0, "0 B"},
+		{1023, "1023 B"},
+		{1024, "1.0 KiB"},
+		{1536, "1.5 KiB"},
+		{5 << 30, "5.0 GiB"},
+	} {
+		if got := formatBytes(c.n); got != c.want {
+			t.Errorf("formatBytes(%d) = %q, want %q", c.n, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+
+	"github.com/database64128/modpack-dl-go/modpacksch"
+)
+
+const (
+	providerPublic     = "public"
+	providerCurseForge = "curseforge"
+)
+
+// modpackProvider is a modpack client for one of the modpacks.ch providers.
+type modpackProvider struct {
+	name   string
+	client modpacksch.ModpackClient
+}
+
+// newModpackProviders returns the provider selected by useCurseForge,
+// followed by the other provider.
+func newModpackProviders(useCurseForge bool, opts []modpacksch.ClientOption) (selected, other modpackProvider) {
+	public := modpackProvider{
+		name:   providerPublic,
+		client: modpacksch.NewPublicModpackClient(opts...),
+	}
+	cf := modpackProvider{
+		name:   providerCurseForge,
+		client: modpacksch.NewCurseForgeModpackClient(opts...),
+	}
+	if useCurseForge {
+		return cf, public
+	}
+	return public, cf
+}
+
+// withProviderFallback calls get with the client of the selected provider.
+// If the modpack is not found and fallback is true, get is called again with
+// the client of the other provider.
+//
+// It returns the provider that found the modpack, and the result of get.
+// If neither provider found it, the error from the selected provider is returned.
+func withProviderFallback[V any](
+	ctx context.Context,
+	logger *slog.Logger,
+	selected, other modpackProvider,
+	fallback bool,
+	modpackID int64,
+	get func(client modpacksch.ModpackClient) (V, error),
+) (modpackProvider, V, error) {
+	v, err := get(selected.client)
+	if err == nil || !fallback || !errors.As(err, new(*modpacksch.NotFoundError)) {
+		return selected, v, err
+	}
+
+	logger.LogAttrs(ctx, slog.LevelDebug, "Modpack not found, trying the other provider",
+		slog.Int64("modpackID", modpackID),
+		slog.String("provider", selected.name),
+		slog.String("otherProvider", other.name),
+	)
+
+	otherV, otherErr := get(other.client)
+	if otherErr != nil {
+		return selected, v, err
+	}
+
+	logger.LogAttrs(ctx, slog.LevelInfo, "Modpack found with the other provider",
+		slog.Int64("modpackID", modpackID),
+		slog.String("provider", other.name),
+	)
+	return other, otherV, nil
+}
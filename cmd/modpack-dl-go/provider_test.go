@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/database64128/modpack-dl-go/modpacksch"
+)
+
+// newProviderServer returns a test server that serves modpack 42 only from the endpoint with the given path,
+// and answers all other requests with status.
+func newProviderServer(t *testing.T, found string, status int) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != found {
+			http.Error(w, http.StatusText(status), status)
+			return
+		}
+		w.Header()["Content-Type"] = []string{"application/json"}
+		_, _ = w.Write([]byte(`{"id":42,"name":"Test Pack"}`))
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// getManifest gets the manifest of modpack 42 with the client.
+func getManifest(client modpacksch.ModpackClient) (modpacksch.ModpackManifest, error) {
+	return client.GetModpackManifest(context.Background(), 42)
+}
+
+func TestWithProviderFallback(t *testing.T) {
+	for _, c := range []struct {
+		name          string
+		useCurseForge bool
+		found         string
+		wantProvider  string
+	}{
+		{"PublicFound", false, modpacksch.APIPublicModpack + "/42", providerPublic},
+		{"PublicFallsBackToCurseForge", false, modpacksch.APIPublicCurseForge + "/42", providerCurseForge},
+		{"CurseForgeFound", true, modpacksch.APIPublicCurseForge + "/42", providerCurseForge},
+		{"CurseForgeFallsBackToPublic", true, modpacksch.APIPublicModpack + "/42", providerPublic},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			srv := newProviderServer(t, c.found, http.StatusNotFound)
+			selected, other := newModpackProviders(c.useCurseForge, []modpacksch.ClientOption{modpacksch.WithBaseURL(srv.URL)})
+			provider, m, err := withProviderFallback(context.Background(), testLogger, selected, other, true, 42, getManifest)
+			if err != nil {
+				t.Fatalf("withProviderFallback() error = %v", err)
+			}
+			if provider.name != c.wantProvider {
+				t.Errorf("provider = %q, want %q", provider.name, c.wantProvider)
+			}
+			if m.Name != "Test Pack" {
+				t.Errorf("manifest name = %q, want %q", m.Name, "Test Pack")
+			}
+		})
+	}
+}
+
+func TestWithProviderFallbackDisabled(t *testing.T) {
+	srv := newProviderServer(t, modpacksch.APIPublicCurseForge+"/42", http.StatusNotFound)
+	selected, other := newModpackProviders(false, []modpacksch.ClientOption{modpacksch.WithBaseURL(srv.URL)})
+	provider, _, err := withProviderFallback(context.Background(), testLogger, selected, other, false, 42, getManifest)
+	if !errors.As(err, new(*modpacksch.NotFoundError)) {
+		t.Errorf("withProviderFallback() error = %v, want a NotFoundError", err)
+	}
+	if provider.name != providerPublic {
+		t.Errorf("provider = %q, want %q", provider.name, providerPublic)
+	}
+}
+
+func TestWithProviderFallbackNotFoundAnywhere(t *testing.T) {
+	srv := newProviderServer(t, "", http.StatusNotFound)
+	selected, other := newModpackProviders(true, []modpacksch.ClientOption{modpacksch.WithBaseURL(srv.URL)})
+	provider, _, err := withProviderFallback(context.Background(), testLogger, selected, other, true, 42, getManifest)
+	if !errors.As(err, new(*modpacksch.NotFoundError)) {
+		t.Errorf("withProviderFallback() error = %v, want a NotFoundError", err)
+	}
+	if provider.name != providerCurseForge {
+		t.Errorf("provider = %q, want the selected provider %q", provider.name, providerCurseForge)
+	}
+}
+
+func TestWithProviderFallbackOnlyOnNotFound(t *testing.T) {
+	srv := newProviderServer(t, modpacksch.APIPublicCurseForge+"/42", http.StatusBadRequest)
+	selected, other := newModpackProviders(false, []modpacksch.ClientOption{modpacksch.WithBaseURL(srv.URL)})
+	provider, _, err := withProviderFallback(context.Background(), testLogger, selected, other, true, 42, getManifest)
+	if err == nil || errors.As(err, new(*modpacksch.NotFoundError)) {
+		t.Errorf("withProviderFallback() error = %v, want the selected provider's error", err)
+	}
+	if provider.name != providerPublic {
+		t.Errorf("provider = %q, want %q", provider.name, providerPublic)
+	}
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"os"
+	"strconv"
+	"sync"
+
+	"github.com/database64128/modpack-dl-go/download"
+	"github.com/database64128/modpack-dl-go/precheck"
+	"github.com/lmittmann/tint"
+)
+
+// Supported values of the -reportFormat flag.
+const (
+	reportFormatCSV  = "csv"
+	reportFormatJSON = "json"
+)
+
+// reportActionNone is the action of files that were not processed,
+// because the run was cancelled, or, in a dry run, because they would be downloaded.
+const reportActionNone = "none"
+
+// reportRow is the integrity report entry of a file in the manifest.
+type reportRow struct {
+	// Path is the file's destination path.
+	Path string `json:"path"`
+
+	// SecondaryPath is the file's secondary destination path, if any.
+	SecondaryPath string `json:"secondaryPath,omitempty"`
+
+	// Size is the expected size of the file.
+	Size int64 `json:"size"`
+
+	// Sum is the hex-encoded expected hash sum of the file, usually SHA-1.
+	Sum string `json:"sum"`
+
+	// Present is whether a file of the expected size is at all destination paths after the run.
+	Present bool `json:"present"`
+
+	// Valid is whether the run verified, migrated, or downloaded the file's content.
+	Valid bool `json:"valid"`
+
+	// Action is what the run did with the file: "skipped", "moved", "copied", "linked",
+	// "downloaded", "failed", or "none".
+	Action string `json:"action"`
+}
+
+// reportCollector is a [download.EventHandler] that records the outcome of each file
+// for the integrity report, keyed by destination path.
+type reportCollector struct {
+	mu    sync.Mutex
+	rows  []reportRow
+	index map[string]int
+}
+
+// newReportCollector returns a new collector with no files.
+func newReportCollector() *reportCollector {
+	return &reportCollector{
+		rows:  []reportRow{},
+		index: make(map[string]int),
+	}
+}
+
+// add adds a row for each precheck job. It must be called before the jobs are run.
+func (c *reportCollector) add(pjs []precheck.Job) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i := range pjs {
+		pj := &pjs[i]
+		c.index[pj.DestinationPath] = len(c.rows)
+		c.rows = append(c.rows, reportRow{
+			Path:          pj.DestinationPath,
+			SecondaryPath: pj.SecondaryDestinationPath,
+			Size:          pj.Size,
+			Sum:           hex.EncodeToString(pj.Sum),
+			Action:        reportActionNone,
+		})
+	}
+}
+
+// set records the action taken on the file at path.
+func (c *reportCollector) set(path, action string, valid bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if i, ok := c.index[path]; ok {
+		c.rows[i].Action = action
+		c.rows[i].Valid = valid
+	}
+}
+
+// OnDownloadStart implements [download.EventHandler.OnDownloadStart].
+func (c *reportCollector) OnDownloadStart(download.Event) {}
+
+// OnDownloadComplete implements [download.EventHandler.OnDownloadComplete].
+func (c *reportCollector) OnDownloadComplete(e download.Event) {
+	c.set(e.Path, "downloaded", true)
+}
+
+// OnSkip implements [download.EventHandler.OnSkip].
+func (c *reportCollector) OnSkip(e download.Event) {
+	c.set(e.Path, e.Reason, true)
+}
+
+// OnError implements [download.EventHandler.OnError].
+func (c *reportCollector) OnError(e download.Event) {
+	c.set(e.Path, "failed", false)
+}
+
+// write checks which files are present, and writes the report to the file at path
+// in the given format.
+func (c *reportCollector) write(path, format string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for i := range c.rows {
+		row := &c.rows[i]
+		row.Present = fileHasSize(row.Path, row.Size) && (row.SecondaryPath == "" || fileHasSize(row.SecondaryPath, row.Size))
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	switch format {
+	case reportFormatJSON:
+		err = writeReportJSON(f, c.rows)
+	default:
+		err = writeReportCSV(f, c.rows)
+	}
+	return errors.Join(err, f.Close())
+}
+
+// fileHasSize returns whether there is a regular file of the given size at path.
+func fileHasSize(path string, size int64) bool {
+	fi, err := os.Stat(path)
+	return err == nil && fi.Mode().IsRegular() && fi.Size() == size
+}
+
+// writeReportJSON writes the report rows to w as a JSON array.
+func writeReportJSON(w io.Writer, rows []reportRow) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "    ")
+	return enc.Encode(rows)
+}
+
+// writeReportCSV writes the report rows to w as CSV with a header row.
+func writeReportCSV(w io.Writer, rows []reportRow) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"path", "secondaryPath", "size", "sum", "present", "valid", "action"})
+	for _, row := range rows {
+		cw.Write([]string{
+			row.Path,
+			row.SecondaryPath,
+			strconv.FormatInt(row.Size, 10),
+			row.Sum,
+			strconv.FormatBool(row.Present),
+			strconv.FormatBool(row.Valid),
+			row.Action,
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// writeReport writes the report collected for fileCount files to path in the given format.
+// It returns false if it failed, after logging the error.
+func writeReport(ctx context.Context, logger *slog.Logger, report *reportCollector, path, format string, fileCount int) bool {
+	if err := report.write(path, format); err != nil {
+		logger.LogAttrs(ctx, slog.LevelError, "Failed to write report",
+			slog.String("path", path),
+			tint.Err(err),
+		)
+		return false
+	}
+	logger.LogAttrs(ctx, slog.LevelInfo, "Wrote report",
+		slog.String("path", path),
+		slog.Int("fileCount", fileCount),
+	)
+	return true
+}
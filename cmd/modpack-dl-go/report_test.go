@@ -0,0 +1,176 @@
+package main
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"testing"
+
+	"github.com/database64128/modpack-dl-go/download"
+	"github.com/database64128/modpack-dl-go/precheck"
+)
+
+// runReportScenario runs a mix of files that are skipped, copied, downloaded,
+// redownloaded, failed, and never run, and returns the collected report and the expected rows.
+func runReportScenario(t *testing.T) (*reportCollector, []reportRow) {
+	t.Helper()
+	content := []byte("file content\n")
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing.jar" {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write(content)
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	client := filepath.Join(dir, "client")
+	server := filepath.Join(dir, "server")
+	sum := sha1.Sum(content)
+	newJob := func(name string) precheck.Job {
+		return precheck.Job{
+			DownloadURL:     srv.URL + "/" + name,
+			DestinationPath: filepath.Join(client, name),
+			NewHash:         sha1.New,
+			Sum:             sum[:],
+			Size:            int64(len(content)),
+		}
+	}
+
+	skipped := newJob("skipped.jar")
+	writeTestFile(t, skipped.DestinationPath, content)
+	copied := newJob("copied.jar")
+	copied.SecondaryDestinationPath = filepath.Join(server, "copied.jar")
+	writeTestFile(t, copied.SecondaryDestinationPath, content)
+	downloaded := newJob("downloaded.jar")
+	corrupt := newJob("corrupt.jar")
+	writeTestFile(t, corrupt.DestinationPath, []byte("corrupt content\n"))
+	missing := newJob("missing.jar")
+	// A distinct sum, so that the download fleet does not reuse the downloaded content.
+	missingSum := sha1.Sum([]byte("missing content\n"))
+	missing.Sum = missingSum[:]
+	notRun := newJob("notrun.jar")
+	pjs := []precheck.Job{skipped, copied, downloaded, corrupt, missing, notRun}
+
+	report := newReportCollector()
+	report.add(pjs)
+	// The last job is never sent, as if the run was cancelled.
+	runFleets(context.Background(), pjs[:len(pjs)-1],
+		[]precheck.Option{precheck.WithEventHandler(report)},
+		[]download.Option{download.WithEventHandler(report)},
+	)
+
+	wantRow := func(pj precheck.Job, present, valid bool, action string) reportRow {
+		return reportRow{
+			Path:          pj.DestinationPath,
+			SecondaryPath: pj.SecondaryDestinationPath,
+			Size:          pj.Size,
+			Sum:           hex.EncodeToString(pj.Sum),
+			Present:       present,
+			Valid:         valid,
+			Action:        action,
+		}
+	}
+	return report, []reportRow{
+		wantRow(skipped, true, true, "skipped"),
+		wantRow(copied, true, true, "copied"),
+		wantRow(downloaded, true, true, "downloaded"),
+		wantRow(corrupt, true, true, "downloaded"),
+		wantRow(missing, false, false, "failed"),
+		wantRow(notRun, false, false, reportActionNone),
+	}
+}
+
+// writeTestFile writes content to the file at path, creating parent directories as needed.
+func writeTestFile(t *testing.T, path string, content []byte) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReportCollectorJSON(t *testing.T) {
+	report, want := runReportScenario(t)
+	path := filepath.Join(t.TempDir(), "report.json")
+	if err := report.write(path, reportFormatJSON); err != nil {
+		t.Fatalf("write() error = %v", err)
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []reportRow
+	if err = json.Unmarshal(b, &got); err != nil {
+		t.Fatalf("report is not valid JSON: %v", err)
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("report rows = %+v, want %+v", got, want)
+	}
+}
+
+func TestReportCollectorCSV(t *testing.T) {
+	report, want := runReportScenario(t)
+	path := filepath.Join(t.TempDir(), "report.csv")
+	if err := report.write(path, reportFormatCSV); err != nil {
+		t.Fatalf("write() error = %v", err)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	records, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		t.Fatalf("report is not valid CSV: %v", err)
+	}
+	if len(records) == 0 {
+		t.Fatal("report has no header row")
+	}
+	wantHeader := []string{"path", "secondaryPath", "size", "sum", "present", "valid", "action"}
+	if !slices.Equal(records[0], wantHeader) {
+		t.Errorf("header = %q, want %q", records[0], wantHeader)
+	}
+	if len(records)-1 != len(want) {
+		t.Fatalf("got %d rows, want %d", len(records)-1, len(want))
+	}
+	for i, w := range want {
+		wantRecord := []string{
+			w.Path,
+			w.SecondaryPath,
+			strconv.FormatInt(w.Size, 10),
+			w.Sum,
+			strconv.FormatBool(w.Present),
+			strconv.FormatBool(w.Valid),
+			w.Action,
+		}
+		if got := records[i+1]; !slices.Equal(got, wantRecord) {
+			t.Errorf("row %d = %q, want %q", i, got, wantRecord)
+		}
+	}
+}
+
+func TestReportCollectorIgnoresUnknownPaths(t *testing.T) {
+	report := newReportCollector()
+	report.add([]precheck.Job{{DestinationPath: "a.jar", Size: 1}})
+	report.OnDownloadComplete(download.Event{Path: "b.jar"})
+	if got := report.rows[0].Action; got != reportActionNone {
+		t.Errorf("action of a.jar = %q, want %q", got, reportActionNone)
+	}
+	if len(report.rows) != 1 {
+		t.Errorf("got %d rows, want 1", len(report.rows))
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"os"
+	"time"
+)
+
+var (
+	errShutdown      = errors.New("received exit signal")
+	errShutdownForce = errors.New("in-flight work aborted on shutdown")
+)
+
+// handleShutdown handles exit signals received on sigCh in two phases.
+//
+// On the first signal, draining is closed, so that no new work is started, while
+// in-flight work carries on under the returned context. The context is canceled
+// when a second signal is received, or grace has passed since the first one.
+// If grace is not positive, the first signal cancels the context right away.
+//
+// Call stop to release the resources once signals are no longer handled.
+func handleShutdown(parent context.Context, logger *slog.Logger, sigCh <-chan os.Signal, grace time.Duration) (ctx context.Context, draining <-chan struct{}, stop func()) {
+	ctx, cancel := context.WithCancelCause(parent)
+	drainCh := make(chan struct{})
+
+	go func() {
+		select {
+		case <-sigCh:
+		case <-ctx.Done():
+			return
+		}
+
+		if grace <= 0 {
+			logger.LogAttrs(ctx, slog.LevelInfo, "Received exit signal")
+			close(drainCh)
+			cancel(errShutdown)
+			return
+		}
+
+		logger.LogAttrs(ctx, slog.LevelInfo, "Received exit signal, finishing in-flight downloads. Send it again to abort them",
+			slog.Duration("gracePeriod", grace),
+		)
+		close(drainCh)
+
+		timer := time.NewTimer(grace)
+		defer timer.Stop()
+
+		select {
+		case <-sigCh:
+			logger.LogAttrs(ctx, slog.LevelInfo, "Received second exit signal, aborting in-flight downloads")
+		case <-timer.C:
+			logger.LogAttrs(ctx, slog.LevelInfo, "Shutdown grace period expired, aborting in-flight downloads")
+		case <-ctx.Done():
+			return
+		}
+		cancel(errShutdownForce)
+	}()
+
+	return ctx, drainCh, func() { cancel(nil) }
+}
+
+// withDrain returns a copy of ctx that is also canceled when draining is closed.
+func withDrain(ctx context.Context, draining <-chan struct{}) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancelCause(ctx)
+	go func() {
+		select {
+		case <-draining:
+			cancel(errShutdown)
+		case <-ctx.Done():
+		}
+	}()
+	return ctx, func() { cancel(nil) }
+}
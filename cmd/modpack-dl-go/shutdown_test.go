@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"crypto/sha1"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/database64128/modpack-dl-go/precheck"
+)
+
+// waitDone fails the test if ch is not closed within a few seconds.
+func waitDone(t *testing.T, what string, ch <-chan struct{}) {
+	t.Helper()
+	select {
+	case <-ch:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("timed out waiting for %s", what)
+	}
+}
+
+// assertNotDone fails the test if ch is closed within a short while.
+func assertNotDone(t *testing.T, what string, ch <-chan struct{}) {
+	t.Helper()
+	select {
+	case <-ch:
+		t.Fatalf("%s before it should be", what)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestHandleShutdownSecondSignalAborts(t *testing.T) {
+	sigCh := make(chan os.Signal, 2)
+	ctx, draining, stop := handleShutdown(context.Background(), testLogger, sigCh, time.Hour)
+	defer stop()
+
+	assertNotDone(t, "draining started", draining)
+	sigCh <- syscall.SIGINT
+	waitDone(t, "draining to start", draining)
+	assertNotDone(t, "context canceled", ctx.Done())
+
+	sigCh <- syscall.SIGINT
+	waitDone(t, "context cancellation", ctx.Done())
+	if cause := context.Cause(ctx); !errors.Is(cause, errShutdownForce) {
+		t.Errorf("context.Cause() = %v, want %v", cause, errShutdownForce)
+	}
+}
+
+func TestHandleShutdownGraceExpiry(t *testing.T) {
+	sigCh := make(chan os.Signal, 2)
+	ctx, draining, stop := handleShutdown(context.Background(), testLogger, sigCh, 50*time.Millisecond)
+	defer stop()
+
+	sigCh <- syscall.SIGTERM
+	waitDone(t, "draining to start", draining)
+	waitDone(t, "context cancellation", ctx.Done())
+	if cause := context.Cause(ctx); !errors.Is(cause, errShutdownForce) {
+		t.Errorf("context.Cause() = %v, want %v", cause, errShutdownForce)
+	}
+}
+
+func TestHandleShutdownWithoutGrace(t *testing.T) {
+	sigCh := make(chan os.Signal, 2)
+	ctx, draining, stop := handleShutdown(context.Background(), testLogger, sigCh, 0)
+	defer stop()
+
+	sigCh <- syscall.SIGINT
+	waitDone(t, "draining to start", draining)
+	waitDone(t, "context cancellation", ctx.Done())
+	if cause := context.Cause(ctx); !errors.Is(cause, errShutdown) {
+		t.Errorf("context.Cause() = %v, want %v", cause, errShutdown)
+	}
+}
+
+func TestHandleShutdownStop(t *testing.T) {
+	sigCh := make(chan os.Signal, 2)
+	ctx, draining, stop := handleShutdown(context.Background(), testLogger, sigCh, time.Hour)
+	stop()
+
+	waitDone(t, "context cancellation", ctx.Done())
+	if cause := context.Cause(ctx); !errors.Is(cause, context.Canceled) {
+		t.Errorf("context.Cause() = %v, want %v", cause, context.Canceled)
+	}
+	assertNotDone(t, "draining started", draining)
+}
+
+func TestWithDrain(t *testing.T) {
+	draining := make(chan struct{})
+	parent, cancelParent := context.WithCancel(context.Background())
+	defer cancelParent()
+	ctx, stop := withDrain(parent, draining)
+	defer stop()
+
+	assertNotDone(t, "context canceled", ctx.Done())
+	close(draining)
+	waitDone(t, "context cancellation", ctx.Done())
+	if cause := context.Cause(ctx); !errors.Is(cause, errShutdown) {
+		t.Errorf("context.Cause() = %v, want %v", cause, errShutdown)
+	}
+	if parent.Err() != nil {
+		t.Errorf("parent context error = %v, want nil", parent.Err())
+	}
+}
+
+func TestDoubleSignalAbortsInFlightDownload(t *testing.T) {
+	started := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header()["Content-Length"] = []string{"10"}
+		w.WriteHeader(http.StatusOK)
+		w.(http.Flusher).Flush()
+		close(started)
+		<-r.Context().Done()
+	}))
+	defer srv.Close()
+
+	sum := sha1.Sum(make([]byte, 10))
+	pj := precheck.Job{
+		DownloadURL:     srv.URL,
+		DestinationPath: filepath.Join(t.TempDir(), "slow.bin"),
+		NewHash:         sha1.New,
+		Sum:             sum[:],
+		Size:            10,
+	}
+
+	sigCh := make(chan os.Signal, 2)
+	workCtx, draining, stop := handleShutdown(context.Background(), testLogger, sigCh, time.Hour)
+	defer stop()
+
+	done := make(chan struct{})
+	var failures int
+	go func() {
+		defer close(done)
+		_, dwf := runFleets(workCtx, []precheck.Job{pj}, nil, nil)
+		failures = dwf.Failures()
+	}()
+
+	waitDone(t, "the download to start", started)
+	sigCh <- syscall.SIGINT
+	waitDone(t, "draining to start", draining)
+	// The first signal lets the in-flight download carry on.
+	assertNotDone(t, "run finished", done)
+
+	sigCh <- syscall.SIGINT
+	waitDone(t, "the run to finish", done)
+	if failures != 1 {
+		t.Errorf("download failures = %d, want 1 for the aborted download", failures)
+	}
+}
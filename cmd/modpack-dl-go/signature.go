@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+
+	"github.com/database64128/modpack-dl-go/minisign"
+)
+
+// signatureSuffix is appended to a file's download URL to get the URL of its minisign signature.
+const signatureSuffix = ".minisig"
+
+// maxSignatureSize is the maximum size of a signature file.
+const maxSignatureSize = 64 << 10
+
+// signatureVerifier verifies files against the minisign signatures published next to them.
+type signatureVerifier struct {
+	ctx    context.Context
+	client *http.Client
+	key    minisign.PublicKey
+
+	mu   sync.Mutex
+	sigs map[string]*signatureEntry
+}
+
+// signatureEntry is a signature URL's fetched signature.
+// Failed fetches are not cached, so that they can be retried.
+type signatureEntry struct {
+	mu      sync.Mutex
+	fetched bool
+	sig     *minisign.Signature
+}
+
+// newSignatureVerifier returns a new verifier for the given public key,
+// which is either the key itself or the path to a public key file.
+// Signatures are fetched with the given client.
+func newSignatureVerifier(ctx context.Context, client *http.Client, key string) (*signatureVerifier, error) {
+	if b, err := os.ReadFile(key); err == nil {
+		key = string(b)
+	}
+	pk, err := minisign.ParsePublicKey(key)
+	if err != nil {
+		return nil, err
+	}
+	return &signatureVerifier{
+		ctx:    ctx,
+		client: client,
+		key:    pk,
+		sigs:   make(map[string]*signatureEntry),
+	}, nil
+}
+
+// verifyFunc returns a function for [precheck.Job.Verify] that verifies the file at the given path
+// against the signature at url with [signatureSuffix] appended.
+// Files without a published signature are accepted.
+func (v *signatureVerifier) verifyFunc(url, userAgent string) func(path string) error {
+	return func(path string) error {
+		sig, err := v.signature(url+signatureSuffix, userAgent)
+		if err != nil {
+			return err
+		}
+		if sig == nil {
+			return nil
+		}
+
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		return v.key.Verify(f, sig)
+	}
+}
+
+// signature returns the signature at url, nil if there is none, or an error.
+func (v *signatureVerifier) signature(url, userAgent string) (*minisign.Signature, error) {
+	v.mu.Lock()
+	e, ok := v.sigs[url]
+	if !ok {
+		e = &signatureEntry{}
+		v.sigs[url] = e
+	}
+	v.mu.Unlock()
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.fetched {
+		return e.sig, nil
+	}
+	sig, err := v.fetchSignature(url, userAgent)
+	if err != nil {
+		return nil, err
+	}
+	e.fetched = true
+	e.sig = sig
+	return sig, nil
+}
+
+// fetchSignature downloads and parses the signature at url.
+// It returns nil if the server responds with 404 Not Found.
+func (v *signatureVerifier) fetchSignature(url, userAgent string) (*minisign.Signature, error) {
+	req, err := http.NewRequestWithContext(v.ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create signature request: %w", err)
+	}
+	if userAgent != "" {
+		req.Header["User-Agent"] = []string{userAgent}
+	}
+
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch signature: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("failed to fetch signature %s: unexpected status code %d", url, resp.StatusCode)
+	}
+
+	b, err := io.ReadAll(io.LimitReader(resp.Body, maxSignatureSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read signature: %w", err)
+	}
+	if len(b) > maxSignatureSize {
+		return nil, fmt.Errorf("signature %s exceeds %d bytes", url, maxSignatureSize)
+	}
+
+	sig, err := minisign.ParseSignature(b)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse signature %s: %w", url, err)
+	}
+	return &sig, nil
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/sha1"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/database64128/modpack-dl-go/minisign"
+	"github.com/database64128/modpack-dl-go/precheck"
+)
+
+var testSignatureKeyID = []byte{8, 7, 6, 5, 4, 3, 2, 1}
+
+// newTestSignatureKey returns a new key pair, with the public key as printed by 'minisign -G'.
+func newTestSignatureKey(t *testing.T) (string, ed25519.PrivateKey) {
+	t.Helper()
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := append(append([]byte("Ed"), testSignatureKeyID...), pub...)
+	return base64.StdEncoding.EncodeToString(b), priv
+}
+
+// signTestContent returns a legacy minisign signature file of content made with priv.
+func signTestContent(priv ed25519.PrivateKey, content []byte) []byte {
+	const trustedComment = "timestamp:1700000000"
+	sig := ed25519.Sign(priv, content)
+	global := ed25519.Sign(priv, append(append([]byte{}, sig...), trustedComment...))
+	return []byte("untrusted comment: signature from minisign secret key\n" +
+		base64.StdEncoding.EncodeToString(append(append([]byte("Ed"), testSignatureKeyID...), sig...)) + "\n" +
+		"trusted comment: " + trustedComment + "\n" +
+		base64.StdEncoding.EncodeToString(global) + "\n")
+}
+
+// signatureTestServer serves files and their signatures, and counts signature requests.
+type signatureTestServer struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	sigFetch map[string]int
+}
+
+// newSignatureTestServer returns a server for the given files and signatures, keyed by path.
+func newSignatureTestServer(t *testing.T, files map[string][]byte) *signatureTestServer {
+	t.Helper()
+	s := &signatureTestServer{sigFetch: make(map[string]int)}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if filepath.Ext(r.URL.Path) == signatureSuffix {
+			s.mu.Lock()
+			s.sigFetch[r.URL.Path]++
+			s.mu.Unlock()
+		}
+		b, ok := files[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write(b)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func TestSignatureVerifierVerifyFunc(t *testing.T) {
+	content := []byte("mod content\n")
+	key, priv := newTestSignatureKey(t)
+	srv := newSignatureTestServer(t, map[string][]byte{
+		"/signed.jar.minisig":   signTestContent(priv, content),
+		"/tampered.jar.minisig": signTestContent(priv, []byte("original content\n")),
+		"/invalid.jar.minisig":  []byte("not a signature"),
+	})
+
+	v, err := newSignatureVerifier(context.Background(), http.DefaultClient, key)
+	if err != nil {
+		t.Fatalf("newSignatureVerifier() error = %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "a.jar")
+	writeTestFile(t, path, content)
+
+	for _, c := range []struct {
+		name    string
+		wantErr error
+	}{
+		{"signed.jar", nil},
+		{"tampered.jar", minisign.ErrInvalidSignature},
+		{"unsigned.jar", nil},
+	} {
+		if err := v.verifyFunc(srv.URL+"/"+c.name, "")(path); !errors.Is(err, c.wantErr) {
+			t.Errorf("verification against the signature of %s error = %v, want %v", c.name, err, c.wantErr)
+		}
+	}
+	if err := v.verifyFunc(srv.URL+"/invalid.jar", "")(path); err == nil {
+		t.Error("verification against an invalid signature file error = nil")
+	}
+
+	// Signatures are fetched once.
+	if err := v.verifyFunc(srv.URL+"/signed.jar", "")(path); err != nil {
+		t.Errorf("second verification error = %v", err)
+	}
+	if got := srv.sigFetch["/signed.jar.minisig"]; got != 1 {
+		t.Errorf("signature fetched %d times, want 1", got)
+	}
+}
+
+func TestNewSignatureVerifierReadsKeyFile(t *testing.T) {
+	key, _ := newTestSignatureKey(t)
+	path := filepath.Join(t.TempDir(), "minisign.pub")
+	writeTestFile(t, path, []byte("untrusted comment: minisign public key\n"+key+"\n"))
+	if _, err := newSignatureVerifier(context.Background(), http.DefaultClient, path); err != nil {
+		t.Errorf("newSignatureVerifier() of key file error = %v", err)
+	}
+	if _, err := newSignatureVerifier(context.Background(), http.DefaultClient, "not a key"); err == nil {
+		t.Error("newSignatureVerifier() of invalid key error = nil")
+	}
+}
+
+func TestSignatureVerificationRejectsTamperedFiles(t *testing.T) {
+	signed := []byte("signed content\n")
+	tampered := []byte("tampered content\n")
+	key, priv := newTestSignatureKey(t)
+	srv := newSignatureTestServer(t, map[string][]byte{
+		"/signed.jar":           signed,
+		"/signed.jar.minisig":   signTestContent(priv, signed),
+		"/tampered.jar":         tampered,
+		"/tampered.jar.minisig": signTestContent(priv, []byte("original content\n")),
+	})
+	v, err := newSignatureVerifier(context.Background(), http.DefaultClient, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	newJob := func(name string, content []byte) precheck.Job {
+		sum := sha1.Sum(content)
+		pj := precheck.Job{
+			DownloadURL:     srv.URL + "/" + name,
+			DestinationPath: filepath.Join(dir, name),
+			NewHash:         sha1.New,
+			Sum:             sum[:],
+			Size:            int64(len(content)),
+		}
+		pj.Verify = v.verifyFunc(pj.DownloadURL, "")
+		return pj
+	}
+	signedJob := newJob("signed.jar", signed)
+	tamperedJob := newJob("tampered.jar", tampered)
+	// The tampered file is already in place, matching the hash sum in the manifest.
+	writeTestFile(t, tamperedJob.DestinationPath, tampered)
+
+	pwf, dwf := runFleets(context.Background(), []precheck.Job{signedJob, tamperedJob}, nil, nil)
+	// The existing tampered file is rejected and downloaded again, which fails verification too.
+	if got := pwf.Stats().Queued; got != 2 {
+		t.Errorf("Stats().Queued = %d, want 2", got)
+	}
+	if got := dwf.Failures(); got != 1 {
+		t.Errorf("download failures = %d, want 1", got)
+	}
+	if got, err := os.ReadFile(signedJob.DestinationPath); err != nil || !bytes.Equal(got, signed) {
+		t.Errorf("signed.jar = %q, %v, want %q", got, err, signed)
+	}
+	if got, err := os.ReadFile(tamperedJob.DestinationPath); err == nil && len(got) != 0 {
+		t.Errorf("tampered.jar = %q, want it removed or emptied", got)
+	}
+}
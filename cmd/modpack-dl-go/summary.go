@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"time"
+
+	"github.com/database64128/modpack-dl-go/download"
+	"github.com/database64128/modpack-dl-go/precheck"
+)
+
+// runSummary is the machine-readable summary of a run.
+type runSummary struct {
+	// Downloaded is the number of files downloaded.
+	Downloaded int64 `json:"downloaded"`
+
+	// Skipped is the number of files that were already in place.
+	Skipped int64 `json:"skipped"`
+
+	// Migrated is the number of files moved, copied, or linked from existing files.
+	Migrated int64 `json:"migrated"`
+
+	// Moved is the number of files moved from the migration source path.
+	Moved int64 `json:"moved"`
+
+	// Copied is the number of files copied from existing files.
+	Copied int64 `json:"copied"`
+
+	// Linked is the number of files hard linked from the migration source path.
+	Linked int64 `json:"linked"`
+
+	// Failed is the number of files that failed to be prechecked or downloaded.
+	Failed int64 `json:"failed"`
+
+	// TotalBytes is the total number of bytes downloaded.
+	TotalBytes int64 `json:"totalBytes"`
+
+	// ElapsedSeconds is the duration of the download phase in seconds.
+	ElapsedSeconds float64 `json:"elapsedSeconds"`
+
+	// BytesPerSecond is the average download throughput.
+	BytesPerSecond int64 `json:"bytesPerSecond"`
+
+	// LastError is the cause of the most recent download failure, if known.
+	LastError string `json:"lastError,omitempty"`
+}
+
+// newRunSummary returns the summary of a run with the given fleet stats,
+// and the duration of the download phase.
+func newRunSummary(ps precheck.Stats, ds download.Stats, elapsed time.Duration) runSummary {
+	s := runSummary{
+		Downloaded:     ds.Downloaded,
+		Skipped:        ps.Skipped,
+		Migrated:       ps.Migrated(),
+		Moved:          ps.Moved,
+		Copied:         ps.Copied,
+		Linked:         ps.Linked,
+		Failed:         ps.Failed + ds.Failed,
+		TotalBytes:     ds.Bytes,
+		ElapsedSeconds: elapsed.Seconds(),
+	}
+	if elapsed > 0 {
+		s.BytesPerSecond = int64(float64(ds.Bytes) / elapsed.Seconds())
+	}
+	if ds.LastError != nil {
+		s.LastError = ds.LastError.Error()
+	}
+	return s
+}
+
+// writeJSON writes the summary to w as a single line of JSON.
+func (s runSummary) writeJSON(w io.Writer) error {
+	return json.NewEncoder(w).Encode(s)
+}
+
+// log logs the summary.
+func (s runSummary) log(ctx context.Context, logger *slog.Logger) {
+	attrs := []slog.Attr{
+		slog.Int64("downloaded", s.Downloaded),
+		slog.Int64("skipped", s.Skipped),
+		slog.Int64("moved", s.Moved),
+		slog.Int64("copied", s.Copied),
+		slog.Int64("linked", s.Linked),
+		slog.Int64("failed", s.Failed),
+		slog.Int64("totalBytes", s.TotalBytes),
+		slog.Duration("elapsed", time.Duration(s.ElapsedSeconds*float64(time.Second)).Round(time.Millisecond)),
+		slog.Int64("bytesPerSecond", s.BytesPerSecond),
+	}
+	if s.LastError != "" {
+		attrs = append(attrs, slog.String("lastError", s.LastError))
+	}
+	logger.LogAttrs(ctx, slog.LevelInfo, "Run complete", attrs...)
+}
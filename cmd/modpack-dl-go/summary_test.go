@@ -0,0 +1,146 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/database64128/modpack-dl-go/download"
+	"github.com/database64128/modpack-dl-go/precheck"
+)
+
+func TestRunSummaryWriteJSON(t *testing.T) {
+	ps := precheck.Stats{Skipped: 5, Moved: 1, Copied: 2, Linked: 3, Failed: 1}
+	ds := download.Stats{Downloaded: 4, Failed: 2, Bytes: 4000, LastError: errors.New("connection reset")}
+	s := newRunSummary(ps, ds, 2*time.Second)
+
+	var buf bytes.Buffer
+	if err := s.writeJSON(&buf); err != nil {
+		t.Fatalf("writeJSON() error = %v", err)
+	}
+	if n := bytes.Count(buf.Bytes(), []byte{'\n'}); n != 1 {
+		t.Errorf("writeJSON() wrote %d lines, want 1:\n%s", n, buf.String())
+	}
+
+	var got map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("failed to unmarshal summary: %v", err)
+	}
+	for key, want := range map[string]any{
+		"downloaded":     4.0,
+		"skipped":        5.0,
+		"migrated":       6.0,
+		"moved":          1.0,
+		"copied":         2.0,
+		"linked":         3.0,
+		"failed":         3.0,
+		"totalBytes":     4000.0,
+		"elapsedSeconds": 2.0,
+		"bytesPerSecond": 2000.0,
+		"lastError":      "connection reset",
+	} {
+		if got[key] != want {
+			t.Errorf("%s = %v, want %v", key, got[key], want)
+		}
+	}
+}
+
+func TestRunSummaryWriteJSONOmitsEmptyLastError(t *testing.T) {
+	var buf bytes.Buffer
+	if err := newRunSummary(precheck.Stats{}, download.Stats{}, 0).writeJSON(&buf); err != nil {
+		t.Fatalf("writeJSON() error = %v", err)
+	}
+	var got map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("failed to unmarshal summary: %v", err)
+	}
+	if _, ok := got["lastError"]; ok {
+		t.Errorf("summary has lastError: %s", buf.String())
+	}
+	if got["bytesPerSecond"] != 0.0 {
+		t.Errorf("bytesPerSecond = %v, want 0 for zero elapsed time", got["bytesPerSecond"])
+	}
+}
+
+func TestRunSummaryCountsOutcomes(t *testing.T) {
+	content := []byte("file content\n")
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write(content)
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	sum := sha1.Sum(content)
+	newJob := func(name, url string) precheck.Job {
+		return precheck.Job{
+			DownloadURL:     url,
+			DestinationPath: filepath.Join(dir, "client", name),
+			NewHash:         sha1.New,
+			Sum:             sum[:],
+			Size:            int64(len(content)),
+		}
+	}
+	writeFile := func(path string) {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, content, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	skipped := newJob("skipped.jar", srv.URL)
+	writeFile(skipped.DestinationPath)
+
+	moved := newJob("moved.jar", srv.URL)
+	moved.MigrateFromPath = filepath.Join(dir, "old", "moved.jar")
+	moved.MigrationMode = precheck.MigrationModeMove
+	writeFile(moved.MigrateFromPath)
+
+	copied := newJob("copied.jar", srv.URL)
+	copied.SecondaryDestinationPath = filepath.Join(dir, "server", "copied.jar")
+	writeFile(copied.DestinationPath)
+
+	downloaded := newJob("downloaded.jar", srv.URL)
+	// A distinct sum, so that the download fleet does not reuse the other downloaded content.
+	failed := newJob("failed.jar", srv.URL+"/missing")
+	failedSum := sha1.Sum([]byte("missing content\n"))
+	failed.Sum = failedSum[:]
+
+	start := time.Now()
+	pwf, dwf := runFleets(context.Background(), []precheck.Job{skipped, moved, copied, downloaded, failed}, nil, nil)
+	s := newRunSummary(pwf.Stats(), dwf.Stats(), time.Since(start))
+
+	for _, c := range []struct {
+		name      string
+		got, want int64
+	}{
+		{"Skipped", s.Skipped, 1},
+		{"Moved", s.Moved, 1},
+		{"Copied", s.Copied, 1},
+		{"Linked", s.Linked, 0},
+		{"Migrated", s.Migrated, 2},
+		{"Downloaded", s.Downloaded, 1},
+		{"Failed", s.Failed, 1},
+		{"TotalBytes", s.TotalBytes, int64(len(content))},
+	} {
+		if c.got != c.want {
+			t.Errorf("%s = %d, want %d", c.name, c.got, c.want)
+		}
+	}
+	if s.LastError == "" {
+		t.Error("LastError is empty, want the cause of the failed download")
+	}
+}
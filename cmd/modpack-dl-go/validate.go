@@ -0,0 +1,47 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"github.com/database64128/modpack-dl-go/modpacksch"
+)
+
+// manifestProblem is a problem found by validating a version manifest.
+type manifestProblem struct {
+	Index   int    `json:"index"`
+	Path    string `json:"path"`
+	Problem string `json:"problem"`
+}
+
+// printManifestProblems prints the problems returned by [modpacksch.ModpackVersionManifest.Validate] to w,
+// either as a table or as JSON.
+func printManifestProblems(w io.Writer, errs []error, asJSON bool) error {
+	problems := make([]manifestProblem, len(errs))
+	for i, err := range errs {
+		p := manifestProblem{Index: -1, Problem: err.Error()}
+		var fe *modpacksch.FileError
+		if errors.As(err, &fe) {
+			p.Index = fe.Index
+			p.Path = fe.Path
+			p.Problem = fe.Err.Error()
+		}
+		problems[i] = p
+	}
+
+	if asJSON {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "    ")
+		return enc.Encode(problems)
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "INDEX\tPATH\tPROBLEM")
+	for _, p := range problems {
+		fmt.Fprintf(tw, "%d\t%s\t%s\n", p.Index, p.Path, p.Problem)
+	}
+	return tw.Flush()
+}
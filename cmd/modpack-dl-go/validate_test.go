@@ -0,0 +1,64 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/database64128/modpack-dl-go/modpacksch"
+)
+
+var testManifestProblems = []error{
+	&modpacksch.FileError{Index: 2, Path: "mods/a.jar", Err: modpacksch.ErrMissingURL},
+	&modpacksch.FileError{Index: 5, Path: "../b.jar", Err: modpacksch.ErrPathSanitization},
+	errors.New("not a file error"),
+}
+
+func TestPrintManifestProblems(t *testing.T) {
+	var buf bytes.Buffer
+	if err := printManifestProblems(&buf, testManifestProblems, false); err != nil {
+		t.Fatal(err)
+	}
+	want := "INDEX  PATH        PROBLEM\n" +
+		"2      mods/a.jar  " + modpacksch.ErrMissingURL.Error() + "\n" +
+		"5      ../b.jar    " + modpacksch.ErrPathSanitization.Error() + "\n" +
+		"-1                 not a file error\n"
+	if got := buf.String(); got != want {
+		t.Errorf("printManifestProblems() wrote %q, want %q", got, want)
+	}
+}
+
+func TestPrintManifestProblemsJSON(t *testing.T) {
+	var buf bytes.Buffer
+	if err := printManifestProblems(&buf, testManifestProblems, true); err != nil {
+		t.Fatal(err)
+	}
+	var got []manifestProblem
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("output %q is not JSON: %v", buf.String(), err)
+	}
+	want := []manifestProblem{
+		{Index: 2, Path: "mods/a.jar", Problem: modpacksch.ErrMissingURL.Error()},
+		{Index: 5, Path: "../b.jar", Problem: modpacksch.ErrPathSanitization.Error()},
+		{Index: -1, Problem: "not a file error"},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d problems, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("problem %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestPrintManifestProblemsNone(t *testing.T) {
+	var buf bytes.Buffer
+	if err := printManifestProblems(&buf, nil, true); err != nil {
+		t.Fatal(err)
+	}
+	if got := buf.String(); got != "[]\n" {
+		t.Errorf("printManifestProblems() wrote %q, want an empty JSON array", got)
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"archive/zip"
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/database64128/modpack-dl-go/precheck"
+	"github.com/lmittmann/tint"
+)
+
+// clientFiles returns the paths relative to clientPath of the files
+// that the precheck jobs put in the client path, sorted and deduplicated.
+func clientFiles(clientPath string, pjs []precheck.Job) []string {
+	var files []string
+	for i := range pjs {
+		for _, p := range [...]string{pjs[i].DestinationPath, pjs[i].SecondaryDestinationPath} {
+			if p == "" {
+				continue
+			}
+			rel, err := filepath.Rel(clientPath, p)
+			if err != nil || !filepath.IsLocal(rel) {
+				continue
+			}
+			files = append(files, rel)
+		}
+	}
+	slices.Sort(files)
+	return slices.Compact(files)
+}
+
+// writeZip writes the files at the given paths relative to root into a new zip archive at zipPath,
+// preserving their relative paths and modification times.
+// If writing the archive fails, the partial archive is removed.
+func writeZip(zipPath, root string, files []string) (err error) {
+	f, err := os.Create(zipPath)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			os.Remove(zipPath)
+		}
+	}()
+
+	zw := zip.NewWriter(f)
+	for _, rel := range files {
+		if err = addZipFile(zw, filepath.Join(root, rel), rel); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+// addZipFile adds the file at path to the zip archive as name.
+func addZipFile(zw *zip.Writer, path, name string) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	fi, err := src.Stat()
+	if err != nil {
+		return err
+	}
+
+	fh, err := zip.FileInfoHeader(fi)
+	if err != nil {
+		return err
+	}
+	fh.Name = filepath.ToSlash(name)
+	fh.Method = zip.Deflate
+	if ext := strings.ToLower(filepath.Ext(name)); ext == ".jar" || ext == ".zip" {
+		// Already compressed.
+		fh.Method = zip.Store
+	}
+
+	w, err := zw.CreateHeader(fh)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(w, src)
+	return err
+}
+
+// writeClientZip writes the files the precheck jobs put in clientPath to a zip archive at zipPath.
+// It returns false if it failed, after logging the error.
+func writeClientZip(ctx context.Context, logger *slog.Logger, zipPath, clientPath string, pjs []precheck.Job) bool {
+	files := clientFiles(clientPath, pjs)
+	if err := writeZip(zipPath, clientPath, files); err != nil {
+		logger.LogAttrs(ctx, slog.LevelError, "Failed to write zip archive",
+			slog.String("path", zipPath),
+			tint.Err(err),
+		)
+		return false
+	}
+	logger.LogAttrs(ctx, slog.LevelInfo, "Wrote zip archive",
+		slog.String("path", zipPath),
+		slog.Int("fileCount", len(files)),
+	)
+	return true
+}
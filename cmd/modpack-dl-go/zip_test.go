@@ -0,0 +1,108 @@
+package main
+
+import (
+	"archive/zip"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/database64128/modpack-dl-go/precheck"
+)
+
+func TestClientFiles(t *testing.T) {
+	dir := t.TempDir()
+	clientPath := filepath.Join(dir, "client")
+	pjs := []precheck.Job{
+		{DestinationPath: filepath.Join(clientPath, "mods", "b.jar")},
+		{DestinationPath: filepath.Join(clientPath, "config", "a.toml"), SecondaryDestinationPath: filepath.Join(dir, "server", "config", "a.toml")},
+		{DestinationPath: filepath.Join(dir, "server", "server.properties")},
+		{DestinationPath: filepath.Join(clientPath, "mods", "b.jar")},
+	}
+	want := []string{filepath.Join("config", "a.toml"), filepath.Join("mods", "b.jar")}
+	if got := clientFiles(clientPath, pjs); !slices.Equal(got, want) {
+		t.Errorf("clientFiles() = %q, want %q", got, want)
+	}
+}
+
+func TestWriteZip(t *testing.T) {
+	dir := t.TempDir()
+	root := filepath.Join(dir, "client")
+	modTime := time.Date(2024, 1, 2, 3, 4, 6, 0, time.UTC)
+	files := map[string]string{
+		"mods/a.jar":        "jar content",
+		"config/sub/b.toml": "[section]\nkey = true\n",
+		"options.txt":       "fov:70\n",
+	}
+	var rels []string
+	for name, content := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+		rels = append(rels, filepath.FromSlash(name))
+	}
+
+	zipPath := filepath.Join(dir, "client.zip")
+	if err := writeZip(zipPath, root, rels); err != nil {
+		t.Fatalf("writeZip() error = %v", err)
+	}
+
+	zr, err := zip.OpenReader(zipPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer zr.Close()
+	if len(zr.File) != len(files) {
+		t.Errorf("zip has %d entries, want %d", len(zr.File), len(files))
+	}
+	for _, zf := range zr.File {
+		want, ok := files[zf.Name]
+		if !ok {
+			t.Errorf("unexpected zip entry %q", zf.Name)
+			continue
+		}
+		rc, err := zf.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != want {
+			t.Errorf("content of %q = %q, want %q", zf.Name, got, want)
+		}
+		if !zf.Modified.Equal(modTime) {
+			t.Errorf("modification time of %q = %v, want %v", zf.Name, zf.Modified, modTime)
+		}
+		wantMethod := zip.Deflate
+		if filepath.Ext(zf.Name) == ".jar" {
+			wantMethod = zip.Store
+		}
+		if zf.Method != wantMethod {
+			t.Errorf("method of %q = %d, want %d", zf.Name, zf.Method, wantMethod)
+		}
+	}
+}
+
+func TestWriteZipRemovesPartialArchive(t *testing.T) {
+	dir := t.TempDir()
+	zipPath := filepath.Join(dir, "client.zip")
+	if err := writeZip(zipPath, dir, []string{"missing.jar"}); err == nil {
+		t.Fatal("writeZip() with a missing file succeeded")
+	}
+	if _, err := os.Stat(zipPath); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("partial archive was not removed: %v", err)
+	}
+}
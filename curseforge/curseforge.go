@@ -0,0 +1,103 @@
+// Package curseforge implements a minimal client for the official CurseForge API.
+//
+// API documentation: https://docs.curseforge.com/rest-api/
+package curseforge
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// APIBaseURL is the base URL of the CurseForge API.
+const APIBaseURL = "https://api.curseforge.com"
+
+// ErrDownloadURLUnavailable is returned when the API does not provide a download URL for a file,
+// usually because the project does not allow third-party distribution.
+var ErrDownloadURLUnavailable = errors.New("download URL unavailable")
+
+// Client is a client for the CurseForge API.
+type Client struct {
+	client  *http.Client
+	baseURL string
+	apiKey  string
+}
+
+// NewClient returns a new [Client] that authenticates with the given API key.
+func NewClient(apiKey string, opts ...ClientOption) *Client {
+	c := Client{
+		client:  http.DefaultClient,
+		baseURL: APIBaseURL,
+		apiKey:  apiKey,
+	}
+	for _, opt := range opts {
+		opt(&c)
+	}
+	return &c
+}
+
+// ClientOption configures a [Client].
+type ClientOption func(*Client)
+
+// WithHTTPClient sets the HTTP client used to send API requests.
+// The default is [http.DefaultClient].
+func WithHTTPClient(client *http.Client) ClientOption {
+	return func(c *Client) {
+		if client != nil {
+			c.client = client
+		}
+	}
+}
+
+// WithBaseURL sets the base URL of the API.
+// An empty base URL keeps the default [APIBaseURL].
+func WithBaseURL(baseURL string) ClientOption {
+	return func(c *Client) {
+		if baseURL != "" {
+			c.baseURL = baseURL
+		}
+	}
+}
+
+// fileDownloadURLResponse is the response of GET /v1/mods/{modId}/files/{fileId}/download-url.
+type fileDownloadURLResponse struct {
+	Data *string `json:"data"`
+}
+
+// GetFileDownloadURL returns the canonical download URL of the file with the given project and file IDs.
+func (c *Client) GetFileDownloadURL(ctx context.Context, projectID, fileID int64) (string, error) {
+	u, err := url.JoinPath(c.baseURL, "v1", "mods", strconv.FormatInt(projectID, 10), "files", strconv.FormatInt(fileID, 10), "download-url")
+	if err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header["Accept"] = []string{"application/json"}
+	req.Header["X-Api-Key"] = []string{c.apiKey}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+
+	var r fileDownloadURLResponse
+	if err = json.NewDecoder(resp.Body).Decode(&r); err != nil {
+		return "", fmt.Errorf("failed to decode response: %w", err)
+	}
+	if r.Data == nil || *r.Data == "" {
+		return "", ErrDownloadURLUnavailable
+	}
+	return *r.Data, nil
+}
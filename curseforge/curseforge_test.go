@@ -0,0 +1,79 @@
+package curseforge
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+const testAPIKey = "test-api-key"
+
+// newTestServer returns a test server that serves the download URL endpoint for project 1234,
+// with file 5678 available for download and file 5679 not.
+func newTestServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Api-Key") != testAPIKey {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		w.Header()["Content-Type"] = []string{"application/json"}
+		switch r.URL.Path {
+		case "/v1/mods/1234/files/5678/download-url":
+			_, _ = w.Write([]byte(`{"data":"https://edge.forgecdn.net/files/5/678/Example%2BMod.jar"}`))
+		case "/v1/mods/1234/files/5679/download-url":
+			_, _ = w.Write([]byte(`{"data":null}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestGetFileDownloadURL(t *testing.T) {
+	srv := newTestServer(t)
+	c := NewClient(testAPIKey, WithBaseURL(srv.URL))
+
+	url, err := c.GetFileDownloadURL(context.Background(), 1234, 5678)
+	if err != nil {
+		t.Fatalf("GetFileDownloadURL() error = %v", err)
+	}
+	if want := "https://edge.forgecdn.net/files/5/678/Example%2BMod.jar"; url != want {
+		t.Errorf("GetFileDownloadURL() = %q, want %q", url, want)
+	}
+}
+
+func TestGetFileDownloadURLUnavailable(t *testing.T) {
+	srv := newTestServer(t)
+	c := NewClient(testAPIKey, WithBaseURL(srv.URL))
+
+	if _, err := c.GetFileDownloadURL(context.Background(), 1234, 5679); !errors.Is(err, ErrDownloadURLUnavailable) {
+		t.Errorf("GetFileDownloadURL() error = %v, want %v", err, ErrDownloadURLUnavailable)
+	}
+}
+
+func TestGetFileDownloadURLErrors(t *testing.T) {
+	srv := newTestServer(t)
+	for _, c := range []struct {
+		name   string
+		apiKey string
+		fileID int64
+	}{
+		{"WrongAPIKey", "wrong", 5678},
+		{"NotFound", testAPIKey, 1},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			client := NewClient(c.apiKey, WithBaseURL(srv.URL))
+			url, err := client.GetFileDownloadURL(context.Background(), 1234, c.fileID)
+			if err == nil {
+				t.Fatalf("GetFileDownloadURL() = %q, want error", url)
+			}
+			if errors.Is(err, ErrDownloadURLUnavailable) {
+				t.Errorf("GetFileDownloadURL() error = %v, want error other than %v", err, ErrDownloadURLUnavailable)
+			}
+		})
+	}
+}
@@ -0,0 +1,47 @@
+package curseforge
+
+const (
+	// ManifestFileName is the name of the manifest file in a CurseForge modpack.
+	ManifestFileName = "manifest.json"
+
+	// ManifestType is the manifest type of Minecraft modpacks.
+	ManifestType = "minecraftModpack"
+
+	// ManifestVersion is the supported version of the manifest format.
+	ManifestVersion = 1
+
+	// DefaultOverrides is the conventional name of the overrides directory.
+	DefaultOverrides = "overrides"
+)
+
+// Manifest is the manifest.json of a CurseForge modpack,
+// as understood by the CurseForge app and compatible launchers when importing modpacks.
+type Manifest struct {
+	Minecraft       ManifestMinecraft `json:"minecraft"`
+	ManifestType    string            `json:"manifestType"`
+	ManifestVersion int               `json:"manifestVersion"`
+	Name            string            `json:"name"`
+	Version         string            `json:"version"`
+	Author          string            `json:"author"`
+	Files           []ManifestFile    `json:"files"`
+	Overrides       string            `json:"overrides"`
+}
+
+// ManifestMinecraft describes the Minecraft version and mod loaders of a modpack.
+type ManifestMinecraft struct {
+	Version    string           `json:"version"`
+	ModLoaders []ManifestLoader `json:"modLoaders"`
+}
+
+// ManifestLoader is a mod loader, identified as "{loader}-{version}", e.g. "forge-47.2.0".
+type ManifestLoader struct {
+	ID      string `json:"id"`
+	Primary bool   `json:"primary"`
+}
+
+// ManifestFile is a CurseForge file in a modpack.
+type ManifestFile struct {
+	ProjectID int64 `json:"projectID"`
+	FileID    int64 `json:"fileID"`
+	Required  bool  `json:"required"`
+}
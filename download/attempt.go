@@ -0,0 +1,92 @@
+package download
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	// attemptBackoff is the delay before the second attempt at downloading from the same URL.
+	// It doubles for each subsequent attempt.
+	attemptBackoff = time.Second
+
+	// maxAttemptBackoff is the maximum delay between attempts at downloading from the same URL.
+	maxAttemptBackoff = 30 * time.Second
+
+	// defaultMinAttemptTimeout is the minimum timeout of an attempt scaled by the minimum throughput,
+	// when no fixed timeout is set.
+	defaultMinAttemptTimeout = 30 * time.Second
+)
+
+var (
+	errContentMismatch = errors.New("downloaded content does not match expected hash sum")
+	errVerifyFailed    = errors.New("downloaded file failed verification")
+	errChunksFailed    = errors.New("chunked download failed")
+)
+
+// StatusError is recorded as the cause of a failed download when the server
+// responds with an unexpected status code.
+type StatusError struct {
+	StatusCode int
+}
+
+// Error implements [error].
+func (e *StatusError) Error() string {
+	return "unexpected status code " + strconv.Itoa(e.StatusCode) + " " + http.StatusText(e.StatusCode)
+}
+
+// retryableStatus returns whether a response with the given status code is worth retrying from the same URL.
+func retryableStatus(code int) bool {
+	return code == http.StatusTooManyRequests || code >= 500
+}
+
+// failAttempt records err as the cause of the current download attempt's failure,
+// and whether the attempt may succeed if repeated against the same URL.
+func (j *Job) failAttempt(err error, retryable bool) {
+	j.lastErr = err
+	j.lastErrRetryable = retryable
+}
+
+// failStatus records an unexpected status code as the cause of the current download attempt's failure.
+func (j *Job) failStatus(code int) {
+	j.failAttempt(&StatusError{StatusCode: code}, retryableStatus(code))
+}
+
+// backoffDelay returns the delay before the given attempt at downloading from the same URL,
+// counting from 1 for the first attempt.
+func backoffDelay(attempt int) time.Duration {
+	d := attemptBackoff
+	for i := 2; i < attempt && d < maxAttemptBackoff; i++ {
+		d *= 2
+	}
+	return min(d, maxAttemptBackoff)
+}
+
+// attemptTimeout returns the timeout of each attempt at downloading a file of the given size.
+// Zero means no timeout.
+func (cfg *config) attemptTimeout(size int64) time.Duration {
+	if cfg.minThroughput <= 0 {
+		return cfg.timeout
+	}
+	floor := cfg.timeout
+	if floor <= 0 {
+		floor = defaultMinAttemptTimeout
+	}
+	scaled := time.Duration(float64(size) / float64(cfg.minThroughput) * float64(time.Second))
+	return max(floor, scaled)
+}
+
+// sleepCtx waits for the given duration, and returns false if ctx is done first.
+func sleepCtx(ctx context.Context, d time.Duration) bool {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
@@ -0,0 +1,238 @@
+package download
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newFlakyServer returns a test server that fails the first failures requests
+// with the given status code, and serves content afterwards.
+// If status is zero, failing requests send half the content and abort instead.
+func newFlakyServer(t *testing.T, content []byte, failures int32, status int, requests *atomic.Int32) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) > failures {
+			http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(content))
+			return
+		}
+		if status != 0 {
+			w.WriteHeader(status)
+			return
+		}
+		w.Header()["Content-Length"] = []string{strconv.Itoa(len(content))}
+		_, _ = w.Write(content[:len(content)/2])
+		panic(http.ErrAbortHandler)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestJobSucceedsOnSecondAttempt(t *testing.T) {
+	content := testContent(10000)
+	for _, c := range []struct {
+		name   string
+		status int
+	}{
+		{"ServiceUnavailable", http.StatusServiceUnavailable},
+		{"TooManyRequests", http.StatusTooManyRequests},
+		{"AbortedBody", 0},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			var requests atomic.Int32
+			srv := newFlakyServer(t, content, 1, c.status, &requests)
+
+			j := newTestJob(srv.URL, content)
+			if _, ok := runTestJob(t, &j, nil, WithMaxAttempts(3)); !ok {
+				t.Fatalf("job failed: %v", j.lastErr)
+			}
+			if got := requests.Load(); got != 2 {
+				t.Errorf("requests = %d, want 2", got)
+			}
+			if !bytes.Equal(targetBytes(t, &j), content) {
+				t.Error("downloaded content does not match")
+			}
+		})
+	}
+}
+
+func TestJobExhaustsAttempts(t *testing.T) {
+	content := testContent(1000)
+	var requests atomic.Int32
+	srv := newFlakyServer(t, content, 100, http.StatusBadGateway, &requests)
+
+	var attempts []int
+	cfg := newConfig(http.DefaultClient, []Option{WithMaxAttempts(3)})
+	cfg.backoff = func(attempt int) time.Duration {
+		attempts = append(attempts, attempt)
+		return 0
+	}
+	j := newTestJob(srv.URL, content)
+	if _, ok := j.runWithConfig(context.Background(), testLogger, cfg); ok {
+		t.Fatal("job succeeded, want failure")
+	}
+	if got := requests.Load(); got != 3 {
+		t.Errorf("requests = %d, want 3", got)
+	}
+	if want := []int{2, 3}; !slices.Equal(attempts, want) {
+		t.Errorf("backed off before attempts %v, want %v", attempts, want)
+	}
+	var statusErr *StatusError
+	if !errors.As(j.lastErr, &statusErr) || statusErr.StatusCode != http.StatusBadGateway {
+		t.Errorf("lastErr = %v, want status 502", j.lastErr)
+	}
+}
+
+func TestJobDoesNotRetryNonRetryableStatus(t *testing.T) {
+	content := testContent(1000)
+	var requests atomic.Int32
+	srv := newFlakyServer(t, content, 100, http.StatusNotFound, &requests)
+
+	j := newTestJob(srv.URL, content)
+	if _, ok := runTestJob(t, &j, nil, WithMaxAttempts(3)); ok {
+		t.Fatal("job succeeded, want failure")
+	}
+	if got := requests.Load(); got != 1 {
+		t.Errorf("requests = %d, want 1", got)
+	}
+}
+
+func TestWorkerFleetReportsLastError(t *testing.T) {
+	content := testContent(1000)
+	var requests atomic.Int32
+	srv := newFlakyServer(t, content, 100, http.StatusInternalServerError, &requests)
+
+	jobCh := make(chan Job)
+	wf := NewWorkerFleet(context.Background(), testLogger, http.DefaultClient, 1, jobCh)
+	jobCh <- newTestJob(srv.URL, content)
+	close(jobCh)
+	wf.Wait()
+
+	var statusErr *StatusError
+	if err := wf.Stats().LastError; !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusInternalServerError {
+		t.Errorf("Stats().LastError = %v, want status 500", err)
+	}
+}
+
+func TestBackoffDelay(t *testing.T) {
+	for _, c := range []struct {
+		attempt int
+		want    time.Duration
+	}{
+		{2, attemptBackoff},
+		{3, 2 * attemptBackoff},
+		{4, 4 * attemptBackoff},
+		{100, maxAttemptBackoff},
+	} {
+		if got := backoffDelay(c.attempt); got != c.want {
+			t.Errorf("backoffDelay(%d) = %v, want %v", c.attempt, got, c.want)
+		}
+	}
+}
+
+func TestAttemptTimeout(t *testing.T) {
+	for _, c := range []struct {
+		name          string
+		timeout       time.Duration
+		minThroughput int64
+		size          int64
+		want          time.Duration
+	}{
+		{"None", 0, 0, 1 << 20, 0},
+		{"Fixed", time.Minute, 0, 1 << 30, time.Minute},
+		{"SmallFileGetsDefaultFloor", 0, 1 << 20, 1 << 10, defaultMinAttemptTimeout},
+		{"SmallFileGetsFixedFloor", 5 * time.Second, 1 << 20, 1 << 10, 5 * time.Second},
+		{"LargeFileScaled", 5 * time.Second, 1 << 20, 100 << 20, 100 * time.Second},
+		{"UnknownSize", 5 * time.Second, 1 << 20, 0, 5 * time.Second},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			cfg := newConfig(http.DefaultClient, []Option{WithTimeout(c.timeout), WithMinThroughput(c.minThroughput)})
+			if got := cfg.attemptTimeout(c.size); got != c.want {
+				t.Errorf("attemptTimeout(%d) = %v, want %v", c.size, got, c.want)
+			}
+		})
+	}
+}
+
+// newTricklingServer returns a server that sends content in chunks of chunkSize bytes,
+// pausing for interval before each chunk, without supporting range requests.
+func newTricklingServer(t *testing.T, content []byte, chunkSize int, interval time.Duration) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header()["Content-Length"] = []string{strconv.Itoa(len(content))}
+		w.WriteHeader(http.StatusOK)
+		for b := content; len(b) > 0; {
+			select {
+			case <-time.After(interval):
+			case <-r.Context().Done():
+				return
+			}
+			n := min(chunkSize, len(b))
+			if _, err := w.Write(b[:n]); err != nil {
+				return
+			}
+			w.(http.Flusher).Flush()
+			b = b[n:]
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestMinThroughputTimeout(t *testing.T) {
+	const floor = 100 * time.Millisecond
+
+	t.Run("SmallAndStalled", func(t *testing.T) {
+		// A small file gets the floor as its timeout, so a stalled transfer fails fast.
+		content := testContent(100)
+		srv := newTricklingServer(t, content, 10, time.Hour)
+		j := newTestJob(srv.URL, content)
+		start := time.Now()
+		if _, ok := runTestJob(t, &j, nil, WithTimeout(floor), WithMinThroughput(1000), WithMaxAttempts(1)); ok {
+			t.Fatal("job succeeded, want failure")
+		}
+		if elapsed := time.Since(start); elapsed > 5*time.Second {
+			t.Errorf("download took %v with a timeout of %v", elapsed, floor)
+		}
+		if !errors.Is(j.lastErr, context.DeadlineExceeded) {
+			t.Errorf("lastErr = %v, want %v", j.lastErr, context.DeadlineExceeded)
+		}
+	})
+
+	// A large file sent at 100 KB/s takes about 200ms, longer than the floor.
+	content := testContent(20000)
+	const (
+		chunkSize = 1000
+		interval  = 10 * time.Millisecond
+	)
+
+	t.Run("LargeAndSlow", func(t *testing.T) {
+		// At a minimum of 20 KB/s, the timeout scales to 1s.
+		srv := newTricklingServer(t, content, chunkSize, interval)
+		j := newTestJob(srv.URL, content)
+		if _, ok := runTestJob(t, &j, nil, WithTimeout(floor), WithMinThroughput(20000), WithMaxAttempts(1)); !ok {
+			t.Fatalf("job failed: %v", j.lastErr)
+		}
+		if !bytes.Equal(targetBytes(t, &j), content) {
+			t.Error("downloaded content does not match")
+		}
+	})
+
+	t.Run("LargeAndSlowWithoutScaling", func(t *testing.T) {
+		srv := newTricklingServer(t, content, chunkSize, interval)
+		j := newTestJob(srv.URL, content)
+		if _, ok := runTestJob(t, &j, nil, WithTimeout(floor), WithMaxAttempts(1)); ok {
+			t.Fatal("job succeeded within the fixed timeout, want failure")
+		}
+		if !errors.Is(j.lastErr, context.DeadlineExceeded) {
+			t.Errorf("lastErr = %v, want %v", j.lastErr, context.DeadlineExceeded)
+		}
+	})
+}
@@ -0,0 +1,233 @@
+package download
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/lmittmann/tint"
+)
+
+// probeRanges sends a HEAD request to the given URL, and returns the response
+// if the server supports range requests for a file of the expected size.
+func (j *Job) probeRanges(ctx context.Context, cfg *config, url string) (*http.Response, bool) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, url, nil)
+	if err != nil {
+		return nil, false
+	}
+	if j.UserAgent != "" {
+		req.Header["User-Agent"] = []string{j.UserAgent}
+	}
+	req.Header["Accept-Encoding"] = []string{"identity"}
+
+	resp, err := cfg.client.Do(req)
+	if err != nil {
+		return nil, false
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Accept-Ranges") != "bytes" || resp.ContentLength != j.Size || contentEncoding(resp) != "" {
+		return nil, false
+	}
+	return resp, true
+}
+
+// downloadChunked downloads the file from the given URL in concurrent range requests,
+// writing each chunk at its offset in the target file, which is first extended to the expected size.
+// probe is the response to the HEAD request that found the server to support range requests.
+//
+// It returns the modification time of the file as reported by the server,
+// the number of bytes downloaded, and whether the download succeeded.
+// On failure, the cause of the first chunk to fail is recorded in the job's lastErr.
+func (j *Job) downloadChunked(ctx context.Context, logger *slog.Logger, cfg *config, url string, probe *http.Response) (mtime time.Time, n int64, ok bool) {
+	allocate := j.TargetFile.Truncate
+	if cfg.preallocate {
+		allocate = func(size int64) error {
+			return preallocateFile(j.TargetFile, size)
+		}
+	}
+	if err := allocate(j.Size); err != nil {
+		logger.LogAttrs(ctx, slog.LevelWarn, "Failed to preallocate file",
+			slog.String("name", j.TargetFile.Name()),
+			slog.Int64("size", j.Size),
+			tint.Err(err),
+		)
+		j.failAttempt(err, false)
+		return time.Time{}, 0, false
+	}
+
+	logger.LogAttrs(ctx, slog.LevelInfo, "Downloading file in chunks",
+		slog.String("name", j.TargetFile.Name()),
+		slog.String("url", url),
+		slog.Int("chunks", cfg.chunks),
+	)
+
+	var sp *sharedProgress
+	if cfg.progressFunc != nil {
+		sp = &sharedProgress{f: cfg.progressFunc, url: url, bytesTotal: j.Size}
+		defer sp.report()
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg      sync.WaitGroup
+		total   atomic.Int64
+		failure atomic.Pointer[chunkFailure]
+	)
+	chunkSize := (j.Size + int64(cfg.chunks) - 1) / int64(cfg.chunks)
+	for start := int64(0); start < j.Size; start += chunkSize {
+		end := min(start+chunkSize, j.Size) - 1
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			cn, f := j.downloadChunk(ctx, logger, cfg, url, start, end, sp)
+			total.Add(cn)
+			if f != nil {
+				// The other chunks fail once canceled, so only the first failure is the cause.
+				failure.CompareAndSwap(nil, f)
+				cancel()
+			}
+		}()
+	}
+	wg.Wait()
+
+	n = total.Load()
+	if f := failure.Load(); f != nil {
+		// Partially written chunks leave holes, so the content cannot be resumed from.
+		_ = truncateFile(j.TargetFile)
+		err := errChunksFailed
+		if f.err != nil {
+			err = fmt.Errorf("%w: %w", errChunksFailed, f.err)
+		}
+		j.failAttempt(err, f.retryable)
+		return time.Time{}, n, false
+	}
+
+	if err := j.verifyContent(ctx, logger, url); err != nil {
+		_ = truncateFile(j.TargetFile)
+		j.failAttempt(err, false)
+		return time.Time{}, n, false
+	}
+
+	logger.LogAttrs(ctx, slog.LevelInfo, "Downloaded file",
+		slog.String("name", j.TargetFile.Name()),
+		slog.String("url", url),
+		slog.Int("chunks", cfg.chunks),
+	)
+
+	return j.mtimeFromResponse(ctx, logger, probe), n, true
+}
+
+// chunkFailure is the cause of a chunk's failure.
+type chunkFailure struct {
+	// err is the cause of the failure, or nil if there's no error to report,
+	// such as when the response is not the requested range.
+	err error
+
+	// retryable is whether the download may succeed if attempted again from the same URL.
+	retryable bool
+}
+
+// downloadChunk downloads the bytes between start and end, inclusive, into the target file.
+// It returns the number of bytes downloaded, and the cause of the failure
+// if the chunk was not downloaded in full.
+func (j *Job) downloadChunk(ctx context.Context, logger *slog.Logger, cfg *config, url string, start, end int64, sp *sharedProgress) (int64, *chunkFailure) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		logger.LogAttrs(ctx, slog.LevelWarn, "Failed to create request",
+			slog.String("name", j.TargetFile.Name()),
+			slog.String("url", url),
+			tint.Err(err),
+		)
+		return 0, &chunkFailure{err: err}
+	}
+	if j.UserAgent != "" {
+		req.Header["User-Agent"] = []string{j.UserAgent}
+	}
+	req.Header["Accept-Encoding"] = []string{"identity"}
+	req.Header["Range"] = []string{"bytes=" + strconv.FormatInt(start, 10) + "-" + strconv.FormatInt(end, 10)}
+
+	resp, err := cfg.client.Do(req)
+	if err != nil {
+		if ctx.Err() == nil {
+			logger.LogAttrs(ctx, slog.LevelWarn, "Failed to send request",
+				slog.String("name", j.TargetFile.Name()),
+				slog.String("url", url),
+				tint.Err(err),
+			)
+		}
+		return 0, &chunkFailure{err: err, retryable: !isRedirectPolicyError(err)}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusPartialContent {
+		logger.LogAttrs(ctx, slog.LevelWarn, "Unexpected status code",
+			slog.String("name", j.TargetFile.Name()),
+			slog.String("url", url),
+			slog.Int("status", resp.StatusCode),
+		)
+		return 0, &chunkFailure{err: &StatusError{StatusCode: resp.StatusCode}, retryable: retryableStatus(resp.StatusCode)}
+	}
+	if enc := contentEncoding(resp); enc != "" {
+		logger.LogAttrs(ctx, slog.LevelWarn, "Unexpected Content-Encoding of partial content",
+			slog.String("name", j.TargetFile.Name()),
+			slog.String("url", url),
+			slog.String("Content-Encoding", enc),
+		)
+		return 0, &chunkFailure{}
+	}
+	if rangeStart, ok := contentRangeStart(resp); !ok || rangeStart != start {
+		logger.LogAttrs(ctx, slog.LevelWarn, "Unexpected Content-Range",
+			slog.String("name", j.TargetFile.Name()),
+			slog.String("url", url),
+			slog.Int64("offset", start),
+			slog.String("Content-Range", resp.Header.Get("Content-Range")),
+		)
+		return 0, &chunkFailure{}
+	}
+
+	length := end - start + 1
+	var body io.Reader = io.LimitReader(resp.Body, length)
+	if cfg.rateLimiter != nil {
+		body = &rateLimitedReader{ctx: ctx, r: body, limiter: cfg.rateLimiter}
+	}
+	if cfg.received != nil {
+		body = &countingReader{r: body, n: cfg.received}
+	}
+	if sp != nil {
+		body = &sharedProgressReader{r: body, sp: sp}
+	}
+
+	n, err := io.Copy(io.NewOffsetWriter(j.TargetFile, start), body)
+	if err != nil {
+		if ctx.Err() == nil {
+			logger.LogAttrs(ctx, slog.LevelWarn, "Failed to download chunk",
+				slog.String("name", j.TargetFile.Name()),
+				slog.String("url", url),
+				slog.Int64("start", start),
+				slog.Int64("end", end),
+				tint.Err(err),
+			)
+		}
+		return n, &chunkFailure{err: err, retryable: true}
+	}
+	if n != length {
+		logger.LogAttrs(ctx, slog.LevelWarn, "Chunk ended early",
+			slog.String("name", j.TargetFile.Name()),
+			slog.String("url", url),
+			slog.Int64("start", start),
+			slog.Int64("end", end),
+			slog.Int64("received", n),
+		)
+		return n, &chunkFailure{err: io.ErrUnexpectedEOF, retryable: true}
+	}
+	return n, nil
+}
@@ -0,0 +1,98 @@
+package download
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newRangedServer returns a server that serves content with [http.ServeContent],
+// except for range requests starting at failAt, which are answered with failStatus
+// for the first failures times, or every time if failures is negative.
+// It counts the GET requests in gets.
+func newRangedServer(t *testing.T, content []byte, failAt int64, failStatus int, failures int32, gets *atomic.Int32) *httptest.Server {
+	t.Helper()
+	var failed atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			gets.Add(1)
+			if start, ok := parseRangeStart(r.Header.Get("Range")); ok && start == failAt && (failures < 0 || failed.Add(1) <= failures) {
+				http.Error(w, http.StatusText(failStatus), failStatus)
+				return
+			}
+		}
+		http.ServeContent(w, r, "test.bin", time.Time{}, bytes.NewReader(content))
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// parseRangeStart returns the start of a "bytes={start}-{end}" range header.
+func parseRangeStart(header string) (int64, bool) {
+	var start, end int64
+	if _, err := fmt.Sscanf(header, "bytes=%d-%d", &start, &end); err != nil {
+		return 0, false
+	}
+	return start, true
+}
+
+func TestJobChunkedDownload(t *testing.T) {
+	content := testContent(40000)
+	var gets atomic.Int32
+	srv := newRangedServer(t, content, -1, 0, 0, &gets)
+
+	j := newTestJob(srv.URL, content)
+	if _, ok := runTestJob(t, &j, nil, WithChunkedDownload(1000, 4)); !ok {
+		t.Fatalf("job failed: %v", j.lastErr)
+	}
+	if !bytes.Equal(targetBytes(t, &j), content) {
+		t.Error("downloaded content does not match")
+	}
+	if got := gets.Load(); got != 4 {
+		t.Errorf("GET requests = %d, want 4", got)
+	}
+}
+
+func TestJobChunkedDownloadReportsChunkStatus(t *testing.T) {
+	content := testContent(40000)
+	var gets atomic.Int32
+	srv := newRangedServer(t, content, 10000, http.StatusNotFound, -1, &gets)
+
+	j := newTestJob(srv.URL, content)
+	if _, ok := runTestJob(t, &j, nil, WithChunkedDownload(1000, 4), WithMaxAttempts(3)); ok {
+		t.Fatal("job succeeded, want failure")
+	}
+	var statusErr *StatusError
+	if !errors.As(j.lastErr, &statusErr) || statusErr.StatusCode != http.StatusNotFound {
+		t.Errorf("lastErr = %v, want status error 404", j.lastErr)
+	}
+	if !errors.Is(j.lastErr, errChunksFailed) {
+		t.Errorf("lastErr = %v, want %v", j.lastErr, errChunksFailed)
+	}
+	if j.lastErrRetryable {
+		t.Error("404 on a chunk is retryable")
+	}
+	// Not retried, as 404 will not go away.
+	if got := gets.Load(); got > 4 {
+		t.Errorf("GET requests = %d, want at most 4", got)
+	}
+}
+
+func TestJobChunkedDownloadRetriesRetryableChunkFailure(t *testing.T) {
+	content := testContent(40000)
+	var gets atomic.Int32
+	srv := newRangedServer(t, content, 10000, http.StatusServiceUnavailable, 1, &gets)
+
+	j := newTestJob(srv.URL, content)
+	if _, ok := runTestJob(t, &j, nil, WithChunkedDownload(1000, 4), WithMaxAttempts(2)); !ok {
+		t.Fatalf("job failed: %v", j.lastErr)
+	}
+	if !bytes.Equal(targetBytes(t, &j), content) {
+		t.Error("downloaded content does not match")
+	}
+}
@@ -0,0 +1,142 @@
+package download
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/lmittmann/tint"
+)
+
+// contentKey identifies file content by its size and hash sum.
+type contentKey struct {
+	size int64
+	sum  string
+}
+
+// contentIndex maps file content to the first job in a fleet that downloads it,
+// so that jobs for identical files at other paths copy the downloaded file
+// instead of downloading it again.
+type contentIndex struct {
+	mu      sync.Mutex
+	entries map[contentKey]*contentEntry
+}
+
+// contentEntry is the download of some content by a job.
+type contentEntry struct {
+	done chan struct{}
+
+	// path is the path to the downloaded file. Empty if the download failed.
+	// It's only safe to read after done is closed.
+	path string
+}
+
+// claim looks up the content of the job.
+// If no other job has claimed the content, it returns a new entry, and true.
+// The caller must complete the entry when the job finishes.
+// Otherwise, it returns the entry of the job that claimed the content first, and false.
+func (ci *contentIndex) claim(j *Job) (*contentEntry, bool) {
+	key := contentKey{size: j.Size, sum: string(j.Sum)}
+
+	ci.mu.Lock()
+	defer ci.mu.Unlock()
+
+	if e, ok := ci.entries[key]; ok {
+		return e, false
+	}
+	if ci.entries == nil {
+		ci.entries = make(map[contentKey]*contentEntry)
+	}
+	e := &contentEntry{done: make(chan struct{})}
+	ci.entries[key] = e
+	return e, true
+}
+
+// complete records the path to the downloaded file, or an empty path if the download failed,
+// and wakes up jobs waiting for the content.
+func (e *contentEntry) complete(path string) {
+	e.path = path
+	close(e.done)
+}
+
+// wait waits for the job that claimed the content to finish,
+// and returns the path to the downloaded file, or an empty path if the download failed.
+func (e *contentEntry) wait(ctx context.Context) string {
+	select {
+	case <-e.done:
+		return e.path
+	case <-ctx.Done():
+		return ""
+	}
+}
+
+// dedupable returns whether the job's content can be looked up in a content index.
+// The content must be verifiable, so that a copy can be trusted as much as a download,
+// and downloaded to the filesystem, so that it can be copied by path.
+func (j *Job) dedupable() bool {
+	_, isOSFile := osFile(j.TargetFile)
+	return isOSFile && j.NewHash != nil && len(j.Sum) > 0
+}
+
+// finalPath returns the path that the job's target file ends up at.
+func (j *Job) finalPath() string {
+	if j.RenameTo != "" {
+		return j.RenameTo
+	}
+	return j.TargetFile.Name()
+}
+
+// copyFromIdentical copies the content of the identical file at path into the target file,
+// and verifies it. It returns the modification time of the source file, and whether the copy succeeded.
+func (j *Job) copyFromIdentical(ctx context.Context, logger *slog.Logger, path string) (time.Time, bool) {
+	src, err := os.Open(path)
+	if err != nil {
+		logger.LogAttrs(ctx, slog.LevelWarn, "Failed to open identical file",
+			slog.String("path", path),
+			tint.Err(err),
+		)
+		return time.Time{}, false
+	}
+	defer src.Close()
+
+	fi, err := src.Stat()
+	if err != nil {
+		logger.LogAttrs(ctx, slog.LevelWarn, "Failed to stat identical file",
+			slog.String("path", path),
+			tint.Err(err),
+		)
+		return time.Time{}, false
+	}
+
+	if err = truncateFile(j.TargetFile); err != nil {
+		logger.LogAttrs(ctx, slog.LevelWarn, "Failed to truncate file",
+			slog.String("name", j.TargetFile.Name()),
+			tint.Err(err),
+		)
+		return time.Time{}, false
+	}
+
+	if _, err = io.Copy(j.TargetFile, src); err != nil {
+		logger.LogAttrs(ctx, slog.LevelWarn, "Failed to copy identical file",
+			slog.String("src", path),
+			slog.String("dst", j.TargetFile.Name()),
+			tint.Err(err),
+		)
+		_ = truncateFile(j.TargetFile)
+		return time.Time{}, false
+	}
+
+	if j.verifyContent(ctx, logger, path) != nil {
+		_ = truncateFile(j.TargetFile)
+		return time.Time{}, false
+	}
+
+	logger.LogAttrs(ctx, slog.LevelInfo, "Copied identical file",
+		slog.String("src", path),
+		slog.String("dst", j.TargetFile.Name()),
+	)
+	return fi.ModTime(), true
+}
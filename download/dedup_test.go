@@ -0,0 +1,84 @@
+package download
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync/atomic"
+	"testing"
+)
+
+// runFleet runs the jobs through a worker fleet, and returns the fleet once it's done.
+func runFleet(t *testing.T, jobs ...Job) *WorkerFleet {
+	t.Helper()
+	jobCh := make(chan Job)
+	wf := NewWorkerFleet(context.Background(), testLogger, http.DefaultClient, 2, jobCh)
+	for _, j := range jobs {
+		jobCh <- j
+	}
+	close(jobCh)
+	wf.Wait()
+	return wf
+}
+
+// readFile returns the content of the file at path.
+func readFile(t *testing.T, path string) []byte {
+	t.Helper()
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestWorkerFleetDeduplicatesIdenticalContent(t *testing.T) {
+	content := testContent(10000)
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		_, _ = w.Write(content)
+	}))
+	defer srv.Close()
+
+	// Two manifest entries at different paths, sharing the same sha1.
+	j1 := newOSFileJob(t, srv.URL+"/a.jar", content)
+	j2 := newOSFileJob(t, srv.URL+"/b.jar", content)
+	wf := runFleet(t, j1, j2)
+
+	if got := wf.Failures(); got != 0 {
+		t.Fatalf("Failures() = %d, want 0", got)
+	}
+	if got := requests.Load(); got != 1 {
+		t.Errorf("requests = %d, want 1", got)
+	}
+	for _, j := range []Job{j1, j2} {
+		if got := readFile(t, j.TargetFile.Name()); !bytes.Equal(got, content) {
+			t.Errorf("content of %q does not match", j.TargetFile.Name())
+		}
+	}
+}
+
+func TestWorkerFleetDownloadsIdenticalContentAfterFailure(t *testing.T) {
+	content := testContent(10000)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/broken.jar" {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write(content)
+	}))
+	defer srv.Close()
+
+	broken := newOSFileJob(t, srv.URL+"/broken.jar", content)
+	ok := newOSFileJob(t, srv.URL+"/ok.jar", content)
+	wf := runFleet(t, broken, ok)
+
+	if got := wf.Failures(); got != 1 {
+		t.Errorf("Failures() = %d, want 1", got)
+	}
+	if got := readFile(t, ok.TargetFile.Name()); !bytes.Equal(got, content) {
+		t.Error("the job waiting on the failed download did not download the content itself")
+	}
+}
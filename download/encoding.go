@@ -0,0 +1,48 @@
+package download
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// errUnsupportedContentEncoding is returned when a response has a content encoding that cannot be decoded.
+var errUnsupportedContentEncoding = errors.New("unsupported content encoding")
+
+// contentEncoding returns the normalized Content-Encoding of the response.
+// The empty string means the content is not encoded.
+func contentEncoding(resp *http.Response) string {
+	enc := strings.ToLower(strings.TrimSpace(resp.Header.Get("Content-Encoding")))
+	if enc == "identity" {
+		return ""
+	}
+	return enc
+}
+
+// decodeBody returns a reader of the decoded response body.
+// Closing the returned reader releases the decoder, but does not close the response body.
+//
+// Requests are sent with "Accept-Encoding: identity", but some servers still send pre-compressed content.
+func decodeBody(resp *http.Response) (io.ReadCloser, error) {
+	switch contentEncoding(resp) {
+	case "":
+		return io.NopCloser(resp.Body), nil
+	case "gzip", "x-gzip":
+		zr, err := gzip.NewReader(resp.Body)
+		if err != nil {
+			return nil, err
+		}
+		return zr, nil
+	case "deflate":
+		zr, err := zlib.NewReader(resp.Body)
+		if err != nil {
+			return nil, err
+		}
+		return zr, nil
+	default:
+		return nil, errUnsupportedContentEncoding
+	}
+}
@@ -0,0 +1,93 @@
+package download
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// newEncodedServer returns a server that serves body with the given Content-Encoding,
+// regardless of the request's Accept-Encoding.
+func newEncodedServer(t *testing.T, encoding string, body []byte) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header()["Content-Encoding"] = []string{encoding}
+		_, _ = w.Write(body)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestJobDecodesEncodedResponse(t *testing.T) {
+	content := testContent(20000)
+
+	var gzipped, deflated bytes.Buffer
+	gw := gzip.NewWriter(&gzipped)
+	_, _ = gw.Write(content)
+	if err := gw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	zw := zlib.NewWriter(&deflated)
+	_, _ = zw.Write(content)
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, c := range []struct {
+		encoding string
+		body     []byte
+	}{
+		{"gzip", gzipped.Bytes()},
+		{"x-gzip", gzipped.Bytes()},
+		{"deflate", deflated.Bytes()},
+		{"identity", content},
+	} {
+		t.Run(c.encoding, func(t *testing.T) {
+			srv := newEncodedServer(t, c.encoding, c.body)
+			j := newTestJob(srv.URL, content)
+			if _, ok := runTestJob(t, &j, nil); !ok {
+				t.Fatalf("job failed: %v", j.lastErr)
+			}
+			if !bytes.Equal(targetBytes(t, &j), content) {
+				t.Error("decoded content does not match")
+			}
+		})
+	}
+}
+
+func TestJobFailsOnUndecodableResponse(t *testing.T) {
+	content := testContent(100)
+	for _, encoding := range []string{"gzip", "deflate", "br"} {
+		t.Run(encoding, func(t *testing.T) {
+			srv := newEncodedServer(t, encoding, content)
+			j := newTestJob(srv.URL, content)
+			if _, ok := runTestJob(t, &j, nil); ok {
+				t.Fatal("job succeeded, want failure")
+			}
+			if j.lastErr == nil {
+				t.Error("lastErr = nil, want the decoding error")
+			}
+		})
+	}
+}
+
+func TestDecodeBodyErrorReturnsNilReader(t *testing.T) {
+	for _, encoding := range []string{"gzip", "deflate", "br"} {
+		resp := &http.Response{
+			Header: http.Header{"Content-Encoding": []string{encoding}},
+			Body:   io.NopCloser(strings.NewReader("not compressed")),
+		}
+		r, err := decodeBody(resp)
+		if err == nil {
+			t.Errorf("decodeBody() with %s error = nil, want error", encoding)
+		}
+		if r != nil {
+			t.Errorf("decodeBody() with %s reader = %#v, want nil", encoding, r)
+		}
+	}
+}
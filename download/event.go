@@ -0,0 +1,60 @@
+package download
+
+import "errors"
+
+// ErrDownloadFailed is the error passed to [EventHandler.OnError] when a download job fails.
+// The details are logged.
+var ErrDownloadFailed = errors.New("download failed")
+
+// Event describes a file that an [EventHandler] is notified about.
+type Event struct {
+	// Path is the file's destination path.
+	Path string
+
+	// URL is the file's download URL.
+	URL string
+
+	// Size is the expected size of the file.
+	// Zero means the size is unknown.
+	Size int64
+
+	// Bytes is the number of bytes downloaded.
+	// It is only set for [EventHandler.OnDownloadComplete] and [EventHandler.OnError].
+	Bytes int64
+
+	// Reason explains why the download was skipped, e.g. "skipped" or "moved".
+	// It is only set for [EventHandler.OnSkip].
+	Reason string
+
+	// Err is the cause of the failure.
+	// It is only set for [EventHandler.OnError].
+	Err error
+}
+
+// EventHandler is notified of the progress of jobs alongside logging,
+// for programs that embed the fleets and need to react to each file.
+//
+// The methods are called from the workers' goroutines, so they must be safe for concurrent use.
+type EventHandler interface {
+	// OnDownloadStart is called when a file starts downloading.
+	OnDownloadStart(e Event)
+
+	// OnDownloadComplete is called when a file has been downloaded.
+	OnDownloadComplete(e Event)
+
+	// OnSkip is called when a file does not need to be downloaded,
+	// because it's already in place or has been migrated from an existing file.
+	OnSkip(e Event)
+
+	// OnError is called when a file fails to be prechecked or downloaded.
+	OnError(e Event)
+}
+
+// jobEvent returns the event for the job.
+func (j *Job) jobEvent() Event {
+	return Event{
+		Path: j.finalPath(),
+		URL:  j.DownloadURL,
+		Size: j.Size,
+	}
+}
@@ -0,0 +1,200 @@
+package download
+
+import (
+	"errors"
+	"io"
+	"os"
+	"sync"
+)
+
+// File is the target of a download job.
+//
+// [*os.File] is the default implementation. Other implementations, such as [MemoryFile],
+// can be used to download into something other than the filesystem. For targets that are
+// not *os.File, features that operate on paths, such as setting the modification time,
+// renaming into place, removing empty files, and copying identical files, are skipped.
+type File interface {
+	io.Reader
+	io.ReaderAt
+	io.Writer
+	io.WriterAt
+	io.Seeker
+	io.ReaderFrom
+	io.Closer
+
+	// Name returns the name of the file, for logging.
+	Name() string
+
+	// Truncate changes the size of the file.
+	Truncate(size int64) error
+}
+
+var _ File = (*os.File)(nil)
+
+// osFile returns the file as an *os.File, if it is one.
+func osFile(f File) (*os.File, bool) {
+	osf, ok := f.(*os.File)
+	return osf, ok
+}
+
+var (
+	errNegativeOffset = errors.New("negative offset")
+	errInvalidWhence  = errors.New("invalid whence")
+)
+
+// MemoryFile is a [File] backed by an in-memory buffer.
+// It is safe for concurrent use.
+type MemoryFile struct {
+	mu     sync.Mutex
+	name   string
+	buf    []byte
+	offset int64
+}
+
+// NewMemoryFile returns a new empty in-memory file with the given name.
+func NewMemoryFile(name string) *MemoryFile {
+	return &MemoryFile{name: name}
+}
+
+// Bytes returns the content of the file.
+// The returned slice is only valid until the next write to the file.
+func (f *MemoryFile) Bytes() []byte {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.buf
+}
+
+// Name implements [File.Name].
+func (f *MemoryFile) Name() string {
+	return f.name
+}
+
+// Read implements [io.Reader.Read].
+func (f *MemoryFile) Read(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	n, err := f.readAt(p, f.offset)
+	f.offset += int64(n)
+	return n, err
+}
+
+// ReadAt implements [io.ReaderAt.ReadAt].
+func (f *MemoryFile) ReadAt(p []byte, off int64) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.readAt(p, off)
+}
+
+func (f *MemoryFile) readAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, errNegativeOffset
+	}
+	if off >= int64(len(f.buf)) {
+		return 0, io.EOF
+	}
+	n := copy(p, f.buf[off:])
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// Write implements [io.Writer.Write].
+func (f *MemoryFile) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	n, err := f.writeAt(p, f.offset)
+	f.offset += int64(n)
+	return n, err
+}
+
+// WriteAt implements [io.WriterAt.WriteAt].
+func (f *MemoryFile) WriteAt(p []byte, off int64) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.writeAt(p, off)
+}
+
+func (f *MemoryFile) writeAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, errNegativeOffset
+	}
+	if end := off + int64(len(p)); end > int64(len(f.buf)) {
+		f.grow(end)
+	}
+	return copy(f.buf[off:], p), nil
+}
+
+// grow extends the buffer to size bytes, zero-filling the new space.
+func (f *MemoryFile) grow(size int64) {
+	if size <= int64(cap(f.buf)) {
+		n := len(f.buf)
+		f.buf = f.buf[:size]
+		clear(f.buf[n:])
+		return
+	}
+	buf := make([]byte, size, max(size, 2*int64(cap(f.buf))))
+	copy(buf, f.buf)
+	f.buf = buf
+}
+
+// ReadFrom implements [io.ReaderFrom.ReadFrom].
+func (f *MemoryFile) ReadFrom(r io.Reader) (n int64, err error) {
+	b := make([]byte, 32*1024)
+	for {
+		nr, rerr := r.Read(b)
+		if nr > 0 {
+			if _, err = f.Write(b[:nr]); err != nil {
+				return n, err
+			}
+			n += int64(nr)
+		}
+		if rerr == io.EOF {
+			return n, nil
+		}
+		if rerr != nil {
+			return n, rerr
+		}
+	}
+}
+
+// Seek implements [io.Seeker.Seek].
+func (f *MemoryFile) Seek(offset int64, whence int) (int64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += f.offset
+	case io.SeekEnd:
+		offset += int64(len(f.buf))
+	default:
+		return 0, errInvalidWhence
+	}
+	if offset < 0 {
+		return 0, errNegativeOffset
+	}
+	f.offset = offset
+	return offset, nil
+}
+
+// Truncate implements [File.Truncate].
+func (f *MemoryFile) Truncate(size int64) error {
+	if size < 0 {
+		return errNegativeOffset
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if size <= int64(len(f.buf)) {
+		f.buf = f.buf[:size]
+	} else {
+		f.grow(size)
+	}
+	return nil
+}
+
+// Close implements [io.Closer.Close]. The content remains available.
+func (f *MemoryFile) Close() error {
+	return nil
+}
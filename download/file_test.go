@@ -0,0 +1,102 @@
+package download
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestMemoryFile(t *testing.T) {
+	f := NewMemoryFile("mem.bin")
+	if f.Name() != "mem.bin" {
+		t.Errorf("Name() = %q, want %q", f.Name(), "mem.bin")
+	}
+
+	if _, err := f.Write([]byte("hello world")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.WriteAt([]byte("WORLD"), 6); err != nil {
+		t.Fatal(err)
+	}
+	// Writing past the end zero-fills the gap.
+	if _, err := f.WriteAt([]byte("!"), 13); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := f.Bytes(), []byte("hello WORLD\x00\x00!"); !bytes.Equal(got, want) {
+		t.Errorf("Bytes() = %q, want %q", got, want)
+	}
+
+	if off, err := f.Seek(-3, io.SeekEnd); err != nil || off != 11 {
+		t.Fatalf("Seek(-3, io.SeekEnd) = %d, %v, want 11, nil", off, err)
+	}
+	if err := f.Truncate(5); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+	got, err := io.ReadAll(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "hello" {
+		t.Errorf("content after Truncate(5) = %q, want %q", got, "hello")
+	}
+
+	// Growing after shrinking does not resurrect the old content.
+	if err = f.Truncate(8); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := f.Bytes(), []byte("hello\x00\x00\x00"); !bytes.Equal(got, want) {
+		t.Errorf("Bytes() after Truncate(8) = %q, want %q", got, want)
+	}
+
+	p := make([]byte, 4)
+	if n, err := f.ReadAt(p, 6); n != 2 || !errors.Is(err, io.EOF) {
+		t.Errorf("ReadAt() past the end = %d, %v, want 2, %v", n, err, io.EOF)
+	}
+	if _, err = f.Seek(-1, io.SeekStart); !errors.Is(err, errNegativeOffset) {
+		t.Errorf("Seek(-1, io.SeekStart) error = %v, want %v", err, errNegativeOffset)
+	}
+	if _, err = f.Seek(0, 42); !errors.Is(err, errInvalidWhence) {
+		t.Errorf("Seek(0, 42) error = %v, want %v", err, errInvalidWhence)
+	}
+}
+
+func TestMemoryFileReadFrom(t *testing.T) {
+	content := strings.Repeat("0123456789", 10000)
+	f := NewMemoryFile("mem.bin")
+	if _, err := f.Write([]byte("prefix:")); err != nil {
+		t.Fatal(err)
+	}
+	n, err := f.ReadFrom(strings.NewReader(content))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != int64(len(content)) {
+		t.Errorf("ReadFrom() = %d, want %d", n, len(content))
+	}
+	if got := string(f.Bytes()); got != "prefix:"+content {
+		t.Errorf("content has %d bytes, want %d", len(got), len("prefix:")+len(content))
+	}
+}
+
+func TestJobDownloadsToMemoryFiles(t *testing.T) {
+	content := testContent(100000)
+	srv := newContentServer(t, content, nil)
+
+	j := newTestJob(srv.URL, content)
+	secondary := NewMemoryFile("secondary.bin")
+	j.SecondaryTargetFile = secondary
+	if _, ok := runTestJob(t, &j, nil); !ok {
+		t.Fatalf("job failed: %v", j.lastErr)
+	}
+	if !bytes.Equal(targetBytes(t, &j), content) {
+		t.Error("primary content does not match")
+	}
+	if !bytes.Equal(secondary.Bytes(), content) {
+		t.Error("secondary content does not match")
+	}
+}
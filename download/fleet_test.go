@@ -0,0 +1,74 @@
+package download
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// waitFor polls cond until it returns true, failing the test after a few seconds.
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestWorkerFleetInFlightAndQueued(t *testing.T) {
+	const (
+		numWorkers = 2
+		numJobs    = 5
+	)
+	release := make(chan struct{})
+	closeRelease := sync.OnceFunc(func() { close(release) })
+	defer closeRelease()
+	var contents sync.Map
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+			return
+		}
+		content, _ := contents.Load(r.URL.Path)
+		_, _ = w.Write(content.([]byte))
+	}))
+	defer srv.Close()
+
+	jobCh := make(chan Job, numJobs)
+	wf := NewWorkerFleet(context.Background(), testLogger, http.DefaultClient, numWorkers, jobCh)
+	if got := wf.InFlight(); got != 0 {
+		t.Errorf("InFlight() = %d before any jobs, want 0", got)
+	}
+	for i := range numJobs {
+		// Distinct content, so that no job is satisfied by another's download.
+		content := testContent(1000 + i)
+		path := "/" + string(rune('a'+i))
+		contents.Store(path, content)
+		jobCh <- newTestJob(srv.URL+path, content)
+	}
+
+	waitFor(t, "workers to pick up jobs", func() bool { return wf.InFlight() == numWorkers })
+	if got, want := wf.Queued(), numJobs-numWorkers; got != want {
+		t.Errorf("Queued() = %d, want %d", got, want)
+	}
+
+	closeRelease()
+	close(jobCh)
+	wf.Wait()
+	if got := wf.InFlight(); got != 0 {
+		t.Errorf("InFlight() = %d after Wait, want 0", got)
+	}
+	if got := wf.Queued(); got != 0 {
+		t.Errorf("Queued() = %d after Wait, want 0", got)
+	}
+	if got := wf.Stats().Downloaded; got != numJobs {
+		t.Errorf("Stats().Downloaded = %d, want %d", got, numJobs)
+	}
+}
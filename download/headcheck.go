@@ -0,0 +1,89 @@
+package download
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+)
+
+var errChecksumMismatch = errors.New("server advertises a checksum that does not match the expected hash sum")
+
+// checksumHeaders are the response headers that may carry a hex digest of the content.
+var checksumHeaders = [...]string{"X-Checksum-Sha256", "X-Checksum-Sha1"}
+
+// advertisedSumMatches looks for a hex digest of the content with the same length as want
+// in the checksum headers and the ETag of the response, and reports whether one was found,
+// and whether it equals want.
+func advertisedSumMatches(h http.Header, want []byte) (match, found bool) {
+	candidates := make([]string, 0, len(checksumHeaders)+1)
+	for _, key := range checksumHeaders {
+		if v := h.Get(key); v != "" {
+			candidates = append(candidates, v)
+		}
+	}
+	if etag := h.Get("ETag"); etag != "" {
+		// Weak validators say nothing about the exact bytes.
+		if !strings.HasPrefix(etag, "W/") {
+			candidates = append(candidates, strings.Trim(etag, `"`))
+		}
+	}
+
+	for _, c := range candidates {
+		if hex.DecodedLen(len(c)) != len(want) {
+			continue
+		}
+		sum, err := hex.DecodeString(c)
+		if err != nil {
+			continue
+		}
+		if bytes.Equal(sum, want) {
+			return true, true
+		}
+		found = true
+	}
+	return false, found
+}
+
+// headCheck sends a HEAD request to the given URL, and returns false if the server advertises
+// a content hash that does not match the job's expected sum, in which case downloading from
+// the URL can only fail the hash check. If the request fails, or no hash is advertised,
+// it returns true, and the URL is downloaded from as usual.
+func (j *Job) headCheck(ctx context.Context, logger *slog.Logger, cfg *config, url string) bool {
+	if len(j.Sum) == 0 {
+		return true
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, url, nil)
+	if err != nil {
+		return true
+	}
+	if j.UserAgent != "" {
+		req.Header["User-Agent"] = []string{j.UserAgent}
+	}
+	req.Header["Accept-Encoding"] = []string{"identity"}
+
+	resp, err := cfg.client.Do(req)
+	if err != nil {
+		return true
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK || contentEncoding(resp) != "" {
+		return true
+	}
+	if match, found := advertisedSumMatches(resp.Header, j.Sum); match || !found {
+		return true
+	}
+
+	logger.LogAttrs(ctx, slog.LevelWarn, "Server advertises a different checksum, skipping URL",
+		slog.String("name", j.TargetFile.Name()),
+		slog.String("url", url),
+		slog.String("expectedSum", hex.EncodeToString(j.Sum)),
+	)
+	j.failAttempt(errChecksumMismatch, false)
+	return false
+}
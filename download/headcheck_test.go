@@ -0,0 +1,147 @@
+package download
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newChecksumServer returns a server that serves content, advertising value in the given header,
+// and counts the HEAD and GET requests it receives.
+func newChecksumServer(t *testing.T, content []byte, header, value string, heads, gets *atomic.Int32) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodHead:
+			heads.Add(1)
+		case http.MethodGet:
+			gets.Add(1)
+		}
+		if header != "" {
+			w.Header()[header] = []string{value}
+		}
+		http.ServeContent(w, r, "test.bin", time.Time{}, bytes.NewReader(content))
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestJobHeadCheck(t *testing.T) {
+	content := testContent(1000)
+	sum := hex.EncodeToString(sha1Sum(content))
+	otherSum := hex.EncodeToString(sha1Sum(testContent(1001)))
+
+	for _, c := range []struct {
+		name      string
+		header    string
+		value     string
+		wantOK    bool
+		wantHeads int32
+		wantGets  int32
+	}{
+		{"MatchingChecksum", "X-Checksum-Sha1", sum, true, 1, 1},
+		{"MatchingETag", "ETag", `"` + sum + `"`, true, 1, 1},
+		{"NoChecksum", "", "", true, 1, 1},
+		{"MismatchingChecksum", "X-Checksum-Sha1", otherSum, false, 1, 0},
+		{"MismatchingETag", "ETag", `"` + otherSum + `"`, false, 1, 0},
+		{"WeakETag", "ETag", `W/"` + otherSum + `"`, true, 1, 1},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			var heads, gets atomic.Int32
+			srv := newChecksumServer(t, content, c.header, c.value, &heads, &gets)
+
+			j := newTestJob(srv.URL, content)
+			_, ok := runTestJob(t, &j, nil, WithHeadCheck())
+			if ok != c.wantOK {
+				t.Fatalf("job ok = %v, want %v, last error: %v", ok, c.wantOK, j.lastErr)
+			}
+			if ok && !bytes.Equal(targetBytes(t, &j), content) {
+				t.Error("downloaded content does not match")
+			}
+			if !ok && !errors.Is(j.lastErr, errChecksumMismatch) {
+				t.Errorf("last error = %v, want %v", j.lastErr, errChecksumMismatch)
+			}
+			if got := heads.Load(); got != c.wantHeads {
+				t.Errorf("HEAD requests = %d, want %d", got, c.wantHeads)
+			}
+			if got := gets.Load(); got != c.wantGets {
+				t.Errorf("GET requests = %d, want %d", got, c.wantGets)
+			}
+		})
+	}
+}
+
+func TestJobHeadCheckSkipsToMirror(t *testing.T) {
+	content := testContent(1000)
+	var staleHeads, staleGets, mirrorHeads, mirrorGets atomic.Int32
+	stale := newChecksumServer(t, testContent(1001), "X-Checksum-Sha1", hex.EncodeToString(sha1Sum(testContent(1001))), &staleHeads, &staleGets)
+	mirror := newChecksumServer(t, content, "X-Checksum-Sha1", hex.EncodeToString(sha1Sum(content)), &mirrorHeads, &mirrorGets)
+
+	j := newTestJob(stale.URL, content)
+	j.Mirrors = []string{mirror.URL}
+	if _, ok := runTestJob(t, &j, nil, WithHeadCheck()); !ok {
+		t.Fatalf("job failed: %v", j.lastErr)
+	}
+	if !bytes.Equal(targetBytes(t, &j), content) {
+		t.Error("downloaded content does not match")
+	}
+	if got := staleGets.Load(); got != 0 {
+		t.Errorf("GET requests to the mismatching URL = %d, want 0", got)
+	}
+	if got := mirrorGets.Load(); got != 1 {
+		t.Errorf("GET requests to the mirror = %d, want 1", got)
+	}
+}
+
+func TestJobWithoutHeadCheckSendsNoHeadRequests(t *testing.T) {
+	content := testContent(1000)
+	var heads, gets atomic.Int32
+	srv := newChecksumServer(t, content, "X-Checksum-Sha1", hex.EncodeToString(sha1Sum(content)), &heads, &gets)
+
+	j := newTestJob(srv.URL, content)
+	if _, ok := runTestJob(t, &j, nil); !ok {
+		t.Fatalf("job failed: %v", j.lastErr)
+	}
+	if got := heads.Load(); got != 0 {
+		t.Errorf("HEAD requests = %d, want 0", got)
+	}
+}
+
+func TestAdvertisedSumMatches(t *testing.T) {
+	content := testContent(100)
+	sha1Hex := hex.EncodeToString(sha1Sum(content))
+	sha256Sum := sha256.Sum256(content)
+	sha256Hex := hex.EncodeToString(sha256Sum[:])
+
+	for _, c := range []struct {
+		name      string
+		header    http.Header
+		want      []byte
+		wantMatch bool
+		wantFound bool
+	}{
+		{"SHA1", http.Header{"X-Checksum-Sha1": {sha1Hex}}, sha1Sum(content), true, true},
+		{"SHA256", http.Header{"X-Checksum-Sha256": {sha256Hex}}, sha256Sum[:], true, true},
+		{"OtherLength", http.Header{"X-Checksum-Sha256": {sha256Hex}}, sha1Sum(content), false, false},
+		{"InvalidHex", http.Header{"X-Checksum-Sha1": {"z" + sha1Hex[1:]}}, sha1Sum(content), false, false},
+		{"StrongETag", http.Header{"Etag": {`"` + sha1Hex + `"`}}, sha1Sum(content), true, true},
+		{"WeakETag", http.Header{"Etag": {`W/"` + sha1Hex + `"`}}, sha1Sum(content), false, false},
+		{"OpaqueETag", http.Header{"Etag": {`"v1"`}}, sha1Sum(content), false, false},
+		{"Mismatch", http.Header{"X-Checksum-Sha1": {hex.EncodeToString(sha1Sum(nil))}}, sha1Sum(content), false, true},
+		{"MismatchAndMatch", http.Header{"X-Checksum-Sha1": {sha1Hex}, "Etag": {`"` + hex.EncodeToString(sha1Sum(nil)) + `"`}}, sha1Sum(content), true, true},
+		{"None", http.Header{}, sha1Sum(content), false, false},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			match, found := advertisedSumMatches(c.header, c.want)
+			if match != c.wantMatch || found != c.wantFound {
+				t.Errorf("advertisedSumMatches() = %v, %v, want %v, %v", match, found, c.wantMatch, c.wantFound)
+			}
+		})
+	}
+}
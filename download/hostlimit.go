@@ -0,0 +1,98 @@
+package download
+
+import (
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// hostLimitedTransport limits the number of concurrent requests to each host,
+// and optionally waits for a random duration before sending each request.
+//
+// A request holds its host's slot until its response body is closed,
+// so that slow downloads count against the limit for as long as they run.
+// Redirects are limited by the host they are sent to.
+type hostLimitedTransport struct {
+	base http.RoundTripper
+
+	// limit is the maximum number of concurrent requests to each host. Zero means unlimited.
+	limit int
+
+	// jitter is the maximum random delay before each request. Zero means no delay.
+	jitter time.Duration
+
+	mu    sync.Mutex
+	slots map[string]chan struct{}
+}
+
+// hostSlots returns the semaphore for the given host.
+func (t *hostLimitedTransport) hostSlots(host string) chan struct{} {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	slots, ok := t.slots[host]
+	if !ok {
+		if t.slots == nil {
+			t.slots = make(map[string]chan struct{})
+		}
+		slots = make(chan struct{}, t.limit)
+		t.slots[host] = slots
+	}
+	return slots
+}
+
+// RoundTrip implements [http.RoundTripper.RoundTrip].
+func (t *hostLimitedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+
+	if t.jitter > 0 {
+		timer := time.NewTimer(rand.N(t.jitter))
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		}
+	}
+
+	if t.limit <= 0 {
+		return t.base.RoundTrip(req)
+	}
+
+	slots := t.hostSlots(req.URL.Host)
+	select {
+	case slots <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	var once sync.Once
+	release := func() {
+		once.Do(func() {
+			<-slots
+		})
+	}
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		release()
+		return nil, err
+	}
+	resp.Body = &releasingBody{ReadCloser: resp.Body, release: release}
+	return resp, nil
+}
+
+// releasingBody calls release when the body is closed.
+type releasingBody struct {
+	io.ReadCloser
+	release func()
+}
+
+// Close implements [io.Closer.Close].
+func (b *releasingBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.release()
+	return err
+}
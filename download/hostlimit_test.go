@@ -0,0 +1,112 @@
+package download
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// concurrencyProbe records the number of concurrent requests, overall and at its peak.
+type concurrencyProbe struct {
+	mu      sync.Mutex
+	active  int
+	maxSeen int
+}
+
+func (p *concurrencyProbe) enter() {
+	p.mu.Lock()
+	p.active++
+	p.maxSeen = max(p.maxSeen, p.active)
+	p.mu.Unlock()
+}
+
+func (p *concurrencyProbe) leave() {
+	p.mu.Lock()
+	p.active--
+	p.mu.Unlock()
+}
+
+func (p *concurrencyProbe) max() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.maxSeen
+}
+
+func TestWorkerFleetLimitsConcurrencyPerHost(t *testing.T) {
+	const (
+		limit      = 2
+		numWorkers = 8
+		jobsPerSrv = 6
+	)
+
+	var all concurrencyProbe
+	contents := make(map[string][]byte)
+	newServer := func(probe *concurrencyProbe) *httptest.Server {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			probe.enter()
+			all.enter()
+			time.Sleep(20 * time.Millisecond)
+			probe.leave()
+			all.leave()
+			_, _ = w.Write(contents[r.URL.Path])
+		}))
+		t.Cleanup(srv.Close)
+		return srv
+	}
+	var probe1, probe2 concurrencyProbe
+	srv1, srv2 := newServer(&probe1), newServer(&probe2)
+
+	var jobs []Job
+	for i := range 2 * jobsPerSrv {
+		path := fmt.Sprintf("/%d", i)
+		// Distinct content, so that no job is satisfied by another's download.
+		content := testContent(1000 + i)
+		contents[path] = content
+		srv := srv1
+		if i%2 == 1 {
+			srv = srv2
+		}
+		jobs = append(jobs, newTestJob(srv.URL+path, content))
+	}
+
+	jobCh := make(chan Job)
+	wf := NewWorkerFleet(context.Background(), testLogger, http.DefaultClient, numWorkers, jobCh, WithPerHostConcurrency(limit))
+	for _, j := range jobs {
+		jobCh <- j
+	}
+	close(jobCh)
+	wf.Wait()
+
+	if got := wf.Failures(); got != 0 {
+		t.Fatalf("Failures() = %d, want 0", got)
+	}
+	for i, p := range []*concurrencyProbe{&probe1, &probe2} {
+		if got := p.max(); got > limit {
+			t.Errorf("max concurrent requests to host %d = %d, want at most %d", i+1, got, limit)
+		}
+	}
+	if got := all.max(); got <= limit {
+		t.Errorf("max concurrent requests to all hosts = %d, want more than the per-host limit %d", got, limit)
+	}
+}
+
+func TestHostLimitedTransportJitterRespectsCancellation(t *testing.T) {
+	tr := &hostLimitedTransport{base: http.DefaultTransport, jitter: time.Hour}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://example.com", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	if _, err = tr.RoundTrip(req); err == nil {
+		t.Fatal("RoundTrip() succeeded, want error")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("RoundTrip() took %v after cancellation", elapsed)
+	}
+}
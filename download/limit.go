@@ -0,0 +1,30 @@
+package download
+
+import (
+	"errors"
+	"io"
+)
+
+var errFileTooLarge = errors.New("file exceeds maximum size")
+
+// maxSizeReader reads from r, and fails with errFileTooLarge
+// as soon as more than n bytes have been read.
+type maxSizeReader struct {
+	r io.Reader
+	n int64 // remaining bytes allowed
+}
+
+// Read implements [io.Reader.Read].
+func (r *maxSizeReader) Read(p []byte) (int, error) {
+	if int64(len(p)) > r.n+1 {
+		p = p[:r.n+1]
+	}
+	n, err := r.r.Read(p)
+	if int64(n) > r.n {
+		n = int(r.n)
+		r.n = 0
+		return n, errFileTooLarge
+	}
+	r.n -= int64(n)
+	return n, err
+}
@@ -0,0 +1,72 @@
+package download
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+)
+
+func TestJobRejectsAdvertisedOversizedFile(t *testing.T) {
+	content := testContent(1000)
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		_, _ = w.Write(content)
+	}))
+	defer srv.Close()
+
+	j := newTestJob(srv.URL, content)
+	if _, ok := runTestJob(t, &j, nil, WithMaxFileSize(999)); ok {
+		t.Fatal("job succeeded, want failure")
+	}
+	if !errors.Is(j.lastErr, errFileTooLarge) {
+		t.Errorf("lastErr = %v, want %v", j.lastErr, errFileTooLarge)
+	}
+	if got := requests.Load(); got != 0 {
+		t.Errorf("requests = %d, want 0", got)
+	}
+}
+
+func TestJobAbortsOversizedBody(t *testing.T) {
+	const maxSize = 2000
+	content := testContent(1000)
+	oversized := testContent(100000)
+
+	for _, c := range []struct {
+		name          string
+		contentLength bool
+	}{
+		{"ContentLength", true},
+		{"Chunked", false},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				// The manifest advertises a small file, but the server sends a lot more.
+				if c.contentLength {
+					w.Header()["Content-Length"] = []string{strconv.Itoa(len(oversized))}
+				}
+				for i := 0; i < len(oversized); i += 1000 {
+					if _, err := w.Write(oversized[i : i+1000]); err != nil {
+						return
+					}
+					w.(http.Flusher).Flush()
+				}
+			}))
+			defer srv.Close()
+
+			j := newTestJob(srv.URL, content)
+			if _, ok := runTestJob(t, &j, nil, WithMaxFileSize(maxSize)); ok {
+				t.Fatal("job succeeded, want failure")
+			}
+			if !errors.Is(j.lastErr, errFileTooLarge) {
+				t.Errorf("lastErr = %v, want %v", j.lastErr, errFileTooLarge)
+			}
+			if got := len(targetBytes(t, &j)); got > maxSize {
+				t.Errorf("kept %d bytes, want at most %d", got, maxSize)
+			}
+		})
+	}
+}
@@ -0,0 +1,155 @@
+package download
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestChtimesFailureDisablesModTimes(t *testing.T) {
+	modTime := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	content := testContent(1000)
+
+	for _, c := range []struct {
+		name      string
+		err       error
+		wantCalls int
+	}{
+		{"Permission", fs.ErrPermission, 1},
+		{"Unsupported", errors.ErrUnsupported, 1},
+		// Other errors may be specific to the file, so the next files are still attempted.
+		{"Other", fs.ErrNotExist, 3},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			srv := newContentServer(t, content, nil)
+			var logs bytes.Buffer
+			logger := slog.New(slog.NewTextHandler(&logs, nil))
+
+			var calls int
+			cfg := newConfig(http.DefaultClient, nil)
+			cfg.chtimes = func(name string, atime, mtime time.Time) error {
+				calls++
+				if !mtime.Equal(modTime) {
+					t.Errorf("chtimes() mtime = %v, want %v", mtime, modTime)
+				}
+				return c.err
+			}
+
+			for range 3 {
+				j := newOSFileJob(t, srv.URL, content)
+				j.ModTime = modTime
+				if _, ok := j.runWithConfig(context.Background(), logger, cfg); !ok {
+					t.Fatalf("job failed: %v", j.lastErr)
+				}
+			}
+			if calls != c.wantCalls {
+				t.Errorf("chtimes() calls = %d, want %d", calls, c.wantCalls)
+			}
+			if c.wantCalls == 1 {
+				if n := strings.Count(logs.String(), "disabling for remaining files"); n != 1 {
+					t.Errorf("logged %d warnings about disabling modification times, want 1", n)
+				}
+			}
+		})
+	}
+}
+
+func TestJobSetsModTime(t *testing.T) {
+	modTime := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	content := testContent(1000)
+	srv := newContentServer(t, content, nil)
+
+	j := newOSFileJob(t, srv.URL, content)
+	j.ModTime = modTime
+	if _, ok := runTestJob(t, &j, nil); !ok {
+		t.Fatalf("job failed: %v", j.lastErr)
+	}
+	fi, err := os.Stat(j.TargetFile.(*os.File).Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !fi.ModTime().Equal(modTime) {
+		t.Errorf("ModTime() = %v, want %v", fi.ModTime(), modTime)
+	}
+}
+
+func TestMtimeFromResponse(t *testing.T) {
+	lastModified := time.Date(2023, 6, 1, 12, 0, 0, 0, time.UTC)
+	manifestTime := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	for _, c := range []struct {
+		name    string
+		header  []string
+		modTime time.Time
+		want    time.Time
+	}{
+		{"Header", []string{lastModified.Format(http.TimeFormat)}, manifestTime, lastModified},
+		{"HeaderWithoutManifestTime", []string{lastModified.Format(http.TimeFormat)}, time.Time{}, lastModified},
+		{"ManifestTime", nil, manifestTime, manifestTime},
+		{"MalformedHeader", []string{"yesterday"}, manifestTime, manifestTime},
+		{"RepeatedHeader", []string{lastModified.Format(http.TimeFormat), lastModified.Format(http.TimeFormat)}, manifestTime, manifestTime},
+		{"Neither", nil, time.Time{}, time.Time{}},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			resp := &http.Response{Header: http.Header{}}
+			if c.header != nil {
+				resp.Header["Last-Modified"] = c.header
+			}
+			j := Job{ModTime: c.modTime}
+			if got := j.mtimeFromResponse(context.Background(), testLogger, resp); !got.Equal(c.want) {
+				t.Errorf("mtimeFromResponse() = %v, want %v", got, c.want)
+			}
+		})
+	}
+}
+
+func TestJobModTimeFallbackChain(t *testing.T) {
+	lastModified := time.Date(2023, 6, 1, 12, 0, 0, 0, time.UTC)
+	manifestTime := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	content := testContent(1000)
+
+	for _, c := range []struct {
+		name         string
+		lastModified time.Time
+		modTime      time.Time
+		want         time.Time
+	}{
+		{"Header", lastModified, manifestTime, lastModified},
+		{"ManifestTime", time.Time{}, manifestTime, manifestTime},
+		{"Neither", time.Time{}, time.Time{}, time.Time{}},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				http.ServeContent(w, r, "test.bin", c.lastModified, bytes.NewReader(content))
+			}))
+			defer srv.Close()
+
+			j := newOSFileJob(t, srv.URL, content)
+			start := time.Now().Add(-time.Minute)
+			j.ModTime = c.modTime
+			if _, ok := runTestJob(t, &j, nil); !ok {
+				t.Fatalf("job failed: %v", j.lastErr)
+			}
+			fi, err := os.Stat(j.TargetFile.(*os.File).Name())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if c.want.IsZero() {
+				// The modification time is left as set by the download itself.
+				if fi.ModTime().Before(start) {
+					t.Errorf("ModTime() = %v, want it left unchanged", fi.ModTime())
+				}
+			} else if !fi.ModTime().Equal(c.want) {
+				t.Errorf("ModTime() = %v, want %v", fi.ModTime(), c.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,284 @@
+package download
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// config holds the settings shared by the jobs run by a [WorkerFleet].
+type config struct {
+	client       *http.Client
+	progressFunc ProgressFunc
+	rateLimiter  *rate.Limiter
+	timeout      time.Duration
+
+	// minThroughput, if positive, scales the timeout of each attempt with the file's size.
+	minThroughput int64
+
+	chunkThreshold int64
+	chunks         int
+
+	maxAttempts int
+	fullRetries int
+	preallocate bool
+	headCheck   bool
+	trace       bool
+	maxFileSize int64
+
+	perHostLimit  int
+	requestJitter time.Duration
+
+	// redirects, if not nil, replaces the client's redirect policy.
+	redirects *redirectPolicy
+
+	eventHandler EventHandler
+
+	// drain, if not nil, is closed when the fleet is to stop starting new jobs.
+	drain <-chan struct{}
+
+	progressLogInterval time.Duration
+	progressLogTotal    func() int64
+
+	// received counts the bytes of response bodies read by the fleet. Nil for jobs run on their own.
+	received *atomic.Int64
+
+	// retries counts the retries taken by the fleet's jobs. Nil for jobs run on their own.
+	retries *atomic.Int64
+
+	// contents indexes the content downloaded by the fleet. Nil for jobs run on their own.
+	contents *contentIndex
+
+	// retryBudget, if not nil, is the number of retries left for all jobs in the fleet.
+	retryBudget *atomic.Int64
+
+	// retryBudgetExhausted is set when the retry budget has run out.
+	retryBudgetExhausted atomic.Bool
+
+	// backoff returns the delay before the given attempt at downloading from the same URL.
+	// It is [backoffDelay] outside of tests.
+	backoff func(attempt int) time.Duration
+
+	// chtimes sets the access and modification times of the named file.
+	// It is [os.Chtimes] outside of tests.
+	chtimes func(name string, atime, mtime time.Time) error
+
+	// chtimesDisabled is set when setting modification times has failed in a way
+	// that is expected to persist for the remainder of the run.
+	chtimesDisabled atomic.Bool
+}
+
+// defaultFullRetries is the default number of full retries after a resumed download fails the hash check.
+const defaultFullRetries = 1
+
+// defaultMaxAttempts is the default number of attempts at downloading from each URL.
+const defaultMaxAttempts = 1
+
+// newConfig returns a new config with the given options applied.
+func newConfig(client *http.Client, opts []Option) *config {
+	cfg := config{client: client, maxAttempts: defaultMaxAttempts, fullRetries: defaultFullRetries, chtimes: os.Chtimes, backoff: backoffDelay}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	if cfg.perHostLimit > 0 || cfg.requestJitter > 0 {
+		base := cfg.client.Transport
+		if base == nil {
+			base = http.DefaultTransport
+		}
+		client := *cfg.client
+		client.Transport = &hostLimitedTransport{
+			base:   base,
+			limit:  cfg.perHostLimit,
+			jitter: cfg.requestJitter,
+		}
+		cfg.client = &client
+	}
+	if cfg.redirects != nil {
+		client := *cfg.client
+		client.CheckRedirect = cfg.redirects.checkRedirect
+		cfg.client = &client
+	}
+	return &cfg
+}
+
+// Option configures optional behavior of a [WorkerFleet].
+type Option func(*config)
+
+// WithProgressFunc sets the function to call to report download progress.
+//
+// The function is called from the workers' goroutines, so it must be safe for concurrent use.
+func WithProgressFunc(f ProgressFunc) Option {
+	return func(c *config) {
+		c.progressFunc = f
+	}
+}
+
+// WithRateLimiter sets the limiter that all workers draw from when reading response bodies,
+// in bytes per second. The limiter's burst size must be positive.
+func WithRateLimiter(l *rate.Limiter) Option {
+	return func(c *config) {
+		c.rateLimiter = l
+	}
+}
+
+// WithTimeout sets the maximum duration of each download attempt, including reading the response body.
+// When an attempt times out, the next mirror is tried, if any. Zero means no timeout.
+func WithTimeout(d time.Duration) Option {
+	return func(c *config) {
+		c.timeout = d
+	}
+}
+
+// WithMinThroughput makes the timeout of each download attempt proportional to the file's expected size,
+// allowing for a throughput of at least bytesPerSecond, so that stalled transfers of small files fail fast,
+// while large files get enough time. The timeout set by [WithTimeout], or 30 seconds if none,
+// is the minimum, and applies as is to files of unknown size. Non-positive values disable scaling.
+func WithMinThroughput(bytesPerSecond int64) Option {
+	return func(c *config) {
+		c.minThroughput = max(bytesPerSecond, 0)
+	}
+}
+
+// WithChunkedDownload enables downloading files of at least threshold bytes in the given number
+// of concurrent range requests, if the server supports them. A chunk count less than 2 disables
+// chunked downloads.
+func WithChunkedDownload(threshold int64, chunks int) Option {
+	return func(c *config) {
+		c.chunkThreshold = threshold
+		c.chunks = chunks
+	}
+}
+
+// WithMaxAttempts sets the maximum number of attempts at downloading a file from each URL.
+// An attempt that fails with a network error or a 429 or 5xx response is repeated against the same URL
+// after an exponential backoff, before moving on to the next mirror. The default is 1.
+func WithMaxAttempts(n int) Option {
+	return func(c *config) {
+		c.maxAttempts = max(n, 1)
+	}
+}
+
+// WithFullRetries sets the maximum number of times a download is retried from scratch
+// after a resumed download fails the hash check. The default is 1.
+func WithFullRetries(n int) Option {
+	return func(c *config) {
+		c.fullRetries = n
+	}
+}
+
+// WithEventHandler sets the handler to notify of each download's progress.
+func WithEventHandler(h EventHandler) Option {
+	return func(c *config) {
+		c.eventHandler = h
+	}
+}
+
+// WithProgressLog enables logging the aggregate progress of the fleet's downloads every interval:
+// the number of bytes received, the recent and average throughput, and, if total is not nil
+// and returns a positive number of bytes expected to be downloaded, an estimated time remaining.
+// Non-positive intervals disable progress logging.
+//
+// total is called from the logging goroutine, so it must be safe for concurrent use.
+func WithProgressLog(interval time.Duration, total func() int64) Option {
+	return func(c *config) {
+		c.progressLogInterval = max(interval, 0)
+		c.progressLogTotal = total
+	}
+}
+
+// WithPreallocation enables allocating disk space for each file up to its expected size
+// before downloading it from scratch, which reduces fragmentation and fails early when
+// the disk is full. On Linux, fallocate(2) is used. Elsewhere, the file is simply extended.
+func WithPreallocation() Option {
+	return func(c *config) {
+		c.preallocate = true
+	}
+}
+
+// WithHeadCheck makes each download from a URL start with a HEAD request.
+// If the server advertises a content hash, in an X-Checksum-Sha256 or X-Checksum-Sha1 header,
+// or as a strong ETag, that does not match the expected sum, the URL is skipped without
+// downloading the content, and the next mirror, if any, is tried.
+func WithHeadCheck() Option {
+	return func(c *config) {
+		c.headCheck = true
+	}
+}
+
+// WithHTTPTrace makes each download request record how long DNS resolution, connecting,
+// the TLS handshake, and waiting for the first response byte took, and whether
+// the connection was reused. The timings are logged at debug level.
+func WithHTTPTrace() Option {
+	return func(c *config) {
+		c.trace = true
+	}
+}
+
+// WithRedirectPolicy caps the number of redirects followed for each download request at maxRedirects,
+// and if httpsOnly is true, rejects redirects to URLs that are not HTTPS, such as plain HTTP mirrors.
+// A rejected redirect fails the download from the URL without retrying it.
+// Negative values of maxRedirects are treated as zero, following no redirects.
+func WithRedirectPolicy(maxRedirects int, httpsOnly bool) Option {
+	return func(c *config) {
+		c.redirects = &redirectPolicy{
+			maxRedirects: max(maxRedirects, 0),
+			httpsOnly:    httpsOnly,
+		}
+	}
+}
+
+// WithMaxFileSize sets the maximum size of downloaded files in bytes.
+// A download is aborted as soon as the file is known to exceed the maximum size,
+// whether from the expected size, the response's Content-Length, or the bytes received.
+// Non-positive values mean no limit.
+func WithMaxFileSize(n int64) Option {
+	return func(c *config) {
+		c.maxFileSize = max(n, 0)
+	}
+}
+
+// WithPerHostConcurrency limits the number of concurrent requests to each host to n,
+// including redirected requests. A request counts until its response body is closed.
+// Non-positive values mean no limit.
+func WithPerHostConcurrency(n int) Option {
+	return func(c *config) {
+		c.perHostLimit = max(n, 0)
+	}
+}
+
+// WithRequestJitter delays each request by a random duration of up to d,
+// to spread out bursts of requests. Non-positive values mean no delay.
+func WithRequestJitter(d time.Duration) Option {
+	return func(c *config) {
+		c.requestJitter = max(d, 0)
+	}
+}
+
+// WithRetryBudget caps the total number of retries across all jobs in the fleet at n,
+// counting repeated attempts at the same URL and full retries after failed resumes.
+// Moving on to the next mirror is not counted, as jobs may have many guessed mirror URLs.
+// Once the budget is exhausted, attempts that fail are not repeated, but mirrors are still tried.
+// Negative values mean no cap.
+func WithRetryBudget(n int) Option {
+	return func(c *config) {
+		if n < 0 {
+			c.retryBudget = nil
+			return
+		}
+		c.retryBudget = new(atomic.Int64)
+		c.retryBudget.Store(int64(n))
+	}
+}
+
+// WithDrain makes the fleet stop starting new jobs once ctx is done.
+// Jobs picked up from then on are discarded, while jobs already running
+// carry on under the fleet's context.
+func WithDrain(ctx context.Context) Option {
+	return func(c *config) {
+		c.drain = ctx.Done()
+	}
+}
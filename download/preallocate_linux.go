@@ -0,0 +1,37 @@
+package download
+
+import (
+	"errors"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// preallocateFile allocates disk space for the file up to size bytes with fallocate(2),
+// extending the file to size. If the filesystem does not support fallocate,
+// the file is extended with ftruncate(2) instead. Files other than *os.File are simply extended.
+func preallocateFile(f File, size int64) error {
+	osf, ok := osFile(f)
+	if !ok {
+		return f.Truncate(size)
+	}
+
+	conn, err := osf.SyscallConn()
+	if err != nil {
+		return err
+	}
+
+	var fallocateErr error
+	if err = conn.Control(func(fd uintptr) {
+		fallocateErr = unix.Fallocate(int(fd), 0, 0, size)
+	}); err != nil {
+		return err
+	}
+	if errors.Is(fallocateErr, unix.EOPNOTSUPP) {
+		return f.Truncate(size)
+	}
+	if fallocateErr != nil {
+		return os.NewSyscallError("fallocate", fallocateErr)
+	}
+	return nil
+}
@@ -0,0 +1,8 @@
+//go:build !linux
+
+package download
+
+// preallocateFile extends the file to size bytes.
+func preallocateFile(f File, size int64) error {
+	return f.Truncate(size)
+}
@@ -0,0 +1,104 @@
+package download
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newOSFileJob returns a job for downloading content from url into a new file in a temporary directory.
+func newOSFileJob(t *testing.T, url string, content []byte) Job {
+	t.Helper()
+	f, err := os.Create(filepath.Join(t.TempDir(), "test.bin"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { f.Close() })
+	j := newTestJob(url, content)
+	j.TargetFile = f
+	return j
+}
+
+func TestJobPreallocatesTargetFile(t *testing.T) {
+	content := testContent(100000)
+	const sent = 1000
+	headersSent := make(chan struct{})
+	release := make(chan struct{})
+	closeRelease := sync.OnceFunc(func() { close(release) })
+	defer closeRelease()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header()["Content-Length"] = []string{"100000"}
+		_, _ = w.Write(content[:sent])
+		w.(http.Flusher).Flush()
+		close(headersSent)
+		<-release
+		_, _ = w.Write(content[sent:])
+	}))
+	defer srv.Close()
+
+	j := newOSFileJob(t, srv.URL, content)
+	done := make(chan bool)
+	go func() {
+		_, ok := j.runWithConfig(context.Background(), testLogger, newConfig(http.DefaultClient, []Option{WithPreallocation()}))
+		done <- ok
+	}()
+
+	// Only part of the content has been sent, so the file can only be at its full size if preallocated.
+	<-headersSent
+	var size int64
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+		fi, err := os.Stat(j.TargetFile.Name())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if size = fi.Size(); size == int64(len(content)) {
+			break
+		}
+	}
+	closeRelease()
+	if size != int64(len(content)) {
+		t.Errorf("file size before content arrived = %d, want %d", size, len(content))
+	}
+
+	if !<-done {
+		t.Fatalf("job failed: %v", j.lastErr)
+	}
+	got, err := os.ReadFile(j.TargetFile.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, content) {
+		t.Error("downloaded content does not match")
+	}
+}
+
+func TestJobTruncatesPreallocatedSpaceOnShortBody(t *testing.T) {
+	content := testContent(10000)
+	const sent = 3000
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header()["Content-Length"] = []string{"10000"}
+		_, _ = w.Write(content[:sent])
+		// Cut the connection short.
+		panic(http.ErrAbortHandler)
+	}))
+	defer srv.Close()
+
+	j := newOSFileJob(t, srv.URL, content)
+	if _, ok := runTestJob(t, &j, nil, WithPreallocation(), WithMaxAttempts(1)); ok {
+		t.Fatal("job succeeded, want failure")
+	}
+	fi, err := os.Stat(j.TargetFile.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The received prefix is kept for resumption, without the unwritten preallocated space.
+	if fi.Size() != sent {
+		t.Errorf("file size = %d, want %d", fi.Size(), sent)
+	}
+}
@@ -0,0 +1,97 @@
+package download
+
+import (
+	"io"
+	"sync"
+	"time"
+)
+
+// ProgressFunc reports the progress of a download.
+//
+// bytesDone is the number of bytes of the file that have been downloaded,
+// including any existing content the download resumed from.
+// bytesTotal is the total size of the file, or -1 if unknown.
+type ProgressFunc func(url string, bytesDone, bytesTotal int64)
+
+// progressReportInterval is the minimum interval between two progress reports of a download.
+const progressReportInterval = 100 * time.Millisecond
+
+// progressReader wraps a reader and reports the number of bytes read to a [ProgressFunc].
+type progressReader struct {
+	r          io.Reader
+	f          ProgressFunc
+	url        string
+	bytesDone  int64
+	bytesTotal int64
+	lastReport time.Time
+}
+
+// newProgressReader returns a new progressReader that reads from r.
+// offset is the number of bytes already downloaded.
+func newProgressReader(r io.Reader, f ProgressFunc, url string, offset, total int64) *progressReader {
+	return &progressReader{
+		r:          r,
+		f:          f,
+		url:        url,
+		bytesDone:  offset,
+		bytesTotal: total,
+	}
+}
+
+// Read implements [io.Reader].
+func (pr *progressReader) Read(b []byte) (int, error) {
+	n, err := pr.r.Read(b)
+	pr.bytesDone += int64(n)
+	if now := time.Now(); now.Sub(pr.lastReport) >= progressReportInterval {
+		pr.lastReport = now
+		pr.f(pr.url, pr.bytesDone, pr.bytesTotal)
+	}
+	return n, err
+}
+
+// report unconditionally reports the current progress.
+func (pr *progressReader) report() {
+	pr.f(pr.url, pr.bytesDone, pr.bytesTotal)
+}
+
+// sharedProgress tracks the progress of a download made of concurrent chunks,
+// and reports it to a [ProgressFunc].
+type sharedProgress struct {
+	mu         sync.Mutex
+	f          ProgressFunc
+	url        string
+	bytesDone  int64
+	bytesTotal int64
+	lastReport time.Time
+}
+
+// add adds n bytes to the progress, and reports it if the report interval has elapsed.
+func (sp *sharedProgress) add(n int) {
+	sp.mu.Lock()
+	defer sp.mu.Unlock()
+	sp.bytesDone += int64(n)
+	if now := time.Now(); now.Sub(sp.lastReport) >= progressReportInterval {
+		sp.lastReport = now
+		sp.f(sp.url, sp.bytesDone, sp.bytesTotal)
+	}
+}
+
+// report unconditionally reports the current progress.
+func (sp *sharedProgress) report() {
+	sp.mu.Lock()
+	defer sp.mu.Unlock()
+	sp.f(sp.url, sp.bytesDone, sp.bytesTotal)
+}
+
+// sharedProgressReader wraps a reader and adds the number of bytes read to a [sharedProgress].
+type sharedProgressReader struct {
+	r  io.Reader
+	sp *sharedProgress
+}
+
+// Read implements [io.Reader].
+func (spr *sharedProgressReader) Read(b []byte) (int, error) {
+	n, err := spr.r.Read(b)
+	spr.sp.add(n)
+	return n, err
+}
@@ -0,0 +1,122 @@
+package download
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// slowReader returns chunks of n bytes, sleeping for delay before each read.
+type slowReader struct {
+	remaining int
+	n         int
+	delay     time.Duration
+}
+
+func (r *slowReader) Read(b []byte) (int, error) {
+	if r.remaining == 0 {
+		return 0, io.EOF
+	}
+	time.Sleep(r.delay)
+	n := min(len(b), r.n, r.remaining)
+	clear(b[:n])
+	r.remaining -= n
+	return n, nil
+}
+
+// progressRecorder records progress reports.
+type progressRecorder struct {
+	mu     sync.Mutex
+	done   []int64
+	totals []int64
+	urls   []string
+}
+
+func (pr *progressRecorder) record(url string, bytesDone, bytesTotal int64) {
+	pr.mu.Lock()
+	defer pr.mu.Unlock()
+	pr.urls = append(pr.urls, url)
+	pr.done = append(pr.done, bytesDone)
+	pr.totals = append(pr.totals, bytesTotal)
+}
+
+// check checks that the reports are monotonically increasing up to total,
+// and that the last report is of the whole file.
+func (pr *progressRecorder) check(t *testing.T, url string, total int64) {
+	t.Helper()
+	pr.mu.Lock()
+	defer pr.mu.Unlock()
+	if len(pr.done) == 0 {
+		t.Fatal("no progress reports")
+	}
+	for i := range pr.done {
+		if pr.urls[i] != url {
+			t.Errorf("report %d: url = %q, want %q", i, pr.urls[i], url)
+		}
+		if pr.totals[i] != total {
+			t.Errorf("report %d: bytesTotal = %d, want %d", i, pr.totals[i], total)
+		}
+		if i > 0 && pr.done[i] < pr.done[i-1] {
+			t.Errorf("report %d: bytesDone = %d, less than previous %d", i, pr.done[i], pr.done[i-1])
+		}
+		if pr.done[i] > total {
+			t.Errorf("report %d: bytesDone = %d, more than total %d", i, pr.done[i], total)
+		}
+	}
+	if last := pr.done[len(pr.done)-1]; last != total {
+		t.Errorf("last bytesDone = %d, want %d", last, total)
+	}
+}
+
+func TestProgressReaderMonotonicAndThrottled(t *testing.T) {
+	const (
+		reads = 20
+		chunk = 100
+		total = reads * chunk
+	)
+	var rec progressRecorder
+	pr := newProgressReader(&slowReader{remaining: total, n: chunk, delay: 10 * time.Millisecond}, rec.record, "u", 0, total)
+	if _, err := io.Copy(io.Discard, pr); err != nil {
+		t.Fatal(err)
+	}
+	pr.report()
+
+	rec.check(t, "u", total)
+	if got := len(rec.done); got >= reads {
+		t.Errorf("reports = %d, want fewer than %d reads", got, reads)
+	}
+}
+
+func TestProgressReaderStartsAtOffset(t *testing.T) {
+	var rec progressRecorder
+	pr := newProgressReader(&slowReader{remaining: 50, n: 50}, rec.record, "u", 150, 200)
+	if _, err := io.Copy(io.Discard, pr); err != nil {
+		t.Fatal(err)
+	}
+	pr.report()
+
+	rec.check(t, "u", 200)
+	if first := rec.done[0]; first < 150 {
+		t.Errorf("first bytesDone = %d, want at least the offset 150", first)
+	}
+}
+
+func TestJobReportsProgress(t *testing.T) {
+	content := testContent(64 << 10)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header()["Content-Length"] = []string{strconv.Itoa(len(content))}
+		w.Write(content)
+	}))
+	defer srv.Close()
+
+	var rec progressRecorder
+	j := newTestJob(srv.URL, content)
+	if _, ok := runTestJob(t, &j, nil, WithProgressFunc(rec.record)); !ok {
+		t.Fatalf("job failed: %v", j.lastErr)
+	}
+	rec.check(t, srv.URL, int64(len(content)))
+}
@@ -0,0 +1,34 @@
+package download
+
+import (
+	"context"
+	"io"
+
+	"golang.org/x/time/rate"
+)
+
+// rateLimitedReader wraps a reader and limits the read rate with a token bucket
+// that may be shared with other readers.
+type rateLimitedReader struct {
+	ctx     context.Context
+	r       io.Reader
+	limiter *rate.Limiter
+}
+
+// Read implements [io.Reader].
+//
+// Each read is capped at the limiter's burst size, and blocks after reading
+// until enough tokens are available, or the context is canceled.
+func (rr *rateLimitedReader) Read(b []byte) (int, error) {
+	if burst := rr.limiter.Burst(); len(b) > burst {
+		b = b[:burst]
+	}
+
+	n, err := rr.r.Read(b)
+	if n > 0 {
+		if waitErr := rr.limiter.WaitN(rr.ctx, n); waitErr != nil {
+			return n, waitErr
+		}
+	}
+	return n, err
+}
@@ -0,0 +1,73 @@
+package download
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+func TestJobRateLimited(t *testing.T) {
+	const (
+		size  = 6000
+		limit = 20000
+		burst = 1000
+	)
+	content := testContent(size)
+	srv := newContentServer(t, content, nil)
+
+	// The bucket starts full, so the first burst is free.
+	minDuration := time.Duration(float64(size-burst) / limit * float64(time.Second))
+
+	j := newTestJob(srv.URL, content)
+	start := time.Now()
+	if _, ok := runTestJob(t, &j, nil, WithRateLimiter(rate.NewLimiter(limit, burst))); !ok {
+		t.Fatalf("job failed: %v", j.lastErr)
+	}
+	if elapsed := time.Since(start); elapsed < minDuration {
+		t.Errorf("download took %v, want at least %v", elapsed, minDuration)
+	}
+	if !bytes.Equal(targetBytes(t, &j), content) {
+		t.Error("downloaded content does not match")
+	}
+}
+
+func TestRateLimitedReaderCapsReadsAtBurst(t *testing.T) {
+	rr := &rateLimitedReader{
+		ctx:     context.Background(),
+		r:       bytes.NewReader(testContent(100)),
+		limiter: rate.NewLimiter(rate.Inf, 10),
+	}
+	n, err := rr.Read(make([]byte, 100))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 10 {
+		t.Errorf("Read() = %d bytes, want the burst size 10", n)
+	}
+}
+
+func TestRateLimitedReaderRespectsCancellation(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	rr := &rateLimitedReader{
+		ctx:     ctx,
+		r:       bytes.NewReader(testContent(100)),
+		limiter: rate.NewLimiter(1, 10),
+	}
+	if _, err := rr.Read(make([]byte, 10)); err != nil {
+		t.Fatal(err)
+	}
+
+	time.AfterFunc(10*time.Millisecond, cancel)
+	start := time.Now()
+	if _, err := io.Copy(io.Discard, rr); !errors.Is(err, context.Canceled) {
+		t.Errorf("io.Copy() error = %v, want %v", err, context.Canceled)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("read took %v after cancellation", elapsed)
+	}
+}
@@ -0,0 +1,38 @@
+package download
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+var (
+	errTooManyRedirects = errors.New("too many redirects")
+	errInsecureRedirect = errors.New("redirect to non-HTTPS URL rejected")
+)
+
+// redirectPolicy is the redirect policy of the fleet's HTTP client.
+type redirectPolicy struct {
+	// maxRedirects is the maximum number of redirects to follow for each request.
+	maxRedirects int
+
+	// httpsOnly controls whether redirects to URLs with schemes other than https are rejected.
+	httpsOnly bool
+}
+
+// checkRedirect implements [http.Client.CheckRedirect].
+func (p redirectPolicy) checkRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) > p.maxRedirects {
+		return fmt.Errorf("%w: stopped after %d", errTooManyRedirects, p.maxRedirects)
+	}
+	if p.httpsOnly && req.URL.Scheme != "https" {
+		return fmt.Errorf("%w: %s", errInsecureRedirect, req.URL.Redacted())
+	}
+	return nil
+}
+
+// isRedirectPolicyError returns whether err is caused by the fleet's redirect policy,
+// in which case repeating the request cannot succeed.
+func isRedirectPolicyError(err error) bool {
+	return errors.Is(err, errTooManyRedirects) || errors.Is(err, errInsecureRedirect)
+}
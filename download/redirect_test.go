@@ -0,0 +1,168 @@
+package download
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// newRedirectChainServer returns a server on which /r/{n} redirects to /r/{n-1},
+// and /r/0 serves content. requests counts the requests to /r/{start}.
+func newRedirectChainServer(t *testing.T, content []byte, start int, requests *atomic.Int32) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n, err := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/r/"))
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+		if n == start {
+			requests.Add(1)
+		}
+		if n == 0 {
+			_, _ = w.Write(content)
+			return
+		}
+		http.Redirect(w, r, "/r/"+strconv.Itoa(n-1), http.StatusFound)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestRedirectPolicyCapsRedirects(t *testing.T) {
+	const maxRedirects = 3
+	content := testContent(1000)
+
+	for _, c := range []struct {
+		name   string
+		hops   int
+		wantOK bool
+	}{
+		{"NoRedirect", 0, true},
+		{"WithinCap", maxRedirects, true},
+		{"ExceedingCap", maxRedirects + 1, false},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			var requests atomic.Int32
+			srv := newRedirectChainServer(t, content, c.hops, &requests)
+
+			j := newTestJob(srv.URL+"/r/"+strconv.Itoa(c.hops), content)
+			_, ok := runTestJob(t, &j, nil, WithRedirectPolicy(maxRedirects, false))
+			if ok != c.wantOK {
+				t.Fatalf("job ok = %v, want %v, last error: %v", ok, c.wantOK, j.lastErr)
+			}
+			if ok {
+				if !bytes.Equal(targetBytes(t, &j), content) {
+					t.Error("downloaded content does not match")
+				}
+				return
+			}
+			if !errors.Is(j.lastErr, errTooManyRedirects) {
+				t.Errorf("last error = %v, want %v", j.lastErr, errTooManyRedirects)
+			}
+			// The policy rejects the URL every time, so it is not retried.
+			if got := requests.Load(); got != 1 {
+				t.Errorf("requests to the redirecting URL = %d, want 1", got)
+			}
+		})
+	}
+}
+
+func TestRedirectPolicyZeroFollowsNoRedirects(t *testing.T) {
+	content := testContent(1000)
+	var requests atomic.Int32
+	srv := newRedirectChainServer(t, content, 1, &requests)
+
+	j := newTestJob(srv.URL+"/r/1", content)
+	if _, ok := runTestJob(t, &j, nil, WithRedirectPolicy(-1, false)); ok {
+		t.Fatal("job succeeded, want it to fail on the first redirect")
+	}
+	if !errors.Is(j.lastErr, errTooManyRedirects) {
+		t.Errorf("last error = %v, want %v", j.lastErr, errTooManyRedirects)
+	}
+}
+
+func TestRedirectPolicyHTTPSOnly(t *testing.T) {
+	content := testContent(1000)
+	var plainRequests atomic.Int32
+	plain := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		plainRequests.Add(1)
+		_, _ = w.Write(content)
+	}))
+	defer plain.Close()
+
+	secure := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/downgrade":
+			http.Redirect(w, r, plain.URL+"/file.bin", http.StatusFound)
+		case "/upgrade":
+			http.Redirect(w, r, "/file.bin", http.StatusFound)
+		default:
+			_, _ = w.Write(content)
+		}
+	}))
+	defer secure.Close()
+
+	t.Run("Downgrade", func(t *testing.T) {
+		plainRequests.Store(0)
+		j := newTestJob(secure.URL+"/downgrade", content)
+		if _, ok := runTestJob(t, &j, secure.Client(), WithRedirectPolicy(10, true)); ok {
+			t.Fatal("job succeeded, want the downgrade to be rejected")
+		}
+		if !errors.Is(j.lastErr, errInsecureRedirect) {
+			t.Errorf("last error = %v, want %v", j.lastErr, errInsecureRedirect)
+		}
+		if got := plainRequests.Load(); got != 0 {
+			t.Errorf("requests to the HTTP server = %d, want 0", got)
+		}
+	})
+
+	t.Run("DowngradeFallsBackToMirror", func(t *testing.T) {
+		plainRequests.Store(0)
+		j := newTestJob(secure.URL+"/downgrade", content)
+		j.Mirrors = []string{secure.URL + "/file.bin"}
+		if _, ok := runTestJob(t, &j, secure.Client(), WithRedirectPolicy(10, true)); !ok {
+			t.Fatalf("job failed: %v", j.lastErr)
+		}
+		if !bytes.Equal(targetBytes(t, &j), content) {
+			t.Error("downloaded content does not match")
+		}
+		if got := plainRequests.Load(); got != 0 {
+			t.Errorf("requests to the HTTP server = %d, want 0", got)
+		}
+	})
+
+	t.Run("HTTPSRedirect", func(t *testing.T) {
+		j := newTestJob(secure.URL+"/upgrade", content)
+		if _, ok := runTestJob(t, &j, secure.Client(), WithRedirectPolicy(10, true)); !ok {
+			t.Fatalf("job failed: %v", j.lastErr)
+		}
+	})
+
+	t.Run("DowngradeAllowed", func(t *testing.T) {
+		plainRequests.Store(0)
+		j := newTestJob(secure.URL+"/downgrade", content)
+		if _, ok := runTestJob(t, &j, secure.Client(), WithRedirectPolicy(10, false)); !ok {
+			t.Fatalf("job failed: %v", j.lastErr)
+		}
+		if got := plainRequests.Load(); got != 1 {
+			t.Errorf("requests to the HTTP server = %d, want 1", got)
+		}
+	})
+}
+
+func TestWithRedirectPolicyLeavesClientUnchanged(t *testing.T) {
+	client := &http.Client{}
+	cfg := newConfig(client, []Option{WithRedirectPolicy(1, true)})
+	if cfg.client == client || cfg.client.CheckRedirect == nil {
+		t.Error("the fleet's client does not have its own redirect policy")
+	}
+	if client.CheckRedirect != nil {
+		t.Error("the caller's client was modified")
+	}
+}
@@ -0,0 +1,142 @@
+package download
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// withNoBackoff makes the fleet retry attempts at the same URL immediately.
+func withNoBackoff() Option {
+	return func(c *config) {
+		c.backoff = noBackoff
+	}
+}
+
+func TestWorkerFleetStopsRetryingWhenBudgetExhausted(t *testing.T) {
+	const budget = 2
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	var logs bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&logs, nil))
+	jobCh := make(chan Job)
+	wf := NewWorkerFleet(context.Background(), logger, http.DefaultClient, 1, jobCh,
+		WithRetryBudget(budget), WithMaxAttempts(3), withNoBackoff())
+	const numJobs = 3
+	for i := range numJobs {
+		// Distinct content, so that no job waits on another's download.
+		jobCh <- newTestJob(fmt.Sprintf("%s/%d", srv.URL, i), testContent(1000+i))
+	}
+	close(jobCh)
+	wf.Wait()
+
+	if got := wf.Failures(); got != numJobs {
+		t.Errorf("Failures() = %d, want %d", got, numJobs)
+	}
+	// Each job makes one attempt, and the budget allows two repeated attempts in total.
+	if got, want := requests.Load(), int32(numJobs+budget); got != want {
+		t.Errorf("requests = %d, want %d", got, want)
+	}
+	if got := wf.Stats().Retries; got != budget {
+		t.Errorf("Stats().Retries = %d, want %d", got, budget)
+	}
+	if n := strings.Count(logs.String(), "Retry budget exhausted"); n != 1 {
+		t.Errorf("logged %d warnings about the exhausted retry budget, want 1", n)
+	}
+}
+
+func TestWorkerFleetGuessedMirrorsDoNotDrainRetryBudget(t *testing.T) {
+	const (
+		numJobs = 5
+		guesses = 15
+	)
+	var (
+		guessRequests atomic.Int32
+		flakyRequests atomic.Int32
+	)
+	flakyContent := testContent(2000)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/guess/", func(w http.ResponseWriter, r *http.Request) {
+		guessRequests.Add(1)
+		http.NotFound(w, r)
+	})
+	mux.HandleFunc("/real/{size}", func(w http.ResponseWriter, r *http.Request) {
+		size, _ := strconv.Atoi(r.PathValue("size"))
+		_, _ = w.Write(testContent(size))
+	})
+	mux.HandleFunc("/flaky", func(w http.ResponseWriter, r *http.Request) {
+		if flakyRequests.Add(1) == 1 {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write(flakyContent)
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	var logs bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&logs, nil))
+	jobCh := make(chan Job)
+	wf := NewWorkerFleet(context.Background(), logger, http.DefaultClient, 1, jobCh,
+		WithRetryBudget(1), WithMaxAttempts(2), withNoBackoff())
+	for i := range numJobs {
+		// Every guessed URL is rejected, and the file is found at the last mirror.
+		size := 1000 + i
+		j := newTestJob(fmt.Sprintf("%s/guess/%d-0", srv.URL, i), testContent(size))
+		for g := 1; g < guesses; g++ {
+			j.Mirrors = append(j.Mirrors, fmt.Sprintf("%s/guess/%d-%d", srv.URL, i, g))
+		}
+		j.Mirrors = append(j.Mirrors, fmt.Sprintf("%s/real/%d", srv.URL, size))
+		jobCh <- j
+	}
+	// A real retry after all the guesses is still within the budget.
+	jobCh <- newTestJob(srv.URL+"/flaky", flakyContent)
+	close(jobCh)
+	wf.Wait()
+
+	if got := wf.Failures(); got != 0 {
+		t.Errorf("Failures() = %d, want 0", got)
+	}
+	if got, want := guessRequests.Load(), int32(numJobs*guesses); got != want {
+		t.Errorf("requests for guessed URLs = %d, want %d", got, want)
+	}
+	if got := flakyRequests.Load(); got != 2 {
+		t.Errorf("requests for the flaky URL = %d, want 2", got)
+	}
+	if strings.Contains(logs.String(), "Retry budget exhausted") {
+		t.Errorf("retry budget exhausted by mirror attempts:\n%s", logs.String())
+	}
+}
+
+func TestWorkerFleetUnlimitedRetryBudget(t *testing.T) {
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		http.NotFound(w, r)
+	}))
+	defer srv.Close()
+
+	jobCh := make(chan Job)
+	wf := NewWorkerFleet(context.Background(), testLogger, http.DefaultClient, 1, jobCh, WithRetryBudget(-1))
+	j := newTestJob(srv.URL, testContent(1000))
+	j.Mirrors = []string{srv.URL + "/mirror1", srv.URL + "/mirror2"}
+	jobCh <- j
+	close(jobCh)
+	wf.Wait()
+
+	if got := requests.Load(); got != 3 {
+		t.Errorf("requests = %d, want 3", got)
+	}
+}
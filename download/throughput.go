@@ -0,0 +1,79 @@
+package download
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"sync/atomic"
+	"time"
+)
+
+// countingReader wraps a reader and adds the number of bytes read to a counter
+// that may be shared with other readers.
+type countingReader struct {
+	r io.Reader
+	n *atomic.Int64
+}
+
+// Read implements [io.Reader].
+func (cr *countingReader) Read(b []byte) (int, error) {
+	n, err := cr.r.Read(b)
+	cr.n.Add(int64(n))
+	return n, err
+}
+
+// throughputLogger periodically logs the aggregate download progress of a fleet.
+type throughputLogger struct {
+	logger   *slog.Logger
+	received *atomic.Int64
+	inFlight *atomic.Int64
+	total    func() int64
+	interval time.Duration
+}
+
+// run logs the progress every interval until stop is closed or ctx is done.
+func (tl *throughputLogger) run(ctx context.Context, stop <-chan struct{}) {
+	ticker := time.NewTicker(tl.interval)
+	defer ticker.Stop()
+
+	start := time.Now()
+	var lastReceived int64
+	lastTick := start
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			received := tl.received.Load()
+			if received == lastReceived && tl.inFlight.Load() == 0 {
+				// Nothing to report while the fleet is idle.
+				lastTick = now
+				continue
+			}
+
+			rate := float64(received-lastReceived) / now.Sub(lastTick).Seconds()
+			avgRate := float64(received) / now.Sub(start).Seconds()
+			lastReceived, lastTick = received, now
+
+			attrs := []slog.Attr{
+				slog.Int64("bytes", received),
+				slog.Int64("bytesPerSecond", int64(rate)),
+				slog.Int64("avgBytesPerSecond", int64(avgRate)),
+			}
+			if tl.total != nil {
+				if total := tl.total(); total > 0 {
+					remaining := max(total-received, 0)
+					attrs = append(attrs, slog.Int64("totalBytes", total))
+					if avgRate > 0 {
+						eta := time.Duration(float64(remaining) / avgRate * float64(time.Second))
+						attrs = append(attrs, slog.Duration("eta", eta.Round(time.Second)))
+					}
+				}
+			}
+			tl.logger.LogAttrs(ctx, slog.LevelInfo, "Download progress", attrs...)
+		}
+	}
+}
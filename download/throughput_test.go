@@ -0,0 +1,115 @@
+package download
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWorkerFleetCountsBytesReceived(t *testing.T) {
+	var contents sync.Map
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		content, _ := contents.Load(r.URL.Path)
+		_, _ = w.Write(content.([]byte))
+	}))
+	defer srv.Close()
+
+	jobCh := make(chan Job)
+	wf := NewWorkerFleet(context.Background(), testLogger, http.DefaultClient, 3, jobCh)
+	var total int64
+	for i, size := range []int{1000, 25000, 300000} {
+		content := testContent(size)
+		path := fmt.Sprintf("/%d", i)
+		contents.Store(path, content)
+		jobCh <- newTestJob(srv.URL+path, content)
+		total += int64(size)
+	}
+	close(jobCh)
+	wf.Wait()
+
+	if got := wf.BytesReceived(); got != total {
+		t.Errorf("BytesReceived() = %d, want %d", got, total)
+	}
+	if got := wf.Stats().Bytes; got != total {
+		t.Errorf("Stats().Bytes = %d, want %d", got, total)
+	}
+}
+
+func TestCountingReaderSharesCounter(t *testing.T) {
+	var n atomic.Int64
+	for _, s := range []string{"hello", "world!"} {
+		cr := &countingReader{r: strings.NewReader(s), n: &n}
+		if _, err := new(bytes.Buffer).ReadFrom(cr); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := n.Load(); got != 11 {
+		t.Errorf("counter = %d, want 11", got)
+	}
+}
+
+func TestThroughputLoggerLogsProgressAndETA(t *testing.T) {
+	var received, inFlight atomic.Int64
+	received.Store(500)
+	inFlight.Store(1)
+
+	var (
+		mu   sync.Mutex
+		logs bytes.Buffer
+	)
+	logger := slog.New(slog.NewTextHandler(&lockedWriter{mu: &mu, w: &logs}, nil))
+	tl := throughputLogger{
+		logger:   logger,
+		received: &received,
+		inFlight: &inFlight,
+		total:    func() int64 { return 1000 },
+		interval: 5 * time.Millisecond,
+	}
+
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		tl.run(context.Background(), stop)
+	}()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		mu.Lock()
+		s := logs.String()
+		mu.Unlock()
+		if strings.Contains(s, "Download progress") {
+			for _, attr := range []string{"bytes=500", "totalBytes=1000", "eta="} {
+				if !strings.Contains(s, attr) {
+					t.Errorf("progress log %q does not contain %q", s, attr)
+				}
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for a progress log")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	close(stop)
+	<-done
+}
+
+// lockedWriter serializes writes to w.
+type lockedWriter struct {
+	mu *sync.Mutex
+	w  *bytes.Buffer
+}
+
+func (lw *lockedWriter) Write(p []byte) (int, error) {
+	lw.mu.Lock()
+	defer lw.mu.Unlock()
+	return lw.w.Write(p)
+}
@@ -0,0 +1,92 @@
+package download
+
+import (
+	"context"
+	"crypto/tls"
+	"log/slog"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+// requestTrace records how long the phases of an HTTP request take,
+// including any redirects.
+type requestTrace struct {
+	mu sync.Mutex
+
+	start        time.Time
+	dnsStart     time.Time
+	connectStart time.Time
+	tlsStart     time.Time
+	dns          time.Duration
+	connect      time.Duration
+	tls          time.Duration
+	ttfb         time.Duration
+	reused       bool
+}
+
+// newRequestTrace returns a new requestTrace, and a context derived from ctx
+// that records the phases of requests made with it into the trace.
+func newRequestTrace(ctx context.Context) (*requestTrace, context.Context) {
+	t := &requestTrace{start: time.Now()}
+	return t, httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) {
+			t.mu.Lock()
+			t.dnsStart = time.Now()
+			t.mu.Unlock()
+		},
+		DNSDone: func(httptrace.DNSDoneInfo) {
+			t.mu.Lock()
+			t.dns += time.Since(t.dnsStart)
+			t.mu.Unlock()
+		},
+		// Dial attempts to multiple addresses may overlap. Count from the first start to the last finish.
+		ConnectStart: func(_, _ string) {
+			t.mu.Lock()
+			if t.connectStart.IsZero() {
+				t.connectStart = time.Now()
+			}
+			t.mu.Unlock()
+		},
+		ConnectDone: func(_, _ string, _ error) {
+			t.mu.Lock()
+			t.connect = time.Since(t.connectStart)
+			t.mu.Unlock()
+		},
+		TLSHandshakeStart: func() {
+			t.mu.Lock()
+			t.tlsStart = time.Now()
+			t.mu.Unlock()
+		},
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			t.mu.Lock()
+			t.tls += time.Since(t.tlsStart)
+			t.mu.Unlock()
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			t.mu.Lock()
+			t.reused = info.Reused
+			t.mu.Unlock()
+		},
+		GotFirstResponseByte: func() {
+			t.mu.Lock()
+			t.ttfb = time.Since(t.start)
+			t.mu.Unlock()
+		},
+	})
+}
+
+// log logs the recorded phase durations at debug level.
+func (t *requestTrace) log(ctx context.Context, logger *slog.Logger, name, url string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	logger.LogAttrs(ctx, slog.LevelDebug, "Request timing",
+		slog.String("name", name),
+		slog.String("url", url),
+		slog.Duration("dns", t.dns),
+		slog.Duration("connect", t.connect),
+		slog.Duration("tls", t.tls),
+		slog.Duration("ttfb", t.ttfb),
+		slog.Bool("reusedConn", t.reused),
+	)
+}
@@ -0,0 +1,124 @@
+package download
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// traceRecords runs the job with a debug logger, and returns the "Request timing" records it logs.
+func traceRecords(t *testing.T, j *Job, client *http.Client, level slog.Level, opts ...Option) []map[string]any {
+	t.Helper()
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: level}))
+	cfg := newConfig(client, opts)
+	cfg.backoff = noBackoff
+	if _, ok := j.runWithConfig(context.Background(), logger, cfg); !ok {
+		t.Fatalf("job failed: %v", j.lastErr)
+	}
+
+	var records []map[string]any
+	sc := bufio.NewScanner(&buf)
+	for sc.Scan() {
+		var r map[string]any
+		if err := json.Unmarshal(sc.Bytes(), &r); err != nil {
+			t.Fatal(err)
+		}
+		if r["msg"] == "Request timing" {
+			records = append(records, r)
+		}
+	}
+	return records
+}
+
+// traceDuration returns the duration field of a trace record.
+func traceDuration(t *testing.T, r map[string]any, key string) time.Duration {
+	t.Helper()
+	n, ok := r[key].(float64)
+	if !ok {
+		t.Fatalf("%s = %v, want a duration", key, r[key])
+	}
+	return time.Duration(n)
+}
+
+func TestJobHTTPTraceLogsTiming(t *testing.T) {
+	content := testContent(1000)
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, "test.bin", time.Time{}, bytes.NewReader(content))
+	}))
+	t.Cleanup(srv.Close)
+	client := srv.Client()
+
+	for i, c := range []struct {
+		name       string
+		wantReused bool
+	}{
+		{"NewConn", false},
+		// The connection of the first download is reused, so nothing is dialed.
+		{"ReusedConn", true},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			j := newTestJob(srv.URL+"/test.bin", content)
+			records := traceRecords(t, &j, client, slog.LevelDebug, WithHTTPTrace())
+			if len(records) != 1 {
+				t.Fatalf("got %d request timing records, want 1", len(records))
+			}
+			r := records[0]
+			if r["level"] != "DEBUG" {
+				t.Errorf("level = %v, want DEBUG", r["level"])
+			}
+			if r["name"] != j.TargetFile.Name() || r["url"] != j.DownloadURL {
+				t.Errorf("name, url = %v, %v, want %q, %q", r["name"], r["url"], j.TargetFile.Name(), j.DownloadURL)
+			}
+			if r["reusedConn"] != c.wantReused {
+				t.Errorf("reusedConn = %v, want %t", r["reusedConn"], c.wantReused)
+			}
+			// The server is dialed by IP address, so there is no DNS lookup.
+			if got := traceDuration(t, r, "dns"); got != 0 {
+				t.Errorf("dns = %v, want 0", got)
+			}
+			connect, tls, ttfb := traceDuration(t, r, "connect"), traceDuration(t, r, "tls"), traceDuration(t, r, "ttfb")
+			if c.wantReused {
+				if connect != 0 || tls != 0 {
+					t.Errorf("connect, tls = %v, %v, want 0 over a reused connection", connect, tls)
+				}
+			} else if connect <= 0 || tls <= 0 {
+				t.Errorf("connect, tls = %v, %v, want positive durations", connect, tls)
+			}
+			if ttfb <= 0 || ttfb < connect+tls {
+				t.Errorf("ttfb = %v, want a positive duration including connect %v and tls %v", ttfb, connect, tls)
+			}
+			if i == 0 && !bytes.Equal(targetBytes(t, &j), content) {
+				t.Error("downloaded content does not match")
+			}
+		})
+	}
+}
+
+func TestJobHTTPTraceOff(t *testing.T) {
+	content := testContent(1000)
+	srv := newContentServer(t, content, nil)
+
+	for _, c := range []struct {
+		name  string
+		level slog.Level
+		opts  []Option
+	}{
+		{"Disabled", slog.LevelDebug, nil},
+		// Tracing is skipped when its records would be dropped anyway.
+		{"AboveDebug", slog.LevelInfo, []Option{WithHTTPTrace()}},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			j := newTestJob(srv.URL, content)
+			if records := traceRecords(t, &j, http.DefaultClient, c.level, c.opts...); len(records) != 0 {
+				t.Errorf("got %d request timing records, want none", len(records))
+			}
+		})
+	}
+}
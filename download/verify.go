@@ -0,0 +1,53 @@
+package download
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+
+	"github.com/lmittmann/tint"
+)
+
+// verifyContent checks the target file's content against the expected hash sum,
+// and then calls the job's Verify function, if any.
+// If the job has no expected hash sum, the content is not checked.
+// It returns nil if the content matches, or the cause of the failure, and logs any mismatch.
+func (j *Job) verifyContent(ctx context.Context, logger *slog.Logger, url string) error {
+	if j.NewHash != nil && len(j.Sum) > 0 {
+		h := j.NewHash()
+		size, err := io.Copy(h, io.NewSectionReader(j.TargetFile, 0, 1<<63-1))
+		if err != nil {
+			logger.LogAttrs(ctx, slog.LevelWarn, "Failed to read downloaded file",
+				slog.String("name", j.TargetFile.Name()),
+				tint.Err(err),
+			)
+			return err
+		}
+
+		if sum := h.Sum(nil); !bytes.Equal(sum, j.Sum) {
+			logger.LogAttrs(ctx, slog.LevelWarn, "Downloaded file does not match expected hash sum",
+				slog.String("name", j.TargetFile.Name()),
+				slog.String("url", url),
+				slog.Int64("size", size),
+				slog.String("expectedSum", hex.EncodeToString(j.Sum)),
+				slog.String("actualSum", hex.EncodeToString(sum)),
+			)
+			return errContentMismatch
+		}
+	}
+
+	if j.Verify != nil {
+		if err := j.Verify(j.TargetFile.Name()); err != nil {
+			logger.LogAttrs(ctx, slog.LevelWarn, "Downloaded file failed verification",
+				slog.String("name", j.TargetFile.Name()),
+				slog.String("url", url),
+				tint.Err(err),
+			)
+			return fmt.Errorf("%w: %w", errVerifyFailed, err)
+		}
+	}
+	return nil
+}
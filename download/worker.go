@@ -2,11 +2,18 @@ package download
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"hash"
 	"io"
+	"io/fs"
 	"log/slog"
 	"net/http"
 	"os"
+	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/lmittmann/tint"
@@ -17,26 +24,89 @@ type Job struct {
 	// DownloadURL is the target file's download URL.
 	DownloadURL string
 
+	// Mirrors is a list of alternative download URLs.
+	// They are tried in order if downloading from DownloadURL fails.
+	Mirrors []string
+
 	// UserAgent is the user agent to use for the request.
 	// If empty, Go's default behavior is preserved.
 	UserAgent string
 
 	// TargetFile is the target file.
-	TargetFile *os.File
+	TargetFile File
 
 	// SecondaryTargetFile is the secondary target file.
 	// Nil means no secondary target file.
-	SecondaryTargetFile *os.File
+	SecondaryTargetFile File
+
+	// Size is the expected size of the file.
+	// Zero means the size is unknown.
+	Size int64
+
+	// NewHash is the function that returns a [hash.Hash] for verifying the downloaded content.
+	// Nil means the content is not verified.
+	NewHash func() hash.Hash
+
+	// Sum is the expected hash sum of the file.
+	// Empty means the content is not verified.
+	Sum []byte
+
+	// IfModifiedSince is the modification time of an existing copy of the file
+	// that could not be verified otherwise. If not zero, the request is made
+	// conditional, and the existing content is kept if the server reports that
+	// the file has not been modified since.
+	IfModifiedSince time.Time
+
+	// ModTime is the modification time to set on the downloaded file if the response has no
+	// Last-Modified header, such as the time the file was last updated in a modpack manifest.
+	// Zero means the modification time is left unchanged in that case.
+	ModTime time.Time
+
+	// RenameTo, if not empty, is the path to rename TargetFile to after the download
+	// has been verified. TargetFile is then a temporary [*os.File], and is left in place if the download fails,
+	// to be resumed from later, unless it's empty.
+	RenameTo string
+
+	// SecondaryRenameTo, if not empty, is the path to rename SecondaryTargetFile,
+	// which must be an [*os.File], to after the download has been verified.
+	SecondaryRenameTo string
+
+	// Verify, if not nil, is called with the name of the target file after its content has been
+	// checked against the expected hash sum, such as to check a detached signature over the file.
+	// If it returns an error, the download is rejected like one that does not match the hash sum.
+	Verify func(path string) error
+
+	// OnClose, if not nil, is called after the target files are closed.
+	OnClose func()
+
+	// OnSuccess, if not nil, is called after the download has been verified,
+	// its modification time set, and the target files renamed into place.
+	OnSuccess func()
+
+	// identicalPath, if not empty, is the path to a downloaded file with the same content,
+	// to copy instead of downloading the file.
+	identicalPath string
+
+	// lastErr is the cause of the most recent failed download attempt, if known.
+	lastErr error
+
+	// lastErrRetryable is whether the most recent failed download attempt
+	// may succeed if repeated against the same URL.
+	lastErrRetryable bool
 }
 
-// mtimeFromResponse returns the modification time from the response.
-func mtimeFromResponse(ctx context.Context, logger *slog.Logger, resp *http.Response) time.Time {
+// mtimeFromResponse returns the modification time from the response's Last-Modified header.
+// If the header is missing or malformed, the job's ModTime is returned instead.
+func (j *Job) mtimeFromResponse(ctx context.Context, logger *slog.Logger, resp *http.Response) time.Time {
 	lastModified := resp.Header["Last-Modified"]
+	if len(lastModified) == 0 {
+		return j.ModTime
+	}
 	if len(lastModified) != 1 {
 		logger.LogAttrs(ctx, slog.LevelWarn, "Malformed Last-Modified header",
 			slog.Any("Last-Modified", lastModified),
 		)
-		return time.Time{}
+		return j.ModTime
 	}
 	mtime, err := time.Parse(http.TimeFormat, lastModified[0])
 	if err != nil {
@@ -44,90 +114,454 @@ func mtimeFromResponse(ctx context.Context, logger *slog.Logger, resp *http.Resp
 			slog.String("Last-Modified", lastModified[0]),
 			tint.Err(err),
 		)
-		return time.Time{}
+		return j.ModTime
 	}
 	return mtime
 }
 
-// run runs the job, closes the target files, and returns the modification time of the file
-// as reported by the server. It's up to the caller to actually set the modification time.
-func (j *Job) run(ctx context.Context, logger *slog.Logger, client *http.Client) (mtime time.Time) {
-	defer func() {
-		j.TargetFile.Close()
-		if j.SecondaryTargetFile != nil {
-			j.SecondaryTargetFile.Close()
-		}
-	}()
+// contentRangeStart returns the first byte position of the Content-Range header of a 206 response.
+func contentRangeStart(resp *http.Response) (int64, bool) {
+	contentRange := resp.Header.Get("Content-Range")
+	unit, spec, ok := strings.Cut(contentRange, " ")
+	if !ok || unit != "bytes" {
+		return 0, false
+	}
+	first, _, ok := strings.Cut(spec, "-")
+	if !ok {
+		return 0, false
+	}
+	start, err := strconv.ParseInt(first, 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return start, true
+}
 
-	logger.LogAttrs(ctx, slog.LevelInfo, "Downloading file",
-		slog.String("name", j.TargetFile.Name()),
-		slog.String("url", j.DownloadURL),
-	)
+// sendRequest sends the download request to the given URL.
+// If offset is positive, a range request is sent to fetch the file starting at offset.
+func (j *Job) sendRequest(ctx context.Context, logger *slog.Logger, cfg *config, url string, offset int64) (*http.Response, bool) {
+	reqCtx := ctx
+	var trace *requestTrace
+	if cfg.trace && logger.Enabled(ctx, slog.LevelDebug) {
+		trace, reqCtx = newRequestTrace(ctx)
+	}
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, j.DownloadURL, nil)
+	req, err := http.NewRequestWithContext(reqCtx, http.MethodGet, url, nil)
 	if err != nil {
 		logger.LogAttrs(ctx, slog.LevelWarn, "Failed to create request",
 			slog.String("name", j.TargetFile.Name()),
-			slog.String("url", j.DownloadURL),
+			slog.String("url", url),
 			tint.Err(err),
 		)
-		return
+		return nil, false
 	}
 
 	if j.UserAgent != "" {
 		req.Header["User-Agent"] = []string{j.UserAgent}
 	}
+	// Ask for the raw bytes, so that they can be hashed and resumed from as is.
+	// This also stops the transport from transparently requesting gzip.
+	req.Header["Accept-Encoding"] = []string{"identity"}
+
+	if offset > 0 {
+		req.Header["Range"] = []string{"bytes=" + strconv.FormatInt(offset, 10) + "-"}
+	} else if !j.IfModifiedSince.IsZero() {
+		req.Header["If-Modified-Since"] = []string{j.IfModifiedSince.UTC().Format(http.TimeFormat)}
+	}
 
-	resp, err := client.Do(req)
+	resp, err := cfg.client.Do(req)
+	if trace != nil {
+		trace.log(ctx, logger, j.TargetFile.Name(), url)
+	}
 	if err != nil {
 		logger.LogAttrs(ctx, slog.LevelWarn, "Failed to send request",
 			slog.String("name", j.TargetFile.Name()),
-			slog.String("url", j.DownloadURL),
+			slog.String("url", url),
 			tint.Err(err),
 		)
-		return
+		j.failAttempt(err, !isRedirectPolicyError(err))
+		return nil, false
+	}
+
+	if finalURL := resp.Request.URL.String(); finalURL != url {
+		logger.LogAttrs(ctx, slog.LevelDebug, "Followed redirect",
+			slog.String("name", j.TargetFile.Name()),
+			slog.String("url", url),
+			slog.String("finalURL", finalURL),
+		)
+	}
+	return resp, true
+}
+
+// downloadFrom downloads the file from the given URL into the target file.
+// It returns the modification time of the file as reported by the server,
+// the number of bytes downloaded, and whether the download succeeded.
+//
+// If an attempt fails with a network error or a 429 or 5xx response, the download is attempted again
+// after an exponential backoff, up to the fleet's maximum number of attempts. Each attempt resumes
+// from the content already written, which downloadOnce validates with a range request.
+//
+// If HEAD checks are enabled, and the server advertises a content hash that does not match,
+// the URL is not downloaded from.
+func (j *Job) downloadFrom(ctx context.Context, logger *slog.Logger, cfg *config, url string) (mtime time.Time, n int64, ok bool) {
+	if cfg.headCheck && !j.headCheck(ctx, logger, cfg, url) {
+		return time.Time{}, 0, false
+	}
+
+	for attempt := 1; ; attempt++ {
+		var attemptN int64
+		mtime, attemptN, ok = j.downloadAttempt(ctx, logger, cfg, url)
+		n += attemptN
+		if ok || attempt >= cfg.maxAttempts || !j.lastErrRetryable || ctx.Err() != nil || !cfg.takeRetry(ctx, logger) {
+			return mtime, n, ok
+		}
+
+		delay := cfg.backoff(attempt + 1)
+		logger.LogAttrs(ctx, slog.LevelInfo, "Retrying download",
+			slog.String("name", j.TargetFile.Name()),
+			slog.String("url", url),
+			slog.Int("attempt", attempt+1),
+			slog.Duration("delay", delay),
+			tint.Err(j.lastErr),
+		)
+		if !sleepCtx(ctx, delay) {
+			return mtime, n, false
+		}
+	}
+}
+
+// downloadAttempt makes one attempt at downloading the file from the given URL into the target file,
+// with the same results as downloadFrom.
+//
+// If a resumed download fails the hash check, the file is downloaded again from scratch,
+// up to the fleet's number of full retries.
+func (j *Job) downloadAttempt(ctx context.Context, logger *slog.Logger, cfg *config, url string) (mtime time.Time, n int64, ok bool) {
+	mtime, n, ok, corruptResume := j.downloadOnce(ctx, logger, cfg, url)
+	for i := 0; corruptResume && i < cfg.fullRetries && ctx.Err() == nil && cfg.takeRetry(ctx, logger); i++ {
+		logger.LogAttrs(ctx, slog.LevelInfo, "Retrying full download after resumed content failed hash check",
+			slog.String("name", j.TargetFile.Name()),
+			slog.String("url", url),
+			slog.Int("attempt", i+1),
+		)
+		var retryN int64
+		mtime, retryN, ok, corruptResume = j.downloadOnce(ctx, logger, cfg, url)
+		n += retryN
+	}
+	return mtime, n, ok
+}
+
+// downloadOnce makes a single pass at downloading the file from the given URL into the target file.
+// In addition to the results of downloadFrom, it returns whether the attempt resumed from existing
+// content and failed the hash check, in which case the target file has been truncated.
+//
+// If the target file already contains a prefix of the file, downloadOnce attempts to resume the download
+// with a range request. If the server does not honor the range request, the file is downloaded
+// from scratch.
+//
+// If chunked downloads are enabled, the file is large enough, and the server supports range requests,
+// the file is downloaded in concurrent chunks instead.
+//
+// If the fleet has a download timeout, or a minimum throughput, the whole attempt,
+// including reading the response body, is aborted when the timeout expires.
+//
+// On failure, the cause is recorded in the job's lastErr, if known.
+func (j *Job) downloadOnce(ctx context.Context, logger *slog.Logger, cfg *config, url string) (mtime time.Time, n int64, ok, corruptResume bool) {
+	j.failAttempt(nil, false)
+
+	if timeout := cfg.attemptTimeout(j.Size); timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	if cfg.maxFileSize > 0 && j.Size > cfg.maxFileSize {
+		logger.LogAttrs(ctx, slog.LevelWarn, "Refusing to download file larger than maximum size",
+			slog.String("name", j.TargetFile.Name()),
+			slog.Int64("size", j.Size),
+			slog.Int64("maxSize", cfg.maxFileSize),
+		)
+		j.failAttempt(errFileTooLarge, false)
+		return time.Time{}, 0, false, false
+	}
+
+	offset, err := j.TargetFile.Seek(0, io.SeekEnd)
+	if err != nil {
+		logger.LogAttrs(ctx, slog.LevelWarn, "Failed to seek to end of file",
+			slog.String("name", j.TargetFile.Name()),
+			tint.Err(err),
+		)
+		return time.Time{}, 0, false, false
+	}
+	if j.Size > 0 && offset >= j.Size {
+		// The existing content cannot be a prefix of the file.
+		offset = 0
+	}
+
+	if offset == 0 && j.IfModifiedSince.IsZero() && cfg.chunks > 1 && j.Size > 0 && j.Size >= cfg.chunkThreshold {
+		if probe, ok := j.probeRanges(ctx, cfg, url); ok {
+			mtime, n, ok = j.downloadChunked(ctx, logger, cfg, url, probe)
+			return mtime, n, ok, false
+		}
+	}
+
+	resp, ok := j.sendRequest(ctx, logger, cfg, url, offset)
+	if !ok {
+		return time.Time{}, 0, false, false
 	}
-	defer resp.Body.Close()
+	defer func() {
+		resp.Body.Close()
+	}()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		offset = 0
+
+	case http.StatusNotModified:
+		logger.LogAttrs(ctx, slog.LevelInfo, "Skipping unmodified file",
+			slog.String("name", j.TargetFile.Name()),
+			slog.String("url", url),
+		)
+		return time.Time{}, 0, true, false
+
+	case http.StatusPartialContent:
+		if enc := contentEncoding(resp); enc != "" {
+			logger.LogAttrs(ctx, slog.LevelWarn, "Unexpected Content-Encoding of partial content",
+				slog.String("name", j.TargetFile.Name()),
+				slog.String("url", url),
+				slog.String("Content-Encoding", enc),
+			)
+			return time.Time{}, 0, false, false
+		}
+		if start, ok := contentRangeStart(resp); !ok || start != offset {
+			logger.LogAttrs(ctx, slog.LevelWarn, "Unexpected Content-Range",
+				slog.String("name", j.TargetFile.Name()),
+				slog.String("url", url),
+				slog.Int64("offset", offset),
+				slog.String("Content-Range", resp.Header.Get("Content-Range")),
+			)
+			return time.Time{}, 0, false, false
+		}
+
+		logger.LogAttrs(ctx, slog.LevelInfo, "Resuming download",
+			slog.String("name", j.TargetFile.Name()),
+			slog.String("url", url),
+			slog.Int64("offset", offset),
+		)
 
-	if resp.StatusCode != http.StatusOK {
+	case http.StatusRequestedRangeNotSatisfiable:
+		// The server supports range requests, but the existing content is not a prefix of the file.
+		resp.Body.Close()
+		offset = 0
+		fullResp, ok := j.sendRequest(ctx, logger, cfg, url, 0)
+		if !ok {
+			return time.Time{}, 0, false, false
+		}
+		resp = fullResp
+		if resp.StatusCode != http.StatusOK {
+			logger.LogAttrs(ctx, slog.LevelWarn, "Unexpected status code",
+				slog.String("name", j.TargetFile.Name()),
+				slog.String("url", url),
+				slog.Int("status", resp.StatusCode),
+			)
+			j.failStatus(resp.StatusCode)
+			return time.Time{}, 0, false, false
+		}
+
+	default:
 		logger.LogAttrs(ctx, slog.LevelWarn, "Unexpected status code",
 			slog.String("name", j.TargetFile.Name()),
-			slog.String("url", j.DownloadURL),
+			slog.String("url", url),
 			slog.Int("status", resp.StatusCode),
 		)
-		return
+		j.failStatus(resp.StatusCode)
+		return time.Time{}, 0, false, false
+	}
+
+	if cfg.maxFileSize > 0 && resp.ContentLength >= 0 && contentEncoding(resp) == "" && offset+resp.ContentLength > cfg.maxFileSize {
+		logger.LogAttrs(ctx, slog.LevelWarn, "Refusing to download file larger than maximum size",
+			slog.String("name", j.TargetFile.Name()),
+			slog.String("url", url),
+			slog.Int64("size", offset+resp.ContentLength),
+			slog.Int64("maxSize", cfg.maxFileSize),
+		)
+		j.failAttempt(errFileTooLarge, false)
+		return time.Time{}, 0, false, false
+	}
+
+	if offset == 0 {
+		if err = truncateFile(j.TargetFile); err != nil {
+			logger.LogAttrs(ctx, slog.LevelWarn, "Failed to truncate file",
+				slog.String("name", j.TargetFile.Name()),
+				tint.Err(err),
+			)
+			return time.Time{}, 0, false, false
+		}
+	}
+
+	preallocated := cfg.preallocate && offset == 0 && j.Size > 0
+	if preallocated {
+		if err = preallocateFile(j.TargetFile, j.Size); err != nil {
+			logger.LogAttrs(ctx, slog.LevelWarn, "Failed to preallocate file",
+				slog.String("name", j.TargetFile.Name()),
+				slog.Int64("size", j.Size),
+				tint.Err(err),
+			)
+			preallocated = false
+		}
+	}
+
+	decoded, err := decodeBody(resp)
+	if err != nil {
+		logger.LogAttrs(ctx, slog.LevelWarn, "Failed to decode response body",
+			slog.String("name", j.TargetFile.Name()),
+			slog.String("url", url),
+			slog.String("Content-Encoding", resp.Header.Get("Content-Encoding")),
+			tint.Err(err),
+		)
+		j.failAttempt(err, false)
+		return time.Time{}, 0, false, false
 	}
+	defer decoded.Close()
 
-	if _, err = j.TargetFile.ReadFrom(resp.Body); err != nil {
+	var body io.Reader = decoded
+	if cfg.maxFileSize > 0 {
+		body = &maxSizeReader{r: body, n: cfg.maxFileSize - offset}
+	}
+	if cfg.rateLimiter != nil {
+		body = &rateLimitedReader{ctx: ctx, r: body, limiter: cfg.rateLimiter}
+	}
+	if cfg.received != nil {
+		body = &countingReader{r: body, n: cfg.received}
+	}
+	if cfg.progressFunc != nil {
+		total := int64(-1)
+		if resp.ContentLength >= 0 && contentEncoding(resp) == "" {
+			total = offset + resp.ContentLength
+		} else if j.Size > 0 {
+			total = j.Size
+		}
+		pr := newProgressReader(body, cfg.progressFunc, url, offset, total)
+		defer pr.report()
+		body = pr
+	}
+
+	n, err = j.TargetFile.ReadFrom(body)
+	if preallocated && n != j.Size {
+		// Cut off the unwritten preallocated space, so that it's not mistaken for content.
+		if terr := j.TargetFile.Truncate(n); terr != nil && err == nil {
+			err = terr
+		}
+	}
+	if err != nil {
 		logger.LogAttrs(ctx, slog.LevelWarn, "Failed to download file",
 			slog.String("name", j.TargetFile.Name()),
-			slog.String("url", j.DownloadURL),
+			slog.String("url", url),
 			tint.Err(err),
 		)
-		return
+		tooLarge := errors.Is(err, errFileTooLarge)
+		if tooLarge {
+			// Do not keep the oversized content around for resumption.
+			_ = truncateFile(j.TargetFile)
+		}
+		j.failAttempt(err, !tooLarge)
+		return time.Time{}, n, false, false
+	}
+
+	if err := j.verifyContent(ctx, logger, url); err != nil {
+		// Do not resume from corrupt content.
+		_ = truncateFile(j.TargetFile)
+		j.failAttempt(err, false)
+		return time.Time{}, n, false, offset > 0
 	}
 
 	logger.LogAttrs(ctx, slog.LevelInfo, "Downloaded file",
 		slog.String("name", j.TargetFile.Name()),
-		slog.String("url", j.DownloadURL),
+		slog.String("url", url),
 	)
 
+	return j.mtimeFromResponse(ctx, logger, resp), n, true, false
+}
+
+// run runs the job, and closes the target files, removing them if the job failed and left them empty.
+// It returns the modification time of the file as reported by the server, or ModTime if it's not reported,
+// the total number of bytes downloaded, and whether the job succeeded.
+// It's up to the caller to actually set the modification time.
+//
+// The file is downloaded from DownloadURL. If that fails, each of Mirrors is tried in order.
+func (j *Job) run(ctx context.Context, logger *slog.Logger, cfg *config) (mtime time.Time, n int64, ok bool) {
+	defer func() {
+		if !ok {
+			// Do not leave behind empty files of downloads that failed or were canceled.
+			j.discard(ctx, logger)
+			return
+		}
+		j.TargetFile.Close()
+		if j.SecondaryTargetFile != nil {
+			j.SecondaryTargetFile.Close()
+		}
+		if j.OnClose != nil {
+			j.OnClose()
+		}
+	}()
+
+	if j.identicalPath != "" {
+		mtime, ok = j.copyFromIdentical(ctx, logger, j.identicalPath)
+	}
+
+	if !ok {
+		logger.LogAttrs(ctx, slog.LevelInfo, "Downloading file",
+			slog.String("name", j.TargetFile.Name()),
+			slog.String("url", j.DownloadURL),
+		)
+
+		mtime, n, ok = j.downloadFrom(ctx, logger, cfg, j.DownloadURL)
+	}
+	// Mirrors are tried regardless of the retry budget.
+	for i := 0; !ok && i < len(j.Mirrors) && ctx.Err() == nil; i++ {
+		cfg.countRetry()
+		logger.LogAttrs(ctx, slog.LevelInfo, "Trying mirror",
+			slog.String("name", j.TargetFile.Name()),
+			slog.String("url", j.Mirrors[i]),
+		)
+		var mirrorN int64
+		mtime, mirrorN, ok = j.downloadFrom(ctx, logger, cfg, j.Mirrors[i])
+		n += mirrorN
+		if ok {
+			logger.LogAttrs(ctx, slog.LevelInfo, "Downloaded file from mirror",
+				slog.String("name", j.TargetFile.Name()),
+				slog.String("url", j.Mirrors[i]),
+				slog.Int("mirror", i),
+			)
+		}
+	}
+	if !ok {
+		return time.Time{}, n, false
+	}
+
 	if j.SecondaryTargetFile != nil {
-		if _, err = j.TargetFile.Seek(0, io.SeekStart); err != nil {
+		if _, err := j.TargetFile.Seek(0, io.SeekStart); err != nil {
 			logger.LogAttrs(ctx, slog.LevelWarn, "Failed to seek to start of file",
 				slog.String("name", j.TargetFile.Name()),
 				tint.Err(err),
 			)
-			return
+			return time.Time{}, n, false
+		}
+
+		if err := truncateFile(j.SecondaryTargetFile); err != nil {
+			logger.LogAttrs(ctx, slog.LevelWarn, "Failed to truncate file",
+				slog.String("name", j.SecondaryTargetFile.Name()),
+				tint.Err(err),
+			)
+			return time.Time{}, n, false
 		}
 
-		if _, err = j.SecondaryTargetFile.ReadFrom(j.TargetFile); err != nil {
+		if _, err := j.SecondaryTargetFile.ReadFrom(j.TargetFile); err != nil {
 			logger.LogAttrs(ctx, slog.LevelWarn, "Failed to copy file",
 				slog.String("src", j.TargetFile.Name()),
 				slog.String("dst", j.SecondaryTargetFile.Name()),
 				tint.Err(err),
 			)
-			return
+			return time.Time{}, n, false
 		}
 
 		logger.LogAttrs(ctx, slog.LevelInfo, "Copied to secondary file",
@@ -136,38 +570,212 @@ func (j *Job) run(ctx context.Context, logger *slog.Logger, client *http.Client)
 		)
 	}
 
-	return mtimeFromResponse(ctx, logger, resp)
+	return mtime, n, true
+}
+
+// discard closes the target files of a job that will not be run,
+// and removes those that are empty, so that they are not left behind as zero-byte files.
+func (j *Job) discard(ctx context.Context, logger *slog.Logger) {
+	discardFile(ctx, logger, j.TargetFile)
+	if j.SecondaryTargetFile != nil {
+		discardFile(ctx, logger, j.SecondaryTargetFile)
+	}
+	if j.OnClose != nil {
+		j.OnClose()
+	}
+}
+
+// discardFile closes the file, and removes it if it's empty.
+func discardFile(ctx context.Context, logger *slog.Logger, f File) {
+	f.Close()
+	if _, ok := osFile(f); ok {
+		removeIfEmpty(ctx, logger, f.Name())
+	}
+}
+
+// removeIfEmpty removes the named file if it's empty.
+func removeIfEmpty(ctx context.Context, logger *slog.Logger, name string) {
+	fi, err := os.Lstat(name)
+	if err != nil || fi.Size() != 0 {
+		return
+	}
+	if err = os.Remove(name); err != nil {
+		logger.LogAttrs(ctx, slog.LevelWarn, "Failed to remove empty file",
+			slog.String("name", name),
+			tint.Err(err),
+		)
+	}
+}
+
+// truncateFile truncates the file to zero size and seeks to the start of the file.
+func truncateFile(f File) error {
+	if err := f.Truncate(0); err != nil {
+		return err
+	}
+	_, err := f.Seek(0, io.SeekStart)
+	return err
 }
 
 // Run runs the job.
 func (j *Job) Run(ctx context.Context, logger *slog.Logger, client *http.Client) {
-	mtime := j.run(ctx, logger, client)
-	if mtime.IsZero() {
-		return
+	j.runWithConfig(ctx, logger, newConfig(client, nil))
+}
+
+// runWithConfig runs the job with the given configuration.
+// It returns the number of bytes downloaded, and whether the job succeeded.
+//
+// If the fleet has a content index, and another job downloads the same content,
+// the job waits for it to finish and copies its file instead of downloading the file again.
+func (j *Job) runWithConfig(ctx context.Context, logger *slog.Logger, cfg *config) (n int64, ok bool) {
+	if cfg.contents == nil || !j.dedupable() {
+		return j.runOnce(ctx, logger, cfg)
 	}
 
-	if err := os.Chtimes(j.TargetFile.Name(), mtime, mtime); err != nil {
-		logger.LogAttrs(ctx, slog.LevelWarn, "Failed to set modification time",
-			slog.String("name", j.TargetFile.Name()),
+	e, first := cfg.contents.claim(j)
+	if !first {
+		j.identicalPath = e.wait(ctx)
+		return j.runOnce(ctx, logger, cfg)
+	}
+
+	n, ok = j.runOnce(ctx, logger, cfg)
+	if ok {
+		e.complete(j.finalPath())
+	} else {
+		e.complete("")
+	}
+	return n, ok
+}
+
+// runOnce runs the job with the given configuration.
+// It returns the number of bytes downloaded, and whether the job succeeded.
+func (j *Job) runOnce(ctx context.Context, logger *slog.Logger, cfg *config) (n int64, ok bool) {
+	var mtime time.Time
+	mtime, n, ok = j.run(ctx, logger, cfg)
+	if !ok {
+		return n, false
+	}
+
+	if !mtime.IsZero() {
+		for _, f := range [...]File{j.TargetFile, j.SecondaryTargetFile} {
+			if _, isOSFile := osFile(f); isOSFile && !cfg.setModTime(ctx, logger, f.Name(), mtime) {
+				break
+			}
+		}
+	}
+
+	if j.RenameTo != "" && !renameFile(ctx, logger, j.TargetFile.Name(), j.RenameTo) {
+		return n, false
+	}
+	if j.SecondaryRenameTo != "" && !renameFile(ctx, logger, j.SecondaryTargetFile.Name(), j.SecondaryRenameTo) {
+		return n, false
+	}
+	if j.OnSuccess != nil {
+		j.OnSuccess()
+	}
+	return n, true
+}
+
+// renameFile renames the downloaded file into place, and returns whether it succeeded.
+func renameFile(ctx context.Context, logger *slog.Logger, oldpath, newpath string) bool {
+	if err := os.Rename(oldpath, newpath); err != nil {
+		logger.LogAttrs(ctx, slog.LevelWarn, "Failed to rename downloaded file",
+			slog.String("src", oldpath),
+			slog.String("dst", newpath),
 			tint.Err(err),
 		)
-		return
+		return false
 	}
+	return true
+}
 
-	if j.SecondaryTargetFile != nil {
-		if err := os.Chtimes(j.SecondaryTargetFile.Name(), mtime, mtime); err != nil {
-			logger.LogAttrs(ctx, slog.LevelWarn, "Failed to set modification time",
-				slog.String("name", j.SecondaryTargetFile.Name()),
+// takeRetry takes a retry from the fleet's retry budget, and returns whether the caller may retry.
+// Retries taken are counted in the fleet's stats.
+// The first time the budget is found to be exhausted, a warning is logged.
+func (cfg *config) takeRetry(ctx context.Context, logger *slog.Logger) bool {
+	if cfg.retryBudget == nil || cfg.retryBudget.Add(-1) >= 0 {
+		cfg.countRetry()
+		return true
+	}
+	if cfg.retryBudgetExhausted.CompareAndSwap(false, true) {
+		logger.LogAttrs(ctx, slog.LevelWarn, "Retry budget exhausted, no longer retrying failed downloads")
+	}
+	return false
+}
+
+// countRetry counts a retry in the fleet's stats.
+func (cfg *config) countRetry() {
+	if cfg.retries != nil {
+		cfg.retries.Add(1)
+	}
+}
+
+// setModTime sets the modification time of the named file.
+// It returns whether the caller should proceed with setting the modification time of other files.
+//
+// If the filesystem does not support setting times, or the operation is not permitted,
+// setting modification times is disabled for all subsequent jobs, and a single warning is logged.
+func (cfg *config) setModTime(ctx context.Context, logger *slog.Logger, name string, mtime time.Time) bool {
+	if cfg.chtimesDisabled.Load() {
+		return false
+	}
+
+	err := cfg.chtimes(name, mtime, mtime)
+	if err == nil {
+		return true
+	}
+
+	if errors.Is(err, errors.ErrUnsupported) || errors.Is(err, fs.ErrPermission) {
+		if cfg.chtimesDisabled.CompareAndSwap(false, true) {
+			logger.LogAttrs(ctx, slog.LevelWarn, "Failed to set modification time, disabling for remaining files",
+				slog.String("name", name),
 				tint.Err(err),
 			)
-			return
 		}
+		return false
 	}
+
+	logger.LogAttrs(ctx, slog.LevelWarn, "Failed to set modification time",
+		slog.String("name", name),
+		tint.Err(err),
+	)
+	return false
+}
+
+// Stats contains the results of the jobs run by a worker fleet.
+type Stats struct {
+	// Downloaded is the number of jobs that succeeded.
+	Downloaded int64
+
+	// Failed is the number of jobs that failed.
+	Failed int64
+
+	// Bytes is the total number of bytes downloaded.
+	Bytes int64
+
+	// Retries is the number of times a failed download was retried.
+	Retries int64
+
+	// LastError is the cause of the most recent job failure, if known.
+	LastError error
 }
 
 // WorkerFleet manages a fleet of workers.
 type WorkerFleet struct {
-	wg sync.WaitGroup
+	wg         sync.WaitGroup
+	jobCh      <-chan Job
+	inFlight   atomic.Int64
+	downloaded atomic.Int64
+	failed     atomic.Int64
+	bytes      atomic.Int64
+	received   atomic.Int64
+	retries    atomic.Int64
+
+	mu      sync.Mutex
+	lastErr error
+
+	stopProgress     chan struct{}
+	stopProgressOnce sync.Once
+	progressWg       sync.WaitGroup
 }
 
 // NewWorkerFleet creates a new worker fleet with the given number of workers.
@@ -176,8 +784,27 @@ type WorkerFleet struct {
 //
 // After use, close the channel to stop the workers.
 // Call the Wait method to wait for the workers to finish.
-func NewWorkerFleet(ctx context.Context, logger *slog.Logger, client *http.Client, numWorkers int, jobCh <-chan Job) *WorkerFleet {
-	var wf WorkerFleet
+func NewWorkerFleet(ctx context.Context, logger *slog.Logger, client *http.Client, numWorkers int, jobCh <-chan Job, opts ...Option) *WorkerFleet {
+	wf := WorkerFleet{jobCh: jobCh}
+	cfg := newConfig(client, opts)
+	cfg.contents = new(contentIndex)
+	cfg.received = &wf.received
+	cfg.retries = &wf.retries
+	if cfg.progressLogInterval > 0 {
+		tl := throughputLogger{
+			logger:   logger,
+			received: &wf.received,
+			inFlight: &wf.inFlight,
+			total:    cfg.progressLogTotal,
+			interval: cfg.progressLogInterval,
+		}
+		wf.stopProgress = make(chan struct{})
+		wf.progressWg.Add(1)
+		go func() {
+			defer wf.progressWg.Done()
+			tl.run(ctx, wf.stopProgress)
+		}()
+	}
 	wf.wg.Add(numWorkers)
 	for i := 0; i < numWorkers; i++ {
 		go func() {
@@ -186,9 +813,43 @@ func NewWorkerFleet(ctx context.Context, logger *slog.Logger, client *http.Clien
 			for job := range jobCh {
 				select {
 				case <-done:
+					job.discard(ctx, logger)
+					continue
+				case <-cfg.drain:
+					job.discard(ctx, logger)
 					continue
 				default:
-					job.Run(ctx, logger, client)
+					var e Event
+					if cfg.eventHandler != nil {
+						e = job.jobEvent()
+						cfg.eventHandler.OnDownloadStart(e)
+					}
+					wf.inFlight.Add(1)
+					n, ok := job.runWithConfig(ctx, logger, cfg)
+					wf.inFlight.Add(-1)
+					wf.bytes.Add(n)
+					if ok {
+						wf.downloaded.Add(1)
+					} else {
+						wf.failed.Add(1)
+						if job.lastErr != nil {
+							wf.mu.Lock()
+							wf.lastErr = job.lastErr
+							wf.mu.Unlock()
+						}
+					}
+					if cfg.eventHandler != nil {
+						e.Bytes = n
+						if ok {
+							cfg.eventHandler.OnDownloadComplete(e)
+						} else {
+							e.Err = ErrDownloadFailed
+							if job.lastErr != nil {
+								e.Err = fmt.Errorf("%w: %w", ErrDownloadFailed, job.lastErr)
+							}
+							cfg.eventHandler.OnError(e)
+						}
+					}
 				}
 			}
 		}()
@@ -196,7 +857,49 @@ func NewWorkerFleet(ctx context.Context, logger *slog.Logger, client *http.Clien
 	return &wf
 }
 
-// Wait waits for the workers to finish.
+// Stats returns the results of the jobs run so far.
+func (wf *WorkerFleet) Stats() Stats {
+	wf.mu.Lock()
+	lastErr := wf.lastErr
+	wf.mu.Unlock()
+	return Stats{
+		Downloaded: wf.downloaded.Load(),
+		Failed:     wf.failed.Load(),
+		Bytes:      wf.bytes.Load(),
+		Retries:    wf.retries.Load(),
+		LastError:  lastErr,
+	}
+}
+
+// InFlight returns the number of jobs being run by the workers.
+func (wf *WorkerFleet) InFlight() int {
+	return int(wf.inFlight.Load())
+}
+
+// Queued returns the number of jobs buffered in the job channel, waiting for a free worker.
+// It is always zero for an unbuffered channel.
+func (wf *WorkerFleet) Queued() int {
+	return len(wf.jobCh)
+}
+
+// Failures returns the number of failed jobs.
+func (wf *WorkerFleet) Failures() int {
+	return int(wf.failed.Load())
+}
+
+// BytesReceived returns the number of bytes of response bodies received so far,
+// including those of downloads that are still in progress or have failed.
+func (wf *WorkerFleet) BytesReceived() int64 {
+	return wf.received.Load()
+}
+
+// Wait waits for the workers to finish, and stops progress logging.
 func (wf *WorkerFleet) Wait() {
 	wf.wg.Wait()
+	if wf.stopProgress != nil {
+		wf.stopProgressOnce.Do(func() {
+			close(wf.stopProgress)
+		})
+		wf.progressWg.Wait()
+	}
 }
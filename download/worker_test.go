@@ -0,0 +1,562 @@
+package download
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testLogger discards all logs.
+var testLogger = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+// testContent returns n bytes of deterministic test content.
+func testContent(n int) []byte {
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = byte(i*7 + i/251)
+	}
+	return b
+}
+
+// sha1Sum returns the SHA-1 sum of b.
+func sha1Sum(b []byte) []byte {
+	sum := sha1.Sum(b)
+	return sum[:]
+}
+
+// newTestJob returns a job for downloading content from url into a memory file,
+// with the expected size and SHA-1 sum of content.
+func newTestJob(url string, content []byte) Job {
+	return Job{
+		DownloadURL: url,
+		TargetFile:  NewMemoryFile("test.bin"),
+		Size:        int64(len(content)),
+		NewHash:     sha1.New,
+		Sum:         sha1Sum(content),
+	}
+}
+
+// runTestJob runs the job with the given options, and returns the number of bytes downloaded
+// and whether the job succeeded. Attempts at the same URL are retried without delay.
+func runTestJob(t *testing.T, j *Job, client *http.Client, opts ...Option) (int64, bool) {
+	t.Helper()
+	if client == nil {
+		client = http.DefaultClient
+	}
+	cfg := newConfig(client, opts)
+	cfg.backoff = noBackoff
+	return j.runWithConfig(context.Background(), testLogger, cfg)
+}
+
+// noBackoff retries immediately.
+func noBackoff(attempt int) time.Duration {
+	return 0
+}
+
+// targetBytes returns the content of the job's memory target file.
+func targetBytes(t *testing.T, j *Job) []byte {
+	t.Helper()
+	mf, ok := j.TargetFile.(*MemoryFile)
+	if !ok {
+		t.Fatalf("target file is %T, want *MemoryFile", j.TargetFile)
+	}
+	return mf.Bytes()
+}
+
+// requestLog records the requests received by a test server.
+type requestLog struct {
+	mu   sync.Mutex
+	reqs []*http.Request
+}
+
+func (l *requestLog) add(r *http.Request) {
+	l.mu.Lock()
+	l.reqs = append(l.reqs, r)
+	l.mu.Unlock()
+}
+
+func (l *requestLog) ranges() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	ranges := make([]string, len(l.reqs))
+	for i, r := range l.reqs {
+		ranges[i] = r.Header.Get("Range")
+	}
+	return ranges
+}
+
+// newContentServer returns a server that serves content with [http.ServeContent],
+// which supports range requests, and records the requests in log.
+func newContentServer(t *testing.T, content []byte, log *requestLog) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if log != nil {
+			log.add(r)
+		}
+		http.ServeContent(w, r, "test.bin", time.Time{}, bytes.NewReader(content))
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestJobDownload(t *testing.T) {
+	content := testContent(10000)
+	srv := newContentServer(t, content, nil)
+
+	j := newTestJob(srv.URL, content)
+	n, ok := runTestJob(t, &j, nil)
+	if !ok {
+		t.Fatalf("job failed: %v", j.lastErr)
+	}
+	if n != int64(len(content)) {
+		t.Errorf("n = %d, want %d", n, len(content))
+	}
+	if !bytes.Equal(targetBytes(t, &j), content) {
+		t.Error("downloaded content does not match")
+	}
+}
+
+func TestJobResumeWithRange(t *testing.T) {
+	content := testContent(10000)
+	var log requestLog
+	srv := newContentServer(t, content, &log)
+
+	const offset = 4000
+	j := newTestJob(srv.URL, content)
+	if _, err := j.TargetFile.Write(content[:offset]); err != nil {
+		t.Fatal(err)
+	}
+
+	n, ok := runTestJob(t, &j, nil)
+	if !ok {
+		t.Fatalf("job failed: %v", j.lastErr)
+	}
+	if n != int64(len(content)-offset) {
+		t.Errorf("n = %d, want %d", n, len(content)-offset)
+	}
+	if !bytes.Equal(targetBytes(t, &j), content) {
+		t.Error("resumed content does not match")
+	}
+	if got, want := log.ranges(), []string{"bytes=4000-"}; !slices.Equal(got, want) {
+		t.Errorf("Range headers = %q, want %q", got, want)
+	}
+}
+
+func TestJobResumeFallsBackToFullDownloadOnOK(t *testing.T) {
+	content := testContent(10000)
+	var log requestLog
+	// The server ignores the Range header and always responds with the full content.
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		log.add(r)
+		w.Write(content)
+	}))
+	defer srv.Close()
+
+	j := newTestJob(srv.URL, content)
+	if _, err := j.TargetFile.Write(content[:4000]); err != nil {
+		t.Fatal(err)
+	}
+
+	n, ok := runTestJob(t, &j, nil)
+	if !ok {
+		t.Fatalf("job failed: %v", j.lastErr)
+	}
+	if n != int64(len(content)) {
+		t.Errorf("n = %d, want %d", n, len(content))
+	}
+	if !bytes.Equal(targetBytes(t, &j), content) {
+		t.Error("content does not match after fallback to full download")
+	}
+	if got, want := log.ranges(), []string{"bytes=4000-"}; !slices.Equal(got, want) {
+		t.Errorf("Range headers = %q, want %q", got, want)
+	}
+}
+
+func TestJobResumeRefetchesOnRangeNotSatisfiable(t *testing.T) {
+	content := testContent(10000)
+	var log requestLog
+	// The server supports ranges, but rejects the offset, as when the existing content is stale.
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		log.add(r)
+		if r.Header.Get("Range") != "" {
+			w.WriteHeader(http.StatusRequestedRangeNotSatisfiable)
+			return
+		}
+		w.Write(content)
+	}))
+	defer srv.Close()
+
+	j := newTestJob(srv.URL, content)
+	if _, err := j.TargetFile.Write(content[:4000]); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := runTestJob(t, &j, nil); !ok {
+		t.Fatalf("job failed: %v", j.lastErr)
+	}
+	if !bytes.Equal(targetBytes(t, &j), content) {
+		t.Error("content does not match after refetch")
+	}
+	if got, want := log.ranges(), []string{"bytes=4000-", ""}; !slices.Equal(got, want) {
+		t.Errorf("Range headers = %q, want %q", got, want)
+	}
+}
+
+func TestJobDoesNotResumeFromOversizedContent(t *testing.T) {
+	content := testContent(1000)
+	var log requestLog
+	srv := newContentServer(t, content, &log)
+
+	j := newTestJob(srv.URL, content)
+	if _, err := j.TargetFile.Write(testContent(2000)); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := runTestJob(t, &j, nil); !ok {
+		t.Fatalf("job failed: %v", j.lastErr)
+	}
+	if !bytes.Equal(targetBytes(t, &j), content) {
+		t.Error("content does not match")
+	}
+	if got, want := log.ranges(), []string{""}; !slices.Equal(got, want) {
+		t.Errorf("Range headers = %q, want %q", got, want)
+	}
+}
+
+func TestContentRangeStart(t *testing.T) {
+	for _, c := range []struct {
+		header string
+		want   int64
+		ok     bool
+	}{
+		{"bytes 100-199/200", 100, true},
+		{"bytes 0-0/1", 0, true},
+		{"bytes */200", 0, false},
+		{"items 100-199/200", 0, false},
+		{"", 0, false},
+	} {
+		resp := &http.Response{Header: http.Header{"Content-Range": []string{c.header}}}
+		got, ok := contentRangeStart(resp)
+		if got != c.want || ok != c.ok {
+			t.Errorf("contentRangeStart(%q) = %d, %v, want %d, %v", c.header, got, ok, c.want, c.ok)
+		}
+	}
+}
+
+func TestJobFallsBackToMirror(t *testing.T) {
+	content := testContent(5000)
+	var missingLog, mirrorLog requestLog
+	missing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		missingLog.add(r)
+		http.NotFound(w, r)
+	}))
+	defer missing.Close()
+	mirror := newContentServer(t, content, &mirrorLog)
+
+	j := newTestJob(missing.URL+"/file.bin", content)
+	j.Mirrors = []string{mirror.URL + "/file.bin"}
+	if _, ok := runTestJob(t, &j, nil); !ok {
+		t.Fatalf("job failed: %v", j.lastErr)
+	}
+	if !bytes.Equal(targetBytes(t, &j), content) {
+		t.Error("content from mirror does not match")
+	}
+	if got := len(missingLog.ranges()); got != 1 {
+		t.Errorf("requests to primary URL = %d, want 1", got)
+	}
+	if got := len(mirrorLog.ranges()); got != 1 {
+		t.Errorf("requests to mirror = %d, want 1", got)
+	}
+}
+
+func TestJobFailsWhenAllMirrorsFail(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	defer srv.Close()
+
+	content := testContent(100)
+	j := newTestJob(srv.URL+"/a", content)
+	j.Mirrors = []string{srv.URL + "/b", srv.URL + "/c"}
+	if _, ok := runTestJob(t, &j, nil); ok {
+		t.Fatal("job succeeded, want failure")
+	}
+	var se *StatusError
+	if !errors.As(j.lastErr, &se) || se.StatusCode != http.StatusNotFound {
+		t.Errorf("lastErr = %v, want StatusError 404", j.lastErr)
+	}
+}
+
+func TestJobSkipsUnmodifiedFile(t *testing.T) {
+	serverModTime := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	existing := testContent(1000)
+	updated := bytes.Repeat([]byte{'x'}, len(existing))
+
+	for _, c := range []struct {
+		name            string
+		ifModifiedSince time.Time
+		want            []byte
+	}{
+		{"NotModified", serverModTime.Add(time.Hour), existing},
+		{"Modified", serverModTime.Add(-time.Hour), updated},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			var log requestLog
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				log.add(r)
+				http.ServeContent(w, r, "test.bin", serverModTime, bytes.NewReader(updated))
+			}))
+			defer srv.Close()
+
+			j := Job{
+				DownloadURL:     srv.URL,
+				TargetFile:      NewMemoryFile("test.bin"),
+				Size:            int64(len(existing)),
+				IfModifiedSince: c.ifModifiedSince,
+			}
+			if _, err := j.TargetFile.Write(existing); err != nil {
+				t.Fatal(err)
+			}
+			if _, ok := runTestJob(t, &j, nil); !ok {
+				t.Fatalf("job failed: %v", j.lastErr)
+			}
+			if !bytes.Equal(targetBytes(t, &j), c.want) {
+				t.Error("file content does not match")
+			}
+			if len(log.reqs) != 1 {
+				t.Fatalf("got %d requests, want 1", len(log.reqs))
+			}
+			if got, want := log.reqs[0].Header.Get("If-Modified-Since"), c.ifModifiedSince.Format(http.TimeFormat); got != want {
+				t.Errorf("If-Modified-Since = %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestWorkerFleetCountsFailures(t *testing.T) {
+	content := testContent(1000)
+	srv := newContentServer(t, content, nil)
+	failing := httptest.NewServer(http.NotFoundHandler())
+	defer failing.Close()
+
+	jobCh := make(chan Job)
+	wf := NewWorkerFleet(context.Background(), testLogger, http.DefaultClient, 2, jobCh)
+	jobCh <- newTestJob(srv.URL, content)
+	// The failing jobs have different content, so they cannot reuse the downloaded file.
+	jobCh <- newTestJob(failing.URL, testContent(1001))
+	jobCh <- newTestJob(failing.URL, testContent(1002))
+	close(jobCh)
+	wf.Wait()
+
+	if got := wf.Failures(); got != 2 {
+		t.Errorf("Failures() = %d, want 2", got)
+	}
+	if got := wf.Stats().Downloaded; got != 1 {
+		t.Errorf("Stats().Downloaded = %d, want 1", got)
+	}
+}
+
+func TestJobTimeoutAbortsStalledBody(t *testing.T) {
+	content := testContent(10000)
+	const stallAt = 4000
+	var log requestLog
+	release := make(chan struct{})
+	defer close(release)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		log.add(r)
+		if r.Header.Get("Range") != "" {
+			http.ServeContent(w, r, "test.bin", time.Time{}, bytes.NewReader(content))
+			return
+		}
+		// Send the headers and part of the body, then stall.
+		w.Header()["Content-Length"] = []string{"10000"}
+		_, _ = w.Write(content[:stallAt])
+		w.(http.Flusher).Flush()
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer srv.Close()
+
+	j := newTestJob(srv.URL, content)
+	start := time.Now()
+	if _, ok := runTestJob(t, &j, nil, WithTimeout(200*time.Millisecond), WithMaxAttempts(2)); !ok {
+		t.Fatalf("job failed: %v", j.lastErr)
+	}
+	if elapsed := time.Since(start); elapsed > 10*time.Second {
+		t.Errorf("download took %v with a timeout of 200ms", elapsed)
+	}
+	if !bytes.Equal(targetBytes(t, &j), content) {
+		t.Error("downloaded content does not match")
+	}
+	if got, want := log.ranges(), []string{"", "bytes=4000-"}; !slices.Equal(got, want) {
+		t.Errorf("Range headers = %q, want %q", got, want)
+	}
+}
+
+func TestJobTimeoutFailsStalledDownload(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header()["Content-Length"] = []string{"10"}
+		w.WriteHeader(http.StatusOK)
+		w.(http.Flusher).Flush()
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer srv.Close()
+
+	j := newTestJob(srv.URL, make([]byte, 10))
+	start := time.Now()
+	if _, ok := runTestJob(t, &j, nil, WithTimeout(100*time.Millisecond)); ok {
+		t.Fatal("job succeeded, want failure")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("download took %v with a timeout of 100ms", elapsed)
+	}
+	if !errors.Is(j.lastErr, context.DeadlineExceeded) {
+		t.Errorf("lastErr = %v, want %v", j.lastErr, context.DeadlineExceeded)
+	}
+}
+
+func TestJobFallsBackToAlternateURLOnForbidden(t *testing.T) {
+	content := testContent(5000)
+	var log requestLog
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		log.add(r)
+		switch r.URL.Path {
+		case "/files/1234/5678901/a.jar":
+			http.Error(w, "forbidden", http.StatusForbidden)
+		case "/files/5678/901/a.jar":
+			http.ServeContent(w, r, "a.jar", time.Time{}, bytes.NewReader(content))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	j := newTestJob(srv.URL+"/files/1234/5678901/a.jar", content)
+	j.Mirrors = []string{srv.URL + "/files/5678/901/a.jar", srv.URL + "/files/unused/a.jar"}
+	if _, ok := runTestJob(t, &j, nil, WithMaxAttempts(3)); !ok {
+		t.Fatalf("job failed: %v", j.lastErr)
+	}
+	if !bytes.Equal(targetBytes(t, &j), content) {
+		t.Error("content from alternate URL does not match")
+	}
+
+	var paths []string
+	for _, r := range log.reqs {
+		paths = append(paths, r.URL.Path)
+	}
+	// 403 is not retried against the same URL.
+	if want := []string{"/files/1234/5678901/a.jar", "/files/5678/901/a.jar"}; !slices.Equal(paths, want) {
+		t.Errorf("requested paths = %q, want %q", paths, want)
+	}
+}
+
+func TestJobRetriesFullDownloadAfterCorruptResume(t *testing.T) {
+	content := testContent(10000)
+	const offset = 4000
+	corruptPrefix := bytes.Repeat([]byte{0xff}, offset)
+
+	for _, c := range []struct {
+		name        string
+		fullRetries int
+		wantOK      bool
+		wantRanges  []string
+	}{
+		{"Retry", 1, true, []string{"bytes=4000-", ""}},
+		{"NoRetry", 0, false, []string{"bytes=4000-"}},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			var log requestLog
+			srv := newContentServer(t, content, &log)
+
+			j := newTestJob(srv.URL, content)
+			if _, err := j.TargetFile.Write(corruptPrefix); err != nil {
+				t.Fatal(err)
+			}
+			_, ok := runTestJob(t, &j, nil, WithFullRetries(c.fullRetries), WithMaxAttempts(1))
+			if ok != c.wantOK {
+				t.Fatalf("job ok = %v, want %v (lastErr: %v)", ok, c.wantOK, j.lastErr)
+			}
+			if got := log.ranges(); !slices.Equal(got, c.wantRanges) {
+				t.Errorf("Range headers = %q, want %q", got, c.wantRanges)
+			}
+			if ok {
+				if !bytes.Equal(targetBytes(t, &j), content) {
+					t.Error("downloaded content does not match")
+				}
+			} else if got := targetBytes(t, &j); len(got) != 0 {
+				t.Errorf("corrupt content of %d bytes kept, want the file truncated", len(got))
+			}
+		})
+	}
+}
+
+func TestJobOnSuccess(t *testing.T) {
+	content := testContent(1000)
+	srv := newContentServer(t, content, nil)
+	dir := t.TempDir()
+
+	for _, c := range []struct {
+		name    string
+		content []byte
+		want    bool
+	}{
+		{"Verified", content, true},
+		{"Mismatch", testContent(999), false},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			path := filepath.Join(dir, c.name+".bin")
+			f, err := os.Create(path + ".part")
+			if err != nil {
+				t.Fatal(err)
+			}
+			j := newTestJob(srv.URL, c.content)
+			j.TargetFile = f
+			j.RenameTo = path
+
+			var (
+				calls     int
+				renamed   bool
+				closed    bool
+				closedNow bool
+			)
+			j.OnClose = func() { closed = true }
+			j.OnSuccess = func() {
+				calls++
+				closedNow = closed
+				_, err := os.Stat(path)
+				renamed = err == nil
+			}
+			if _, ok := runTestJob(t, &j, nil); ok != c.want {
+				t.Fatalf("job succeeded: %t, want %t", ok, c.want)
+			}
+			if !c.want {
+				if calls != 0 {
+					t.Errorf("OnSuccess called %d times for a failed job", calls)
+				}
+				return
+			}
+			if calls != 1 {
+				t.Fatalf("OnSuccess called %d times, want once", calls)
+			}
+			if !closedNow || !renamed {
+				t.Errorf("OnSuccess called with files closed: %t, renamed into place: %t, want both", closedNow, renamed)
+			}
+		})
+	}
+}
@@ -2,4 +2,8 @@ module github.com/database64128/modpack-dl-go
 
 go 1.23.0
 
-require github.com/lmittmann/tint v1.0.6
+require (
+	github.com/lmittmann/tint v1.0.6
+	golang.org/x/sys v0.35.0
+	golang.org/x/time v0.12.0
+)
@@ -0,0 +1,172 @@
+// Package minisign verifies minisign signatures.
+//
+// Format documentation: https://jedisct1.github.io/minisign/
+package minisign
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+var (
+	// ErrInvalidSignature is returned when a signature does not match the content or its trusted comment.
+	ErrInvalidSignature = errors.New("invalid signature")
+
+	// ErrKeyIDMismatch is returned when a signature was made with a different key than the one it's verified with.
+	ErrKeyIDMismatch = errors.New("signature was made with a different key")
+
+	// ErrUnsupportedAlgorithm is returned when a key or signature uses an unknown algorithm.
+	ErrUnsupportedAlgorithm = errors.New("unsupported signature algorithm")
+)
+
+var (
+	// algEd25519 signs the content itself. This is the legacy format.
+	algEd25519 = [2]byte{'E', 'd'}
+
+	// algEd25519Prehashed signs the BLAKE2b-512 hash of the content.
+	algEd25519Prehashed = [2]byte{'E', 'D'}
+)
+
+const (
+	untrustedCommentPrefix = "untrusted comment: "
+	trustedCommentPrefix   = "trusted comment: "
+)
+
+// PublicKey is a minisign public key.
+type PublicKey struct {
+	// KeyID identifies the key pair in signatures made with it.
+	KeyID [8]byte
+
+	// Key is the Ed25519 public key.
+	Key ed25519.PublicKey
+}
+
+// ParsePublicKey parses a public key, either as the base64 string printed by 'minisign -G',
+// or as the contents of a public key file, which has an untrusted comment line before the key.
+func ParsePublicKey(s string) (PublicKey, error) {
+	s = strings.TrimSpace(s)
+	if comment, key, ok := strings.Cut(s, "\n"); ok {
+		if !strings.HasPrefix(comment, untrustedCommentPrefix) {
+			return PublicKey{}, errors.New("public key file does not start with an untrusted comment")
+		}
+		s = strings.TrimSpace(key)
+	}
+
+	b, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return PublicKey{}, fmt.Errorf("failed to decode public key: %w", err)
+	}
+	if len(b) != 2+8+ed25519.PublicKeySize {
+		return PublicKey{}, fmt.Errorf("invalid public key length: %d", len(b))
+	}
+	if [2]byte(b[:2]) != algEd25519 {
+		return PublicKey{}, fmt.Errorf("%w: %q", ErrUnsupportedAlgorithm, b[:2])
+	}
+
+	return PublicKey{
+		KeyID: [8]byte(b[2:10]),
+		Key:   ed25519.PublicKey(b[10:]),
+	}, nil
+}
+
+// Signature is a parsed minisign signature file.
+type Signature struct {
+	// Algorithm is "Ed" for signatures over the content, or "ED" for signatures over its BLAKE2b-512 hash.
+	Algorithm [2]byte
+
+	// KeyID identifies the key pair the signature was made with.
+	KeyID [8]byte
+
+	// Signature is the Ed25519 signature of the content or its hash.
+	Signature [ed25519.SignatureSize]byte
+
+	// TrustedComment is the comment covered by GlobalSignature.
+	TrustedComment string
+
+	// GlobalSignature is the Ed25519 signature of Signature followed by TrustedComment.
+	GlobalSignature [ed25519.SignatureSize]byte
+}
+
+// ParseSignature parses the contents of a minisign signature file.
+func ParseSignature(b []byte) (Signature, error) {
+	lines := strings.Split(strings.TrimSpace(string(bytes.ReplaceAll(b, []byte("\r\n"), []byte("\n")))), "\n")
+	if len(lines) != 4 {
+		return Signature{}, fmt.Errorf("signature file has %d lines, expected 4", len(lines))
+	}
+	if !strings.HasPrefix(lines[0], untrustedCommentPrefix) {
+		return Signature{}, errors.New("signature file does not start with an untrusted comment")
+	}
+	trustedComment, ok := strings.CutPrefix(lines[2], trustedCommentPrefix)
+	if !ok {
+		return Signature{}, errors.New("signature file is missing the trusted comment")
+	}
+
+	sb, err := base64.StdEncoding.DecodeString(lines[1])
+	if err != nil {
+		return Signature{}, fmt.Errorf("failed to decode signature: %w", err)
+	}
+	if len(sb) != 2+8+ed25519.SignatureSize {
+		return Signature{}, fmt.Errorf("invalid signature length: %d", len(sb))
+	}
+
+	gb, err := base64.StdEncoding.DecodeString(lines[3])
+	if err != nil {
+		return Signature{}, fmt.Errorf("failed to decode global signature: %w", err)
+	}
+	if len(gb) != ed25519.SignatureSize {
+		return Signature{}, fmt.Errorf("invalid global signature length: %d", len(gb))
+	}
+
+	sig := Signature{
+		Algorithm:       [2]byte(sb[:2]),
+		KeyID:           [8]byte(sb[2:10]),
+		Signature:       [ed25519.SignatureSize]byte(sb[10:]),
+		TrustedComment:  trustedComment,
+		GlobalSignature: [ed25519.SignatureSize]byte(gb),
+	}
+	if sig.Algorithm != algEd25519 && sig.Algorithm != algEd25519Prehashed {
+		return Signature{}, fmt.Errorf("%w: %q", ErrUnsupportedAlgorithm, sig.Algorithm[:])
+	}
+	return sig, nil
+}
+
+// Verify reads the content from r, and returns nil if sig is a valid signature of it made with the key.
+func (pk PublicKey) Verify(r io.Reader, sig *Signature) error {
+	if sig.KeyID != pk.KeyID {
+		return ErrKeyIDMismatch
+	}
+
+	var msg []byte
+	switch sig.Algorithm {
+	case algEd25519Prehashed:
+		h := newBlake2b512()
+		if _, err := io.Copy(h, r); err != nil {
+			return err
+		}
+		msg = h.Sum(nil)
+	case algEd25519:
+		var err error
+		if msg, err = io.ReadAll(r); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("%w: %q", ErrUnsupportedAlgorithm, sig.Algorithm[:])
+	}
+
+	if !ed25519.Verify(pk.Key, msg, sig.Signature[:]) {
+		return ErrInvalidSignature
+	}
+
+	global := make([]byte, 0, len(sig.Signature)+len(sig.TrustedComment))
+	global = append(global, sig.Signature[:]...)
+	global = append(global, sig.TrustedComment...)
+	if !ed25519.Verify(pk.Key, global, sig.GlobalSignature[:]) {
+		return fmt.Errorf("%w: trusted comment has been tampered with", ErrInvalidSignature)
+	}
+	return nil
+}
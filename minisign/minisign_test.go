@@ -0,0 +1,211 @@
+package minisign
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"testing"
+)
+
+var testKeyID = [8]byte{1, 2, 3, 4, 5, 6, 7, 8}
+
+// newTestKey returns a new key pair, with the public key as printed by 'minisign -G'.
+func newTestKey(t *testing.T) (string, ed25519.PrivateKey) {
+	t.Helper()
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := append(append(algEd25519[:], testKeyID[:]...), pub...)
+	return base64.StdEncoding.EncodeToString(b), priv
+}
+
+// signTest returns a signature file of content made with priv,
+// in the format written by 'minisign -S'.
+func signTest(priv ed25519.PrivateKey, alg [2]byte, content []byte, trustedComment string) []byte {
+	msg := content
+	if alg == algEd25519Prehashed {
+		h := newBlake2b512()
+		h.Write(content)
+		msg = h.Sum(nil)
+	}
+	sig := ed25519.Sign(priv, msg)
+	global := ed25519.Sign(priv, append(append([]byte{}, sig...), trustedComment...))
+
+	var b bytes.Buffer
+	b.WriteString(untrustedCommentPrefix + "signature from minisign secret key\n")
+	b.WriteString(base64.StdEncoding.EncodeToString(append(append(alg[:], testKeyID[:]...), sig...)) + "\n")
+	b.WriteString(trustedCommentPrefix + trustedComment + "\n")
+	b.WriteString(base64.StdEncoding.EncodeToString(global) + "\n")
+	return b.Bytes()
+}
+
+func TestVerify(t *testing.T) {
+	content := []byte("mod content\n")
+	key, priv := newTestKey(t)
+	pk, err := ParsePublicKey(key)
+	if err != nil {
+		t.Fatalf("ParsePublicKey() error = %v", err)
+	}
+
+	for _, alg := range [][2]byte{algEd25519, algEd25519Prehashed} {
+		t.Run(string(alg[:]), func(t *testing.T) {
+			sig, err := ParseSignature(signTest(priv, alg, content, "timestamp:1700000000\tfile:a.jar"))
+			if err != nil {
+				t.Fatalf("ParseSignature() error = %v", err)
+			}
+			if sig.Algorithm != alg || sig.KeyID != testKeyID {
+				t.Errorf("Algorithm, KeyID = %q, %v, want %q, %v", sig.Algorithm[:], sig.KeyID, alg[:], testKeyID)
+			}
+
+			if err = pk.Verify(bytes.NewReader(content), &sig); err != nil {
+				t.Errorf("Verify() of signed content error = %v", err)
+			}
+
+			tampered := bytes.ToUpper(content)
+			if err = pk.Verify(bytes.NewReader(tampered), &sig); !errors.Is(err, ErrInvalidSignature) {
+				t.Errorf("Verify() of tampered content error = %v, want %v", err, ErrInvalidSignature)
+			}
+
+			tamperedComment := sig
+			tamperedComment.TrustedComment += " (edited)"
+			if err = pk.Verify(bytes.NewReader(content), &tamperedComment); !errors.Is(err, ErrInvalidSignature) {
+				t.Errorf("Verify() with tampered trusted comment error = %v, want %v", err, ErrInvalidSignature)
+			}
+
+			otherKey := sig
+			otherKey.KeyID[0]++
+			if err = pk.Verify(bytes.NewReader(content), &otherKey); !errors.Is(err, ErrKeyIDMismatch) {
+				t.Errorf("Verify() of signature by another key error = %v, want %v", err, ErrKeyIDMismatch)
+			}
+		})
+	}
+}
+
+func TestVerifyRejectsSignatureByAnotherKeyWithSameID(t *testing.T) {
+	content := []byte("mod content\n")
+	key, _ := newTestKey(t)
+	_, otherPriv := newTestKey(t)
+	pk, err := ParsePublicKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig, err := ParseSignature(signTest(otherPriv, algEd25519Prehashed, content, "trusted"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = pk.Verify(bytes.NewReader(content), &sig); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("Verify() error = %v, want %v", err, ErrInvalidSignature)
+	}
+}
+
+func TestParsePublicKey(t *testing.T) {
+	key, _ := newTestKey(t)
+	want, err := ParsePublicKey(key)
+	if err != nil {
+		t.Fatalf("ParsePublicKey() error = %v", err)
+	}
+	if want.KeyID != testKeyID {
+		t.Errorf("KeyID = %v, want %v", want.KeyID, testKeyID)
+	}
+
+	got, err := ParsePublicKey(untrustedCommentPrefix + "minisign public key\r\n" + key + "\n")
+	if err != nil {
+		t.Fatalf("ParsePublicKey() of key file error = %v", err)
+	}
+	if got.KeyID != want.KeyID || !got.Key.Equal(want.Key) {
+		t.Errorf("ParsePublicKey() of key file = %+v, want %+v", got, want)
+	}
+
+	b, _ := base64.StdEncoding.DecodeString(key)
+	for _, c := range []struct {
+		name    string
+		key     string
+		wantErr error
+	}{
+		{"NotBase64", "not base64!", nil},
+		{"Short", base64.StdEncoding.EncodeToString(b[:20]), nil},
+		{"NoComment", "a comment\n" + key, nil},
+		{"Algorithm", base64.StdEncoding.EncodeToString(append([]byte("XX"), b[2:]...)), ErrUnsupportedAlgorithm},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			_, err := ParsePublicKey(c.key)
+			if err == nil {
+				t.Fatal("ParsePublicKey() error = nil")
+			}
+			if c.wantErr != nil && !errors.Is(err, c.wantErr) {
+				t.Errorf("ParsePublicKey() error = %v, want %v", err, c.wantErr)
+			}
+		})
+	}
+}
+
+func TestParseSignatureErrors(t *testing.T) {
+	_, priv := newTestKey(t)
+	valid := string(signTest(priv, algEd25519Prehashed, []byte("content"), "trusted"))
+	lines := strings.Split(strings.TrimSpace(valid), "\n")
+
+	for _, c := range []struct {
+		name string
+		sig  string
+	}{
+		{"Empty", ""},
+		{"MissingLine", strings.Join(lines[:3], "\n")},
+		{"NoUntrustedComment", strings.Join(append([]string{"comment"}, lines[1:]...), "\n")},
+		{"NoTrustedComment", strings.Join([]string{lines[0], lines[1], "comment", lines[3]}, "\n")},
+		{"SignatureNotBase64", strings.Join([]string{lines[0], "!", lines[2], lines[3]}, "\n")},
+		{"ShortSignature", strings.Join([]string{lines[0], lines[1][:20], lines[2], lines[3]}, "\n")},
+		{"ShortGlobalSignature", strings.Join([]string{lines[0], lines[1], lines[2], lines[3][:20]}, "\n")},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			if _, err := ParseSignature([]byte(c.sig)); err == nil {
+				t.Error("ParseSignature() error = nil")
+			}
+		})
+	}
+
+	if _, err := ParseSignature([]byte(strings.ReplaceAll(valid, "\n", "\r\n"))); err != nil {
+		t.Errorf("ParseSignature() with CRLF line endings error = %v", err)
+	}
+}
+
+func TestBlake2b512(t *testing.T) {
+	for _, c := range []struct {
+		name string
+		in   []byte
+		want string
+	}{
+		// Test vectors from RFC 7693, and the reference implementation.
+		{"Empty", nil, "786a02f742015903c6c6fd852552d272912f4740e15847618a86e217f71f5419d25e1031afee585313896444934eb04b903a685b1448b755d56f701afe9be2ce"},
+		{"abc", []byte("abc"), "ba80a53f981c4d0d6a2797b69f12f6e94c212f14685ac4b74b12bb6fdbffa2d17d87c5392aab792dc252d5de4533cc9518d38aa8dbf1925ab92386edd4009923"},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			h := newBlake2b512()
+			h.Write(c.in)
+			if got := hex.EncodeToString(h.Sum(nil)); got != c.want {
+				t.Errorf("BLAKE2b-512(%q) = %s, want %s", c.in, got, c.want)
+			}
+		})
+	}
+}
+
+func TestBlake2b512BlockBoundaries(t *testing.T) {
+	// Writing in pieces gives the same sum as writing at once, including at block boundaries.
+	for _, n := range []int{blake2bBlockSize - 1, blake2bBlockSize, blake2bBlockSize + 1, 3 * blake2bBlockSize} {
+		in := bytes.Repeat([]byte{0xa5}, n)
+		h := newBlake2b512()
+		h.Write(in)
+		want := h.Sum(nil)
+
+		h.Reset()
+		for i := range in {
+			h.Write(in[i : i+1])
+		}
+		if got := h.Sum(nil); !bytes.Equal(got, want) {
+			t.Errorf("sum of %d bytes written one at a time = %x, want %x", n, got, want)
+		}
+	}
+}
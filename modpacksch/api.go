@@ -4,17 +4,24 @@
 package modpacksch
 
 import (
+	"bytes"
 	"context"
 	"crypto/sha1"
+	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"hash"
+	"io"
 	"net/http"
 	"net/url"
+	"path"
 	"path/filepath"
+	"runtime"
 	"slices"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/database64128/modpack-dl-go/precheck"
@@ -39,6 +46,16 @@ const (
 var (
 	ErrPathSanitization = errors.New("path rejected by sanitization")
 	ErrMissingURL       = errors.New("missing URL")
+	ErrFileTooLarge     = errors.New("file exceeds maximum size")
+
+	// ErrResponseTooLarge is returned when an API response body exceeds the client's maximum response size.
+	ErrResponseTooLarge = errors.New("API response exceeds maximum size")
+
+	// ErrAuthRequired is returned when the API rejects a request made without an auth token.
+	ErrAuthRequired = errors.New("authentication required, the modpack may be private")
+
+	// ErrVersionNotFound is returned when a version ID is not one of the modpack's versions.
+	ErrVersionNotFound = errors.New("version not found in modpack manifest")
 )
 
 // ModpackClient is a modpack client for the modpacks.ch API.
@@ -48,66 +65,224 @@ type ModpackClient interface {
 
 	// GetModpackVersionManifest gets the manifest of a modpack version with the given modpack ID and version ID.
 	GetModpackVersionManifest(ctx context.Context, modpackID, versionID int64) (ModpackVersionManifest, error)
+
+	// SearchModpacks searches for modpacks matching the given term,
+	// and returns the IDs of up to limit matching modpacks.
+	SearchModpacks(ctx context.Context, term string, limit int) ([]int64, error)
+
+	// CheckForUpdate gets the manifest of a modpack with the given ID,
+	// and returns the versions newer than the given current version, newest first.
+	// No versions are returned if the current version is the latest.
+	CheckForUpdate(ctx context.Context, modpackID, currentVersionID int64) ([]ModpackVersion, error)
+}
+
+// apiClient is the common implementation of the modpack clients.
+type apiClient struct {
+	client      *http.Client
+	baseURL     string
+	userAgent   string
+	retryPolicy RetryPolicy
+	authToken   string
+	cache       *Cache
+
+	// maxResponseSize is the maximum size of a response body in bytes. Zero means no limit.
+	maxResponseSize int64
+}
+
+// DefaultMaxResponseSize is the default maximum size of an API response body in bytes.
+const DefaultMaxResponseSize = 32 << 20
+
+// newAPIClient returns a new [apiClient] with the given options applied.
+func newAPIClient(opts []ClientOption) apiClient {
+	c := apiClient{
+		client:          http.DefaultClient,
+		baseURL:         APIBaseURL,
+		userAgent:       APIUserAgent,
+		maxResponseSize: DefaultMaxResponseSize,
+	}
+	for _, opt := range opts {
+		opt(&c)
+	}
+	return c
+}
+
+// ClientOption configures a modpack client.
+type ClientOption func(*apiClient)
+
+// WithHTTPClient sets the HTTP client used to send API requests.
+// The default is [http.DefaultClient].
+func WithHTTPClient(client *http.Client) ClientOption {
+	return func(c *apiClient) {
+		if client != nil {
+			c.client = client
+		}
+	}
+}
+
+// WithUserAgent sets the user agent sent with API requests.
+// An empty user agent keeps the default [APIUserAgent].
+func WithUserAgent(userAgent string) ClientOption {
+	return func(c *apiClient) {
+		if userAgent != "" {
+			c.userAgent = userAgent
+		}
+	}
+}
+
+// WithRetryPolicy sets the retry policy for API requests.
+func WithRetryPolicy(policy RetryPolicy) ClientOption {
+	return func(c *apiClient) {
+		c.retryPolicy = policy
+	}
+}
+
+// WithBaseURL sets the base URL of the API, for use with proxies or self-hosted mirrors.
+// An empty base URL keeps the default [APIBaseURL].
+func WithBaseURL(baseURL string) ClientOption {
+	return func(c *apiClient) {
+		if baseURL != "" {
+			c.baseURL = baseURL
+		}
+	}
+}
+
+// WithAuthToken sets the token used to authenticate API requests, which is required for private modpacks.
+// The token is sent as a bearer token in the Authorization header.
+func WithAuthToken(token string) ClientOption {
+	return func(c *apiClient) {
+		c.authToken = token
+	}
+}
+
+// WithCache sets the on-disk cache for API responses.
+func WithCache(cache *Cache) ClientOption {
+	return func(c *apiClient) {
+		c.cache = cache
+	}
+}
+
+// WithMaxResponseSize sets the maximum size of an API response body in bytes.
+// Responses that exceed it fail with [ErrResponseTooLarge], instead of being read into memory in full.
+// The default is [DefaultMaxResponseSize]. Non-positive values mean no limit.
+func WithMaxResponseSize(n int64) ClientOption {
+	return func(c *apiClient) {
+		c.maxResponseSize = max(n, 0)
+	}
 }
 
 // PublicModpackClient is a modpack client for the modpacks.ch public modpack API.
 //
 // PublicModpackClient implements [ModpackClient].
 type PublicModpackClient struct {
-	client *http.Client
+	apiClient
 }
 
-// NewPublicModpackClient creates a new [PublicModpackClient].
-func NewPublicModpackClient(client *http.Client) *PublicModpackClient {
-	return &PublicModpackClient{client: client}
+// NewPublicModpackClient creates a new [PublicModpackClient] with the given options.
+func NewPublicModpackClient(opts ...ClientOption) *PublicModpackClient {
+	return &PublicModpackClient{apiClient: newAPIClient(opts)}
 }
 
 // GetModpackManifest gets the manifest of a public modpack with the given ID.
 //
 // GetModpackManifest implements [ModpackClient.GetModpackManifest].
 func (c *PublicModpackClient) GetModpackManifest(ctx context.Context, modpackID int64) (ModpackManifest, error) {
-	return doGetRequest[ModpackManifest](ctx, c.client, fmt.Sprintf(APIBaseURL+APIPublicModpack+"/%d", modpackID))
+	return doGetEndpoint[ModpackManifest](ctx, &c.apiClient, "", APIPublicModpack, strconv.FormatInt(modpackID, 10))
 }
 
 // GetModpackVersionManifest gets the manifest of a public modpack version with the given modpack ID and version ID.
 //
 // GetModpackVersionManifest implements [ModpackClient.GetModpackVersionManifest].
 func (c *PublicModpackClient) GetModpackVersionManifest(ctx context.Context, modpackID, versionID int64) (ModpackVersionManifest, error) {
-	return doGetRequest[ModpackVersionManifest](ctx, c.client, fmt.Sprintf(APIBaseURL+APIPublicModpack+"/%d/%d", modpackID, versionID))
+	return doGetEndpoint[ModpackVersionManifest](ctx, &c.apiClient, "", APIPublicModpack, strconv.FormatInt(modpackID, 10), strconv.FormatInt(versionID, 10))
+}
+
+// SearchModpacks searches for public modpacks matching the given term.
+//
+// SearchModpacks implements [ModpackClient.SearchModpacks].
+func (c *PublicModpackClient) SearchModpacks(ctx context.Context, term string, limit int) ([]int64, error) {
+	result, err := searchModpacks(ctx, &c.apiClient, term, limit)
+	if err != nil {
+		return nil, err
+	}
+	return result.Packs, nil
+}
+
+// CheckForUpdate returns the versions of a public modpack newer than the given current version.
+//
+// CheckForUpdate implements [ModpackClient.CheckForUpdate].
+func (c *PublicModpackClient) CheckForUpdate(ctx context.Context, modpackID, currentVersionID int64) ([]ModpackVersion, error) {
+	return checkForUpdate(ctx, c, modpackID, currentVersionID)
 }
 
 // CurseForgeModpackClient is a modpack client for the modpacks.ch CurseForge modpack API.
 //
 // CurseForgeModpackClient implements [ModpackClient].
 type CurseForgeModpackClient struct {
-	client *http.Client
+	apiClient
 }
 
-// NewCurseForgeModpackClient creates a new [CurseForgeModpackClient].
-func NewCurseForgeModpackClient(client *http.Client) *CurseForgeModpackClient {
-	return &CurseForgeModpackClient{client: client}
+// NewCurseForgeModpackClient creates a new [CurseForgeModpackClient] with the given options.
+func NewCurseForgeModpackClient(opts ...ClientOption) *CurseForgeModpackClient {
+	return &CurseForgeModpackClient{apiClient: newAPIClient(opts)}
 }
 
 // GetModpackManifest gets the manifest of a CurseForge modpack with the given ID.
 //
 // GetModpackManifest implements [ModpackClient.GetModpackManifest].
 func (c *CurseForgeModpackClient) GetModpackManifest(ctx context.Context, modpackID int64) (ModpackManifest, error) {
-	return doGetRequest[ModpackManifest](ctx, c.client, fmt.Sprintf(APIBaseURL+APIPublicCurseForge+"/%d", modpackID))
+	return doGetEndpoint[ModpackManifest](ctx, &c.apiClient, "", APIPublicCurseForge, strconv.FormatInt(modpackID, 10))
 }
 
 // GetModpackVersionManifest gets the manifest of a CurseForge modpack version with the given modpack ID and version ID.
 //
 // GetModpackVersionManifest implements [ModpackClient.GetModpackVersionManifest].
 func (c *CurseForgeModpackClient) GetModpackVersionManifest(ctx context.Context, modpackID, versionID int64) (ModpackVersionManifest, error) {
-	return doGetRequest[ModpackVersionManifest](ctx, c.client, fmt.Sprintf(APIBaseURL+APIPublicCurseForge+"/%d/%d", modpackID, versionID))
+	return doGetEndpoint[ModpackVersionManifest](ctx, &c.apiClient, "", APIPublicCurseForge, strconv.FormatInt(modpackID, 10), strconv.FormatInt(versionID, 10))
+}
+
+// SearchModpacks searches for CurseForge modpacks matching the given term.
+//
+// SearchModpacks implements [ModpackClient.SearchModpacks].
+func (c *CurseForgeModpackClient) SearchModpacks(ctx context.Context, term string, limit int) ([]int64, error) {
+	result, err := searchModpacks(ctx, &c.apiClient, term, limit)
+	if err != nil {
+		return nil, err
+	}
+	return result.CurseForge, nil
+}
+
+// CheckForUpdate returns the versions of a CurseForge modpack newer than the given current version.
+//
+// CheckForUpdate implements [ModpackClient.CheckForUpdate].
+func (c *CurseForgeModpackClient) CheckForUpdate(ctx context.Context, modpackID, currentVersionID int64) ([]ModpackVersion, error) {
+	return checkForUpdate(ctx, c, modpackID, currentVersionID)
+}
+
+// checkForUpdate gets the manifest of a modpack with the given ID using the given client,
+// and returns the versions newer than the given current version.
+func checkForUpdate(ctx context.Context, c ModpackClient, modpackID, currentVersionID int64) ([]ModpackVersion, error) {
+	m, err := c.GetModpackManifest(ctx, modpackID)
+	if err != nil {
+		return nil, err
+	}
+	newer, ok := m.VersionsNewerThan(currentVersionID)
+	if !ok {
+		return nil, fmt.Errorf("%w: modpack %d has no version %d", ErrVersionNotFound, modpackID, currentVersionID)
+	}
+	return newer, nil
+}
+
+// searchModpacks searches for modpacks matching the given term.
+func searchModpacks(ctx context.Context, c *apiClient, term string, limit int) (SearchResult, error) {
+	return doGetEndpoint[SearchResult](ctx, c, "term="+url.QueryEscape(term), APIPublicModpack, "search", strconv.Itoa(limit))
 }
 
 var (
 	// DefaultPublicModpackClient is the default public modpack client.
-	DefaultPublicModpackClient = NewPublicModpackClient(http.DefaultClient)
+	DefaultPublicModpackClient = NewPublicModpackClient()
 
 	// DefaultCurseForgeModpackClient is the default CurseForge modpack client.
-	DefaultCurseForgeModpackClient = NewCurseForgeModpackClient(http.DefaultClient)
+	DefaultCurseForgeModpackClient = NewCurseForgeModpackClient()
 )
 
 // GetPublicModpackManifest gets the manifest of a public modpack with the given ID.
@@ -130,28 +305,172 @@ func GetCurseForgeModpackVersionManifest(ctx context.Context, modpackID, version
 	return DefaultCurseForgeModpackClient.GetModpackVersionManifest(ctx, modpackID, versionID)
 }
 
+// doGetEndpoint sends a GET request to the API endpoint at the given path elements
+// joined to the client's base URL, with the given raw query, and returns the response
+// unmarshaled from JSON.
+func doGetEndpoint[V any](ctx context.Context, c *apiClient, rawQuery string, elem ...string) (V, error) {
+	u, err := url.JoinPath(c.baseURL, elem...)
+	if err != nil {
+		var zero V
+		return zero, err
+	}
+	if rawQuery != "" {
+		u += "?" + rawQuery
+	}
+	return doGetRequest[V](ctx, c, u)
+}
+
 // doGetRequest sends a GET request to the given URL and returns the response unmarshaled from JSON.
-func doGetRequest[V any](ctx context.Context, client *http.Client, url string) (v V, err error) {
+// Failed requests are retried according to the client's retry policy.
+//
+// If the client has a cache, a fresh cached response is returned without sending the request,
+// and a stale one is revalidated with a conditional request.
+func doGetRequest[V any](ctx context.Context, c *apiClient, url string) (v V, err error) {
+	var cached *cacheEntry
+	if c.cache != nil {
+		var ok bool
+		if cached, ok = c.cache.load(url); ok && c.cache.isFresh(cached) {
+			if err = json.Unmarshal(cached.Body, &v); err == nil {
+				return v, nil
+			}
+			cached = nil
+		}
+	}
+
+	for attempt := 1; ; attempt++ {
+		var (
+			retryable  bool
+			retryAfter time.Duration
+		)
+		v, retryable, retryAfter, err = doGetRequestOnce[V](ctx, c, url, cached)
+		if err == nil || !retryable || attempt >= c.retryPolicy.MaxAttempts {
+			return v, err
+		}
+
+		if err := c.retryPolicy.wait(ctx, attempt, retryAfter); err != nil {
+			return v, err
+		}
+	}
+}
+
+// doGetRequestOnce is like doGetRequest, but does not retry.
+// On failure, it also returns whether the request may be retried,
+// and the delay requested by the server, if any.
+//
+// If cached is not nil, the request is made conditional on the cached response being stale.
+func doGetRequestOnce[V any](ctx context.Context, c *apiClient, url string, cached *cacheEntry) (v V, retryable bool, retryAfter time.Duration, err error) {
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
-		return v, fmt.Errorf("failed to create request: %w", err)
+		return v, false, 0, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header["User-Agent"] = []string{c.userAgent}
+	req.Header["Accept"] = []string{"application/json"}
+	setAcceptGzip(req)
+	if c.authToken != "" {
+		req.Header["Authorization"] = []string{"Bearer " + c.authToken}
+	}
+	if cached != nil {
+		cached.setConditionalHeaders(req)
 	}
-	req.Header["User-Agent"] = []string{APIUserAgent}
 
-	resp, err := client.Do(req)
+	resp, err := c.client.Do(req)
 	if err != nil {
-		return v, fmt.Errorf("failed to send request: %w", err)
+		return v, ctx.Err() == nil, 0, fmt.Errorf("failed to send request: %w", err)
 	}
+	decodeGzipBody(resp)
 	defer resp.Body.Close()
 
+	if resp.StatusCode == http.StatusNotModified && cached != nil {
+		if err = json.Unmarshal(cached.Body, &v); err != nil {
+			return v, false, 0, fmt.Errorf("failed to decode cached response: %w", err)
+		}
+		cached.Fetched = Time{time.Now()}
+		_ = c.cache.store(cached)
+		return v, false, 0, nil
+	}
+
 	if resp.StatusCode != http.StatusOK {
-		return v, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+		apiErr := newAPIError(url, resp)
+		switch {
+		case resp.StatusCode == http.StatusNotFound:
+			return v, false, 0, &NotFoundError{apiErr}
+		case (resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden) && c.authToken == "":
+			return v, false, 0, fmt.Errorf("%w: %w", ErrAuthRequired, apiErr)
+		}
+		return v, isRetryableStatusCode(resp.StatusCode), retryAfterFromResponse(resp), apiErr
 	}
 
-	if err = json.NewDecoder(resp.Body).Decode(&v); err != nil {
-		return v, fmt.Errorf("failed to decode response: %w", err)
+	if !isJSONContentType(resp.Header.Get("Content-Type")) {
+		return v, false, 0, newContentTypeError(url, resp)
 	}
-	return v, nil
+
+	if c.maxResponseSize > 0 && resp.ContentLength > c.maxResponseSize {
+		return v, false, 0, fmt.Errorf("%w: %d > %d bytes", ErrResponseTooLarge, resp.ContentLength, c.maxResponseSize)
+	}
+
+	var (
+		body io.Reader = resp.Body
+		raw  bytes.Buffer
+	)
+	if c.maxResponseSize > 0 {
+		body = &limitedBody{r: body, n: c.maxResponseSize, limit: c.maxResponseSize}
+	}
+	if c.cache != nil {
+		body = io.TeeReader(body, &raw)
+	}
+
+	dec := json.NewDecoder(body)
+	// Keep numbers in untyped values exact, instead of rounding them to float64.
+	dec.UseNumber()
+	if err = dec.Decode(&v); err != nil {
+		return v, false, 0, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	if c.cache != nil {
+		_ = c.cache.store(&cacheEntry{
+			URL:          url,
+			ETag:         resp.Header.Get("ETag"),
+			LastModified: resp.Header.Get("Last-Modified"),
+			Fetched:      Time{time.Now()},
+			Body:         bytes.TrimSpace(raw.Bytes()),
+		})
+	}
+	return v, false, 0, nil
+}
+
+// limitedBody wraps a response body and fails with [ErrResponseTooLarge]
+// once more than limit bytes have been read.
+type limitedBody struct {
+	r     io.Reader
+	n     int64
+	limit int64
+}
+
+// Read implements [io.Reader].
+func (lb *limitedBody) Read(b []byte) (int, error) {
+	if lb.n < 0 {
+		return 0, fmt.Errorf("%w: more than %d bytes", ErrResponseTooLarge, lb.limit)
+	}
+	// Read one byte past the limit, to tell a body of exactly limit bytes from a larger one.
+	if int64(len(b)) > lb.n+1 {
+		b = b[:lb.n+1]
+	}
+	n, err := lb.r.Read(b)
+	lb.n -= int64(n)
+	if lb.n < 0 {
+		return n, fmt.Errorf("%w: more than %d bytes", ErrResponseTooLarge, lb.limit)
+	}
+	return n, err
+}
+
+// SearchResult is the result of a modpack search.
+// This is the response of GET /public/modpack/search/{limit}?term={term}.
+type SearchResult struct {
+	Packs      []int64 `json:"packs"`
+	CurseForge []int64 `json:"curseforge"`
+	Total      int64   `json:"total"`
+	Limit      int64   `json:"limit"`
+	Refreshed  Time    `json:"refreshed"`
 }
 
 // ModpackManifest is the manifest of a modpack.
@@ -189,6 +508,26 @@ func (m *ModpackManifest) LatestVersion() (ModpackVersion, bool) {
 	return m.Versions[len(m.Versions)-1], true
 }
 
+// VersionsNewestFirst returns a copy of the modpack's versions, ordered from newest to oldest.
+func (m *ModpackManifest) VersionsNewestFirst() []ModpackVersion {
+	versions := slices.Clone(m.Versions)
+	if m.Provider != "curseforge" {
+		slices.Reverse(versions)
+	}
+	return versions
+}
+
+// VersionsNewerThan returns the modpack's versions newer than the version with the given ID,
+// ordered from newest to oldest, and whether the version was found.
+func (m *ModpackManifest) VersionsNewerThan(versionID int64) ([]ModpackVersion, bool) {
+	versions := m.VersionsNewestFirst()
+	i := slices.IndexFunc(versions, func(v ModpackVersion) bool { return v.ID == versionID })
+	if i < 0 {
+		return nil, false
+	}
+	return versions[:i:i], true
+}
+
 // ModpackArt is an image of a modpack.
 type ModpackArt struct {
 	Width      int      `json:"width"`
@@ -203,6 +542,50 @@ type ModpackArt struct {
 	Updated    Time     `json:"updated"`
 }
 
+// FileName returns the name the art is saved under, in the form "{type}-{id}{ext}",
+// where ext is the extension of the art's URL.
+func (a *ModpackArt) FileName() string {
+	ext := ""
+	if u, err := url.Parse(a.URL); err == nil {
+		ext = path.Ext(u.Path)
+	}
+	return a.Type + "-" + strconv.FormatInt(a.ID, 10) + ext
+}
+
+// PrecheckJob returns a precheck job for downloading the art into dir.
+//
+// The art is downloaded with the given user agent, or [APIUserAgent] if empty.
+func (a *ModpackArt) PrecheckJob(dir, userAgent string) (precheck.Job, error) {
+	name := a.FileName()
+	if !filepath.IsLocal(name) || filepath.Base(name) != name {
+		return precheck.Job{}, ErrPathSanitization
+	}
+
+	if a.URL == "" {
+		return precheck.Job{}, ErrMissingURL
+	}
+
+	sum, err := hex.DecodeString(a.SHA1)
+	if err != nil {
+		return precheck.Job{}, fmt.Errorf("failed to decode SHA1: %w", err)
+	}
+
+	if userAgent == "" {
+		userAgent = APIUserAgent
+	}
+
+	return precheck.Job{
+		DownloadURL:     a.URL,
+		Mirrors:         a.Mirrors,
+		UserAgent:       userAgent,
+		DestinationPath: filepath.Join(dir, name),
+		NewHash:         sha1.New,
+		Sum:             sum,
+		Size:            a.Size,
+		ModTime:         a.Updated.modTime(),
+	}, nil
+}
+
 // ModpackLink is a modpack's miscellaneous link.
 type ModpackLink struct {
 	ID   int64  `json:"id"`
@@ -279,6 +662,53 @@ type ModpackVersionManifest struct {
 	Private bool `json:"private"`
 }
 
+// FileByPath returns the file at the given slash-separated path relative to the installation,
+// such as "mods/example.jar". If the manifest lists several files at the path,
+// for example separate client and server variants, the first one is returned.
+func (m *ModpackVersionManifest) FileByPath(rel string) (*ModpackVersionFile, bool) {
+	rel = path.Clean(rel)
+	for i := range m.Files {
+		if f := &m.Files[i]; f.ManifestPath() == rel {
+			return f, true
+		}
+	}
+	return nil, false
+}
+
+// ClientFiles returns the files installed on the client by default.
+func (m *ModpackVersionManifest) ClientFiles() []*ModpackVersionFile {
+	return m.filesWhere(func(f *ModpackVersionFile) bool {
+		return f.OnClient() && f.SelectedByDefault()
+	})
+}
+
+// ServerFiles returns the files installed on the server by default.
+func (m *ModpackVersionManifest) ServerFiles() []*ModpackVersionFile {
+	return m.filesWhere(func(f *ModpackVersionFile) bool {
+		return f.OnServer() && f.SelectedByDefault()
+	})
+}
+
+// filesWhere returns the files for which keep returns true.
+func (m *ModpackVersionManifest) filesWhere(keep func(*ModpackVersionFile) bool) []*ModpackVersionFile {
+	var files []*ModpackVersionFile
+	for i := range m.Files {
+		if f := &m.Files[i]; keep(f) {
+			files = append(files, f)
+		}
+	}
+	return files
+}
+
+// TotalSize returns the total size of all files in the manifest.
+func (m *ModpackVersionManifest) TotalSize() int64 {
+	var size int64
+	for i := range m.Files {
+		size += m.Files[i].Size
+	}
+	return size
+}
+
 // ModpackVersionFile is a file in a modpack version's file list.
 type ModpackVersionFile struct {
 	// "version: int64" is in quotes for public modpacks, but not for CurseForge modpacks.
@@ -289,72 +719,256 @@ type ModpackVersionFile struct {
 	SHA1    string   `json:"sha1"`
 	Size    int64    `json:"size"`
 
+	// SHA256 is the file's SHA-256 sum, if available.
+	// When present, it is verified instead of SHA1.
+	SHA256 string `json:"sha256,omitempty"`
+
 	// "tags" array has no content.
 
 	ClientOnly bool `json:"clientonly"`
 	ServerOnly bool `json:"serveronly"`
 	Optional   bool `json:"optional"`
+
+	// Default is whether an optional file is selected by default in the launcher.
+	// Nil means the manifest does not say, in which case the file is selected.
+	Default *bool `json:"default,omitempty"`
+
 	ResourceBase
 
 	CurseForge *CurseForgeFile `json:"curseforge,omitempty"`
 }
 
+// ManifestPath returns the file's slash-separated path relative to the installation,
+// as listed in the manifest.
+func (f *ModpackVersionFile) ManifestPath() string {
+	return path.Join(f.Path, f.Name)
+}
+
+// OnClient returns whether the file belongs on the client.
+func (f *ModpackVersionFile) OnClient() bool {
+	return !f.ServerOnly
+}
+
+// OnServer returns whether the file belongs on the server.
+func (f *ModpackVersionFile) OnServer() bool {
+	return !f.ClientOnly
+}
+
+// SelectedByDefault returns whether the file is installed by default, as in the launcher.
+// Required files always are. Optional files are, unless the manifest marks them as not selected by default.
+func (f *ModpackVersionFile) SelectedByDefault() bool {
+	return !f.Optional || f.Default == nil || *f.Default
+}
+
+// DownloadURL returns the URL to download the file from.
+// For CurseForge files without a URL, it is derived from the CurseForge project and file IDs.
+func (f *ModpackVersionFile) DownloadURL() (string, error) {
+	if f.URL != "" {
+		return f.URL, nil
+	}
+	if f.CurseForge == nil {
+		return "", ErrMissingURL
+	}
+	return f.CurseForge.DownloadURL(f.Name), nil
+}
+
+// CurseForgeURLResolver resolves the canonical download URLs of CurseForge files.
+// It is implemented by the client in the curseforge package.
+type CurseForgeURLResolver interface {
+	GetFileDownloadURL(ctx context.Context, projectID, fileID int64) (string, error)
+}
+
+// ResolveCurseForgeURL sets the file's URL to the canonical download URL resolved by r,
+// if the file is a CurseForge file without a URL. The guessed URLs are kept as the last mirrors.
+// On error, the file is left unchanged.
+func (f *ModpackVersionFile) ResolveCurseForgeURL(ctx context.Context, r CurseForgeURLResolver) error {
+	if f.URL != "" || f.CurseForge == nil {
+		return nil
+	}
+
+	url, err := r.GetFileDownloadURL(ctx, f.CurseForge.Project, f.CurseForge.File)
+	if err != nil {
+		return err
+	}
+
+	f.Mirrors = append(slices.Clip(f.Mirrors), f.CurseForge.DownloadURLs(f.Name)...)
+	f.URL = url
+	return nil
+}
+
+// PathMapper maps a file to its destination path relative to the client or server path.
+// It returns false to exclude the file. The returned path must be local, as reported by [filepath.IsLocal].
+type PathMapper func(file *ModpackVersionFile) (relPath string, ok bool)
+
+// DefaultPathMapper is the [PathMapper] that puts files at their paths in the manifest.
+func DefaultPathMapper(file *ModpackVersionFile) (string, bool) {
+	return filepath.Join(file.Path, file.Name), true
+}
+
+// pathIsLocal returns whether the file's path and name stay within the directory they are joined to.
+// The path must be local, as reported by [filepath.IsLocal], the name must be a single local path element,
+// and neither may contain NUL bytes.
+func (f *ModpackVersionFile) pathIsLocal() bool {
+	if strings.IndexByte(f.Path, 0) >= 0 || strings.IndexByte(f.Name, 0) >= 0 {
+		return false
+	}
+	if !filepath.IsLocal(f.Path) || !filepath.IsLocal(f.Name) || filepath.Base(f.Name) != f.Name {
+		return false
+	}
+	return filepath.IsLocal(filepath.Join(f.Path, f.Name))
+}
+
 // PrecheckJob returns a precheck job for the file.
-func (f *ModpackVersionFile) PrecheckJob(
-	migrateFromPath, clientPath, serverPath string,
-	serverIgnoreCurseForgeProjects []int64,
-	preserveMigrationSource bool,
-) (precheck.Job, bool, error) {
-	if !filepath.IsLocal(f.Path) {
+//
+// The file is downloaded with opts.UserAgent, or [APIUserAgent] if empty.
+//
+// The file's path and name are validated before the destination paths are determined,
+// and files that would escape the client, server, or migration source path are rejected
+// with [ErrPathSanitization]. On Windows, destination paths containing reserved device names
+// or characters that are not allowed in file names are rejected with [ErrInvalidWindowsName].
+//
+// The destination paths are determined by opts.MapPath, or [DefaultPathMapper] if nil.
+// The migration source path always follows the manifest's layout.
+//
+// CurseForge files from projects in opts.ClientIgnoreCurseForgeProjects are not put under the client path,
+// and those from projects in opts.ServerIgnoreCurseForgeProjects are not put under the server path.
+//
+// If opts.MaxSize is positive, larger files are rejected with [ErrFileTooLarge].
+//
+// The Select and Planned hooks of opts are not used.
+func (f *ModpackVersionFile) PrecheckJob(opts PrecheckOptions) (precheck.Job, bool, error) {
+	if runtime.GOOS == "windows" {
+		// Checked first, as filepath.IsLocal also rejects reserved names on Windows, with a less helpful error.
+		if err := validateWindowsPath(filepath.Join(f.Path, f.Name)); err != nil {
+			return precheck.Job{}, false, err
+		}
+	}
+	if !f.pathIsLocal() {
 		return precheck.Job{}, false, ErrPathSanitization
 	}
 
-	url := f.URL
-	if url == "" {
-		if f.CurseForge == nil {
-			return precheck.Job{}, false, ErrMissingURL
+	if opts.MaxSize > 0 && f.Size > opts.MaxSize {
+		return precheck.Job{}, false, fmt.Errorf("%w: %d > %d bytes", ErrFileTooLarge, f.Size, opts.MaxSize)
+	}
+
+	mapPath := opts.MapPath
+	if mapPath == nil {
+		mapPath = DefaultPathMapper
+	}
+	relPath, ok := mapPath(f)
+	if !ok {
+		return precheck.Job{}, false, nil
+	}
+	if runtime.GOOS == "windows" {
+		if err := validateWindowsPath(relPath); err != nil {
+			return precheck.Job{}, false, err
 		}
-		url = f.CurseForge.DownloadURL(f.Name)
+	}
+	if !filepath.IsLocal(relPath) || strings.IndexByte(relPath, 0) >= 0 {
+		return precheck.Job{}, false, ErrPathSanitization
 	}
 
-	var destinationPath, secondaryDestinationPath string
-	if !f.ServerOnly && clientPath != "" {
-		destinationPath = filepath.Join(clientPath, f.Path, f.Name)
+	url, err := f.DownloadURL()
+	if err != nil {
+		return precheck.Job{}, false, err
 	}
-	if !f.ClientOnly && serverPath != "" && (f.CurseForge == nil || !slices.Contains(serverIgnoreCurseForgeProjects, f.CurseForge.Project)) {
-		secondaryDestinationPath = filepath.Join(serverPath, f.Path, f.Name)
+
+	mirrors := f.Mirrors
+	if f.URL == "" {
+		// The URL is guessed, so try the alternative guesses if it's rejected.
+		mirrors = append(slices.Clip(mirrors), f.CurseForge.DownloadURLs(f.Name)[1:]...)
 	}
 
+	destinationPath, secondaryDestinationPath := f.destinationPaths(relPath, opts.ClientPath, opts.ServerPath, opts.ClientIgnoreCurseForgeProjects, opts.ServerIgnoreCurseForgeProjects)
 	if destinationPath == "" {
-		if secondaryDestinationPath == "" {
-			return precheck.Job{}, false, nil
-		}
-		destinationPath = secondaryDestinationPath
-		secondaryDestinationPath = ""
+		return precheck.Job{}, false, nil
 	}
 
-	if migrateFromPath != "" {
-		migrateFromPath = filepath.Join(migrateFromPath, f.Path, f.Name)
+	var migrateFromPath string
+	if opts.MigrateFromPath != "" {
+		migrateFromPath = filepath.Join(opts.MigrateFromPath, f.Path, f.Name)
 	}
 
-	sum, err := hex.DecodeString(f.SHA1)
+	userAgent := opts.UserAgent
+	if userAgent == "" {
+		userAgent = APIUserAgent
+	}
+
+	newHash, sum, err := f.hashAndSum()
 	if err != nil {
-		return precheck.Job{}, false, fmt.Errorf("failed to decode SHA1: %w", err)
+		return precheck.Job{}, false, err
 	}
 
 	return precheck.Job{
 		DownloadURL:              url,
-		UserAgent:                APIUserAgent,
+		Mirrors:                  mirrors,
+		UserAgent:                userAgent,
 		MigrateFromPath:          migrateFromPath,
-		PreserveMigrationSource:  preserveMigrationSource,
+		MigrationMode:            opts.MigrationMode,
 		DestinationPath:          destinationPath,
 		SecondaryDestinationPath: secondaryDestinationPath,
-		NewHash:                  sha1.New,
+		NewHash:                  newHash,
 		Sum:                      sum,
 		Size:                     f.Size,
+		ModTime:                  f.Updated.modTime(),
 	}, true, nil
 }
 
+// destinationPaths returns the paths to put the file at under the client and server paths.
+//
+// The file goes under the client path unless it's server-only, the client path is empty,
+// or it's a CurseForge file from one of the projects ignored on the client, and under the server path
+// unless it's client-only, the server path is empty, or it's a CurseForge file from one of the projects
+// ignored on the server.
+//
+// The primary path is the client destination if there is one. Otherwise, the server destination
+// is promoted to the primary path, and the secondary path is empty. Both paths are empty
+// if the file goes nowhere.
+func (f *ModpackVersionFile) destinationPaths(relPath, clientPath, serverPath string, clientIgnoreCurseForgeProjects, serverIgnoreCurseForgeProjects []int64) (primary, secondary string) {
+	if f.OnClient() && clientPath != "" && !f.fromCurseForgeProject(clientIgnoreCurseForgeProjects) {
+		primary = filepath.Join(clientPath, relPath)
+	}
+	if f.OnServer() && serverPath != "" && !f.fromCurseForgeProject(serverIgnoreCurseForgeProjects) {
+		secondary = filepath.Join(serverPath, relPath)
+	}
+	if primary == "" {
+		return secondary, ""
+	}
+	return primary, secondary
+}
+
+// fromCurseForgeProject returns whether the file is a CurseForge file from one of the given projects.
+func (f *ModpackVersionFile) fromCurseForgeProject(projects []int64) bool {
+	return f.CurseForge != nil && slices.Contains(projects, f.CurseForge.Project)
+}
+
+// ExpectedSum returns the hex-encoded sum the file is verified with:
+// the SHA-256 sum if the manifest has one, or the SHA-1 sum otherwise.
+func (f *ModpackVersionFile) ExpectedSum() string {
+	if f.SHA256 != "" {
+		return f.SHA256
+	}
+	return f.SHA1
+}
+
+// hashAndSum returns the hash function and the decoded expected sum
+// of the strongest hash available for the file.
+func (f *ModpackVersionFile) hashAndSum() (func() hash.Hash, []byte, error) {
+	if f.SHA256 != "" {
+		sum, err := hex.DecodeString(f.SHA256)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to decode SHA256: %w", err)
+		}
+		return sha256.New, sum, nil
+	}
+
+	sum, err := hex.DecodeString(f.SHA1)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to decode SHA1: %w", err)
+	}
+	return sha1.New, sum, nil
+}
+
 // CurseForgeFile is a file under a CurseForge project.
 type CurseForgeFile struct {
 	Project int64 `json:"project"`
@@ -368,6 +982,48 @@ func (f *CurseForgeFile) DownloadURL(name string) string {
 	return fmt.Sprintf("https://edge.forgecdn.net/files/%d/%d/%s", f.Project, f.File, url.PathEscape(name))
 }
 
+// DownloadURLs returns the candidate download URLs of the file, starting with [CurseForgeFile.DownloadURL].
+// The alternatives follow the CDN's layout of splitting the file ID into its leading digits
+// and its last three digits, which is tried when the first guess is rejected.
+//
+// As the CDN does not always agree with [url.PathEscape] on how to encode file names,
+// the URLs with the escaped name are followed by the same URLs with each of the variants
+// returned by curseForgeNameVariants.
+func (f *CurseForgeFile) DownloadURLs(name string) []string {
+	hi, lo := f.File/1000, f.File%1000
+	var urls []string
+	for _, encoded := range curseForgeNameVariants(name) {
+		urls = append(urls,
+			fmt.Sprintf("https://edge.forgecdn.net/files/%d/%d/%s", f.Project, f.File, encoded),
+			fmt.Sprintf("https://edge.forgecdn.net/files/%d/%d/%s", hi, lo, encoded),
+			fmt.Sprintf("https://mediafilez.forgecdn.net/files/%d/%d/%s", hi, lo, encoded),
+		)
+		if lo < 100 {
+			urls = append(urls, fmt.Sprintf("https://edge.forgecdn.net/files/%d/%03d/%s", hi, lo, encoded))
+		}
+	}
+	return urls
+}
+
+// curseForgeNameVariants returns the distinct encodings of the file name to try in CurseForge CDN URLs,
+// in order: escaped with [url.PathEscape], with escaped spaces as '+', raw with only the characters
+// that would break the URL escaped, and in lower case, escaped.
+func curseForgeNameVariants(name string) []string {
+	escaped := url.PathEscape(name)
+	raw := strings.NewReplacer("%", "%25", " ", "%20", "#", "%23", "?", "%3F", "/", "%2F").Replace(name)
+	variants := []string{escaped}
+	for _, v := range [...]string{
+		strings.ReplaceAll(escaped, "%20", "+"),
+		raw,
+		url.PathEscape(strings.ToLower(name)),
+	} {
+		if !slices.Contains(variants, v) {
+			variants = append(variants, v)
+		}
+	}
+	return variants
+}
+
 // ResourceBase contains basic information about a remote resource.
 type ResourceBase struct {
 	ID      int64  `json:"id"`
@@ -395,3 +1051,12 @@ func (t *Time) UnmarshalJSON(data []byte) error {
 	t.Time = time.Unix(secs, 0)
 	return nil
 }
+
+// modTime returns the time for use as a file's modification time.
+// Missing timestamps, which the API reports as zero, are returned as the zero [time.Time].
+func (t Time) modTime() time.Time {
+	if t.Unix() <= 0 {
+		return time.Time{}
+	}
+	return t.Time
+}
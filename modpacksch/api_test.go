@@ -0,0 +1,797 @@
+package modpacksch
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/database64128/modpack-dl-go/download"
+	"github.com/database64128/modpack-dl-go/precheck"
+)
+
+var (
+	testFileContent = []byte("test file content\n")
+	testFileSHA1    = sha1.Sum(testFileContent)
+	testFileSHA256  = sha256.Sum256(testFileContent)
+)
+
+func TestModpackVersionFileHashAndSum(t *testing.T) {
+	for _, c := range []struct {
+		name     string
+		sha1     string
+		sha256   string
+		wantSize int
+		wantSum  []byte
+	}{
+		{"SHA1Only", hex.EncodeToString(testFileSHA1[:]), "", sha1.Size, testFileSHA1[:]},
+		{"SHA256Only", "", hex.EncodeToString(testFileSHA256[:]), sha256.Size, testFileSHA256[:]},
+		{"Both", hex.EncodeToString(testFileSHA1[:]), hex.EncodeToString(testFileSHA256[:]), sha256.Size, testFileSHA256[:]},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			f := ModpackVersionFile{SHA1: c.sha1, SHA256: c.sha256}
+			newHash, sum, err := f.hashAndSum()
+			if err != nil {
+				t.Fatalf("hashAndSum() error = %v", err)
+			}
+			h := newHash()
+			if h.Size() != c.wantSize {
+				t.Errorf("hash size = %d, want %d", h.Size(), c.wantSize)
+			}
+			if !bytes.Equal(sum, c.wantSum) {
+				t.Errorf("sum = %x, want %x", sum, c.wantSum)
+			}
+			h.Write(testFileContent)
+			if got := h.Sum(nil); !bytes.Equal(got, sum) {
+				t.Errorf("hash of content = %x, want %x", got, sum)
+			}
+		})
+	}
+}
+
+func TestModpackVersionFileHashAndSumInvalid(t *testing.T) {
+	for _, f := range []ModpackVersionFile{
+		{SHA1: "not hex"},
+		{SHA1: hex.EncodeToString(testFileSHA1[:]), SHA256: "not hex"},
+	} {
+		if _, _, err := f.hashAndSum(); err == nil {
+			t.Errorf("hashAndSum() of SHA1 %q, SHA256 %q: error = nil, want error", f.SHA1, f.SHA256)
+		}
+	}
+}
+
+func TestGetModpackManifestWithAuthToken(t *testing.T) {
+	const token = "secret-token"
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer "+token {
+			http.Error(w, `{"status":"error","message":"unauthorized"}`, http.StatusUnauthorized)
+			return
+		}
+		writeJSON(w, `{"id":7,"name":"Private Pack","private":true}`)
+	}))
+	defer srv.Close()
+
+	c := NewPublicModpackClient(WithBaseURL(srv.URL), WithAuthToken(token))
+	m, err := c.GetModpackManifest(context.Background(), 7)
+	if err != nil {
+		t.Fatalf("GetModpackManifest() error = %v", err)
+	}
+	if m.ID != 7 || !m.Private {
+		t.Errorf("GetModpackManifest() = %+v, want private modpack 7", m)
+	}
+
+	c = NewPublicModpackClient(WithBaseURL(srv.URL))
+	if _, err = c.GetModpackManifest(context.Background(), 7); !errors.Is(err, ErrAuthRequired) {
+		t.Errorf("GetModpackManifest() without token error = %v, want %v", err, ErrAuthRequired)
+	}
+
+	c = NewPublicModpackClient(WithBaseURL(srv.URL), WithAuthToken("wrong"))
+	_, err = c.GetModpackManifest(context.Background(), 7)
+	if err == nil || errors.Is(err, ErrAuthRequired) {
+		t.Errorf("GetModpackManifest() with wrong token error = %v, want error other than %v", err, ErrAuthRequired)
+	}
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusUnauthorized {
+		t.Errorf("GetModpackManifest() with wrong token error = %v, want APIError with status 401", err)
+	}
+}
+
+func TestSearchModpacks(t *testing.T) {
+	const term = "all the mods & more/10"
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/public/modpack/search/5" {
+			t.Errorf("path = %q, want %q", r.URL.Path, "/public/modpack/search/5")
+		}
+		if got := r.URL.Query()["term"]; len(got) != 1 || got[0] != term {
+			t.Errorf("term = %q, want [%q]", got, term)
+		}
+		writeJSON(w, `{"packs":[1,2,3],"curseforge":[4,5],"total":5,"limit":5,"refreshed":1620000000}`)
+	}))
+	defer srv.Close()
+
+	for _, c := range []struct {
+		name   string
+		client ModpackClient
+		want   []int64
+	}{
+		{"Public", NewPublicModpackClient(WithBaseURL(srv.URL)), []int64{1, 2, 3}},
+		{"CurseForge", NewCurseForgeModpackClient(WithBaseURL(srv.URL)), []int64{4, 5}},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			ids, err := c.client.SearchModpacks(context.Background(), term, 5)
+			if err != nil {
+				t.Fatalf("SearchModpacks() error = %v", err)
+			}
+			if !slices.Equal(ids, c.want) {
+				t.Errorf("SearchModpacks() = %v, want %v", ids, c.want)
+			}
+		})
+	}
+}
+
+func TestWithBaseURL(t *testing.T) {
+	var gotPath string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		writeJSON(w, `{"id":42}`)
+	}))
+	defer srv.Close()
+
+	for _, c := range []struct {
+		baseURL  string
+		wantPath string
+	}{
+		{srv.URL, "/public/modpack/42"},
+		{srv.URL + "/", "/public/modpack/42"},
+		{srv.URL + "/mirror", "/mirror/public/modpack/42"},
+		{srv.URL + "/mirror/", "/mirror/public/modpack/42"},
+	} {
+		gotPath = ""
+		c1 := NewPublicModpackClient(WithBaseURL(c.baseURL))
+		if _, err := c1.GetModpackManifest(context.Background(), 42); err != nil {
+			t.Errorf("base URL %q: GetModpackManifest() error = %v", c.baseURL, err)
+			continue
+		}
+		if gotPath != c.wantPath {
+			t.Errorf("base URL %q: request path = %q, want %q", c.baseURL, gotPath, c.wantPath)
+		}
+	}
+}
+
+func TestClientOptions(t *testing.T) {
+	httpClient := &http.Client{}
+	cache := NewCache(t.TempDir(), time.Minute)
+	c := newAPIClient([]ClientOption{
+		WithHTTPClient(httpClient),
+		WithUserAgent("test-agent/1.0"),
+		WithRetryPolicy(testRetryPolicy),
+		WithBaseURL("https://mirror.example.com/api"),
+		WithAuthToken("token"),
+		WithCache(cache),
+		WithMaxResponseSize(1024),
+	})
+	if c.client != httpClient {
+		t.Error("WithHTTPClient was not applied")
+	}
+	if c.userAgent != "test-agent/1.0" {
+		t.Errorf("userAgent = %q, want %q", c.userAgent, "test-agent/1.0")
+	}
+	if c.retryPolicy != testRetryPolicy {
+		t.Errorf("retryPolicy = %+v, want %+v", c.retryPolicy, testRetryPolicy)
+	}
+	if c.baseURL != "https://mirror.example.com/api" {
+		t.Errorf("baseURL = %q, want %q", c.baseURL, "https://mirror.example.com/api")
+	}
+	if c.authToken != "token" {
+		t.Errorf("authToken = %q, want %q", c.authToken, "token")
+	}
+	if c.cache != cache {
+		t.Error("WithCache was not applied")
+	}
+	if c.maxResponseSize != 1024 {
+		t.Errorf("maxResponseSize = %d, want 1024", c.maxResponseSize)
+	}
+}
+
+func TestClientOptionsDefaults(t *testing.T) {
+	for _, c := range []apiClient{
+		newAPIClient(nil),
+		// Empty values keep the defaults.
+		newAPIClient([]ClientOption{WithHTTPClient(nil), WithUserAgent(""), WithBaseURL("")}),
+	} {
+		if c.client != http.DefaultClient {
+			t.Errorf("client = %p, want http.DefaultClient", c.client)
+		}
+		if c.userAgent != APIUserAgent {
+			t.Errorf("userAgent = %q, want %q", c.userAgent, APIUserAgent)
+		}
+		if c.baseURL != APIBaseURL {
+			t.Errorf("baseURL = %q, want %q", c.baseURL, APIBaseURL)
+		}
+		if c.retryPolicy != (RetryPolicy{}) {
+			t.Errorf("retryPolicy = %+v, want zero", c.retryPolicy)
+		}
+		if c.authToken != "" || c.cache != nil {
+			t.Errorf("authToken, cache = %q, %p, want none", c.authToken, c.cache)
+		}
+		if c.maxResponseSize != DefaultMaxResponseSize {
+			t.Errorf("maxResponseSize = %d, want %d", c.maxResponseSize, DefaultMaxResponseSize)
+		}
+	}
+}
+
+// testURLResolver is a [CurseForgeURLResolver] that resolves every file to the same URL.
+type testURLResolver struct {
+	url string
+	err error
+}
+
+func (r testURLResolver) GetFileDownloadURL(ctx context.Context, projectID, fileID int64) (string, error) {
+	return r.url, r.err
+}
+
+func TestResolveCurseForgeURL(t *testing.T) {
+	const resolved = "https://edge.forgecdn.net/files/5678/901/Example+Mod.jar"
+	newFile := func() ModpackVersionFile {
+		return ModpackVersionFile{
+			ResourceBase: ResourceBase{Name: "Example+Mod.jar"},
+			CurseForge:   &CurseForgeFile{Project: 1234, File: 5678901},
+		}
+	}
+
+	f := newFile()
+	guessed := f.CurseForge.DownloadURLs(f.Name)
+	if err := f.ResolveCurseForgeURL(context.Background(), testURLResolver{url: resolved}); err != nil {
+		t.Fatalf("ResolveCurseForgeURL() error = %v", err)
+	}
+	if url, _ := f.DownloadURL(); url != resolved {
+		t.Errorf("DownloadURL() = %q, want %q", url, resolved)
+	}
+	if !slices.Equal(f.Mirrors, guessed) {
+		t.Errorf("Mirrors = %q, want the guessed URLs %q", f.Mirrors, guessed)
+	}
+
+	// Without a resolved URL, the guessed URL is used.
+	f = newFile()
+	if err := f.ResolveCurseForgeURL(context.Background(), testURLResolver{err: errors.New("no API key")}); err == nil {
+		t.Error("ResolveCurseForgeURL() error = nil, want error")
+	}
+	if url, _ := f.DownloadURL(); url != guessed[0] {
+		t.Errorf("DownloadURL() after failed resolution = %q, want %q", url, guessed[0])
+	}
+	if len(f.Mirrors) != 0 {
+		t.Errorf("Mirrors after failed resolution = %q, want none", f.Mirrors)
+	}
+
+	// Files with a URL are left alone.
+	f = ModpackVersionFile{URL: "https://example.com/a.jar", CurseForge: &CurseForgeFile{Project: 1, File: 2}}
+	if err := f.ResolveCurseForgeURL(context.Background(), testURLResolver{url: resolved}); err != nil {
+		t.Fatalf("ResolveCurseForgeURL() error = %v", err)
+	}
+	if f.URL != "https://example.com/a.jar" {
+		t.Errorf("URL = %q, want it unchanged", f.URL)
+	}
+}
+
+func TestModpackArtPrecheckJob(t *testing.T) {
+	a := ModpackArt{
+		ID:   3,
+		Type: "square",
+		URL:  "https://example.com/art/icon.png?size=256",
+		SHA1: hex.EncodeToString(testFileSHA1[:]),
+		Size: int64(len(testFileContent)),
+	}
+	pj, err := a.PrecheckJob("art", "")
+	if err != nil {
+		t.Fatalf("PrecheckJob() error = %v", err)
+	}
+	if want := filepath.Join("art", "square-3.png"); pj.DestinationPath != want {
+		t.Errorf("DestinationPath = %q, want %q", pj.DestinationPath, want)
+	}
+	if pj.UserAgent != APIUserAgent {
+		t.Errorf("UserAgent = %q, want %q", pj.UserAgent, APIUserAgent)
+	}
+	if !bytes.Equal(pj.Sum, testFileSHA1[:]) || pj.Size != a.Size {
+		t.Errorf("Sum, Size = %x, %d, want %x, %d", pj.Sum, pj.Size, testFileSHA1, a.Size)
+	}
+
+	for _, bad := range []ModpackArt{
+		{ID: 3, Type: "../escape", URL: a.URL, SHA1: a.SHA1},
+		{ID: 3, Type: "square", SHA1: a.SHA1},
+		{ID: 3, Type: "square", URL: a.URL, SHA1: "not hex"},
+	} {
+		if _, err := bad.PrecheckJob("art", ""); err == nil {
+			t.Errorf("PrecheckJob() of %+v: error = nil, want error", bad)
+		}
+	}
+}
+
+func TestCurseForgePrecheckJobHasAlternateURLs(t *testing.T) {
+	f := ModpackVersionFile{
+		Path:         "./mods/",
+		SHA1:         hex.EncodeToString(testFileSHA1[:]),
+		Size:         int64(len(testFileContent)),
+		ResourceBase: ResourceBase{Name: "a.jar"},
+		CurseForge:   &CurseForgeFile{Project: 1234, File: 5678901},
+	}
+	pj, ok, err := f.PrecheckJob(PrecheckOptions{ClientPath: "client"})
+	if err != nil || !ok {
+		t.Fatalf("PrecheckJob() = %v, %v", ok, err)
+	}
+	if want := "https://edge.forgecdn.net/files/1234/5678901/a.jar"; pj.DownloadURL != want {
+		t.Errorf("DownloadURL = %q, want %q", pj.DownloadURL, want)
+	}
+	for _, want := range []string{
+		"https://edge.forgecdn.net/files/5678/901/a.jar",
+		"https://mediafilez.forgecdn.net/files/5678/901/a.jar",
+	} {
+		if !slices.Contains(pj.Mirrors, want) {
+			t.Errorf("Mirrors = %q, want to contain %q", pj.Mirrors, want)
+		}
+	}
+	if slices.Contains(pj.Mirrors, pj.DownloadURL) {
+		t.Errorf("Mirrors = %q, want them not to repeat the download URL", pj.Mirrors)
+	}
+}
+
+func TestCurseForgeDownloadURLsZeroPadded(t *testing.T) {
+	f := CurseForgeFile{Project: 1234, File: 5678042}
+	if want := "https://edge.forgecdn.net/files/5678/042/a.jar"; !slices.Contains(f.DownloadURLs("a.jar"), want) {
+		t.Errorf("DownloadURLs() = %q, want to contain %q", f.DownloadURLs("a.jar"), want)
+	}
+}
+
+// testVersionFile returns a file at mods/name with the test file content.
+func testVersionFile(name string) ModpackVersionFile {
+	return ModpackVersionFile{
+		Path:         "./mods/",
+		URL:          "https://example.com/" + name,
+		SHA1:         hex.EncodeToString(testFileSHA1[:]),
+		Size:         int64(len(testFileContent)),
+		ResourceBase: ResourceBase{Name: name},
+	}
+}
+
+func TestPrecheckJobPathMapper(t *testing.T) {
+	remap := func(f *ModpackVersionFile) (string, bool) {
+		if strings.HasSuffix(f.Name, ".zip") {
+			return filepath.Join("packs", f.Name), true
+		}
+		if strings.HasPrefix(f.Name, "skip-") {
+			return "", false
+		}
+		return DefaultPathMapper(f)
+	}
+
+	for _, c := range []struct {
+		name     string
+		wantPath string
+		wantOK   bool
+	}{
+		{"pack.zip", filepath.Join("client", "packs", "pack.zip"), true},
+		{"skip-me.jar", "", false},
+		{"kept.jar", filepath.Join("client", "mods", "kept.jar"), true},
+	} {
+		f := testVersionFile(c.name)
+		pj, ok, err := f.PrecheckJob(PrecheckOptions{ClientPath: "client", MapPath: remap})
+		if err != nil {
+			t.Errorf("PrecheckJob() of %s error = %v", c.name, err)
+			continue
+		}
+		if ok != c.wantOK || pj.DestinationPath != c.wantPath {
+			t.Errorf("PrecheckJob() of %s = %q, %v, want %q, %v", c.name, pj.DestinationPath, ok, c.wantPath, c.wantOK)
+		}
+	}
+}
+
+func TestPrecheckJobPathMapperIsSanitized(t *testing.T) {
+	for _, mapped := range []string{"../escape.jar", filepath.Join("mods", "..", "..", "escape.jar"), string(filepath.Separator) + "abs.jar", "nul\x00.jar"} {
+		mapPath := func(*ModpackVersionFile) (string, bool) { return mapped, true }
+		f := testVersionFile("a.jar")
+		_, _, err := f.PrecheckJob(PrecheckOptions{ClientPath: "client", MapPath: mapPath})
+		if runtime.GOOS == "windows" && errors.Is(err, ErrInvalidWindowsName) {
+			continue
+		}
+		if !errors.Is(err, ErrPathSanitization) {
+			t.Errorf("PrecheckJob() with mapped path %q error = %v, want %v", mapped, err, ErrPathSanitization)
+		}
+	}
+}
+
+func TestPrecheckJobDestinations(t *testing.T) {
+	const project = 1234
+	client := filepath.Join("root", "client")
+	server := filepath.Join("root", "server")
+	clientFile := filepath.Join(client, "mods", "a.jar")
+	serverFile := filepath.Join(server, "mods", "a.jar")
+
+	for _, c := range []struct {
+		name                         string
+		clientOnly, serverOnly       bool
+		clientPath, serverPath       string
+		clientIgnored, serverIgnored []int64
+		wantPrimary, wantSecondary   string
+		wantOK                       bool
+	}{
+		{"Both", false, false, client, server, nil, nil, clientFile, serverFile, true},
+		{"BothClientPathOnly", false, false, client, "", nil, nil, clientFile, "", true},
+		{"BothServerPathOnly", false, false, "", server, nil, nil, serverFile, "", true},
+		{"ClientOnly", true, false, client, server, nil, nil, clientFile, "", true},
+		{"ClientOnlyNoClientPath", true, false, "", server, nil, nil, "", "", false},
+		{"ServerOnly", false, true, client, server, nil, nil, serverFile, "", true},
+		{"ServerOnlyNoServerPath", false, true, client, "", nil, nil, "", "", false},
+		{"ServerIgnored", false, false, client, server, nil, []int64{project}, clientFile, "", true},
+		{"ClientIgnored", false, false, client, server, []int64{project}, nil, serverFile, "", true},
+		{"BothIgnored", false, false, client, server, []int64{project}, []int64{project}, "", "", false},
+		{"OtherProjectIgnored", false, false, client, server, []int64{1}, []int64{2}, clientFile, serverFile, true},
+		{"NoPaths", false, false, "", "", nil, nil, "", "", false},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			f := testVersionFile("a.jar")
+			f.ClientOnly = c.clientOnly
+			f.ServerOnly = c.serverOnly
+			f.CurseForge = &CurseForgeFile{Project: project, File: 5678}
+			pj, ok, err := f.PrecheckJob(PrecheckOptions{
+				ClientPath:                     c.clientPath,
+				ServerPath:                     c.serverPath,
+				ClientIgnoreCurseForgeProjects: c.clientIgnored,
+				ServerIgnoreCurseForgeProjects: c.serverIgnored,
+			})
+			if err != nil {
+				t.Fatalf("PrecheckJob() error = %v", err)
+			}
+			if ok != c.wantOK {
+				t.Fatalf("PrecheckJob() ok = %v, want %v", ok, c.wantOK)
+			}
+			if pj.DestinationPath != c.wantPrimary || pj.SecondaryDestinationPath != c.wantSecondary {
+				t.Errorf("destinations = %q, %q, want %q, %q", pj.DestinationPath, pj.SecondaryDestinationPath, c.wantPrimary, c.wantSecondary)
+			}
+		})
+	}
+}
+
+func TestPrecheckJobMirrorsAndMigration(t *testing.T) {
+	f := testVersionFile("a.jar")
+	f.Mirrors = []string{"https://mirror.example.com/a.jar"}
+	pj, ok, err := f.PrecheckJob(PrecheckOptions{MigrateFromPath: "old", ClientPath: "client", UserAgent: "agent"})
+	if err != nil || !ok {
+		t.Fatalf("PrecheckJob() = %v, %v", ok, err)
+	}
+	if pj.DownloadURL != f.URL {
+		t.Errorf("DownloadURL = %q, want %q", pj.DownloadURL, f.URL)
+	}
+	if !slices.Equal(pj.Mirrors, f.Mirrors) {
+		t.Errorf("Mirrors = %q, want %q", pj.Mirrors, f.Mirrors)
+	}
+	if want := filepath.Join("old", "mods", "a.jar"); pj.MigrateFromPath != want {
+		t.Errorf("MigrateFromPath = %q, want %q", pj.MigrateFromPath, want)
+	}
+	if pj.UserAgent != "agent" {
+		t.Errorf("UserAgent = %q, want %q", pj.UserAgent, "agent")
+	}
+}
+
+func TestPrecheckJobRejectsOversizedFile(t *testing.T) {
+	f := testVersionFile("a.jar")
+	for _, c := range []struct {
+		name    string
+		maxSize int64
+		wantErr bool
+	}{
+		{"Unlimited", 0, false},
+		{"AtLimit", f.Size, false},
+		{"OverLimit", f.Size - 1, true},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			_, ok, err := f.PrecheckJob(PrecheckOptions{ClientPath: "client", MaxSize: c.maxSize})
+			if c.wantErr {
+				if !errors.Is(err, ErrFileTooLarge) {
+					t.Errorf("PrecheckJob() error = %v, want %v", err, ErrFileTooLarge)
+				}
+				return
+			}
+			if err != nil || !ok {
+				t.Errorf("PrecheckJob() = %v, %v, want true, nil", ok, err)
+			}
+		})
+	}
+}
+
+func TestPrecheckJobRejectsPathTraversal(t *testing.T) {
+	abs := string(filepath.Separator) + filepath.Join("etc", "evil")
+	if runtime.GOOS == "windows" {
+		abs = `C:\evil`
+	}
+	for _, c := range []struct {
+		name       string
+		path, file string
+	}{
+		{"NameParent", "./mods/", "../../evil.jar"},
+		{"NameDotDot", "./mods/", ".."},
+		{"NameSubdirectory", "./mods/", "sub/a.jar"},
+		{"NameAbsolute", "./mods/", abs},
+		{"NameNUL", "./mods/", "a\x00.jar"},
+		{"PathParent", "../", "evil.jar"},
+		{"PathEscapesAfterJoin", "./mods/../../", "evil.jar"},
+		{"PathAbsolute", abs, "evil.jar"},
+		{"PathNUL", "./mods\x00/", "a.jar"},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			f := testVersionFile(c.file)
+			f.Path = c.path
+			_, _, err := f.PrecheckJob(PrecheckOptions{MigrateFromPath: "old", ClientPath: "client", ServerPath: "server"})
+			if runtime.GOOS == "windows" && errors.Is(err, ErrInvalidWindowsName) {
+				return
+			}
+			if !errors.Is(err, ErrPathSanitization) {
+				t.Errorf("PrecheckJob() with path %q and name %q error = %v, want %v", c.path, c.file, err, ErrPathSanitization)
+			}
+		})
+	}
+}
+
+func TestPrecheckJobAcceptsLocalPaths(t *testing.T) {
+	for _, c := range []struct {
+		path, file string
+	}{
+		{"./", "options.txt"},
+		{"./config/sub/", "a.toml"},
+		{"./mods/", "..double-dot-prefix.jar"},
+	} {
+		f := testVersionFile(c.file)
+		f.Path = c.path
+		if _, ok, err := f.PrecheckJob(PrecheckOptions{ClientPath: "client"}); err != nil || !ok {
+			t.Errorf("PrecheckJob() with path %q and name %q = %v, %v, want true, nil", c.path, c.file, ok, err)
+		}
+	}
+}
+
+func TestPrecheckJobIgnoresCurseForgeProjects(t *testing.T) {
+	client := filepath.Join("client", "mods", "a.jar")
+	server := filepath.Join("server", "mods", "a.jar")
+
+	for _, c := range []struct {
+		name                   string
+		clientIgnore           []int64
+		serverIgnore           []int64
+		notCurseForge          bool
+		wantOK                 bool
+		wantPrimary, wantOther string
+	}{
+		{"NotIgnored", []int64{2}, []int64{3}, false, true, client, server},
+		{"IgnoredOnClient", []int64{1}, nil, false, true, server, ""},
+		{"IgnoredOnServer", nil, []int64{1}, false, true, client, ""},
+		{"IgnoredOnBoth", []int64{1}, []int64{1}, false, false, "", ""},
+		{"NotFromCurseForge", []int64{1}, []int64{1}, true, true, client, server},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			f := testVersionFile("a.jar")
+			if !c.notCurseForge {
+				f.CurseForge = &CurseForgeFile{Project: 1, File: 10}
+			}
+			pj, ok, err := f.PrecheckJob(PrecheckOptions{
+				ClientPath:                     "client",
+				ServerPath:                     "server",
+				ClientIgnoreCurseForgeProjects: c.clientIgnore,
+				ServerIgnoreCurseForgeProjects: c.serverIgnore,
+			})
+			if err != nil {
+				t.Fatalf("PrecheckJob() error = %v", err)
+			}
+			if ok != c.wantOK {
+				t.Fatalf("PrecheckJob() ok = %v, want %v", ok, c.wantOK)
+			}
+			if pj.DestinationPath != c.wantPrimary || pj.SecondaryDestinationPath != c.wantOther {
+				t.Errorf("destination paths = %q, %q, want %q, %q", pj.DestinationPath, pj.SecondaryDestinationPath, c.wantPrimary, c.wantOther)
+			}
+		})
+	}
+}
+
+func TestPrecheckJobModTime(t *testing.T) {
+	updated := time.Unix(1704067200, 0)
+	for _, c := range []struct {
+		name    string
+		updated Time
+		want    time.Time
+	}{
+		{"Updated", Time{updated}, updated},
+		{"Missing", Time{time.Unix(0, 0)}, time.Time{}},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			f := testVersionFile("a.jar")
+			f.Updated = c.updated
+			pj, ok, err := f.PrecheckJob(PrecheckOptions{ClientPath: "client"})
+			if err != nil || !ok {
+				t.Fatalf("PrecheckJob() = %v, %v", ok, err)
+			}
+			if !pj.ModTime.Equal(c.want) {
+				t.Errorf("file ModTime = %v, want %v", pj.ModTime, c.want)
+			}
+
+			a := ModpackArt{
+				ID:      3,
+				Type:    "square",
+				URL:     "https://example.com/art/icon.png",
+				SHA1:    hex.EncodeToString(testFileSHA1[:]),
+				Updated: c.updated,
+			}
+			if pj, err = a.PrecheckJob("art", ""); err != nil {
+				t.Fatalf("PrecheckJob() error = %v", err)
+			}
+			if !pj.ModTime.Equal(c.want) {
+				t.Errorf("art ModTime = %v, want %v", pj.ModTime, c.want)
+			}
+		})
+	}
+}
+
+// testLookupManifest returns a manifest with files for the client, the server, and both,
+// some of them optional.
+func testLookupManifest(t *testing.T) *ModpackVersionManifest {
+	t.Helper()
+	var m ModpackVersionManifest
+	if err := json.Unmarshal([]byte(`{"files": [
+		{"path": "./mods/", "name": "both.jar", "size": 1},
+		{"path": "./mods/", "name": "client.jar", "size": 10, "clientonly": true},
+		{"path": "./mods/", "name": "server.jar", "size": 100, "serveronly": true},
+		{"path": "./config/", "name": "variant.toml", "size": 1000, "clientonly": true},
+		{"path": "./config/", "name": "variant.toml", "size": 10000, "serveronly": true},
+		{"path": "./mods/", "name": "optional-on.jar", "size": 100000, "optional": true},
+		{"path": "./mods/", "name": "optional-off.jar", "size": 1000000, "optional": true, "default": false, "clientonly": true}
+	]}`), &m); err != nil {
+		t.Fatal(err)
+	}
+	return &m
+}
+
+// fileNames returns the manifest paths of the files.
+func fileNames(files []*ModpackVersionFile) []string {
+	names := make([]string, len(files))
+	for i, f := range files {
+		names[i] = f.ManifestPath()
+	}
+	return names
+}
+
+func TestModpackVersionManifestFileByPath(t *testing.T) {
+	m := testLookupManifest(t)
+	for _, rel := range []string{"mods/client.jar", "./mods/client.jar", "mods//client.jar"} {
+		f, ok := m.FileByPath(rel)
+		if !ok || f != &m.Files[1] {
+			t.Errorf("FileByPath(%q) = %v, %v, want the second file", rel, f, ok)
+		}
+	}
+	// The first of several files at the same path is returned.
+	if f, ok := m.FileByPath("config/variant.toml"); !ok || f != &m.Files[3] {
+		t.Errorf("FileByPath(%q) = %v, %v, want the client variant", "config/variant.toml", f, ok)
+	}
+	if f, ok := m.FileByPath("mods/missing.jar"); ok || f != nil {
+		t.Errorf("FileByPath(%q) = %v, %v, want nil, false", "mods/missing.jar", f, ok)
+	}
+}
+
+func TestModpackVersionManifestClientAndServerFiles(t *testing.T) {
+	m := testLookupManifest(t)
+	if got, want := fileNames(m.ClientFiles()), []string{"mods/both.jar", "mods/client.jar", "config/variant.toml", "mods/optional-on.jar"}; !slices.Equal(got, want) {
+		t.Errorf("ClientFiles() = %q, want %q", got, want)
+	}
+	server := m.ServerFiles()
+	if got, want := fileNames(server), []string{"mods/both.jar", "mods/server.jar", "config/variant.toml", "mods/optional-on.jar"}; !slices.Equal(got, want) {
+		t.Errorf("ServerFiles() = %q, want %q", got, want)
+	}
+	if server[2] != &m.Files[4] {
+		t.Error("ServerFiles() has the client variant of config/variant.toml")
+	}
+}
+
+func TestModpackVersionManifestTotalSize(t *testing.T) {
+	if got := testLookupManifest(t).TotalSize(); got != 1111111 {
+		t.Errorf("TotalSize() = %d, want 1111111", got)
+	}
+	if got := new(ModpackVersionManifest).TotalSize(); got != 0 {
+		t.Errorf("TotalSize() of an empty manifest = %d, want 0", got)
+	}
+}
+
+func TestCurseForgeNameVariants(t *testing.T) {
+	for _, c := range []struct {
+		name string
+		want []string
+	}{
+		{"a.jar", []string{"a.jar"}},
+		{"Some Mod.jar", []string{"Some%20Mod.jar", "Some+Mod.jar", "some%20mod.jar"}},
+		{"Mod [1.20] (Forge).jar", []string{"Mod%20%5B1.20%5D%20%28Forge%29.jar", "Mod+%5B1.20%5D+%28Forge%29.jar", "Mod%20[1.20]%20(Forge).jar", "mod%20%5B1.20%5D%20%28forge%29.jar"}},
+	} {
+		if got := curseForgeNameVariants(c.name); !slices.Equal(got, c.want) {
+			t.Errorf("curseForgeNameVariants(%q) = %q, want %q", c.name, got, c.want)
+		}
+	}
+}
+
+// hostRewritingTransport sends all requests to the test server at addr,
+// keeping the original host in the Host header.
+type hostRewritingTransport struct {
+	addr string
+}
+
+func (t hostRewritingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.Host = req.URL.Host
+	req.URL.Scheme = "http"
+	req.URL.Host = t.addr
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestCurseForgeDownloadFallsBackToPlusVariant(t *testing.T) {
+	const want = "edge.forgecdn.net/files/1234/5678901/Some+Mod.jar"
+	var (
+		mu        sync.Mutex
+		requested []string
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		u := r.Host + r.URL.EscapedPath()
+		mu.Lock()
+		requested = append(requested, u)
+		mu.Unlock()
+		if u != want {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write(testFileContent)
+	}))
+	defer srv.Close()
+
+	f := ModpackVersionFile{
+		Path:         "./mods/",
+		SHA1:         hex.EncodeToString(testFileSHA1[:]),
+		Size:         int64(len(testFileContent)),
+		ResourceBase: ResourceBase{Name: "Some Mod.jar"},
+		CurseForge:   &CurseForgeFile{Project: 1234, File: 5678901},
+	}
+	clientPath := t.TempDir()
+	pj, ok, err := f.PrecheckJob(PrecheckOptions{ClientPath: clientPath})
+	if err != nil || !ok {
+		t.Fatalf("PrecheckJob() = %v, %v", ok, err)
+	}
+
+	var logs bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&logs, nil))
+	pjch := make(chan precheck.Job, 1)
+	pjch <- pj
+	close(pjch)
+	pwf := precheck.NewWorkerFleet(context.Background(), logger, 1, pjch)
+	client := &http.Client{Transport: hostRewritingTransport{addr: srv.Listener.Addr().String()}}
+	dwf := download.NewWorkerFleet(context.Background(), logger, client, 1, pwf.DownloadJobChannel())
+	pwf.Wait()
+	dwf.Wait()
+
+	if dwf.Failures() != 0 {
+		t.Fatalf("download failures = %d, want 0, requested %q", dwf.Failures(), requested)
+	}
+	got, err := os.ReadFile(filepath.Join(clientPath, "mods", "Some Mod.jar"))
+	if err != nil || !bytes.Equal(got, testFileContent) {
+		t.Errorf("downloaded file = %q, %v, want %q", got, err, testFileContent)
+	}
+	// The escaped name is tried first.
+	if len(requested) < 2 || !strings.HasSuffix(requested[0], "/Some%20Mod.jar") || requested[len(requested)-1] != want {
+		t.Errorf("requested %q, want the escaped name first and %q last", requested, want)
+	}
+	if !strings.Contains(logs.String(), "Downloaded file from mirror") || !strings.Contains(logs.String(), "Some+Mod.jar") {
+		t.Error("the working variant was not logged")
+	}
+}
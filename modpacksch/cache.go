@@ -0,0 +1,111 @@
+package modpacksch
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Cache is an on-disk cache of raw API responses.
+//
+// Each response is stored in its own file under the cache directory, named after the request URL.
+// Fresh entries are used without contacting the API. Stale entries are revalidated with a
+// conditional request using the stored ETag and Last-Modified values.
+//
+// Failures to read or write cache entries are not fatal: the request is sent to the API as usual.
+type Cache struct {
+	dir string
+	ttl time.Duration
+}
+
+// NewCache returns a new cache that stores entries in dir.
+// Entries younger than ttl are considered fresh.
+func NewCache(dir string, ttl time.Duration) *Cache {
+	return &Cache{dir: dir, ttl: ttl}
+}
+
+// cacheEntry is a cached API response.
+type cacheEntry struct {
+	URL          string          `json:"url"`
+	ETag         string          `json:"etag,omitempty"`
+	LastModified string          `json:"lastModified,omitempty"`
+	Fetched      Time            `json:"fetched"`
+	Body         json.RawMessage `json:"body"`
+}
+
+// isFresh returns whether the entry may be used without revalidation.
+func (c *Cache) isFresh(entry *cacheEntry) bool {
+	return time.Since(entry.Fetched.Time) < c.ttl
+}
+
+// setConditionalHeaders sets the request headers for revalidating the entry.
+func (entry *cacheEntry) setConditionalHeaders(req *http.Request) {
+	if entry.ETag != "" {
+		req.Header["If-None-Match"] = []string{entry.ETag}
+	}
+	if entry.LastModified != "" {
+		req.Header["If-Modified-Since"] = []string{entry.LastModified}
+	}
+}
+
+// path returns the path of the cache file for the given URL.
+func (c *Cache) path(rawURL string) string {
+	name := rawURL
+	if u, err := url.Parse(rawURL); err == nil {
+		name = u.Host + u.EscapedPath()
+	}
+	name = strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '.', r == '-':
+			return r
+		default:
+			return '_'
+		}
+	}, name)
+	return filepath.Join(c.dir, name+".json")
+}
+
+// load returns the cache entry for the given URL, if any.
+func (c *Cache) load(rawURL string) (*cacheEntry, bool) {
+	b, err := os.ReadFile(c.path(rawURL))
+	if err != nil {
+		return nil, false
+	}
+
+	var entry cacheEntry
+	if err = json.Unmarshal(b, &entry); err != nil || entry.URL != rawURL {
+		return nil, false
+	}
+	return &entry, true
+}
+
+// store saves the cache entry, replacing any existing one for the same URL.
+func (c *Cache) store(entry *cacheEntry) error {
+	b, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	if err = os.MkdirAll(c.dir, 0755); err != nil {
+		return err
+	}
+
+	f, err := os.CreateTemp(c.dir, ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	if _, err = f.Write(b); err != nil {
+		f.Close()
+		return err
+	}
+	if err = f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), c.path(entry.URL))
+}
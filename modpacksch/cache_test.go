@@ -0,0 +1,80 @@
+package modpacksch
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const testETag = `"v1"`
+
+// newCachingServer returns a test server that serves a modpack manifest with an ETag,
+// and answers matching conditional requests with 304 Not Modified.
+func newCachingServer(t *testing.T, requests, revalidations *atomic.Int32) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		if r.Header.Get("If-None-Match") == testETag {
+			revalidations.Add(1)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header()["ETag"] = []string{testETag}
+		writeJSON(w, `{"id":42,"name":"Cached Pack"}`)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestCacheHitAvoidsRequest(t *testing.T) {
+	var requests, revalidations atomic.Int32
+	srv := newCachingServer(t, &requests, &revalidations)
+
+	c := NewPublicModpackClient(WithBaseURL(srv.URL), WithCache(NewCache(t.TempDir(), time.Hour)))
+	for i := range 2 {
+		m, err := c.GetModpackManifest(context.Background(), 42)
+		if err != nil {
+			t.Fatalf("GetModpackManifest() #%d error = %v", i+1, err)
+		}
+		if m.Name != "Cached Pack" {
+			t.Errorf("GetModpackManifest() #%d name = %q, want %q", i+1, m.Name, "Cached Pack")
+		}
+	}
+	if got := requests.Load(); got != 1 {
+		t.Errorf("requests = %d, want 1", got)
+	}
+}
+
+func TestCacheRevalidatesExpiredEntry(t *testing.T) {
+	var requests, revalidations atomic.Int32
+	srv := newCachingServer(t, &requests, &revalidations)
+
+	c := NewPublicModpackClient(WithBaseURL(srv.URL), WithCache(NewCache(t.TempDir(), time.Nanosecond)))
+	for i := range 2 {
+		m, err := c.GetModpackManifest(context.Background(), 42)
+		if err != nil {
+			t.Fatalf("GetModpackManifest() #%d error = %v", i+1, err)
+		}
+		if m.Name != "Cached Pack" {
+			t.Errorf("GetModpackManifest() #%d name = %q, want %q", i+1, m.Name, "Cached Pack")
+		}
+	}
+	if got := requests.Load(); got != 2 {
+		t.Errorf("requests = %d, want 2", got)
+	}
+	if got := revalidations.Load(); got != 1 {
+		t.Errorf("conditional requests = %d, want 1", got)
+	}
+}
+
+func TestCachePathIsPerURL(t *testing.T) {
+	c := NewCache(t.TempDir(), time.Hour)
+	a := c.path("https://api.modpacks.ch/public/modpack/1")
+	b := c.path("https://api.modpacks.ch/public/modpack/2")
+	if a == b {
+		t.Errorf("path() = %q for different URLs", a)
+	}
+}
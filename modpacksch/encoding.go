@@ -0,0 +1,57 @@
+package modpacksch
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// setAcceptGzip makes the request ask for a gzip-compressed response.
+//
+// [http.Transport] already does this transparently, but not other round trippers,
+// and manifests of modpacks with hundreds of versions compress well.
+// Setting the header stops the transport from decompressing the response,
+// which is then done by [decodeGzipBody].
+func setAcceptGzip(req *http.Request) {
+	req.Header["Accept-Encoding"] = []string{"gzip"}
+}
+
+// decodeGzipBody replaces the body of a gzip-compressed response with one that
+// decompresses it as it's read, and updates the headers to match, like [http.Transport] does.
+func decodeGzipBody(resp *http.Response) {
+	switch strings.ToLower(strings.TrimSpace(resp.Header.Get("Content-Encoding"))) {
+	case "gzip", "x-gzip":
+	default:
+		return
+	}
+	resp.Body = &gzipBody{body: resp.Body}
+	resp.Header.Del("Content-Encoding")
+	resp.Header.Del("Content-Length")
+	resp.ContentLength = -1
+	resp.Uncompressed = true
+}
+
+// gzipBody is a response body that is decompressed as it's read.
+// The gzip header is read lazily, so that empty bodies, e.g. of 304 responses, are not an error until read.
+type gzipBody struct {
+	body io.ReadCloser
+	zr   *gzip.Reader
+	err  error
+}
+
+// Read implements [io.Reader].
+func (b *gzipBody) Read(p []byte) (int, error) {
+	if b.zr == nil && b.err == nil {
+		b.zr, b.err = gzip.NewReader(b.body)
+	}
+	if b.err != nil {
+		return 0, b.err
+	}
+	return b.zr.Read(p)
+}
+
+// Close implements [io.Closer].
+func (b *gzipBody) Close() error {
+	return b.body.Close()
+}
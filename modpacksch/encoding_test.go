@@ -0,0 +1,255 @@
+package modpacksch
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// largeManifestJSON returns the JSON of a modpack manifest with the given number of versions.
+func largeManifestJSON(versions int) []byte {
+	var b bytes.Buffer
+	b.WriteString(`{"id": 42, "name": "Large Pack", "synopsis": "A pack with many versions", "status": "success", "versions": [`)
+	for i := range versions {
+		if i > 0 {
+			b.WriteString(",")
+		}
+		fmt.Fprintf(&b, `{"id": %d, "name": "1.%d.0", "type": "Release", "updated": %d, "private": false,`+
+			`"specs": {"id": %d, "minimum": 4096, "recommended": 6144},`+
+			`"targets": [{"id": 1, "name": "forge", "type": "modloader", "version": "47.2.%d", "updated": 1700000000},`+
+			`{"id": 2, "name": "minecraft", "type": "game", "version": "1.20.1", "updated": 1700000000}]}`,
+			i+1, i, 1700000000+i, i+1, i)
+	}
+	b.WriteString(`]}`)
+	return b.Bytes()
+}
+
+// gzipBytes returns b compressed with gzip.
+func gzipBytes(t testing.TB, b []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(b); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// newGzipServer returns a server that responds with body, compressed with gzip
+// if the request accepts it, and records the Accept-Encoding header of the last request.
+func newGzipServer(t testing.TB, body []byte, acceptEncoding *string) *httptest.Server {
+	t.Helper()
+	compressed := gzipBytes(t, body)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*acceptEncoding = r.Header.Get("Accept-Encoding")
+		w.Header()["Content-Type"] = []string{"application/json"}
+		if strings.Contains(*acceptEncoding, "gzip") {
+			w.Header()["Content-Encoding"] = []string{"gzip"}
+			_, _ = w.Write(compressed)
+			return
+		}
+		_, _ = w.Write(body)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// roundTripperFunc is an [http.RoundTripper] that is not an [http.Transport],
+// so responses are not decompressed for it.
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+// RoundTrip implements [http.RoundTripper.RoundTrip].
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestGetModpackManifestGzip(t *testing.T) {
+	const versions = 300
+	body := largeManifestJSON(versions)
+
+	for _, c := range []struct {
+		name   string
+		client *http.Client
+	}{
+		{"Transport", http.DefaultClient},
+		{"RoundTripper", &http.Client{Transport: roundTripperFunc(http.DefaultTransport.RoundTrip)}},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			var acceptEncoding string
+			srv := newGzipServer(t, body, &acceptEncoding)
+
+			m, err := NewPublicModpackClient(WithBaseURL(srv.URL), WithHTTPClient(c.client)).GetModpackManifest(context.Background(), 42)
+			if err != nil {
+				t.Fatalf("GetModpackManifest() error = %v", err)
+			}
+			if acceptEncoding != "gzip" {
+				t.Errorf("Accept-Encoding = %q, want %q", acceptEncoding, "gzip")
+			}
+			if m.ID != 42 || len(m.Versions) != versions {
+				t.Fatalf("got modpack %d with %d versions, want 42 with %d", m.ID, len(m.Versions), versions)
+			}
+			if last := m.Versions[versions-1]; last.ID != versions || last.Specs.Recommended != 6144 || len(last.Targets) != 2 {
+				t.Errorf("last version = %+v", last)
+			}
+		})
+	}
+}
+
+func TestGetModpackManifestUncompressed(t *testing.T) {
+	// Servers may ignore Accept-Encoding.
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header()["Content-Type"] = []string{"application/json"}
+		_, _ = w.Write([]byte(`{"id": 42}`))
+	}))
+	defer srv.Close()
+
+	m, err := NewPublicModpackClient(WithBaseURL(srv.URL)).GetModpackManifest(context.Background(), 42)
+	if err != nil {
+		t.Fatalf("GetModpackManifest() error = %v", err)
+	}
+	if m.ID != 42 {
+		t.Errorf("ID = %d, want 42", m.ID)
+	}
+}
+
+func TestGetModpackManifestCorruptGzip(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header()["Content-Type"] = []string{"application/json"}
+		w.Header()["Content-Encoding"] = []string{"gzip"}
+		_, _ = w.Write([]byte(`{"id": 42}`))
+	}))
+	defer srv.Close()
+
+	c := NewPublicModpackClient(WithBaseURL(srv.URL), WithRetryPolicy(RetryPolicy{MaxAttempts: 1}))
+	if _, err := c.GetModpackManifest(context.Background(), 42); !errors.Is(err, gzip.ErrHeader) {
+		t.Errorf("GetModpackManifest() error = %v, want %v", err, gzip.ErrHeader)
+	}
+}
+
+func TestMaxResponseSizeAppliesToDecompressedBody(t *testing.T) {
+	body := largeManifestJSON(100)
+	var acceptEncoding string
+	srv := newGzipServer(t, body, &acceptEncoding)
+	// The compressed body is well under the limit, but the decompressed body is not.
+	limit := int64(len(body) / 2)
+	if n := int64(len(gzipBytes(t, body))); n >= limit {
+		t.Fatalf("compressed body has %d bytes, want less than %d", n, limit)
+	}
+
+	_, err := NewPublicModpackClient(WithBaseURL(srv.URL), WithMaxResponseSize(limit)).GetModpackManifest(context.Background(), 42)
+	if !errors.Is(err, ErrResponseTooLarge) {
+		t.Errorf("GetModpackManifest() error = %v, want %v", err, ErrResponseTooLarge)
+	}
+}
+
+func TestDecodeGzipBody(t *testing.T) {
+	content := []byte(`{"id": 42}`)
+	for _, c := range []struct {
+		name     string
+		encoding string
+		body     []byte
+		want     []byte
+	}{
+		{"Gzip", "gzip", gzipBytes(t, content), content},
+		{"XGzip", " X-Gzip ", gzipBytes(t, content), content},
+		{"Identity", "", content, content},
+		{"Other", "br", content, content},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			resp := &http.Response{
+				Header:        http.Header{"Content-Length": {fmt.Sprint(len(c.body))}},
+				Body:          io.NopCloser(bytes.NewReader(c.body)),
+				ContentLength: int64(len(c.body)),
+			}
+			if c.encoding != "" {
+				resp.Header["Content-Encoding"] = []string{c.encoding}
+			}
+			decodeGzipBody(resp)
+			got, err := io.ReadAll(resp.Body)
+			if err != nil {
+				t.Fatalf("reading the body error = %v", err)
+			}
+			if !bytes.Equal(got, c.want) {
+				t.Errorf("body = %q, want %q", got, c.want)
+			}
+			if compressed := strings.Contains(strings.ToLower(c.encoding), "gzip"); compressed != resp.Uncompressed {
+				t.Errorf("Uncompressed = %t, want %t", resp.Uncompressed, compressed)
+			} else if compressed && (resp.ContentLength != -1 || resp.Header.Get("Content-Encoding") != "" || resp.Header.Get("Content-Length") != "") {
+				t.Errorf("headers of decompressed response = %v, content length %d", resp.Header, resp.ContentLength)
+			}
+		})
+	}
+}
+
+func TestGzipBodyEmptyUntilRead(t *testing.T) {
+	// A 304 response has no body, which is not an error unless it's read.
+	resp := &http.Response{
+		Header: http.Header{"Content-Encoding": {"gzip"}},
+		Body:   io.NopCloser(bytes.NewReader(nil)),
+	}
+	decodeGzipBody(resp)
+	if err := resp.Body.Close(); err != nil {
+		t.Errorf("Close() error = %v", err)
+	}
+}
+
+func BenchmarkGetModpackManifestLarge(b *testing.B) {
+	body := largeManifestJSON(1000)
+	for _, c := range []struct {
+		name     string
+		compress bool
+	}{
+		{"Gzip", true},
+		{"Identity", false},
+	} {
+		b.Run(c.name, func(b *testing.B) {
+			var srv *httptest.Server
+			if c.compress {
+				var acceptEncoding string
+				srv = newGzipServer(b, body, &acceptEncoding)
+			} else {
+				srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					w.Header()["Content-Type"] = []string{"application/json"}
+					_, _ = w.Write(body)
+				}))
+				defer srv.Close()
+			}
+			client := NewPublicModpackClient(WithBaseURL(srv.URL))
+			b.SetBytes(int64(len(body)))
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := client.GetModpackManifest(context.Background(), 42); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkDecodeLargeManifest(b *testing.B) {
+	// Decodes the manifest as it's streamed, as doGetRequestOnce does.
+	body := largeManifestJSON(1000)
+	b.SetBytes(int64(len(body)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var m ModpackManifest
+		dec := json.NewDecoder(bytes.NewReader(body))
+		dec.UseNumber()
+		if err := dec.Decode(&m); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,163 @@
+package modpacksch
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// apiErrorBodyLimit is the maximum number of bytes of the response body kept in an [APIError].
+const apiErrorBodyLimit = 4096
+
+// APIError is returned when the API responds with an unexpected status code.
+type APIError struct {
+	// StatusCode is the HTTP status code of the response.
+	StatusCode int
+
+	// URL is the URL of the request.
+	URL string
+
+	// Body is the beginning of the response body, for diagnostics.
+	// If the body was longer than the limit, it is cut off at a character boundary,
+	// and BodyTruncated is set.
+	Body string
+
+	// BodyTruncated is whether Body is only the beginning of the response body.
+	BodyTruncated bool
+
+	// Message is the error message in the response body, if the body is
+	// a JSON object with a "message" field, as returned by the API on errors.
+	Message string
+}
+
+// newAPIError returns a new [APIError] for the response to the request to url.
+// It reads up to [apiErrorBodyLimit] bytes of the response body, leaving closing it to the caller.
+func newAPIError(url string, resp *http.Response) *APIError {
+	b, truncated := readBodySnippet(resp)
+
+	var msg struct {
+		Message string `json:"message"`
+	}
+	if !truncated {
+		_ = json.Unmarshal(b, &msg)
+	}
+
+	return &APIError{
+		StatusCode:    resp.StatusCode,
+		URL:           url,
+		Body:          string(b),
+		BodyTruncated: truncated,
+		Message:       msg.Message,
+	}
+}
+
+// readBodySnippet reads up to [apiErrorBodyLimit] bytes of the response body,
+// leaving closing it to the caller. It returns the bytes read with surrounding whitespace
+// trimmed, and whether the body was longer than the limit.
+func readBodySnippet(resp *http.Response) ([]byte, bool) {
+	b, _ := io.ReadAll(io.LimitReader(resp.Body, apiErrorBodyLimit+1))
+
+	var truncated bool
+	if len(b) > apiErrorBodyLimit {
+		b = b[:apiErrorBodyLimit]
+		// Do not cut a character in half.
+		for i := 0; i < utf8.UTFMax && len(b) > 0; i++ {
+			if r, size := utf8.DecodeLastRune(b); r != utf8.RuneError || size != 1 {
+				break
+			}
+			b = b[:len(b)-1]
+		}
+		truncated = true
+	}
+	return bytes.TrimSpace(b), truncated
+}
+
+// Error implements [error].
+func (e *APIError) Error() string {
+	msg := "unexpected status code: " + strconv.Itoa(e.StatusCode) + " from " + e.URL
+	switch {
+	case e.Message != "":
+		msg += ": " + e.Message
+	case e.Body != "":
+		msg += ": " + e.Body
+		if e.BodyTruncated {
+			msg += "..."
+		}
+	}
+	return msg
+}
+
+// NotFoundError is returned when the requested resource, such as a modpack or version, does not exist.
+type NotFoundError struct {
+	*APIError
+}
+
+// Error implements [error].
+func (e *NotFoundError) Error() string {
+	return "not found: " + e.APIError.Error()
+}
+
+// Unwrap returns the underlying [APIError].
+func (e *NotFoundError) Unwrap() error {
+	return e.APIError
+}
+
+// ContentTypeError is returned when a successful API response is not JSON,
+// such as an HTML page served by a proxy or captive portal in place of the API.
+type ContentTypeError struct {
+	// ContentType is the value of the response's Content-Type header.
+	ContentType string
+
+	// URL is the URL of the request.
+	URL string
+
+	// Body is the beginning of the response body, for diagnostics.
+	// If the body was longer than the limit, it is cut off at a character boundary,
+	// and BodyTruncated is set.
+	Body string
+
+	// BodyTruncated is whether Body is only the beginning of the response body.
+	BodyTruncated bool
+}
+
+// newContentTypeError returns a new [ContentTypeError] for the response to the request to url.
+// It reads up to [apiErrorBodyLimit] bytes of the response body, leaving closing it to the caller.
+func newContentTypeError(url string, resp *http.Response) *ContentTypeError {
+	b, truncated := readBodySnippet(resp)
+	return &ContentTypeError{
+		ContentType:   resp.Header.Get("Content-Type"),
+		URL:           url,
+		Body:          string(b),
+		BodyTruncated: truncated,
+	}
+}
+
+// Error implements [error].
+func (e *ContentTypeError) Error() string {
+	msg := "unexpected content type " + strconv.Quote(e.ContentType) + " from " + e.URL + ", expected JSON"
+	if e.Body != "" {
+		msg += ": " + e.Body
+		if e.BodyTruncated {
+			msg += "..."
+		}
+	}
+	return msg
+}
+
+// isJSONContentType returns whether the media type in the Content-Type header value ct is JSON.
+// An empty value is accepted, as some servers omit the header.
+func isJSONContentType(ct string) bool {
+	if ct == "" {
+		return true
+	}
+	mediaType, _, err := mime.ParseMediaType(ct)
+	if err != nil {
+		return false
+	}
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
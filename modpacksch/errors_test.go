@@ -0,0 +1,195 @@
+package modpacksch
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestGetModpackManifestErrorTypes(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/public/modpack/404":
+			http.Error(w, "no such modpack", http.StatusNotFound)
+		default:
+			http.Error(w, "internal server error", http.StatusInternalServerError)
+		}
+	}))
+	defer srv.Close()
+	c := NewPublicModpackClient(WithBaseURL(srv.URL))
+
+	_, err := c.GetModpackManifest(context.Background(), 404)
+	var notFound *NotFoundError
+	if !errors.As(err, &notFound) {
+		t.Fatalf("GetModpackManifest() of missing modpack error = %v, want NotFoundError", err)
+	}
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("GetModpackManifest() of missing modpack error = %v, want APIError", err)
+	}
+	if apiErr.StatusCode != http.StatusNotFound {
+		t.Errorf("StatusCode = %d, want 404", apiErr.StatusCode)
+	}
+	if want := srv.URL + "/public/modpack/404"; apiErr.URL != want {
+		t.Errorf("URL = %q, want %q", apiErr.URL, want)
+	}
+	if apiErr.Body != "no such modpack" {
+		t.Errorf("Body = %q, want %q", apiErr.Body, "no such modpack")
+	}
+
+	_, err = c.GetModpackVersionManifest(context.Background(), 500, 1)
+	if errors.As(err, &notFound) {
+		t.Errorf("GetModpackVersionManifest() of failing server error = %v, want no NotFoundError", err)
+	}
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusInternalServerError {
+		t.Fatalf("GetModpackVersionManifest() of failing server error = %v, want APIError with status 500", err)
+	}
+	if !strings.Contains(err.Error(), "500") {
+		t.Errorf("Error() = %q, want it to mention the status code", err.Error())
+	}
+}
+
+func TestAPIErrorTruncatesBody(t *testing.T) {
+	// A multi-byte character straddles the limit.
+	body := strings.Repeat("a", apiErrorBodyLimit-1) + "é" + strings.Repeat("b", 100)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, body, http.StatusBadGateway)
+	}))
+	defer srv.Close()
+
+	_, err := NewPublicModpackClient(WithBaseURL(srv.URL)).GetModpackManifest(context.Background(), 1)
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("GetModpackManifest() error = %v, want APIError", err)
+	}
+	if !apiErr.BodyTruncated {
+		t.Error("BodyTruncated = false, want true")
+	}
+	if want := strings.Repeat("a", apiErrorBodyLimit-1); apiErr.Body != want {
+		t.Errorf("Body has %d bytes, want the %d bytes before the split character", len(apiErr.Body), len(want))
+	}
+	if !strings.HasSuffix(err.Error(), "...") {
+		t.Errorf("Error() does not mark the body as truncated: %q", err.Error()[len(err.Error())-10:])
+	}
+}
+
+func TestAPIErrorSurfacesJSONMessage(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header()["Content-Type"] = []string{"application/json"}
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte(`{"status": "error", "message": "Invalid modpack ID"}`))
+	}))
+	defer srv.Close()
+
+	_, err := NewPublicModpackClient(WithBaseURL(srv.URL)).GetModpackManifest(context.Background(), -1)
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("GetModpackManifest() error = %v, want APIError", err)
+	}
+	if apiErr.StatusCode != http.StatusBadRequest {
+		t.Errorf("StatusCode = %d, want 400", apiErr.StatusCode)
+	}
+	if apiErr.Message != "Invalid modpack ID" {
+		t.Errorf("Message = %q, want %q", apiErr.Message, "Invalid modpack ID")
+	}
+	if !strings.HasSuffix(err.Error(), ": Invalid modpack ID") {
+		t.Errorf("Error() = %q, want it to end with the message", err.Error())
+	}
+}
+
+func TestMaxResponseSize(t *testing.T) {
+	body := `{"id": 42, "name": "` + strings.Repeat("x", 10000) + `"}`
+	for _, c := range []struct {
+		name          string
+		maxSize       int64
+		contentLength bool
+		wantErr       bool
+	}{
+		{"ContentLength", 1000, true, true},
+		{"Chunked", 1000, false, true},
+		{"ExactlyAtLimit", int64(len(body)), false, false},
+		{"Unlimited", 0, true, false},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header()["Content-Type"] = []string{"application/json"}
+				if !c.contentLength {
+					// Flushing before writing prevents the server from setting Content-Length.
+					w.(http.Flusher).Flush()
+				}
+				_, _ = w.Write([]byte(body))
+			}))
+			defer srv.Close()
+
+			m, err := NewPublicModpackClient(WithBaseURL(srv.URL), WithMaxResponseSize(c.maxSize)).GetModpackManifest(context.Background(), 42)
+			if c.wantErr {
+				if !errors.Is(err, ErrResponseTooLarge) {
+					t.Errorf("GetModpackManifest() error = %v, want %v", err, ErrResponseTooLarge)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("GetModpackManifest() error = %v", err)
+			}
+			if m.ID != 42 {
+				t.Errorf("ID = %d, want 42", m.ID)
+			}
+		})
+	}
+}
+
+func TestGetModpackManifestRejectsHTML(t *testing.T) {
+	const page = "<html><body>Please log in to continue</body></html>"
+	var accept string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		accept = r.Header.Get("Accept")
+		w.Header()["Content-Type"] = []string{"text/html; charset=utf-8"}
+		_, _ = w.Write([]byte(page))
+	}))
+	defer srv.Close()
+
+	_, err := NewPublicModpackClient(WithBaseURL(srv.URL)).GetModpackManifest(context.Background(), 42)
+	if accept != "application/json" {
+		t.Errorf("Accept = %q, want %q", accept, "application/json")
+	}
+	var ctErr *ContentTypeError
+	if !errors.As(err, &ctErr) {
+		t.Fatalf("GetModpackManifest() error = %v, want ContentTypeError", err)
+	}
+	if ctErr.ContentType != "text/html; charset=utf-8" {
+		t.Errorf("ContentType = %q, want %q", ctErr.ContentType, "text/html; charset=utf-8")
+	}
+	if want := srv.URL + "/public/modpack/42"; ctErr.URL != want {
+		t.Errorf("URL = %q, want %q", ctErr.URL, want)
+	}
+	if ctErr.Body != page || ctErr.BodyTruncated {
+		t.Errorf("Body = %q, truncated %t, want %q, not truncated", ctErr.Body, ctErr.BodyTruncated, page)
+	}
+	if msg := err.Error(); !strings.Contains(msg, "text/html") || !strings.Contains(msg, "Please log in") {
+		t.Errorf("Error() = %q, want it to mention the content type and the body", msg)
+	}
+}
+
+func TestIsJSONContentType(t *testing.T) {
+	for _, c := range []struct {
+		ct   string
+		want bool
+	}{
+		{"", true},
+		{"application/json", true},
+		{"application/json; charset=utf-8", true},
+		{"Application/JSON", true},
+		{"application/problem+json", true},
+		{"text/html", false},
+		{"text/plain; charset=utf-8", false},
+		{"application/javascript", false},
+		{"invalid;;", false},
+	} {
+		if got := isJSONContentType(c.ct); got != c.want {
+			t.Errorf("isJSONContentType(%q) = %t, want %t", c.ct, got, c.want)
+		}
+	}
+}
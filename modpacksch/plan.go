@@ -0,0 +1,166 @@
+package modpacksch
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/database64128/modpack-dl-go/precheck"
+)
+
+// ErrConflictingPath is returned for files with the same destination path
+// as another file in the manifest, but a different expected sum.
+var ErrConflictingPath = errors.New("destination path is shared with another file with different content")
+
+// DestinationSet records the destination paths of the precheck jobs planned for a version manifest,
+// to catch files that would be written to the same path by concurrent jobs.
+//
+// The zero value is ready for use.
+type DestinationSet struct {
+	files map[string]*ModpackVersionFile
+}
+
+// Add records the destination paths of the job planned for the file.
+//
+// If either path has already been recorded for an earlier file, nothing is recorded,
+// and the earlier file is returned. The job is then a duplicate, and must not be run.
+// If the files have different expected sums, [ErrConflictingPath] is also returned.
+func (s *DestinationSet) Add(f *ModpackVersionFile, pj *precheck.Job) (*ModpackVersionFile, error) {
+	for _, path := range [...]string{pj.DestinationPath, pj.SecondaryDestinationPath} {
+		if path == "" {
+			continue
+		}
+		prev, ok := s.files[path]
+		if !ok {
+			continue
+		}
+		if !sameSum(prev, f) {
+			return prev, fmt.Errorf("%w: %s at %s", ErrConflictingPath, prev.ManifestPath(), path)
+		}
+		return prev, nil
+	}
+
+	if s.files == nil {
+		s.files = make(map[string]*ModpackVersionFile)
+	}
+	s.files[pj.DestinationPath] = f
+	if pj.SecondaryDestinationPath != "" {
+		s.files[pj.SecondaryDestinationPath] = f
+	}
+	return nil, nil
+}
+
+// sameSum returns whether the files have the same expected sum, as returned by
+// [ModpackVersionFile.ExpectedSum]. If only one of them has a SHA-256 sum,
+// their SHA-1 sums are compared instead, and must not be empty.
+func sameSum(a, b *ModpackVersionFile) bool {
+	if (a.SHA256 == "") == (b.SHA256 == "") {
+		return strings.EqualFold(a.ExpectedSum(), b.ExpectedSum())
+	}
+	return a.SHA1 != "" && strings.EqualFold(a.SHA1, b.SHA1)
+}
+
+// PrecheckOptions holds the arguments to [ModpackVersionFile.PrecheckJob]
+// shared by all files of a version manifest.
+type PrecheckOptions struct {
+	// MigrateFromPath is the path of an existing installation to migrate files from.
+	// Empty means no migration.
+	MigrateFromPath string
+
+	// ClientPath is the path to install the client to. Empty means the client is not installed.
+	ClientPath string
+
+	// ServerPath is the path to install the server to. Empty means the server is not installed.
+	ServerPath string
+
+	// ClientIgnoreCurseForgeProjects is the list of CurseForge project IDs
+	// whose files are not installed to the client path.
+	ClientIgnoreCurseForgeProjects []int64
+
+	// ServerIgnoreCurseForgeProjects is the list of CurseForge project IDs
+	// whose files are not installed to the server path.
+	ServerIgnoreCurseForgeProjects []int64
+
+	// MigrationMode controls how files are migrated from MigrateFromPath.
+	MigrationMode precheck.MigrationMode
+
+	// UserAgent is the user agent to download files with. Empty means [APIUserAgent].
+	UserAgent string
+
+	// MapPath maps files to their destination paths. Nil means [DefaultPathMapper].
+	MapPath PathMapper
+
+	// MaxSize, if positive, is the maximum size of files to download.
+	MaxSize int64
+
+	// Select, if not nil, is called by [BuildPrecheckJobs] on each file before its job is created.
+	// Files it returns false for are left out. It may modify the file, such as to resolve its download URL.
+	Select func(f *ModpackVersionFile) bool
+
+	// Planned, if not nil, is called by [BuildPrecheckJobs] on each selected file once its job
+	// has been planned. It may modify the job before it is returned. Returning false stops planning,
+	// and leaves out the file and the rest of the manifest.
+	Planned func(pf *PlannedFile) bool
+}
+
+// PlannedFile is a file of a version manifest, and the outcome of planning its precheck job.
+type PlannedFile struct {
+	// File is the file in the version manifest.
+	File *ModpackVersionFile
+
+	// Job is the precheck job planned for the file.
+	// It is only valid if OK is true and Err is nil.
+	Job precheck.Job
+
+	// OK is false if the file is not mapped to any destination path.
+	OK bool
+
+	// Err is the error creating the job, or [ErrConflictingPath].
+	Err error
+
+	// Previous, if not nil, is the earlier file with the same destination path.
+	// The job is then a duplicate, and is left out.
+	Previous *ModpackVersionFile
+}
+
+// BuildPrecheckJobs returns the precheck jobs for the files of the version manifest,
+// in manifest order, without running them.
+//
+// Files that are not mapped to any destination path are left out. Files whose jobs
+// cannot be created are left out too, and the errors are returned, each prefixed
+// with the file's path in the manifest.
+//
+// Files with the same destination path as an earlier file are left out as duplicates.
+// If their sums differ, [ErrConflictingPath] is also returned for them.
+func BuildPrecheckJobs(vm *ModpackVersionManifest, opts PrecheckOptions) ([]precheck.Job, []error) {
+	var (
+		jobs = make([]precheck.Job, 0, len(vm.Files))
+		errs []error
+		dsts DestinationSet
+	)
+	for i := range vm.Files {
+		f := &vm.Files[i]
+		if opts.Select != nil && !opts.Select(f) {
+			continue
+		}
+
+		pf := PlannedFile{File: f}
+		pf.Job, pf.OK, pf.Err = f.PrecheckJob(opts)
+		if pf.Err == nil && pf.OK {
+			pf.Previous, pf.Err = dsts.Add(f, &pf.Job)
+		}
+		if opts.Planned != nil && !opts.Planned(&pf) {
+			break
+		}
+
+		if pf.Err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", f.ManifestPath(), pf.Err))
+			continue
+		}
+		if !pf.OK || pf.Previous != nil {
+			continue
+		}
+		jobs = append(jobs, pf.Job)
+	}
+	return jobs, errs
+}
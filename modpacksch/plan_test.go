@@ -0,0 +1,288 @@
+package modpacksch
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/database64128/modpack-dl-go/precheck"
+)
+
+// testFileSHA1Hex is the hex-encoded SHA-1 sum of testFileContent.
+var testFileSHA1Hex = hex.EncodeToString(testFileSHA1[:])
+
+// testPlanManifest returns a manifest with files for the client, the server, and both,
+// and files whose precheck jobs cannot be created.
+func testPlanManifest(t *testing.T) *ModpackVersionManifest {
+	t.Helper()
+	var m ModpackVersionManifest
+	if err := json.Unmarshal([]byte(`{"files": [
+		{"path": "./mods/", "name": "both.jar", "url": "https://example.com/both.jar", "sha1": "`+testFileSHA1Hex+`", "size": 1},
+		{"path": "./mods/", "name": "client.jar", "url": "https://example.com/client.jar", "sha1": "`+testFileSHA1Hex+`", "size": 1, "clientonly": true},
+		{"path": "./mods/", "name": "server.jar", "url": "https://example.com/server.jar", "sha1": "`+testFileSHA1Hex+`", "size": 1, "serveronly": true},
+		{"path": "./mods/", "name": "bad-sum.jar", "url": "https://example.com/bad-sum.jar", "sha1": "not hex", "size": 1},
+		{"path": "./../", "name": "escape.jar", "url": "https://example.com/escape.jar", "sha1": "`+testFileSHA1Hex+`", "size": 1},
+		{"path": "./mods/", "name": "huge.jar", "url": "https://example.com/huge.jar", "sha1": "`+testFileSHA1Hex+`", "size": 1000000}
+	]}`), &m); err != nil {
+		t.Fatal(err)
+	}
+	return &m
+}
+
+func TestBuildPrecheckJobs(t *testing.T) {
+	for _, c := range []struct {
+		name      string
+		opts      PrecheckOptions
+		wantPaths []string
+	}{
+		{
+			"Client",
+			PrecheckOptions{ClientPath: "client", MaxSize: 1000},
+			[]string{filepath.Join("client", "mods", "both.jar"), filepath.Join("client", "mods", "client.jar")},
+		},
+		{
+			"Server",
+			PrecheckOptions{ServerPath: "server", MaxSize: 1000},
+			[]string{filepath.Join("server", "mods", "both.jar"), filepath.Join("server", "mods", "server.jar")},
+		},
+		{
+			"Both",
+			PrecheckOptions{ClientPath: "client", ServerPath: "server", MaxSize: 1000},
+			[]string{filepath.Join("client", "mods", "both.jar"), filepath.Join("client", "mods", "client.jar"), filepath.Join("server", "mods", "server.jar")},
+		},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			jobs, errs := BuildPrecheckJobs(testPlanManifest(t), c.opts)
+			if len(jobs) != len(c.wantPaths) {
+				t.Fatalf("got %d jobs, want %d", len(jobs), len(c.wantPaths))
+			}
+			for i, pj := range jobs {
+				if pj.DestinationPath != c.wantPaths[i] {
+					t.Errorf("jobs[%d].DestinationPath = %q, want %q", i, pj.DestinationPath, c.wantPaths[i])
+				}
+			}
+			if c.name == "Both" {
+				if want := filepath.Join("server", "mods", "both.jar"); jobs[0].SecondaryDestinationPath != want {
+					t.Errorf("jobs[0].SecondaryDestinationPath = %q, want %q", jobs[0].SecondaryDestinationPath, want)
+				}
+			}
+
+			// Each file without a job has one error, prefixed with its path in the manifest.
+			if len(errs) != 3 {
+				t.Fatalf("got %d errors, want 3: %v", len(errs), errs)
+			}
+			for i, prefix := range []string{"mods/bad-sum.jar: ", "../escape.jar: ", "mods/huge.jar: "} {
+				if !strings.HasPrefix(errs[i].Error(), prefix) {
+					t.Errorf("errs[%d] = %q, want prefix %q", i, errs[i], prefix)
+				}
+			}
+			if !errors.Is(errs[1], ErrPathSanitization) {
+				t.Errorf("errs[1] = %v, want %v", errs[1], ErrPathSanitization)
+			}
+		})
+	}
+}
+
+func TestBuildPrecheckJobsIgnoresCurseForgeProjects(t *testing.T) {
+	var m ModpackVersionManifest
+	if err := json.Unmarshal([]byte(`{"files": [
+		{"path": "./mods/", "name": "a.jar", "url": "https://example.com/a.jar", "sha1": "`+testFileSHA1Hex+`", "size": 1, "curseforge": {"project": 1, "file": 10}},
+		{"path": "./mods/", "name": "b.jar", "url": "https://example.com/b.jar", "sha1": "`+testFileSHA1Hex+`", "size": 1, "curseforge": {"project": 2, "file": 20}}
+	]}`), &m); err != nil {
+		t.Fatal(err)
+	}
+
+	jobs, errs := BuildPrecheckJobs(&m, PrecheckOptions{
+		ClientPath:                     "client",
+		ClientIgnoreCurseForgeProjects: []int64{1},
+	})
+	if len(errs) != 0 {
+		t.Errorf("BuildPrecheckJobs() errors = %v", errs)
+	}
+	if len(jobs) != 1 || jobs[0].DestinationPath != filepath.Join("client", "mods", "b.jar") {
+		t.Errorf("BuildPrecheckJobs() = %+v, want only the job for b.jar", jobs)
+	}
+}
+
+func TestBuildPrecheckJobsEmptyManifest(t *testing.T) {
+	jobs, errs := BuildPrecheckJobs(&ModpackVersionManifest{}, PrecheckOptions{ClientPath: "client"})
+	if len(jobs) != 0 || len(errs) != 0 {
+		t.Errorf("BuildPrecheckJobs() = %d jobs, %v, want none", len(jobs), errs)
+	}
+}
+
+func TestDestinationSetAdd(t *testing.T) {
+	otherSHA1Hex := strings.Repeat("ff", len(testFileSHA1))
+	newFile := func(name, sha1 string) *ModpackVersionFile {
+		var f ModpackVersionFile
+		f.Path = "./mods/"
+		f.Name = name
+		f.SHA1 = sha1
+		return &f
+	}
+
+	var dsts DestinationSet
+	first := newFile("a.jar", testFileSHA1Hex)
+	both := precheck.Job{DestinationPath: filepath.Join("client", "mods", "a.jar"), SecondaryDestinationPath: filepath.Join("server", "mods", "a.jar")}
+	if prev, err := dsts.Add(first, &both); prev != nil || err != nil {
+		t.Fatalf("Add() = %v, %v, want nil, nil for the first file", prev, err)
+	}
+
+	for _, c := range []struct {
+		name     string
+		file     *ModpackVersionFile
+		job      precheck.Job
+		wantPrev *ModpackVersionFile
+		wantErr  error
+	}{
+		{
+			"OtherPath",
+			newFile("b.jar", otherSHA1Hex),
+			precheck.Job{DestinationPath: filepath.Join("client", "mods", "b.jar")},
+			nil, nil,
+		},
+		{
+			"SamePathSameHash",
+			newFile("a.jar", strings.ToUpper(testFileSHA1Hex)),
+			precheck.Job{DestinationPath: filepath.Join("client", "mods", "a.jar")},
+			first, nil,
+		},
+		{
+			"SamePathDifferentHash",
+			newFile("a.jar", otherSHA1Hex),
+			precheck.Job{DestinationPath: filepath.Join("client", "mods", "a.jar")},
+			first, ErrConflictingPath,
+		},
+		{
+			"SameSecondaryPath",
+			newFile("a.jar", otherSHA1Hex),
+			precheck.Job{DestinationPath: filepath.Join("server", "mods", "a.jar")},
+			first, ErrConflictingPath,
+		},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			prev, err := dsts.Add(c.file, &c.job)
+			if prev != c.wantPrev {
+				t.Errorf("Add() previous file = %v, want %v", prev, c.wantPrev)
+			}
+			if !errors.Is(err, c.wantErr) || (err == nil) != (c.wantErr == nil) {
+				t.Errorf("Add() error = %v, want %v", err, c.wantErr)
+			}
+		})
+	}
+}
+
+func TestDestinationSetAddComparesExpectedSum(t *testing.T) {
+	sha256Hex := strings.Repeat("aa", 32)
+	otherSHA256Hex := strings.Repeat("bb", 32)
+	otherSHA1Hex := strings.Repeat("ff", len(testFileSHA1))
+	newFile := func(sha1, sha256 string) *ModpackVersionFile {
+		var f ModpackVersionFile
+		f.Path = "./mods/"
+		f.Name = "a.jar"
+		f.SHA1 = sha1
+		f.SHA256 = sha256
+		return &f
+	}
+
+	for _, c := range []struct {
+		name        string
+		first, next *ModpackVersionFile
+		wantErr     error
+	}{
+		{"SHA256OnlySame", newFile("", sha256Hex), newFile("", strings.ToUpper(sha256Hex)), nil},
+		{"SHA256OnlyDifferent", newFile("", sha256Hex), newFile("", otherSHA256Hex), ErrConflictingPath},
+		{"SHA256DifferentSameSHA1", newFile(testFileSHA1Hex, sha256Hex), newFile(testFileSHA1Hex, otherSHA256Hex), ErrConflictingPath},
+		{"OneSHA256SameSHA1", newFile(testFileSHA1Hex, sha256Hex), newFile(testFileSHA1Hex, ""), nil},
+		{"OneSHA256DifferentSHA1", newFile(testFileSHA1Hex, sha256Hex), newFile(otherSHA1Hex, ""), ErrConflictingPath},
+		{"OneSHA256NoSHA1", newFile("", sha256Hex), newFile("", ""), ErrConflictingPath},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			var dsts DestinationSet
+			pj := precheck.Job{DestinationPath: filepath.Join("client", "mods", "a.jar")}
+			if prev, err := dsts.Add(c.first, &pj); prev != nil || err != nil {
+				t.Fatalf("Add() = %v, %v, want nil, nil for the first file", prev, err)
+			}
+			prev, err := dsts.Add(c.next, &pj)
+			if prev != c.first {
+				t.Errorf("Add() previous file = %v, want %v", prev, c.first)
+			}
+			if !errors.Is(err, c.wantErr) || (err == nil) != (c.wantErr == nil) {
+				t.Errorf("Add() error = %v, want %v", err, c.wantErr)
+			}
+		})
+	}
+}
+
+func TestBuildPrecheckJobsCollidingFiles(t *testing.T) {
+	otherSHA1Hex := strings.Repeat("ff", len(testFileSHA1))
+	var m ModpackVersionManifest
+	if err := json.Unmarshal([]byte(`{"files": [
+		{"path": "./mods/", "name": "a.jar", "url": "https://example.com/1/a.jar", "sha1": "`+testFileSHA1Hex+`", "size": 1},
+		{"path": "./mods/", "name": "a.jar", "url": "https://example.com/2/a.jar", "sha1": "`+testFileSHA1Hex+`", "size": 1},
+		{"path": "./mods/", "name": "b.jar", "url": "https://example.com/1/b.jar", "sha1": "`+testFileSHA1Hex+`", "size": 1},
+		{"path": "./mods/", "name": "b.jar", "url": "https://example.com/2/b.jar", "sha1": "`+otherSHA1Hex+`", "size": 1}
+	]}`), &m); err != nil {
+		t.Fatal(err)
+	}
+
+	jobs, errs := BuildPrecheckJobs(&m, PrecheckOptions{ClientPath: "client"})
+
+	// The first file at each path is kept, and the duplicate of a.jar is skipped silently.
+	wantURLs := []string{"https://example.com/1/a.jar", "https://example.com/1/b.jar"}
+	if len(jobs) != len(wantURLs) {
+		t.Fatalf("got %d jobs, want %d", len(jobs), len(wantURLs))
+	}
+	for i, pj := range jobs {
+		if pj.DownloadURL != wantURLs[i] {
+			t.Errorf("jobs[%d].DownloadURL = %q, want %q", i, pj.DownloadURL, wantURLs[i])
+		}
+	}
+
+	// The conflicting b.jar is reported.
+	if len(errs) != 1 {
+		t.Fatalf("got %d errors, want 1: %v", len(errs), errs)
+	}
+	if !errors.Is(errs[0], ErrConflictingPath) {
+		t.Errorf("error = %v, want %v", errs[0], ErrConflictingPath)
+	}
+	if !strings.HasPrefix(errs[0].Error(), "mods/b.jar: ") {
+		t.Errorf("error = %q, want it prefixed with the file's manifest path", errs[0])
+	}
+}
+
+func TestBuildPrecheckJobsHooks(t *testing.T) {
+	var planned []string
+	jobs, errs := BuildPrecheckJobs(testPlanManifest(t), PrecheckOptions{
+		ClientPath: "client",
+		MaxSize:    1000,
+		Select: func(f *ModpackVersionFile) bool {
+			return f.Name != "client.jar"
+		},
+		Planned: func(pf *PlannedFile) bool {
+			planned = append(planned, pf.File.Name)
+			pf.Job.NoClobber = true
+			// Stop at the first file whose job cannot be created.
+			return pf.Err == nil
+		},
+	})
+
+	// server.jar is not mapped to the client path, but is still passed to Planned.
+	wantPlanned := []string{"both.jar", "server.jar", "bad-sum.jar"}
+	if !slices.Equal(planned, wantPlanned) {
+		t.Errorf("planned files = %q, want %q", planned, wantPlanned)
+	}
+	if len(jobs) != 1 || jobs[0].DestinationPath != filepath.Join("client", "mods", "both.jar") {
+		t.Fatalf("BuildPrecheckJobs() = %+v, want only the job for both.jar", jobs)
+	}
+	if !jobs[0].NoClobber {
+		t.Error("jobs[0].NoClobber = false, want the job as modified by Planned")
+	}
+	if len(errs) != 0 {
+		t.Errorf("BuildPrecheckJobs() errors = %v, want none after stopping", errs)
+	}
+}
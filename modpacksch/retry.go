@@ -0,0 +1,105 @@
+package modpacksch
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RetryPolicy controls how failed API requests are retried.
+//
+// A request is retried when it fails with a network error, or when the server
+// responds with 429, 500, 502, 503, or 504. The delay between attempts starts
+// at BaseDelay and doubles after each attempt, up to MaxDelay.
+//
+// The zero value disables retries.
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of attempts, including the first one.
+	// Values less than 2 disable retries.
+	MaxAttempts int
+
+	// BaseDelay is the delay before the first retry.
+	BaseDelay time.Duration
+
+	// MaxDelay is the maximum delay between attempts.
+	// If zero, the delay is not capped.
+	//
+	// A delay requested by the server via the Retry-After header
+	// is also capped by MaxDelay.
+	MaxDelay time.Duration
+}
+
+// DefaultRetryPolicy is a reasonable retry policy for interactive use.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 5,
+	BaseDelay:   time.Second,
+	MaxDelay:    30 * time.Second,
+}
+
+// delay returns the delay after the given attempt, which starts at 1.
+func (p *RetryPolicy) delay(attempt int, retryAfter time.Duration) time.Duration {
+	d := p.BaseDelay
+	for i := 1; i < attempt && (p.MaxDelay == 0 || d < p.MaxDelay); i++ {
+		d *= 2
+	}
+	d = max(d, retryAfter)
+	if p.MaxDelay > 0 {
+		d = min(d, p.MaxDelay)
+	}
+	return d
+}
+
+// wait blocks until it's time for the next attempt, or the context is canceled.
+// It returns the context's error if the context is canceled.
+func (p *RetryPolicy) wait(ctx context.Context, attempt int, retryAfter time.Duration) error {
+	timer := time.NewTimer(p.delay(attempt, retryAfter))
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// isRetryableStatusCode returns whether a request that received the given status code may be retried.
+func isRetryableStatusCode(statusCode int) bool {
+	switch statusCode {
+	case http.StatusTooManyRequests,
+		http.StatusInternalServerError,
+		http.StatusBadGateway,
+		http.StatusServiceUnavailable,
+		http.StatusGatewayTimeout:
+		return true
+	default:
+		return false
+	}
+}
+
+// retryAfterFromResponse returns the delay requested by the Retry-After header of a 429 response.
+// It returns 0 if the header is absent or malformed.
+func retryAfterFromResponse(resp *http.Response) time.Duration {
+	if resp.StatusCode != http.StatusTooManyRequests {
+		return 0
+	}
+
+	retryAfter := resp.Header.Get("Retry-After")
+	if retryAfter == "" {
+		return 0
+	}
+
+	if secs, err := strconv.ParseInt(retryAfter, 10, 64); err == nil {
+		if secs < 0 {
+			return 0
+		}
+		return time.Duration(secs) * time.Second
+	}
+
+	if t, err := http.ParseTime(retryAfter); err == nil {
+		return max(time.Until(t), 0)
+	}
+
+	return 0
+}
@@ -0,0 +1,109 @@
+package modpacksch
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// testRetryPolicy retries quickly, so that tests don't wait for real backoff delays.
+var testRetryPolicy = RetryPolicy{
+	MaxAttempts: 3,
+	BaseDelay:   time.Millisecond,
+	MaxDelay:    10 * time.Millisecond,
+}
+
+// writeJSON writes body as a JSON response.
+func writeJSON(w http.ResponseWriter, body string) {
+	w.Header()["Content-Type"] = []string{"application/json"}
+	_, _ = w.Write([]byte(body))
+}
+
+func TestGetModpackManifestRetriesTransientFailures(t *testing.T) {
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) <= 2 {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		writeJSON(w, `{"id":42,"name":"Example Pack","versions":[{"id":100,"name":"1.0.0"}]}`)
+	}))
+	defer srv.Close()
+
+	c := NewPublicModpackClient(WithBaseURL(srv.URL), WithRetryPolicy(testRetryPolicy))
+	m, err := c.GetModpackManifest(context.Background(), 42)
+	if err != nil {
+		t.Fatalf("GetModpackManifest() error = %v", err)
+	}
+	if m.ID != 42 || m.Name != "Example Pack" || len(m.Versions) != 1 || m.Versions[0].ID != 100 {
+		t.Errorf("GetModpackManifest() = %+v, want decoded manifest of modpack 42", m)
+	}
+	if got := requests.Load(); got != 3 {
+		t.Errorf("requests = %d, want 3", got)
+	}
+}
+
+func TestGetModpackManifestGivesUpAfterMaxAttempts(t *testing.T) {
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	c := NewPublicModpackClient(WithBaseURL(srv.URL), WithRetryPolicy(testRetryPolicy))
+	if _, err := c.GetModpackManifest(context.Background(), 42); err == nil {
+		t.Fatal("GetModpackManifest() error = nil, want error")
+	}
+	if got := requests.Load(); got != int32(testRetryPolicy.MaxAttempts) {
+		t.Errorf("requests = %d, want %d", got, testRetryPolicy.MaxAttempts)
+	}
+}
+
+func TestGetModpackManifestRetryRespectsCancellation(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	c := NewPublicModpackClient(WithBaseURL(srv.URL), WithRetryPolicy(RetryPolicy{
+		MaxAttempts: 5,
+		BaseDelay:   time.Hour,
+	}))
+	start := time.Now()
+	_, err := c.GetModpackManifest(ctx, 42)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("GetModpackManifest() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("GetModpackManifest() took %v after cancellation", elapsed)
+	}
+}
+
+func TestRetryPolicyDelay(t *testing.T) {
+	p := RetryPolicy{BaseDelay: time.Second, MaxDelay: 5 * time.Second}
+	for _, c := range []struct {
+		attempt    int
+		retryAfter time.Duration
+		want       time.Duration
+	}{
+		{1, 0, time.Second},
+		{2, 0, 2 * time.Second},
+		{3, 0, 4 * time.Second},
+		{4, 0, 5 * time.Second},
+		{10, 0, 5 * time.Second},
+		{1, 3 * time.Second, 3 * time.Second},
+		{1, time.Minute, 5 * time.Second},
+	} {
+		if got := p.delay(c.attempt, c.retryAfter); got != c.want {
+			t.Errorf("delay(%d, %v) = %v, want %v", c.attempt, c.retryAfter, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package modpacksch
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+)
+
+// newUpdateServer returns a test server that serves a public modpack whose versions 1, 2 and 3
+// are listed oldest first, and a CurseForge modpack whose versions 30, 20 and 10 are listed newest first.
+func newUpdateServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case APIPublicModpack + "/42":
+			writeJSON(w, `{"id":42,"provider":"modpacks.ch","versions":[{"id":1},{"id":2},{"id":3}]}`)
+		case APIPublicCurseForge + "/42":
+			writeJSON(w, `{"id":42,"provider":"curseforge","versions":[{"id":30},{"id":20},{"id":10}]}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// versionIDs returns the IDs of the versions.
+func versionIDs(versions []ModpackVersion) []int64 {
+	ids := make([]int64, len(versions))
+	for i, v := range versions {
+		ids[i] = v.ID
+	}
+	return ids
+}
+
+func TestCheckForUpdate(t *testing.T) {
+	srv := newUpdateServer(t)
+	public := NewPublicModpackClient(WithBaseURL(srv.URL))
+	curseForge := NewCurseForgeModpackClient(WithBaseURL(srv.URL))
+
+	for _, c := range []struct {
+		name      string
+		client    ModpackClient
+		currentID int64
+		want      []int64
+	}{
+		{"Public/Oldest", public, 1, []int64{3, 2}},
+		{"Public/Middle", public, 2, []int64{3}},
+		{"Public/Latest", public, 3, []int64{}},
+		{"CurseForge/Oldest", curseForge, 10, []int64{30, 20}},
+		{"CurseForge/Middle", curseForge, 20, []int64{30}},
+		{"CurseForge/Latest", curseForge, 30, []int64{}},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			newer, err := c.client.CheckForUpdate(context.Background(), 42, c.currentID)
+			if err != nil {
+				t.Fatalf("CheckForUpdate() error = %v", err)
+			}
+			if got := versionIDs(newer); !slices.Equal(got, c.want) {
+				t.Errorf("CheckForUpdate() = %v, want %v", got, c.want)
+			}
+		})
+	}
+}
+
+func TestCheckForUpdateUnknownVersion(t *testing.T) {
+	srv := newUpdateServer(t)
+	for _, c := range []struct {
+		name   string
+		client ModpackClient
+	}{
+		{"Public", NewPublicModpackClient(WithBaseURL(srv.URL))},
+		{"CurseForge", NewCurseForgeModpackClient(WithBaseURL(srv.URL))},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			if _, err := c.client.CheckForUpdate(context.Background(), 42, 99); !errors.Is(err, ErrVersionNotFound) {
+				t.Errorf("CheckForUpdate() error = %v, want %v", err, ErrVersionNotFound)
+			}
+		})
+	}
+}
+
+func TestLatestVersion(t *testing.T) {
+	for _, c := range []struct {
+		name     string
+		provider string
+		want     int64
+	}{
+		{"Public", "modpacks.ch", 3},
+		{"CurseForge", "curseforge", 1},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			m := ModpackManifest{
+				Provider: c.provider,
+				Versions: make([]ModpackVersion, 3),
+			}
+			for i := range m.Versions {
+				m.Versions[i].ID = int64(i + 1)
+			}
+			v, ok := m.LatestVersion()
+			if !ok || v.ID != c.want {
+				t.Errorf("LatestVersion() = %d, %v, want %d, true", v.ID, ok, c.want)
+			}
+		})
+	}
+
+	var m ModpackManifest
+	if _, ok := m.LatestVersion(); ok {
+		t.Error("LatestVersion() of a modpack without versions = _, true, want false")
+	}
+}
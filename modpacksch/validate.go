@@ -0,0 +1,98 @@
+package modpacksch
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrInvalidSum is reported for files whose hash sums are not hex digests of the expected length.
+	ErrInvalidSum = errors.New("invalid hash sum")
+
+	// ErrInvalidSize is reported for files with negative sizes.
+	ErrInvalidSize = errors.New("invalid size")
+)
+
+// FileError is a problem with a file in a version manifest.
+type FileError struct {
+	// Index is the index of the file in the manifest's files.
+	Index int
+
+	// Path is the file's path in the manifest.
+	Path string
+
+	// Err is the problem.
+	Err error
+}
+
+// Error implements [error].
+func (e *FileError) Error() string {
+	return e.Path + ": " + e.Err.Error()
+}
+
+// Unwrap returns the problem.
+func (e *FileError) Unwrap() error {
+	return e.Err
+}
+
+// Validate checks that every file in the version manifest can be downloaded and verified,
+// and returns all problems found, as [*FileError] values, in manifest order.
+//
+// Each file must have a URL or CurseForge file info, a SHA-1 sum and, if present, a SHA-256 sum
+// that are hex digests of the right length, a non-negative size, and a path that stays local
+// and can be created on Windows. The checks do not depend on the operating system they run on.
+func (m *ModpackVersionManifest) Validate() []error {
+	var errs []error
+	for i := range m.Files {
+		f := &m.Files[i]
+		for _, err := range f.validate() {
+			errs = append(errs, &FileError{Index: i, Path: f.ManifestPath(), Err: err})
+		}
+	}
+	return errs
+}
+
+// validate returns the problems with the file.
+func (f *ModpackVersionFile) validate() []error {
+	var errs []error
+
+	if f.URL == "" && f.CurseForge == nil {
+		errs = append(errs, ErrMissingURL)
+	}
+
+	if err := validateSum("SHA1", f.SHA1, sha1.Size); err != nil {
+		errs = append(errs, err)
+	}
+	if f.SHA256 != "" {
+		if err := validateSum("SHA256", f.SHA256, sha256.Size); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if f.Size < 0 {
+		errs = append(errs, fmt.Errorf("%w: %d", ErrInvalidSize, f.Size))
+	}
+
+	if !f.pathIsLocal() {
+		errs = append(errs, ErrPathSanitization)
+	} else if err := validateWindowsPath(f.ManifestPath()); err != nil {
+		errs = append(errs, err)
+	}
+
+	return errs
+}
+
+// validateSum checks that sum is a hex digest of the given size in bytes.
+func validateSum(name, sum string, size int) error {
+	b, err := hex.DecodeString(sum)
+	if err != nil {
+		return fmt.Errorf("%w: %s %q is not hex", ErrInvalidSum, name, sum)
+	}
+	if len(b) != size {
+		return fmt.Errorf("%w: %s %q is %d bytes, expected %d", ErrInvalidSum, name, sum, len(b), size)
+	}
+	return nil
+}
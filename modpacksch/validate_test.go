@@ -0,0 +1,78 @@
+package modpacksch
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestModpackVersionManifestValidate(t *testing.T) {
+	var m ModpackVersionManifest
+	if err := json.Unmarshal([]byte(`{"files": [
+		{"path": "./mods/", "name": "valid.jar", "url": "https://example.com/valid.jar", "sha1": "`+testFileSHA1Hex+`", "size": 1},
+		{"path": "./mods/", "name": "curseforge.jar", "sha1": "`+testFileSHA1Hex+`", "size": 1, "curseforge": {"project": 1, "file": 2}},
+		{"path": "./mods/", "name": "no-url.jar", "sha1": "`+testFileSHA1Hex+`", "size": 1},
+		{"path": "./mods/", "name": "not-hex.jar", "url": "https://example.com/a", "sha1": "not hex", "size": 1},
+		{"path": "./mods/", "name": "short-sum.jar", "url": "https://example.com/a", "sha1": "abcd", "size": 1},
+		{"path": "./mods/", "name": "bad-sha256.jar", "url": "https://example.com/a", "sha1": "`+testFileSHA1Hex+`", "sha256": "`+testFileSHA1Hex+`", "size": 1},
+		{"path": "./mods/", "name": "negative.jar", "url": "https://example.com/a", "sha1": "`+testFileSHA1Hex+`", "size": -1},
+		{"path": "./../", "name": "escape.jar", "url": "https://example.com/a", "sha1": "`+testFileSHA1Hex+`", "size": 1},
+		{"path": "./mods/", "name": "sub/dir.jar", "url": "https://example.com/a", "sha1": "`+testFileSHA1Hex+`", "size": 1},
+		{"path": "./mods/", "name": "con.jar", "url": "https://example.com/a", "sha1": "`+testFileSHA1Hex+`", "size": 1},
+		{"path": "./mods/", "name": "everything.jar", "sha1": "", "size": -1}
+	]}`), &m); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []struct {
+		index int
+		path  string
+		err   error
+	}{
+		{2, "mods/no-url.jar", ErrMissingURL},
+		{3, "mods/not-hex.jar", ErrInvalidSum},
+		{4, "mods/short-sum.jar", ErrInvalidSum},
+		{5, "mods/bad-sha256.jar", ErrInvalidSum},
+		{6, "mods/negative.jar", ErrInvalidSize},
+		{7, "../escape.jar", ErrPathSanitization},
+		{8, "mods/sub/dir.jar", ErrPathSanitization},
+		{9, "mods/con.jar", ErrInvalidWindowsName},
+		// All problems with a file are reported.
+		{10, "mods/everything.jar", ErrMissingURL},
+		{10, "mods/everything.jar", ErrInvalidSum},
+		{10, "mods/everything.jar", ErrInvalidSize},
+	}
+
+	errs := m.Validate()
+	if len(errs) != len(want) {
+		t.Fatalf("Validate() returned %d problems, want %d: %v", len(errs), len(want), errs)
+	}
+	for i, err := range errs {
+		var fe *FileError
+		if !errors.As(err, &fe) {
+			t.Errorf("problem %d = %v, want a *FileError", i, err)
+			continue
+		}
+		if fe.Index != want[i].index || fe.Path != want[i].path || !errors.Is(err, want[i].err) {
+			t.Errorf("problem %d = %d, %q, %v, want %d, %q, %v", i, fe.Index, fe.Path, fe.Err, want[i].index, want[i].path, want[i].err)
+		}
+	}
+}
+
+func TestModpackVersionManifestValidateValid(t *testing.T) {
+	m := testLookupManifest(t)
+	for i := range m.Files {
+		m.Files[i].URL = "https://example.com/" + m.Files[i].Name
+		m.Files[i].SHA1 = testFileSHA1Hex
+	}
+	if errs := m.Validate(); len(errs) != 0 {
+		t.Errorf("Validate() = %v, want no problems", errs)
+	}
+}
+
+func TestFileErrorFormat(t *testing.T) {
+	err := &FileError{Index: 3, Path: "mods/a.jar", Err: ErrMissingURL}
+	if got, want := err.Error(), "mods/a.jar: "+ErrMissingURL.Error(); got != want {
+		t.Errorf("Error() = %q, want %q", got, want)
+	}
+}
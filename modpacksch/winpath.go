@@ -0,0 +1,61 @@
+package modpacksch
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrInvalidWindowsName is returned when a file's destination path cannot be created on Windows,
+// because one of its elements is a reserved device name or contains characters that are not allowed.
+var ErrInvalidWindowsName = errors.New("name is not valid on Windows")
+
+// windowsReservedNames are the device names that cannot be used as file names on Windows,
+// with or without an extension.
+var windowsReservedNames = [...]string{
+	"CON", "PRN", "AUX", "NUL",
+	"COM0", "COM1", "COM2", "COM3", "COM4", "COM5", "COM6", "COM7", "COM8", "COM9",
+	"COM¹", "COM²", "COM³",
+	"LPT0", "LPT1", "LPT2", "LPT3", "LPT4", "LPT5", "LPT6", "LPT7", "LPT8", "LPT9",
+	"LPT¹", "LPT²", "LPT³",
+}
+
+// validateWindowsPath checks that each element of the relative path, separated by
+// either forward or back slashes, can be used as a file name on Windows.
+//
+// It does not depend on the operating system it runs on.
+func validateWindowsPath(relPath string) error {
+	for _, elem := range strings.FieldsFunc(relPath, func(r rune) bool { return r == '/' || r == '\\' }) {
+		if err := validateWindowsName(elem); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateWindowsName checks that name can be used as a file name on Windows.
+func validateWindowsName(name string) error {
+	if name == "." || name == ".." {
+		return nil
+	}
+
+	for _, r := range name {
+		if r < 0x20 || strings.ContainsRune(`<>:"|?*`, r) {
+			return fmt.Errorf("%w: %q contains %q", ErrInvalidWindowsName, name, r)
+		}
+	}
+
+	if last := name[len(name)-1]; last == '.' || last == ' ' {
+		return fmt.Errorf("%w: %q ends with %q", ErrInvalidWindowsName, name, last)
+	}
+
+	// "CON.txt" and "CON .txt" are both the console device.
+	base, _, _ := strings.Cut(name, ".")
+	base = strings.TrimRight(base, " ")
+	for _, reserved := range windowsReservedNames {
+		if strings.EqualFold(base, reserved) {
+			return fmt.Errorf("%w: %q is a reserved device name", ErrInvalidWindowsName, name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,49 @@
+package modpacksch
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestValidateWindowsPath(t *testing.T) {
+	for _, c := range []struct {
+		path  string
+		valid bool
+	}{
+		{"mods/jei.jar", true},
+		{`config\sub\a.toml`, true},
+		{"config/../options.txt", true},
+		{"mods/console.jar", true},
+		{"mods/CONFIG.jar", true},
+		{"mods/COM10.jar", true},
+		{"CON", false},
+		{"con", false},
+		{"config/aux.jar", false},
+		{"config/Nul.cfg", false},
+		{"mods/COM1.jar", false},
+		{"mods/lpt9", false},
+		{"mods/COM¹.jar", false},
+		{"mods/CON .txt", false},
+		{"config/a:b.toml", false},
+		{"config/what?.txt", false},
+		{`config/"quoted".txt`, false},
+		{"config/a<b>.txt", false},
+		{"config/pipe|.txt", false},
+		{"config/star*.txt", false},
+		{"config/tab\t.txt", false},
+		{"config/trailing.", false},
+		{"config/trailing ", false},
+		{"trailing /a.txt", false},
+	} {
+		err := validateWindowsPath(c.path)
+		if c.valid {
+			if err != nil {
+				t.Errorf("validateWindowsPath(%q) error = %v, want nil", c.path, err)
+			}
+			continue
+		}
+		if !errors.Is(err, ErrInvalidWindowsName) {
+			t.Errorf("validateWindowsPath(%q) error = %v, want %v", c.path, err, ErrInvalidWindowsName)
+		}
+	}
+}
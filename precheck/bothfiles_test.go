@@ -0,0 +1,129 @@
+package precheck
+
+import (
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testFileState is the state of a destination path before a job runs.
+type testFileState int
+
+const (
+	testFileMissing testFileState = iota
+	testFileValid
+	testFileCorrupt
+	testFileTruncated
+	testFileBlocked
+)
+
+var testFileStateNames = [...]string{"Missing", "Valid", "Corrupt", "Truncated", "Blocked"}
+
+// setUpTestFile puts the file at path in the given state, and returns the path to check.
+func setUpTestFile(t *testing.T, dir string, state testFileState) string {
+	t.Helper()
+	path := filepath.Join(dir, "mods", "a.jar")
+	switch state {
+	case testFileValid:
+		writeTestFile(t, path, testContent)
+	case testFileCorrupt:
+		writeTestFile(t, path, testOtherContent[:len(testContent)])
+	case testFileTruncated:
+		writeTestFile(t, path, testContent[:len(testContent)/2])
+	case testFileBlocked:
+		// The parent directory cannot be created, because a file is in the way.
+		writeTestFile(t, filepath.Join(dir, "mods"), nil)
+	}
+	return path
+}
+
+// checkResult is the result of [Job.createAndCheckFile], without the file.
+type checkResult struct {
+	opened bool
+	ok     bool
+	failed bool
+}
+
+func newCheckResult(f file, ok bool, err error) checkResult {
+	if f != nil {
+		f.Close()
+	}
+	return checkResult{opened: f != nil, ok: ok, failed: err != nil}
+}
+
+func TestCreateAndCheckBothFilesMatchesSequentialChecks(t *testing.T) {
+	if len(testOtherContent) < len(testContent) {
+		t.Fatal("testOtherContent is shorter than testContent")
+	}
+	for s1 := range testFileState(len(testFileStateNames)) {
+		for s2 := range testFileState(len(testFileStateNames)) {
+			t.Run(testFileStateNames[s1]+"/"+testFileStateNames[s2], func(t *testing.T) {
+				// newJob returns a job for the files in the given states under a new directory.
+				newJob := func() Job {
+					dir := t.TempDir()
+					j := newTestJob(setUpTestFile(t, filepath.Join(dir, "client"), s1), testContent)
+					j.SecondaryDestinationPath = setUpTestFile(t, filepath.Join(dir, "server"), s2)
+					return j
+				}
+
+				seq := newJob()
+				want1 := newCheckResult(seq.createAndCheckFile(seq.DestinationPath))
+				want2 := newCheckResult(seq.createAndCheckFile(seq.SecondaryDestinationPath))
+
+				con := newJob()
+				f1, ok1, f2, ok2, err1, err2 := con.createAndCheckBothFiles()
+				got1 := newCheckResult(f1, ok1, err1)
+				got2 := newCheckResult(f2, ok2, err2)
+
+				if got1 != want1 {
+					t.Errorf("destination path result = %+v, want %+v", got1, want1)
+				}
+				if got2 != want2 {
+					t.Errorf("secondary destination path result = %+v, want %+v", got2, want2)
+				}
+			})
+		}
+	}
+}
+
+func TestCreateAndCheckBothFilesRunsConcurrently(t *testing.T) {
+	dir := t.TempDir()
+	j := newTestJob(setUpTestFile(t, filepath.Join(dir, "client"), testFileValid), testContent)
+	j.SecondaryDestinationPath = setUpTestFile(t, filepath.Join(dir, "server"), testFileValid)
+
+	// Each check waits for the other to start.
+	var (
+		arrived sync.WaitGroup
+		both    = make(chan struct{})
+	)
+	arrived.Add(2)
+	go func() {
+		arrived.Wait()
+		close(both)
+	}()
+	errNotConcurrent := errors.New("the other file was not checked concurrently")
+	j.Verify = func(path string) error {
+		arrived.Done()
+		select {
+		case <-both:
+			return nil
+		case <-time.After(5 * time.Second):
+			return errNotConcurrent
+		}
+	}
+
+	f1, ok1, f2, ok2, err1, err2 := j.createAndCheckBothFiles()
+	for _, f := range []file{f1, f2} {
+		if f != nil {
+			f.Close()
+		}
+	}
+	if err1 != nil || err2 != nil {
+		t.Fatalf("errors = %v, %v", err1, err2)
+	}
+	if !ok1 || !ok2 {
+		t.Errorf("ok1, ok2 = %t, %t, want both checked concurrently and valid", ok1, ok2)
+	}
+}
@@ -0,0 +1,127 @@
+package precheck
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// corruptingFile is a file that flips the first byte of whatever is copied into it.
+type corruptingFile struct {
+	file
+}
+
+// ReadFrom implements [io.ReaderFrom].
+func (f corruptingFile) ReadFrom(r io.Reader) (int64, error) {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return 0, err
+	}
+	if len(b) > 0 {
+		b[0] ^= 0xff
+	}
+	n, err := f.Write(b)
+	return int64(n), err
+}
+
+// corruptingFileSystem is the [osFileSystem], except that copies into the file at path are corrupted.
+type corruptingFileSystem struct {
+	osFileSystem
+	path string
+}
+
+// OpenFile implements [fileSystem.OpenFile].
+func (fsys corruptingFileSystem) OpenFile(name string, flag int, perm os.FileMode) (file, error) {
+	f, err := fsys.osFileSystem.OpenFile(name, flag, perm)
+	if err != nil || name != fsys.path {
+		return f, err
+	}
+	return corruptingFile{f}, nil
+}
+
+// newCopyTestJob returns a job for a file that exists only at the client path,
+// and is copied to the server path, on a filesystem that corrupts the copy if corrupt is true.
+func newCopyTestJob(t *testing.T, corrupt bool) (j Job, server string) {
+	t.Helper()
+	dir := t.TempDir()
+	client := filepath.Join(dir, "client", "mods", "a.jar")
+	server = filepath.Join(dir, "server", "mods", "a.jar")
+	writeTestFile(t, client, testContent)
+
+	j = newTestJob(client, testContent)
+	j.SecondaryDestinationPath = server
+	if corrupt {
+		j.fsys = corruptingFileSystem{path: server}
+	}
+	return j, server
+}
+
+func TestJobCopyVerificationDownloadsCorruptCopy(t *testing.T) {
+	j, server := newCopyTestJob(t, true)
+	j.verifyCopies = true
+	outcome, djs := runTestJob(t, &j)
+	if outcome != OutcomeQueued {
+		t.Fatalf("outcome = %s, want %s", outcome, OutcomeQueued)
+	}
+	if len(djs) != 1 {
+		t.Fatalf("got %d download jobs, want 1", len(djs))
+	}
+	dj := djs[0]
+	if got := dj.TargetFile.Name(); got != server {
+		t.Errorf("TargetFile = %q, want the corrupt copy %q", got, server)
+	}
+	if dj.SecondaryTargetFile != nil {
+		t.Errorf("SecondaryTargetFile = %q, want nil", dj.SecondaryTargetFile.Name())
+	}
+	if dj.DownloadURL != j.DownloadURL || !bytes.Equal(dj.Sum, j.Sum) {
+		t.Errorf("download job = %+v, does not match precheck job", dj)
+	}
+	// The bad copy is not resumed from.
+	if got := readTestFile(t, server); len(got) != 0 {
+		t.Errorf("corrupt copy has %d bytes left, want 0", len(got))
+	}
+}
+
+func TestJobCopyVerificationKeepsGoodCopy(t *testing.T) {
+	j, server := newCopyTestJob(t, false)
+	j.verifyCopies = true
+	if outcome, djs := runTestJob(t, &j); outcome != OutcomeCopied || len(djs) != 0 {
+		t.Fatalf("outcome = %s with %d download jobs, want %s with none", outcome, len(djs), OutcomeCopied)
+	}
+	if got := readTestFile(t, server); !bytes.Equal(got, testContent) {
+		t.Errorf("copied content = %q, want %q", got, testContent)
+	}
+}
+
+func TestJobWithoutCopyVerificationTrustsCopy(t *testing.T) {
+	j, server := newCopyTestJob(t, true)
+	if outcome, djs := runTestJob(t, &j); outcome != OutcomeCopied || len(djs) != 0 {
+		t.Fatalf("outcome = %s with %d download jobs, want %s with none", outcome, len(djs), OutcomeCopied)
+	}
+	if got := readTestFile(t, server); bytes.Equal(got, testContent) {
+		t.Error("copy was not corrupted")
+	}
+}
+
+func TestWithCopyVerification(t *testing.T) {
+	j, server := newCopyTestJob(t, true)
+	pjch := make(chan Job, 1)
+	pjch <- j
+	close(pjch)
+
+	wf := NewWorkerFleet(context.Background(), testLogger, 1, pjch, WithCopyVerification(), withFileSystem(j.fsys))
+	dj := <-wf.DownloadJobChannel()
+	name := dj.TargetFile.Name()
+	closeDownloadJob(dj)
+	wf.Wait()
+
+	if name != server {
+		t.Errorf("TargetFile = %q, want the corrupt copy %q", name, server)
+	}
+	if got := wf.Stats().Queued; got != 1 {
+		t.Errorf("Stats().Queued = %d, want 1", got)
+	}
+}
@@ -0,0 +1,119 @@
+//go:build unix
+
+package precheck
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+// umask returns the process's umask.
+func umask() os.FileMode {
+	mask := syscall.Umask(0)
+	syscall.Umask(mask)
+	return os.FileMode(mask)
+}
+
+// assertMode fails the test if the permission bits of the file at path are not want.
+func assertMode(t *testing.T, path string, want os.FileMode) {
+	t.Helper()
+	fi, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := fi.Mode().Perm(); got != want {
+		t.Errorf("mode of %q = %#o, want %#o", path, got, want)
+	}
+}
+
+func TestJobCreatesFilesWithModes(t *testing.T) {
+	mask := umask()
+	for _, c := range []struct {
+		name              string
+		fileMode, dirMode os.FileMode
+		wantFileMode      os.FileMode
+		wantDirMode       os.FileMode
+	}{
+		{"Default", 0, 0, defaultFileMode, defaultDirMode},
+		{"Private", 0600, 0700, 0600, 0700},
+		{"GroupWritable", 0664, 0775, 0664, 0775},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			for _, usePartFile := range []bool{false, true} {
+				dir := t.TempDir()
+				path := filepath.Join(dir, "mods", "a.jar")
+				j := newTestJob(path, testContent)
+				j.UsePartFile = usePartFile
+				j.fileMode = c.fileMode
+				j.dirMode = c.dirMode
+				if outcome, _ := runTestJob(t, &j); outcome != OutcomeQueued {
+					t.Fatalf("outcome = %s, want %s", outcome, OutcomeQueued)
+				}
+
+				created := path
+				if usePartFile {
+					created += PartFileSuffix
+				}
+				assertMode(t, created, c.wantFileMode&^mask)
+				assertMode(t, filepath.Dir(path), c.wantDirMode&^mask)
+			}
+		})
+	}
+}
+
+func TestJobKeepsModesOfExistingFiles(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "mods", "a.jar")
+	writeTestFile(t, path, testOtherContent)
+	if err := os.Chmod(path, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(filepath.Dir(path), 0700); err != nil {
+		t.Fatal(err)
+	}
+
+	j := newTestJob(path, testContent)
+	j.fileMode = 0666
+	j.dirMode = 0777
+	if outcome, _ := runTestJob(t, &j); outcome != OutcomeQueued {
+		t.Fatalf("outcome = %s, want %s", outcome, OutcomeQueued)
+	}
+	assertMode(t, path, 0600)
+	assertMode(t, filepath.Dir(path), 0700)
+}
+
+func TestJobCopiesMigrationSourceWithFileMode(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "old", "mods", "a.jar")
+	dst := filepath.Join(dir, "new", "mods", "a.jar")
+	writeTestFile(t, src, testContent)
+
+	j := newTestJob(dst, testContent)
+	j.MigrateFromPath = src
+	j.MigrationMode = MigrationModeCopy
+	j.fileMode = 0600
+	j.dirMode = 0700
+	if outcome, _ := runTestJob(t, &j); outcome != OutcomeCopied {
+		t.Fatalf("outcome = %s, want %s", outcome, OutcomeCopied)
+	}
+	assertMode(t, dst, 0600&^umask())
+	assertMode(t, filepath.Dir(dst), 0700&^umask())
+}
+
+func TestWithFileModes(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "mods", "a.jar")
+
+	pjch := make(chan Job, 1)
+	pjch <- newTestJob(path, testContent)
+	close(pjch)
+	wf := NewWorkerFleet(context.Background(), testLogger, 1, pjch, WithFileModes(0600, 0700))
+	closeDownloadJob(<-wf.DownloadJobChannel())
+	wf.Wait()
+
+	assertMode(t, path, 0600&^umask())
+	assertMode(t, filepath.Dir(path), 0700&^umask())
+}
@@ -0,0 +1,128 @@
+package precheck
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/database64128/modpack-dl-go/download"
+)
+
+// file is an open file, as returned by a [fileSystem].
+//
+// Files are handed to download jobs as is. Cloning and extended attributes need
+// the file descriptor of an [*os.File], and are treated as unsupported for other files.
+type file interface {
+	download.File
+
+	// Stat returns the [fs.FileInfo] describing the file.
+	Stat() (fs.FileInfo, error)
+
+	// Sync commits the content of the file to stable storage.
+	Sync() error
+}
+
+var _ file = (*os.File)(nil)
+
+// fileSystem is the set of filesystem operations that jobs perform by path.
+type fileSystem interface {
+	Open(name string) (file, error)
+	OpenFile(name string, flag int, perm os.FileMode) (file, error)
+	CreateTemp(dir, pattern string) (file, error)
+	Stat(name string) (fs.FileInfo, error)
+	Rename(oldpath, newpath string) error
+	Remove(name string) error
+	MkdirAll(path string, perm os.FileMode) error
+	Link(oldname, newname string) error
+	Chtimes(name string, atime, mtime time.Time) error
+}
+
+// osFileSystem is the [fileSystem] implemented by the os package.
+type osFileSystem struct{}
+
+// osFileOrNil returns f as a file, or a nil file if err is not nil,
+// so that a nil [*os.File] is never wrapped in a non-nil interface.
+func osFileOrNil(f *os.File, err error) (file, error) {
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// Open implements [fileSystem.Open].
+func (osFileSystem) Open(name string) (file, error) {
+	return osFileOrNil(os.Open(name))
+}
+
+// OpenFile implements [fileSystem.OpenFile].
+func (osFileSystem) OpenFile(name string, flag int, perm os.FileMode) (file, error) {
+	return osFileOrNil(os.OpenFile(name, flag, perm))
+}
+
+// CreateTemp implements [fileSystem.CreateTemp].
+func (osFileSystem) CreateTemp(dir, pattern string) (file, error) {
+	return osFileOrNil(os.CreateTemp(dir, pattern))
+}
+
+// Stat implements [fileSystem.Stat].
+func (osFileSystem) Stat(name string) (fs.FileInfo, error) {
+	return os.Stat(name)
+}
+
+// Rename implements [fileSystem.Rename].
+func (osFileSystem) Rename(oldpath, newpath string) error {
+	return os.Rename(oldpath, newpath)
+}
+
+// Remove implements [fileSystem.Remove].
+func (osFileSystem) Remove(name string) error {
+	return os.Remove(name)
+}
+
+// MkdirAll implements [fileSystem.MkdirAll].
+func (osFileSystem) MkdirAll(path string, perm os.FileMode) error {
+	return os.MkdirAll(path, perm)
+}
+
+// Link implements [fileSystem.Link].
+func (osFileSystem) Link(oldname, newname string) error {
+	return os.Link(oldname, newname)
+}
+
+// Chtimes implements [fileSystem.Chtimes].
+func (osFileSystem) Chtimes(name string, atime, mtime time.Time) error {
+	return os.Chtimes(name, atime, mtime)
+}
+
+// filesystem returns the filesystem the job operates on.
+func (j *Job) filesystem() fileSystem {
+	if j.fsys != nil {
+		return j.fsys
+	}
+	return osFileSystem{}
+}
+
+// writeFileAtomic writes b to the file at path on fsys.
+// The content is written to a temporary file in the same directory first,
+// which is then renamed into place, so that an interrupted write leaves the file unchanged.
+func writeFileAtomic(fsys fileSystem, path string, b []byte) error {
+	f, err := fsys.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	if _, err = f.Write(b); err != nil {
+		f.Close()
+		fsys.Remove(f.Name())
+		return err
+	}
+	if err = f.Close(); err != nil {
+		fsys.Remove(f.Name())
+		return err
+	}
+	if err = fsys.Rename(f.Name(), path); err != nil {
+		fsys.Remove(f.Name())
+		return err
+	}
+	return nil
+}
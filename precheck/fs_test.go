@@ -0,0 +1,223 @@
+package precheck
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+)
+
+// wrappedFile hides the [*os.File] of a file behind another type.
+type wrappedFile struct {
+	file
+}
+
+// wrappingFileSystem is the [osFileSystem], except that the files it opens are [wrappedFile]s,
+// so that nothing can reach their descriptors.
+type wrappingFileSystem struct {
+	osFileSystem
+}
+
+// wrap wraps f in a [wrappedFile], unless err is not nil.
+func wrap(f file, err error) (file, error) {
+	if err != nil {
+		return nil, err
+	}
+	return wrappedFile{f}, nil
+}
+
+// Open implements [fileSystem.Open].
+func (fsys wrappingFileSystem) Open(name string) (file, error) {
+	return wrap(fsys.osFileSystem.Open(name))
+}
+
+// OpenFile implements [fileSystem.OpenFile].
+func (fsys wrappingFileSystem) OpenFile(name string, flag int, perm os.FileMode) (file, error) {
+	return wrap(fsys.osFileSystem.OpenFile(name, flag, perm))
+}
+
+// CreateTemp implements [fileSystem.CreateTemp].
+func (fsys wrappingFileSystem) CreateTemp(dir, pattern string) (file, error) {
+	return wrap(fsys.osFileSystem.CreateTemp(dir, pattern))
+}
+
+// recordingFileSystem is a [fileSystem] that records the paths renamed on it.
+type recordingFileSystem struct {
+	fileSystem
+
+	mu      sync.Mutex
+	renames [][2]string
+}
+
+// Rename implements [fileSystem.Rename].
+func (fsys *recordingFileSystem) Rename(oldpath, newpath string) error {
+	fsys.mu.Lock()
+	fsys.renames = append(fsys.renames, [2]string{oldpath, newpath})
+	fsys.mu.Unlock()
+	return fsys.fileSystem.Rename(oldpath, newpath)
+}
+
+// failingRenameFileSystem is the [osFileSystem], except that all renames fail.
+type failingRenameFileSystem struct {
+	osFileSystem
+}
+
+// errRenameFailed is returned by [failingRenameFileSystem.Rename].
+var errRenameFailed = errors.New("rename failed")
+
+// Rename implements [fileSystem.Rename].
+func (failingRenameFileSystem) Rename(oldpath, newpath string) error {
+	return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: errRenameFailed}
+}
+
+func TestWorkerFleetMigratesAcrossFilesystems(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "old", "mods", "a.jar")
+	dst := filepath.Join(dir, "new", "mods", "a.jar")
+	writeTestFile(t, src, testContent)
+
+	fsys := &recordingFileSystem{fileSystem: crossDeviceFileSystem{migrateFromPath: src}}
+	j := newTestJob(dst, testContent)
+	j.MigrateFromPath = src
+	j.MigrationMode = MigrationModeMove
+
+	pjch := make(chan Job, 1)
+	pjch <- j
+	close(pjch)
+	wf := NewWorkerFleet(context.Background(), testLogger, 1, pjch, withFileSystem(fsys))
+	wf.Wait()
+
+	if got := wf.Stats().Moved; got != 1 {
+		t.Fatalf("Stats().Moved = %d, want 1", got)
+	}
+	if got := readTestFile(t, dst); !bytes.Equal(got, testContent) {
+		t.Errorf("moved content = %q, want %q", got, testContent)
+	}
+	if _, err := os.Stat(src); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("migration source still exists after move: %v", err)
+	}
+
+	// The rename across filesystems fails, and the copy is renamed into place instead.
+	want := [][2]string{
+		{src, dst},
+		{dst + migrationTempSuffix, dst},
+	}
+	if !slices.Equal(fsys.renames, want) {
+		t.Errorf("renames = %q, want %q", fsys.renames, want)
+	}
+}
+
+func TestJobCopiesWithoutFileDescriptors(t *testing.T) {
+	for _, c := range []struct {
+		name string
+		mode MigrationMode
+	}{
+		{"Copy", MigrationModeCopy},
+		// Cloning needs file descriptors, so it falls back to a copy.
+		{"Reflink", MigrationModeReflink},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			dir := t.TempDir()
+			src := filepath.Join(dir, "old", "mods", "a.jar")
+			client := filepath.Join(dir, "client", "mods", "a.jar")
+			server := filepath.Join(dir, "server", "mods", "a.jar")
+			writeTestFile(t, src, testContent)
+
+			j := newTestJob(client, testContent)
+			j.SecondaryDestinationPath = server
+			j.MigrateFromPath = src
+			j.MigrationMode = c.mode
+			j.fsys = wrappingFileSystem{}
+			if outcome, _ := runTestJob(t, &j); outcome != OutcomeCopied {
+				t.Fatalf("outcome = %s, want %s", outcome, OutcomeCopied)
+			}
+			for _, path := range []string{src, client, server} {
+				if got := readTestFile(t, path); !bytes.Equal(got, testContent) {
+					t.Errorf("content of %q = %q, want %q", path, got, testContent)
+				}
+			}
+		})
+	}
+}
+
+func TestJobSendsFilesOfFileSystemToDownload(t *testing.T) {
+	j := newTestJob(filepath.Join(t.TempDir(), "mods", "a.jar"), testContent)
+	j.fsys = wrappingFileSystem{}
+	outcome, djs := runTestJob(t, &j)
+	if outcome != OutcomeQueued || len(djs) != 1 {
+		t.Fatalf("outcome = %s with %d download jobs, want %s with 1", outcome, len(djs), OutcomeQueued)
+	}
+	if _, ok := djs[0].TargetFile.(wrappedFile); !ok {
+		t.Errorf("TargetFile is %T, want the file opened on the job's filesystem", djs[0].TargetFile)
+	}
+	if djs[0].SecondaryTargetFile != nil {
+		t.Errorf("SecondaryTargetFile = %v, want nil", djs[0].SecondaryTargetFile)
+	}
+}
+
+func TestWriteFileAtomicKeepsFileOnFailedRename(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "state.json")
+	writeTestFile(t, path, []byte("old"))
+
+	if err := writeFileAtomic(failingRenameFileSystem{}, path, []byte("new")); !errors.Is(err, errRenameFailed) {
+		t.Fatalf("writeFileAtomic() error = %v, want %v", err, errRenameFailed)
+	}
+	if got := readTestFile(t, path); string(got) != "old" {
+		t.Errorf("content = %q, want %q", got, "old")
+	}
+	if got := listFiles(t, dir); !slices.Equal(got, []string{"state.json"}) {
+		t.Errorf("files = %q, want only state.json", got)
+	}
+}
+
+func TestSaveUsesFileSystem(t *testing.T) {
+	plan, err := NewPlan([]Job{newTestJob("a.jar", testContent)}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	verified := filepath.Join(t.TempDir(), "a.jar")
+	writeTestFile(t, verified, testContent)
+	fi, err := os.Stat(verified)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jn := NewJournal()
+	jn.record(verified, fi, sha1Sum(testContent))
+
+	for _, c := range []struct {
+		name string
+		save func(fsys fileSystem, path string) error
+	}{
+		{"Journal", jn.save},
+		{"Plan", plan.save},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			dir := t.TempDir()
+			path := filepath.Join(dir, "state.json")
+
+			fsys := &recordingFileSystem{fileSystem: osFileSystem{}}
+			if err := c.save(fsys, path); err != nil {
+				t.Fatal(err)
+			}
+			if len(fsys.renames) != 1 || fsys.renames[0][1] != path {
+				t.Errorf("renames = %q, want one to %q", fsys.renames, path)
+			}
+			saved := readTestFile(t, path)
+
+			if err := c.save(failingRenameFileSystem{}, path); !errors.Is(err, errRenameFailed) {
+				t.Fatalf("save() error = %v, want %v", err, errRenameFailed)
+			}
+			if got := readTestFile(t, path); !bytes.Equal(got, saved) {
+				t.Errorf("content after failed save = %q, want %q", got, saved)
+			}
+			if got := listFiles(t, dir); !slices.Equal(got, []string{"state.json"}) {
+				t.Errorf("files = %q, want only state.json", got)
+			}
+		})
+	}
+}
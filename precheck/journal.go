@@ -0,0 +1,136 @@
+package precheck
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// JournalVersion is the version of the journal file format.
+const JournalVersion = 1
+
+// Journal records files whose content has been verified against their expected hash sums,
+// so that a later run can skip hashing files that have not been modified since.
+//
+// A file is considered unmodified if its size and modification time are unchanged.
+// Entries of files that have been modified, or whose expected hash sum has changed,
+// are invalidated when they are looked up.
+//
+// A Journal is safe for concurrent use.
+type Journal struct {
+	mu    sync.Mutex
+	files map[string]journalEntry
+}
+
+// journalFile is the on-disk format of a journal.
+type journalFile struct {
+	Version int                     `json:"version"`
+	Files   map[string]journalEntry `json:"files"`
+}
+
+// journalEntry is a verified file in a journal, keyed by its absolute path.
+type journalEntry struct {
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mtime"`
+
+	// Sum is the hex-encoded hash sum that the file was verified against.
+	Sum string `json:"sum"`
+}
+
+// NewJournal returns a new empty journal.
+func NewJournal() *Journal {
+	return &Journal{files: make(map[string]journalEntry)}
+}
+
+// LoadJournal loads the journal from the file at path.
+// If the file does not exist, an empty journal is returned.
+func LoadJournal(path string) (*Journal, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return NewJournal(), nil
+		}
+		return nil, err
+	}
+
+	var jf journalFile
+	if err = json.Unmarshal(b, &jf); err != nil {
+		return nil, fmt.Errorf("failed to decode journal: %w", err)
+	}
+	if jf.Version != JournalVersion {
+		return nil, fmt.Errorf("unsupported journal version %d, expected %d", jf.Version, JournalVersion)
+	}
+	if jf.Files == nil {
+		jf.Files = make(map[string]journalEntry)
+	}
+	return &Journal{files: jf.Files}, nil
+}
+
+// Save writes the journal to the file at path.
+// The journal is written to a temporary file in the same directory first,
+// which is then renamed into place, so that an interrupted save does not corrupt the journal.
+func (jn *Journal) Save(path string) error {
+	return jn.save(osFileSystem{}, path)
+}
+
+// save writes the journal to the file at path on fsys.
+func (jn *Journal) save(fsys fileSystem, path string) error {
+	jn.mu.Lock()
+	b, err := json.Marshal(journalFile{
+		Version: JournalVersion,
+		Files:   jn.files,
+	})
+	jn.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	return writeFileAtomic(fsys, path, b)
+}
+
+// journalKey returns the key of the file at path in the journal.
+func journalKey(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+	return path
+}
+
+// verified returns whether the file at path, with the given file info, has been verified
+// against the expected hash sum and not modified since. Stale entries are removed.
+func (jn *Journal) verified(path string, fi os.FileInfo, sum []byte) bool {
+	key := journalKey(path)
+
+	jn.mu.Lock()
+	defer jn.mu.Unlock()
+
+	e, ok := jn.files[key]
+	if !ok {
+		return false
+	}
+	if e.Size != fi.Size() || !e.ModTime.Equal(fi.ModTime()) || e.Sum != hex.EncodeToString(sum) {
+		delete(jn.files, key)
+		return false
+	}
+	return true
+}
+
+// record records that the file at path, with the given file info, has been verified
+// against the expected hash sum.
+func (jn *Journal) record(path string, fi os.FileInfo, sum []byte) {
+	key := journalKey(path)
+	e := journalEntry{
+		Size:    fi.Size(),
+		ModTime: fi.ModTime(),
+		Sum:     hex.EncodeToString(sum),
+	}
+
+	jn.mu.Lock()
+	jn.files[key] = e
+	jn.mu.Unlock()
+}
@@ -0,0 +1,240 @@
+package precheck
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/database64128/modpack-dl-go/download"
+)
+
+// tamperPreservingModTime overwrites the file at path with content of the same size,
+// and restores its modification time, so that only hashing can tell it has changed.
+func tamperPreservingModTime(t *testing.T, path string, content []byte) {
+	t.Helper()
+	fi, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if int64(len(content)) != fi.Size() {
+		t.Fatalf("tampered content has %d bytes, want %d", len(content), fi.Size())
+	}
+	writeTestFile(t, path, content)
+	if err = os.Chtimes(path, fi.ModTime(), fi.ModTime()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestJournalSkipsHashingVerifiedFiles(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "mods", "a.jar")
+	journalPath := filepath.Join(dir, "journal.json")
+	writeTestFile(t, path, testContent)
+
+	j := newTestJob(path, testContent)
+	j.journal = NewJournal()
+	if outcome, _ := runTestJob(t, &j); outcome != OutcomeSkipped {
+		t.Fatalf("outcome = %s, want %s", outcome, OutcomeSkipped)
+	}
+	if err := j.journal.Save(journalPath); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	// A restarted run trusts the journal, as long as the size and modification time are unchanged.
+	jn, err := LoadJournal(journalPath)
+	if err != nil {
+		t.Fatalf("LoadJournal() error = %v", err)
+	}
+	tamperPreservingModTime(t, path, bytes.Repeat([]byte{'x'}, len(testContent)))
+	j = newTestJob(path, testContent)
+	j.journal = jn
+	if outcome, _ := runTestJob(t, &j); outcome != OutcomeSkipped {
+		t.Errorf("outcome = %s, want %s without hashing", outcome, OutcomeSkipped)
+	}
+}
+
+func TestJournalInvalidatesModifiedFiles(t *testing.T) {
+	for _, c := range []struct {
+		name   string
+		modify func(t *testing.T, path string)
+	}{
+		{"ModTime", func(t *testing.T, path string) {
+			tamperPreservingModTime(t, path, bytes.Repeat([]byte{'x'}, len(testContent)))
+			mtime := time.Now().Add(-time.Hour)
+			if err := os.Chtimes(path, mtime, mtime); err != nil {
+				t.Fatal(err)
+			}
+		}},
+		{"Size", func(t *testing.T, path string) {
+			writeTestFile(t, path, testOtherContent)
+		}},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			dir := t.TempDir()
+			path := filepath.Join(dir, "mods", "a.jar")
+			writeTestFile(t, path, testContent)
+
+			jn := NewJournal()
+			j := newTestJob(path, testContent)
+			j.journal = jn
+			if outcome, _ := runTestJob(t, &j); outcome != OutcomeSkipped {
+				t.Fatalf("outcome = %s, want %s", outcome, OutcomeSkipped)
+			}
+
+			c.modify(t, path)
+			j = newTestJob(path, testContent)
+			j.journal = jn
+			if outcome, _ := runTestJob(t, &j); outcome != OutcomeQueued {
+				t.Errorf("outcome = %s, want %s for a modified file", outcome, OutcomeQueued)
+			}
+			fi, err := os.Stat(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if jn.verified(path, fi, j.Sum) {
+				t.Error("journal entry of the modified file was not invalidated")
+			}
+		})
+	}
+}
+
+func TestJournalInvalidatesChangedSum(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "a.jar")
+	writeTestFile(t, path, testContent)
+	fi, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	jn := NewJournal()
+	jn.record(path, fi, sha1Sum(testContent))
+	if jn.verified(path, fi, sha1Sum(testOtherContent)) {
+		t.Error("verified() = true for a different expected sum")
+	}
+	if jn.verified(path, fi, sha1Sum(testContent)) {
+		t.Error("verified() = true after the entry was invalidated")
+	}
+}
+
+func TestLoadJournal(t *testing.T) {
+	dir := t.TempDir()
+	jn, err := LoadJournal(filepath.Join(dir, "missing.json"))
+	if err != nil {
+		t.Fatalf("LoadJournal() of a missing file error = %v", err)
+	}
+	if len(jn.files) != 0 {
+		t.Errorf("LoadJournal() of a missing file has %d entries, want 0", len(jn.files))
+	}
+
+	for name, content := range map[string]string{
+		"corrupt.json": "{",
+		"future.json":  `{"version": 99, "files": {}}`,
+	} {
+		path := filepath.Join(dir, name)
+		writeTestFile(t, path, []byte(content))
+		if _, err = LoadJournal(path); err == nil {
+			t.Errorf("LoadJournal(%q) succeeded, want error", name)
+		}
+	}
+}
+
+// placeFiles downloads, copies, and migrates files into place under dir through a precheck fleet
+// and a download fleet with the given options, and returns the jobs of the files and their content.
+func placeFiles(t *testing.T, dir string, opts ...Option) ([]Job, [][]byte) {
+	t.Helper()
+	names := []string{"downloaded.jar", "downloadedtwice.jar", "partfile.jar", "copied.jar", "moved.jar", "migratedcopy.jar"}
+	files := make(map[string][]byte, len(names))
+	contents := make([][]byte, len(names))
+	for i, name := range names {
+		contents[i] = append([]byte(name+": "), testContent...)
+		files["/"+name] = contents[i]
+	}
+	srv := newFileServer(t, files)
+
+	jobs := make([]Job, len(names))
+	for i, name := range names {
+		jobs[i] = newTestJob(filepath.Join(dir, "client", "mods", name), contents[i])
+		jobs[i].DownloadURL = srv.URL + "/" + name
+	}
+	jobs[1].SecondaryDestinationPath = filepath.Join(dir, "server", "mods", names[1])
+	jobs[2].UsePartFile = true
+	jobs[3].SecondaryDestinationPath = filepath.Join(dir, "server", "mods", names[3])
+	writeTestFile(t, jobs[3].SecondaryDestinationPath, contents[3])
+	for i, mode := range map[int]MigrationMode{4: MigrationModeMove, 5: MigrationModeCopy} {
+		jobs[i].MigrateFromPath = filepath.Join(dir, "old", "mods", names[i])
+		jobs[i].MigrationMode = mode
+		writeTestFile(t, jobs[i].MigrateFromPath, contents[i])
+	}
+
+	pjch := make(chan Job)
+	pwf := NewWorkerFleet(context.Background(), testLogger, 2, pjch, opts...)
+	dwf := download.NewWorkerFleet(context.Background(), testLogger, http.DefaultClient, 2, pwf.DownloadJobChannel())
+	for _, j := range jobs {
+		pjch <- j
+	}
+	close(pjch)
+	pwf.Wait()
+	dwf.Wait()
+
+	stats := pwf.Stats()
+	if stats.Queued != 3 || stats.Copied != 2 || stats.Moved != 1 || dwf.Failures() != 0 {
+		t.Fatalf("precheck stats = %+v, download failures = %d, want 3 queued, 2 copied, 1 moved, no failures", stats, dwf.Failures())
+	}
+	return jobs, contents
+}
+
+// assertPlacedFilesTrusted tampers with the files placed by [placeFiles], preserving their
+// size and modification time, and fails the test if running their jobs again hashes them.
+func assertPlacedFilesTrusted(t *testing.T, jobs []Job, contents [][]byte, opts ...Option) {
+	t.Helper()
+	for i := range jobs {
+		for _, path := range []string{jobs[i].DestinationPath, jobs[i].SecondaryDestinationPath} {
+			if path != "" {
+				tamperPreservingModTime(t, path, bytes.Repeat([]byte{'x'}, len(contents[i])))
+			}
+		}
+	}
+
+	pjch := make(chan Job)
+	pwf := NewWorkerFleet(context.Background(), testLogger, 2, pjch, opts...)
+	go func() {
+		for dj := range pwf.DownloadJobChannel() {
+			dj.TargetFile.Close()
+			if dj.SecondaryTargetFile != nil {
+				dj.SecondaryTargetFile.Close()
+			}
+			if dj.OnClose != nil {
+				dj.OnClose()
+			}
+		}
+	}()
+	for _, j := range jobs {
+		j.MigrateFromPath = ""
+		pjch <- j
+	}
+	close(pjch)
+	pwf.Wait()
+	if got := pwf.Stats().Skipped; got != int64(len(jobs)) {
+		t.Errorf("Stats().Skipped = %d, want %d without hashing", got, len(jobs))
+	}
+}
+
+func TestJournalRecordsPlacedFiles(t *testing.T) {
+	dir := t.TempDir()
+	jn := NewJournal()
+	jobs, contents := placeFiles(t, dir, WithJournal(jn))
+
+	journalPath := filepath.Join(dir, "journal.json")
+	if err := jn.Save(journalPath); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadJournal(journalPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertPlacedFilesTrusted(t, jobs, contents, WithJournal(loaded))
+}
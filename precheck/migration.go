@@ -0,0 +1,255 @@
+package precheck
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+
+	"github.com/lmittmann/tint"
+)
+
+// MigrationMode specifies how an existing file is migrated from the migration source.
+type MigrationMode uint8
+
+const (
+	// MigrationModeMove moves the file, falling back to copy & remove
+	// if the file cannot be renamed.
+	MigrationModeMove MigrationMode = iota
+
+	// MigrationModeCopy copies the file, preserving the migration source.
+	MigrationModeCopy
+
+	// MigrationModeHardlink creates a hard link to the file, preserving the migration source.
+	// It falls back to copy if the file cannot be linked, e.g. across filesystems.
+	MigrationModeHardlink
+
+	// MigrationModeReflink makes copy-on-write clones of the file, preserving the migration source.
+	// It falls back to copy if the filesystem does not support reflinks.
+	//
+	// Reflinks are also used when copying between the destination paths.
+	MigrationModeReflink
+)
+
+// ErrUnknownMigrationMode is returned when parsing an unknown migration mode.
+var ErrUnknownMigrationMode = errors.New("unknown migration mode")
+
+// String returns the string representation of the migration mode.
+func (m MigrationMode) String() string {
+	switch m {
+	case MigrationModeMove:
+		return "move"
+	case MigrationModeCopy:
+		return "copy"
+	case MigrationModeHardlink:
+		return "hardlink"
+	case MigrationModeReflink:
+		return "reflink"
+	default:
+		return fmt.Sprintf("MigrationMode(%d)", m)
+	}
+}
+
+// MarshalText implements [encoding.TextMarshaler].
+func (m MigrationMode) MarshalText() ([]byte, error) {
+	return []byte(m.String()), nil
+}
+
+// UnmarshalText implements [encoding.TextUnmarshaler].
+func (m *MigrationMode) UnmarshalText(text []byte) error {
+	switch string(text) {
+	case "move":
+		*m = MigrationModeMove
+	case "copy":
+		*m = MigrationModeCopy
+	case "hardlink":
+		*m = MigrationModeHardlink
+	case "reflink":
+		*m = MigrationModeReflink
+	default:
+		return fmt.Errorf("%w: %q", ErrUnknownMigrationMode, text)
+	}
+	return nil
+}
+
+// PreservesSource returns whether the migration mode leaves the migration source in place.
+func (m MigrationMode) PreservesSource() bool {
+	return m != MigrationModeMove
+}
+
+// outcome returns the outcome of a successful migration in this mode.
+func (m MigrationMode) outcome() Outcome {
+	switch m {
+	case MigrationModeMove:
+		return OutcomeMoved
+	case MigrationModeHardlink:
+		return OutcomeLinked
+	default:
+		return OutcomeCopied
+	}
+}
+
+// linkFile replaces the file at newname with a hard link to oldname.
+func linkFile(fsys fileSystem, oldname, newname string) error {
+	if err := fsys.Remove(newname); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return fsys.Link(oldname, newname)
+}
+
+// linkMigrationSource replaces the file at dstPath with a hard link to the migration source file.
+// If the link cannot be created, it falls back to copying the file.
+// It returns whether the file is in place.
+func (j *Job) linkMigrationSource(ctx context.Context, logger *slog.Logger, dstPath string) bool {
+	err := linkFile(j.filesystem(), j.MigrateFromPath, dstPath)
+	if err == nil {
+		logger.LogAttrs(ctx, slog.LevelInfo, "Linked existing file",
+			slog.String("src", j.MigrateFromPath),
+			slog.String("dst", dstPath),
+		)
+		return true
+	}
+
+	logger.LogAttrs(ctx, slog.LevelDebug, "Link failed, falling back to copy",
+		slog.String("src", j.MigrateFromPath),
+		slog.String("dst", dstPath),
+		tint.Err(err),
+	)
+
+	dst, err := j.createFile(dstPath)
+	if err != nil {
+		logger.LogAttrs(ctx, slog.LevelWarn, "Failed to create file",
+			slog.String("path", dstPath),
+			tint.Err(err),
+		)
+		return false
+	}
+	defer dst.Close()
+
+	src, err := j.filesystem().Open(j.MigrateFromPath)
+	if err != nil {
+		logger.LogAttrs(ctx, slog.LevelWarn, "Failed to open file at migration source path",
+			slog.String("path", j.MigrateFromPath),
+			tint.Err(err),
+		)
+		return false
+	}
+	defer src.Close()
+
+	if err = j.copyFile(ctx, logger, dst, src); err != nil {
+		logger.LogAttrs(ctx, slog.LevelWarn, "Failed to copy file",
+			slog.String("src", src.Name()),
+			slog.String("dst", dst.Name()),
+			tint.Err(err),
+		)
+		return false
+	}
+
+	logger.LogAttrs(ctx, slog.LevelInfo, "Copied existing file",
+		slog.String("src", src.Name()),
+		slog.String("dst", dst.Name()),
+	)
+	return true
+}
+
+// migrationTempSuffix is appended to destination paths to name the temporary files
+// that migration sources are copied to before being renamed into place.
+const migrationTempSuffix = ".migrating"
+
+// copyMigrationSource copies the migration source file to dstPath, before the source is removed
+// when falling back to copy & remove. The content is written to a temporary file next to dstPath,
+// synced to disk, and then renamed into place, so that an interrupted migration never leaves
+// a partial copy at dstPath, nor a removed source without a complete copy.
+// It returns whether the file is in place.
+func (j *Job) copyMigrationSource(ctx context.Context, logger *slog.Logger, dstPath string) bool {
+	tmpPath := dstPath + migrationTempSuffix
+	if err := j.copyToTempAndRename(ctx, logger, tmpPath, dstPath); err != nil {
+		logger.LogAttrs(ctx, slog.LevelWarn, "Failed to copy file",
+			slog.String("src", j.MigrateFromPath),
+			slog.String("dst", dstPath),
+			tint.Err(err),
+		)
+		if err = j.filesystem().Remove(tmpPath); err != nil && !errors.Is(err, os.ErrNotExist) {
+			logger.LogAttrs(ctx, slog.LevelWarn, "Failed to remove temporary file",
+				slog.String("path", tmpPath),
+				tint.Err(err),
+			)
+		}
+		return false
+	}
+
+	logger.LogAttrs(ctx, slog.LevelInfo, "Copied existing file",
+		slog.String("src", j.MigrateFromPath),
+		slog.String("dst", dstPath),
+	)
+	return true
+}
+
+// copyToTempAndRename copies the migration source file to tmpPath, syncs it, and renames it to dstPath.
+func (j *Job) copyToTempAndRename(ctx context.Context, logger *slog.Logger, tmpPath, dstPath string) error {
+	src, err := j.filesystem().Open(j.MigrateFromPath)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	tmp, err := j.createFile(tmpPath)
+	if err != nil {
+		return err
+	}
+	err = j.copyFile(ctx, logger, tmp, src)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	return j.filesystem().Rename(tmpPath, dstPath)
+}
+
+// removeMigrationSource removes the migration source file after it has been copied into place,
+// and returns the outcome of the migration.
+func (j *Job) removeMigrationSource(ctx context.Context, logger *slog.Logger) Outcome {
+	if err := j.filesystem().Remove(j.MigrateFromPath); err != nil {
+		logger.LogAttrs(ctx, slog.LevelWarn, "Failed to remove migration source file",
+			slog.String("path", j.MigrateFromPath),
+			tint.Err(err),
+		)
+		return OutcomeCopied
+	}
+
+	logger.LogAttrs(ctx, slog.LevelInfo, "Removed migration source file", slog.String("path", j.MigrateFromPath))
+	return OutcomeMoved
+}
+
+// copyFile replaces the content of dst with the content of src from its current offset.
+//
+// In reflink mode, it first attempts to clone the entire file,
+// and falls back to a regular copy if that fails.
+func (j *Job) copyFile(ctx context.Context, logger *slog.Logger, dst, src file) error {
+	if j.MigrationMode == MigrationModeReflink {
+		err := reflinkFile(dst, src)
+		if err == nil {
+			return nil
+		}
+		logger.LogAttrs(ctx, slog.LevelDebug, "Reflink failed, falling back to copy",
+			slog.String("src", src.Name()),
+			slog.String("dst", dst.Name()),
+			tint.Err(err),
+		)
+	}
+
+	if err := dst.Truncate(0); err != nil {
+		return err
+	}
+	if _, err := dst.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	_, err := dst.ReadFrom(src)
+	return err
+}
@@ -0,0 +1,348 @@
+package precheck
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// skipIfNoHardlinks skips the test if hard links cannot be created in dir.
+func skipIfNoHardlinks(t *testing.T, dir string) {
+	t.Helper()
+	src := filepath.Join(dir, ".link-probe")
+	writeTestFile(t, src, nil)
+	defer os.Remove(src)
+	if err := os.Link(src, src+".link"); err != nil {
+		t.Skipf("hard links not supported: %v", err)
+	}
+	os.Remove(src + ".link")
+}
+
+// assertSameFile fails the test if the files at the given paths are not the same file.
+func assertSameFile(t *testing.T, path1, path2 string) {
+	t.Helper()
+	fi1, err := os.Stat(path1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fi2, err := os.Stat(path2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !os.SameFile(fi1, fi2) {
+		t.Errorf("%q and %q are not the same file", path1, path2)
+	}
+}
+
+func TestJobRunHardlinksMigrationSource(t *testing.T) {
+	dir := t.TempDir()
+	skipIfNoHardlinks(t, dir)
+	src := filepath.Join(dir, "old", "mods", "a.jar")
+	dst := filepath.Join(dir, "new", "mods", "a.jar")
+	writeTestFile(t, src, testContent)
+
+	j := newTestJob(dst, testContent)
+	j.MigrateFromPath = src
+	j.MigrationMode = MigrationModeHardlink
+	if outcome, _ := runTestJob(t, &j); outcome != OutcomeLinked {
+		t.Fatalf("outcome = %s, want %s", outcome, OutcomeLinked)
+	}
+	assertSameFile(t, src, dst)
+}
+
+func TestJobRunHardlinksMigrationSourceToBothDestinations(t *testing.T) {
+	dir := t.TempDir()
+	skipIfNoHardlinks(t, dir)
+	src := filepath.Join(dir, "old", "mods", "a.jar")
+	client := filepath.Join(dir, "client", "mods", "a.jar")
+	server := filepath.Join(dir, "server", "mods", "a.jar")
+	writeTestFile(t, src, testContent)
+
+	j := newTestJob(client, testContent)
+	j.SecondaryDestinationPath = server
+	j.MigrateFromPath = src
+	j.MigrationMode = MigrationModeHardlink
+	if outcome, _ := runTestJob(t, &j); outcome != OutcomeLinked {
+		t.Fatalf("outcome = %s, want %s", outcome, OutcomeLinked)
+	}
+	assertSameFile(t, src, client)
+	assertSameFile(t, src, server)
+}
+
+func TestJobRunMovesMigrationSource(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "old", "mods", "a.jar")
+	dst := filepath.Join(dir, "new", "mods", "a.jar")
+	writeTestFile(t, src, testContent)
+
+	j := newTestJob(dst, testContent)
+	j.MigrateFromPath = src
+	j.MigrationMode = MigrationModeMove
+	if outcome, _ := runTestJob(t, &j); outcome != OutcomeMoved {
+		t.Fatalf("outcome = %s, want %s", outcome, OutcomeMoved)
+	}
+	if got := readTestFile(t, dst); !bytes.Equal(got, testContent) {
+		t.Errorf("moved content = %q, want %q", got, testContent)
+	}
+	if _, err := os.Stat(src); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("migration source still exists after move: %v", err)
+	}
+}
+
+func TestJobRunCopiesMigrationSource(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "old", "mods", "a.jar")
+	dst := filepath.Join(dir, "new", "mods", "a.jar")
+	writeTestFile(t, src, testContent)
+
+	j := newTestJob(dst, testContent)
+	j.MigrateFromPath = src
+	j.MigrationMode = MigrationModeCopy
+	if outcome, _ := runTestJob(t, &j); outcome != OutcomeCopied {
+		t.Fatalf("outcome = %s, want %s", outcome, OutcomeCopied)
+	}
+	if got := readTestFile(t, dst); !bytes.Equal(got, testContent) {
+		t.Errorf("copied content = %q, want %q", got, testContent)
+	}
+	if got := readTestFile(t, src); !bytes.Equal(got, testContent) {
+		t.Errorf("migration source content = %q, want %q", got, testContent)
+	}
+}
+
+func TestJobRunQueuesMissingMigrationSource(t *testing.T) {
+	for _, c := range []struct {
+		name      string
+		secondary bool
+	}{
+		{"OneDestination", false},
+		{"TwoDestinations", true},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			dir := t.TempDir()
+			j := newTestJob(filepath.Join(dir, "client", "mods", "a.jar"), testContent)
+			if c.secondary {
+				j.SecondaryDestinationPath = filepath.Join(dir, "server", "mods", "a.jar")
+			}
+			j.MigrateFromPath = filepath.Join(dir, "old", "mods", "a.jar")
+			j.MigrationMode = MigrationModeMove
+			outcome, djs := runTestJob(t, &j)
+			if outcome != OutcomeQueued {
+				t.Fatalf("outcome = %s, want %s", outcome, OutcomeQueued)
+			}
+			if dj := djs[0]; c.secondary != (dj.SecondaryTargetFile != nil) {
+				t.Errorf("download job has secondary target file %v, want one: %t", dj.SecondaryTargetFile, c.secondary)
+			}
+		})
+	}
+}
+
+func TestMigrationModeText(t *testing.T) {
+	for _, m := range []MigrationMode{MigrationModeMove, MigrationModeCopy, MigrationModeHardlink, MigrationModeReflink} {
+		text, err := m.MarshalText()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got MigrationMode
+		if err = got.UnmarshalText(text); err != nil {
+			t.Errorf("UnmarshalText(%q) error = %v", text, err)
+		}
+		if got != m {
+			t.Errorf("UnmarshalText(%q) = %v, want %v", text, got, m)
+		}
+	}
+
+	var m MigrationMode
+	if err := m.UnmarshalText([]byte("symlink")); !errors.Is(err, ErrUnknownMigrationMode) {
+		t.Errorf("UnmarshalText(\"symlink\") error = %v, want %v", err, ErrUnknownMigrationMode)
+	}
+}
+
+func TestReflinkFile(t *testing.T) {
+	dir := t.TempDir()
+	srcPath := filepath.Join(dir, "src")
+	writeTestFile(t, srcPath, testContent)
+	src, err := os.Open(srcPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer src.Close()
+	dst, err := os.Create(filepath.Join(dir, "dst"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dst.Close()
+
+	if err = reflinkFile(dst, src); err != nil {
+		t.Skipf("reflinks not supported: %v", err)
+	}
+	if got := readTestFile(t, dst.Name()); !bytes.Equal(got, testContent) {
+		t.Errorf("cloned content = %q, want %q", got, testContent)
+	}
+}
+
+func TestJobRunReflinksMigrationSource(t *testing.T) {
+	// Whether or not the filesystem supports reflinks, the file ends up in place,
+	// and the migration source is preserved.
+	dir := t.TempDir()
+	src := filepath.Join(dir, "old", "mods", "a.jar")
+	client := filepath.Join(dir, "client", "mods", "a.jar")
+	server := filepath.Join(dir, "server", "mods", "a.jar")
+	writeTestFile(t, src, testContent)
+
+	j := newTestJob(client, testContent)
+	j.SecondaryDestinationPath = server
+	j.MigrateFromPath = src
+	j.MigrationMode = MigrationModeReflink
+	if outcome, _ := runTestJob(t, &j); outcome != OutcomeCopied {
+		t.Fatalf("outcome = %s, want %s", outcome, OutcomeCopied)
+	}
+	for _, path := range []string{src, client, server} {
+		if got := readTestFile(t, path); !bytes.Equal(got, testContent) {
+			t.Errorf("content of %q = %q, want %q", path, got, testContent)
+		}
+	}
+}
+
+// errSimulatedCrash is the panic value of [crashingFileSystem].
+var errSimulatedCrash = errors.New("simulated crash")
+
+// crashingFileSystem is a [fileSystem] on which moving the migration source fails like a rename
+// across filesystems, and the process crashes right before a migration copy is renamed into place,
+// leaving behind the first half of the copy.
+type crashingFileSystem struct {
+	osFileSystem
+	migrateFromPath string
+}
+
+// Rename implements [fileSystem.Rename].
+func (fsys crashingFileSystem) Rename(oldpath, newpath string) error {
+	if oldpath == fsys.migrateFromPath {
+		return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: errors.New("invalid cross-device link")}
+	}
+	if filepath.Ext(oldpath) == migrationTempSuffix {
+		fi, err := os.Stat(oldpath)
+		if err == nil {
+			err = os.Truncate(oldpath, fi.Size()/2)
+		}
+		if err != nil {
+			panic(err)
+		}
+		panic(errSimulatedCrash)
+	}
+	return fsys.osFileSystem.Rename(oldpath, newpath)
+}
+
+// runCrashingTestJob runs the job on a [crashingFileSystem], and recovers from the simulated crash.
+func runCrashingTestJob(t *testing.T, j *Job) {
+	t.Helper()
+	j.fsys = crashingFileSystem{migrateFromPath: j.MigrateFromPath}
+	defer func() {
+		if r := recover(); r != errSimulatedCrash {
+			t.Fatalf("recovered %v, want the simulated crash", r)
+		}
+	}()
+	runTestJob(t, j)
+}
+
+func TestMigrationCrashMidCopyKeepsSource(t *testing.T) {
+	for _, c := range []struct {
+		name        string
+		secondary   bool
+		wantOutcome Outcome
+	}{
+		{"OneDestination", false, OutcomeMoved},
+		// The client copy was complete before the crash, so the server copy is made from it,
+		// and the migration source is left alone.
+		{"TwoDestinations", true, OutcomeCopied},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			content := bytes.Repeat(testContent, 100)
+			dir := t.TempDir()
+			src := filepath.Join(dir, "old", "mods", "a.jar")
+			client := filepath.Join(dir, "client", "mods", "a.jar")
+			server := filepath.Join(dir, "server", "mods", "a.jar")
+			writeTestFile(t, src, content)
+
+			newJob := func() Job {
+				j := newTestJob(client, content)
+				if c.secondary {
+					j.SecondaryDestinationPath = server
+				}
+				j.MigrateFromPath = src
+				j.MigrationMode = MigrationModeMove
+				return j
+			}
+
+			j := newJob()
+			runCrashingTestJob(t, &j)
+
+			// The crash leaves the source intact, and no partial copy at a destination path.
+			if got := readTestFile(t, src); !bytes.Equal(got, content) {
+				t.Fatalf("migration source has %d bytes after the crash, want %d", len(got), len(content))
+			}
+			for _, path := range []string{client, server} {
+				if b, err := os.ReadFile(path); err == nil && len(b) != 0 && !bytes.Equal(b, content) {
+					t.Errorf("%q has a partial copy of %d bytes after the crash", path, len(b))
+				}
+			}
+
+			// The next run completes the migration.
+			j = newJob()
+			if outcome, _ := runTestJob(t, &j); outcome != c.wantOutcome {
+				t.Fatalf("outcome of the next run = %s, want %s", outcome, c.wantOutcome)
+			}
+			paths := []string{client}
+			if c.secondary {
+				paths = append(paths, server)
+			}
+			for _, path := range paths {
+				if got := readTestFile(t, path); !bytes.Equal(got, content) {
+					t.Errorf("%q has %d bytes, want %d", path, len(got), len(content))
+				}
+			}
+			if _, err := os.Stat(src); c.wantOutcome == OutcomeMoved && !errors.Is(err, os.ErrNotExist) {
+				t.Errorf("migration source still exists after the next run: %v", err)
+			}
+		})
+	}
+}
+
+func TestMigrationCopyFallbackLeavesNoTempFile(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "old", "mods", "a.jar")
+	dst := filepath.Join(dir, "new", "mods", "a.jar")
+	writeTestFile(t, src, testContent)
+	// A temporary file left behind by a crashed run is replaced.
+	writeTestFile(t, dst+migrationTempSuffix, []byte("stale partial copy"))
+
+	j := newTestJob(dst, testContent)
+	j.MigrateFromPath = src
+	j.MigrationMode = MigrationModeMove
+	j.fsys = crossDeviceFileSystem{migrateFromPath: src}
+	if outcome, _ := runTestJob(t, &j); outcome != OutcomeMoved {
+		t.Fatalf("outcome = %s, want %s", outcome, OutcomeMoved)
+	}
+	if got := readTestFile(t, dst); !bytes.Equal(got, testContent) {
+		t.Errorf("copied content = %q, want %q", got, testContent)
+	}
+	if _, err := os.Stat(dst + migrationTempSuffix); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("temporary file still exists after the migration: %v", err)
+	}
+}
+
+// crossDeviceFileSystem is a [fileSystem] on which moving the migration source fails
+// like a rename across filesystems.
+type crossDeviceFileSystem struct {
+	osFileSystem
+	migrateFromPath string
+}
+
+// Rename implements [fileSystem.Rename].
+func (fsys crossDeviceFileSystem) Rename(oldpath, newpath string) error {
+	if oldpath == fsys.migrateFromPath {
+		return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: errors.New("invalid cross-device link")}
+	}
+	return fsys.osFileSystem.Rename(oldpath, newpath)
+}
@@ -0,0 +1,112 @@
+package precheck
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/database64128/modpack-dl-go/download"
+)
+
+// keepModified returns whether the file, which failed the check, is to be kept as is,
+// because NoClobber is set and the file has content, and logs that it differs.
+func (j *Job) keepModified(ctx context.Context, logger *slog.Logger, f file) bool {
+	if !j.NoClobber {
+		return false
+	}
+	fi, err := f.Stat()
+	if err != nil || fi.Size() == 0 {
+		return false
+	}
+	logger.LogAttrs(ctx, slog.LevelInfo, "Keeping locally modified file",
+		slog.String("path", f.Name()),
+		slog.Int64("expectedSize", j.Size),
+		slog.Int64("actualSize", fi.Size()),
+	)
+	return true
+}
+
+// runKeepingModified handles the files at both destination paths, of which at most one is valid,
+// when NoClobber is set. Modified files are kept as is. The other file is left alone if it's valid,
+// or downloaded otherwise. It returns the outcome, and whether any file was modified and the files were handled.
+// Otherwise, the files are left open for the caller.
+func (j *Job) runKeepingModified(ctx context.Context, logger *slog.Logger, djch chan<- download.Job, f1 file, ok1 bool, f2 file, ok2 bool) (Outcome, bool) {
+	modified1 := !ok1 && j.keepModified(ctx, logger, f1)
+	modified2 := !ok2 && j.keepModified(ctx, logger, f2)
+
+	switch {
+	case modified1 && modified2:
+		f1.Close()
+		f2.Close()
+		return OutcomeSkipped, true
+
+	case modified1:
+		f1.Close()
+		if ok2 {
+			f2.Close()
+			return OutcomeSkipped, true
+		}
+		return j.sendDownloadJob(ctx, logger, djch, f2, nil), true
+
+	case modified2:
+		f2.Close()
+		if ok1 {
+			f1.Close()
+			return OutcomeSkipped, true
+		}
+		return j.sendDownloadJob(ctx, logger, djch, f1, nil), true
+	}
+
+	return 0, false
+}
+
+// modifiedAtPath returns whether NoClobber is set and the file at the given path,
+// which failed the check, exists with content.
+func (j *Job) modifiedAtPath(path string) bool {
+	if !j.NoClobber {
+		return false
+	}
+	fi, err := j.filesystem().Stat(path)
+	return err == nil && fi.Mode().IsRegular() && fi.Size() > 0
+}
+
+// dryRunKeepingModified logs the action the job would take when NoClobber is set
+// and the file at either destination path has been modified. ok1 and ok2 are the results
+// of checking the files at the destination paths, of which at most one is valid.
+// It returns the planned outcome, and whether any file was modified.
+func (j *Job) dryRunKeepingModified(ctx context.Context, logger *slog.Logger, ok1, ok2 bool) (Outcome, bool) {
+	modified1 := !ok1 && j.modifiedAtPath(j.DestinationPath)
+	modified2 := !ok2 && j.SecondaryDestinationPath != "" && j.modifiedAtPath(j.SecondaryDestinationPath)
+	if !modified1 && !modified2 {
+		return 0, false
+	}
+
+	for _, m := range [...]struct {
+		modified bool
+		path     string
+	}{{modified1, j.DestinationPath}, {modified2, j.SecondaryDestinationPath}} {
+		if m.modified {
+			logger.LogAttrs(ctx, slog.LevelInfo, "Would keep locally modified file",
+				slog.String("path", m.path),
+			)
+		}
+	}
+
+	// The path that is neither valid nor modified, if any, is downloaded to.
+	path := ""
+	switch {
+	case !ok1 && !modified1:
+		path = j.DestinationPath
+	case j.SecondaryDestinationPath != "" && !ok2 && !modified2:
+		path = j.SecondaryDestinationPath
+	}
+	if path == "" {
+		return OutcomeSkipped, true
+	}
+
+	logger.LogAttrs(ctx, slog.LevelInfo, "Would download file",
+		slog.String("url", j.DownloadURL),
+		slog.String("path", path),
+		slog.Int64("size", j.Size),
+	)
+	return OutcomeQueued, true
+}
@@ -0,0 +1,144 @@
+package precheck
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// newFileServer returns a test server that serves the given content by URL path.
+func newFileServer(t *testing.T, files map[string][]byte) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		content, ok := files[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(content))
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestNoClobberKeepsEditedConfigWhileRefreshingMods(t *testing.T) {
+	dir := t.TempDir()
+	newConfig := []byte("[client]\nfov = 70\n")
+	newMod := bytes.Repeat([]byte("new mod "), 1000)
+	srv := newFileServer(t, map[string][]byte{
+		"/config/a.toml": newConfig,
+		"/mods/a.jar":    newMod,
+	})
+
+	configPath := filepath.Join(dir, "config", "a.toml")
+	modPath := filepath.Join(dir, "mods", "a.jar")
+	editedConfig := []byte("[client]\nfov = 110 # edited\n")
+	writeTestFile(t, configPath, editedConfig)
+	writeTestFile(t, modPath, []byte("old mod"))
+
+	config := newTestJob(configPath, newConfig)
+	config.DownloadURL = srv.URL + "/config/a.toml"
+	config.NoClobber = true
+	mod := newTestJob(modPath, newMod)
+	mod.DownloadURL = srv.URL + "/mods/a.jar"
+
+	pwf, dwf := runFleets(t, config, mod)
+	if got := dwf.Failures(); got != 0 {
+		t.Fatalf("download failures = %d, want 0", got)
+	}
+	if got := pwf.Stats().Skipped; got != 1 {
+		t.Errorf("skipped = %d, want 1 for the edited config", got)
+	}
+	if got := readTestFile(t, configPath); !bytes.Equal(got, editedConfig) {
+		t.Errorf("config = %q, want the edited %q", got, editedConfig)
+	}
+	if got := readTestFile(t, modPath); !bytes.Equal(got, newMod) {
+		t.Errorf("mod has %d bytes, want the refreshed %d bytes", len(got), len(newMod))
+	}
+}
+
+func TestNoClobberDoesNotKeepInterruptedDownload(t *testing.T) {
+	content := bytes.Repeat([]byte("config line\n"), 1000)
+	broken := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header()["Content-Length"] = []string{strconv.Itoa(len(content))}
+		_, _ = w.Write(content[:len(content)/2])
+		panic(http.ErrAbortHandler)
+	}))
+	defer broken.Close()
+
+	path := filepath.Join(t.TempDir(), "config", "big.toml")
+	j := newTestJob(path, content)
+	j.DownloadURL = broken.URL
+	j.NoClobber = true
+	if _, dwf := runFleets(t, j); dwf.Failures() != 1 {
+		t.Fatalf("download failures = %d, want 1", dwf.Failures())
+	}
+	if _, err := os.Stat(path); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("interrupted download left content at the destination path: %v", err)
+	}
+
+	// The next run downloads the file instead of keeping the partial content as a local edit.
+	j.DownloadURL = newFileServer(t, map[string][]byte{"/": content}).URL
+	pwf, dwf := runFleets(t, j)
+	if dwf.Failures() != 0 {
+		t.Fatalf("download failures = %d, want 0", dwf.Failures())
+	}
+	if got := pwf.Stats().Skipped; got != 0 {
+		t.Errorf("skipped = %d, want 0", got)
+	}
+	if got := readTestFile(t, path); !bytes.Equal(got, content) {
+		t.Errorf("content has %d bytes, want %d", len(got), len(content))
+	}
+}
+
+func TestNoClobberDownloadsMissingAndEmptyFiles(t *testing.T) {
+	dir := t.TempDir()
+	srv := newFileServer(t, map[string][]byte{"/": testContent})
+	missing := filepath.Join(dir, "config", "missing.toml")
+	empty := filepath.Join(dir, "config", "empty.toml")
+	writeTestFile(t, empty, nil)
+
+	var jobs []Job
+	for _, path := range []string{missing, empty} {
+		j := newTestJob(path, testContent)
+		j.DownloadURL = srv.URL
+		j.NoClobber = true
+		jobs = append(jobs, j)
+	}
+	if _, dwf := runFleets(t, jobs...); dwf.Failures() != 0 {
+		t.Fatalf("download failures = %d, want 0", dwf.Failures())
+	}
+	for _, path := range []string{missing, empty} {
+		if got := readTestFile(t, path); !bytes.Equal(got, testContent) {
+			t.Errorf("content of %q = %q, want %q", path, got, testContent)
+		}
+	}
+}
+
+func TestNoClobberKeepsEditAtOneDestination(t *testing.T) {
+	dir := t.TempDir()
+	srv := newFileServer(t, map[string][]byte{"/": testContent})
+	client := filepath.Join(dir, "client", "config", "a.toml")
+	server := filepath.Join(dir, "server", "config", "a.toml")
+	writeTestFile(t, client, testOtherContent)
+
+	j := newTestJob(client, testContent)
+	j.SecondaryDestinationPath = server
+	j.DownloadURL = srv.URL
+	j.NoClobber = true
+	if _, dwf := runFleets(t, j); dwf.Failures() != 0 {
+		t.Fatalf("download failures = %d, want 0", dwf.Failures())
+	}
+	if got := readTestFile(t, client); !bytes.Equal(got, testOtherContent) {
+		t.Errorf("client content = %q, want the edited %q", got, testOtherContent)
+	}
+	if got := readTestFile(t, server); !bytes.Equal(got, testContent) {
+		t.Errorf("server content = %q, want %q", got, testContent)
+	}
+}
@@ -0,0 +1,60 @@
+package precheck
+
+import (
+	"context"
+	"sync"
+)
+
+// openFileBudget caps the number of files created by precheck jobs
+// that are open and waiting to be, or being, downloaded.
+//
+// A job that may need downloading takes one unit for its destination file,
+// and one more if it has a secondary destination, before creating any files.
+// If the job is handed off to the download fleet, the units are returned when the download job
+// closes its files. Otherwise, they are returned when the precheck job finishes.
+//
+// Files opened only to check or migrate existing content are closed before the precheck job
+// finishes, so they are bounded by the number of precheck workers instead, and not counted.
+type openFileBudget struct {
+	// mu serializes acquisitions, so that jobs taking more than one unit
+	// cannot deadlock by each holding part of what they need.
+	mu     sync.Mutex
+	tokens chan struct{}
+}
+
+// newOpenFileBudget returns a budget of n open files.
+func newOpenFileBudget(n int) *openFileBudget {
+	return &openFileBudget{tokens: make(chan struct{}, n)}
+}
+
+// acquire waits until n files can be opened, and returns a function that returns them to the budget.
+// n is capped at the size of the budget. If ctx is canceled first, it returns false.
+func (b *openFileBudget) acquire(ctx context.Context, n int) (release func(), ok bool) {
+	n = min(n, cap(b.tokens))
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for i := 0; i < n; i++ {
+		select {
+		case b.tokens <- struct{}{}:
+		case <-ctx.Done():
+			b.releaseN(i)
+			return nil, false
+		}
+	}
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			b.releaseN(n)
+		})
+	}, true
+}
+
+// releaseN returns n files to the budget.
+func (b *openFileBudget) releaseN(n int) {
+	for i := 0; i < n; i++ {
+		<-b.tokens
+	}
+}
@@ -0,0 +1,111 @@
+package precheck
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/database64128/modpack-dl-go/download"
+)
+
+// closeDownloadJob closes the files of a download job as the download fleet would.
+func closeDownloadJob(dj download.Job) {
+	dj.TargetFile.Close()
+	if dj.SecondaryTargetFile != nil {
+		dj.SecondaryTargetFile.Close()
+	}
+	if dj.OnClose != nil {
+		dj.OnClose()
+	}
+}
+
+func TestWorkerFleetCapsOpenFiles(t *testing.T) {
+	const (
+		maxOpenFiles = 3
+		jobs         = 10
+	)
+	dir := t.TempDir()
+
+	pjch := make(chan Job)
+	wf := NewWorkerFleet(context.Background(), testLogger, 4, pjch, WithMaxOpenFiles(maxOpenFiles))
+	go func() {
+		for i := range jobs {
+			j := newTestJob(filepath.Join(dir, "client", fmt.Sprintf("%d.jar", i)), testContent)
+			if i%2 == 0 {
+				j.SecondaryDestinationPath = filepath.Join(dir, "server", fmt.Sprintf("%d.jar", i))
+			}
+			pjch <- j
+		}
+		close(pjch)
+	}()
+
+	var (
+		held     []download.Job
+		open     int
+		received int
+		maxOpen  int
+	)
+	djch := wf.DownloadJobChannel()
+	for received < jobs {
+		select {
+		case dj := <-djch:
+			received++
+			open++
+			if dj.SecondaryTargetFile != nil {
+				open++
+			}
+			maxOpen = max(maxOpen, open)
+			held = append(held, dj)
+		case <-time.After(50 * time.Millisecond):
+			// The precheck workers had a chance to exceed the cap.
+			if len(held) == 0 {
+				continue
+			}
+			// Finish the oldest download to make room.
+			dj := held[0]
+			held = held[1:]
+			open--
+			if dj.SecondaryTargetFile != nil {
+				open--
+			}
+			closeDownloadJob(dj)
+		}
+	}
+	for _, dj := range held {
+		closeDownloadJob(dj)
+	}
+	wf.Wait()
+
+	if received != jobs {
+		t.Errorf("received %d download jobs, want %d", received, jobs)
+	}
+	if maxOpen > maxOpenFiles {
+		t.Errorf("max open files = %d, want at most %d", maxOpen, maxOpenFiles)
+	}
+	if maxOpen < maxOpenFiles-1 {
+		t.Errorf("max open files = %d, want the cap %d to be used", maxOpen, maxOpenFiles)
+	}
+}
+
+func TestOpenFileBudgetAcquireRespectsCancellation(t *testing.T) {
+	b := newOpenFileBudget(1)
+	release, ok := b.acquire(context.Background(), 1)
+	if !ok {
+		t.Fatal("acquire() failed on an empty budget")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, ok = b.acquire(ctx, 1); ok {
+		t.Fatal("acquire() succeeded on a full budget")
+	}
+
+	release()
+	release() // no-op
+	if release, ok = b.acquire(context.Background(), 2); !ok {
+		t.Fatal("acquire() failed after release")
+	}
+	release()
+}
@@ -0,0 +1,124 @@
+package precheck
+
+import (
+	"context"
+	"os"
+
+	"github.com/database64128/modpack-dl-go/download"
+)
+
+// config holds the settings shared by the jobs run by a [WorkerFleet].
+type config struct {
+	eventHandler download.EventHandler
+	openFiles    *openFileBudget
+	journal      *Journal
+	xattrCache   bool
+	verifyCopies bool
+	touch        bool
+	fileMode     os.FileMode
+	dirMode      os.FileMode
+
+	// fsys, if not nil, replaces the os package for the filesystem operations of jobs.
+	fsys fileSystem
+
+	// drain, if not nil, is closed when the fleet is to stop starting new jobs.
+	drain <-chan struct{}
+}
+
+// newConfig returns a new config with the given options applied.
+func newConfig(opts []Option) *config {
+	var cfg config
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	return &cfg
+}
+
+// Option configures optional behavior of a [WorkerFleet].
+type Option func(*config)
+
+// WithEventHandler sets the handler to notify of files that are skipped or fail the precheck.
+// Files queued for download are reported by the download fleet.
+func WithEventHandler(h download.EventHandler) Option {
+	return func(c *config) {
+		c.eventHandler = h
+	}
+}
+
+// WithMaxOpenFiles caps the number of files created for download that are open at the same time,
+// including files waiting to be picked up by the download fleet and files being downloaded.
+// When the cap is reached, precheck workers wait for downloads to finish before creating more files.
+// A job with a secondary destination path counts as two files.
+//
+// Non-positive values mean no cap.
+func WithMaxOpenFiles(n int) Option {
+	return func(c *config) {
+		if n > 0 {
+			c.openFiles = newOpenFileBudget(n)
+		} else {
+			c.openFiles = nil
+		}
+	}
+}
+
+// WithJournal sets the journal to skip hashing files that have been verified
+// and not modified since, and to record newly verified files in.
+func WithJournal(jn *Journal) Option {
+	return func(c *config) {
+		c.journal = jn
+	}
+}
+
+// WithXattrCache enables recording verified files in an extended attribute of each file,
+// along with its size and modification time, and skipping hashing files whose record matches.
+// On platforms and filesystems without extended attribute support, files are always hashed.
+func WithXattrCache() Option {
+	return func(c *config) {
+		c.xattrCache = true
+	}
+}
+
+// WithCopyVerification enables hashing the destination file again after copying
+// the other destination file of a job to it, and downloading the file instead
+// if the copy does not match the expected hash sum.
+func WithCopyVerification() Option {
+	return func(c *config) {
+		c.verifyCopies = true
+	}
+}
+
+// WithTouchExisting enables setting the modification time of existing files that pass the check
+// to the job's [Job.ModTime], without downloading them again, for files whose modification time
+// has been lost, e.g. by restoring them from a backup.
+func WithTouchExisting() Option {
+	return func(c *config) {
+		c.touch = true
+	}
+}
+
+// WithFileModes sets the permission bits of the files and directories created by the fleet,
+// which are subject to the umask. Zero keeps the default of 0644 for files or 0755 for directories.
+// The modes of existing files and directories are left alone.
+func WithFileModes(fileMode, dirMode os.FileMode) Option {
+	return func(c *config) {
+		c.fileMode = fileMode.Perm()
+		c.dirMode = dirMode.Perm()
+	}
+}
+
+// withFileSystem makes the jobs of the fleet operate on fsys instead of the os package.
+// It is used by tests to inject filesystem failures.
+func withFileSystem(fsys fileSystem) Option {
+	return func(c *config) {
+		c.fsys = fsys
+	}
+}
+
+// WithDrain makes the fleet stop starting new jobs once ctx is done.
+// Jobs picked up from then on are skipped, while jobs already running
+// carry on under the fleet's context.
+func WithDrain(ctx context.Context) Option {
+	return func(c *config) {
+		c.drain = ctx.Done()
+	}
+}
@@ -0,0 +1,85 @@
+package precheck
+
+import (
+	"errors"
+	"strconv"
+)
+
+// ErrPrecheckFailed is the error passed to the event handler when a precheck job fails.
+// The details are logged.
+var ErrPrecheckFailed = errors.New("precheck failed")
+
+// Outcome is the outcome of a precheck job.
+type Outcome uint8
+
+const (
+	// OutcomeFailed means the job failed, and the file may not be in place.
+	OutcomeFailed Outcome = iota
+
+	// OutcomeSkipped means the file already exists at all destination paths.
+	OutcomeSkipped
+
+	// OutcomeMoved means the file was moved from the migration source path.
+	OutcomeMoved
+
+	// OutcomeCopied means the file was copied from an existing file
+	// at the migration source path or another destination path.
+	OutcomeCopied
+
+	// OutcomeLinked means the file was hard linked from the migration source path.
+	OutcomeLinked
+
+	// OutcomeQueued means the file was queued for download.
+	OutcomeQueued
+
+	outcomeCount
+)
+
+// String returns the name of the outcome.
+func (o Outcome) String() string {
+	switch o {
+	case OutcomeFailed:
+		return "failed"
+	case OutcomeSkipped:
+		return "skipped"
+	case OutcomeMoved:
+		return "moved"
+	case OutcomeCopied:
+		return "copied"
+	case OutcomeLinked:
+		return "linked"
+	case OutcomeQueued:
+		return "queued"
+	default:
+		return "Outcome(" + strconv.Itoa(int(o)) + ")"
+	}
+}
+
+// Stats is a snapshot of the counters of a [WorkerFleet].
+type Stats struct {
+	// Skipped is the number of jobs whose files already exist at all destination paths.
+	Skipped int64
+
+	// Moved is the number of jobs whose files were moved from the migration source path.
+	Moved int64
+
+	// Copied is the number of jobs whose files were copied from existing files.
+	Copied int64
+
+	// Linked is the number of jobs whose files were hard linked from the migration source path.
+	Linked int64
+
+	// Queued is the number of jobs whose files were queued for download.
+	Queued int64
+
+	// Failed is the number of failed jobs.
+	Failed int64
+
+	// QueuedDownloadSize is the total expected size of the files queued for download.
+	QueuedDownloadSize int64
+}
+
+// Migrated returns the number of jobs whose files were moved, copied, or linked from existing files.
+func (s Stats) Migrated() int64 {
+	return s.Moved + s.Copied + s.Linked
+}
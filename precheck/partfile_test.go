@@ -0,0 +1,98 @@
+package precheck
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/database64128/modpack-dl-go/download"
+)
+
+// runFleets runs the jobs through a precheck fleet and a download fleet.
+func runFleets(t *testing.T, jobs ...Job) (*WorkerFleet, *download.WorkerFleet) {
+	t.Helper()
+	pjch := make(chan Job)
+	pwf := NewWorkerFleet(context.Background(), testLogger, 2, pjch)
+	dwf := download.NewWorkerFleet(context.Background(), testLogger, http.DefaultClient, 2, pwf.DownloadJobChannel())
+	for _, j := range jobs {
+		pjch <- j
+	}
+	close(pjch)
+	pwf.Wait()
+	dwf.Wait()
+	return pwf, dwf
+}
+
+func TestPartFileKeepsDestinationPathComplete(t *testing.T) {
+	content := bytes.Repeat(testContent, 1000)
+	half := len(content) / 2
+
+	midStream := make(chan struct{})
+	release := make(chan struct{})
+	closeRelease := sync.OnceFunc(func() { close(release) })
+	defer closeRelease()
+	var once sync.Once
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header()["Content-Length"] = []string{strconv.Itoa(len(content))}
+		_, _ = w.Write(content[:half])
+		w.(http.Flusher).Flush()
+		once.Do(func() { close(midStream) })
+		<-release
+		// Kill the download mid-stream.
+		panic(http.ErrAbortHandler)
+	}))
+	defer srv.Close()
+
+	dst := filepath.Join(t.TempDir(), "mods", "a.jar")
+	j := newTestJob(dst, content)
+	j.DownloadURL = srv.URL
+	j.UsePartFile = true
+
+	done := make(chan struct{})
+	var dwf *download.WorkerFleet
+	go func() {
+		defer close(done)
+		_, dwf = runFleets(t, j)
+	}()
+
+	<-midStream
+	if _, err := os.Stat(dst); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("destination path exists mid-download: %v", err)
+	}
+	if _, err := os.Stat(dst + PartFileSuffix); err != nil {
+		t.Errorf("part file does not exist mid-download: %v", err)
+	}
+	closeRelease()
+	<-done
+
+	if got := dwf.Failures(); got != 1 {
+		t.Fatalf("download failures = %d, want 1", got)
+	}
+	if _, err := os.Stat(dst); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("destination path exists after failed download: %v", err)
+	}
+
+	// A later run with a working server puts the complete file in place.
+	okSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(content))
+	}))
+	defer okSrv.Close()
+	j.DownloadURL = okSrv.URL
+	if _, dwf = runFleets(t, j); dwf.Failures() != 0 {
+		t.Fatalf("download failures = %d, want 0", dwf.Failures())
+	}
+	if got := readTestFile(t, dst); !bytes.Equal(got, content) {
+		t.Errorf("destination content has %d bytes, want %d", len(got), len(content))
+	}
+	if _, err := os.Stat(dst + PartFileSuffix); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("part file left behind after successful download: %v", err)
+	}
+}
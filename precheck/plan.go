@@ -0,0 +1,233 @@
+package precheck
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"os"
+	"reflect"
+	"slices"
+	"time"
+)
+
+// PlanVersion is the version of the plan file format.
+const PlanVersion = 1
+
+// HashAlgorithm identifies the hash function that a job's expected sum is computed with,
+// so that [Job.NewHash] can be serialized.
+type HashAlgorithm uint8
+
+const (
+	// HashAlgorithmSHA1 is SHA-1.
+	HashAlgorithmSHA1 HashAlgorithm = iota
+
+	// HashAlgorithmSHA256 is SHA-256.
+	HashAlgorithmSHA256
+)
+
+// ErrUnknownHashAlgorithm is returned when parsing an unknown hash algorithm,
+// or serializing a job whose hash function is not one of the known algorithms.
+var ErrUnknownHashAlgorithm = errors.New("unknown hash algorithm")
+
+// String returns the string representation of the hash algorithm.
+func (a HashAlgorithm) String() string {
+	switch a {
+	case HashAlgorithmSHA1:
+		return "sha1"
+	case HashAlgorithmSHA256:
+		return "sha256"
+	default:
+		return fmt.Sprintf("HashAlgorithm(%d)", a)
+	}
+}
+
+// MarshalText implements [encoding.TextMarshaler].
+func (a HashAlgorithm) MarshalText() ([]byte, error) {
+	return []byte(a.String()), nil
+}
+
+// UnmarshalText implements [encoding.TextUnmarshaler].
+func (a *HashAlgorithm) UnmarshalText(text []byte) error {
+	switch string(text) {
+	case "sha1":
+		*a = HashAlgorithmSHA1
+	case "sha256":
+		*a = HashAlgorithmSHA256
+	default:
+		return fmt.Errorf("%w: %q", ErrUnknownHashAlgorithm, text)
+	}
+	return nil
+}
+
+// NewFunc returns the function that returns a new [hash.Hash] of the algorithm.
+func (a HashAlgorithm) NewFunc() (func() hash.Hash, error) {
+	switch a {
+	case HashAlgorithmSHA1:
+		return sha1.New, nil
+	case HashAlgorithmSHA256:
+		return sha256.New, nil
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnknownHashAlgorithm, a)
+	}
+}
+
+// hashAlgorithmOf returns the algorithm of the given hash function.
+func hashAlgorithmOf(newHash func() hash.Hash) (HashAlgorithm, error) {
+	if newHash != nil {
+		switch reflect.ValueOf(newHash).Pointer() {
+		case reflect.ValueOf(sha1.New).Pointer():
+			return HashAlgorithmSHA1, nil
+		case reflect.ValueOf(sha256.New).Pointer():
+			return HashAlgorithmSHA256, nil
+		}
+	}
+	return 0, ErrUnknownHashAlgorithm
+}
+
+// Plan is the serializable form of a set of planned precheck jobs,
+// for resuming an install later without fetching the manifests again.
+//
+// Only the fields of a [Job] that are derived from the manifests are kept.
+// Fields controlled by how the plan is run, such as DryRun and Verify, are not.
+type Plan struct {
+	// Roots are the directories the files are installed under, for checking free disk space.
+	Roots []string
+
+	// Jobs are the planned jobs.
+	Jobs []PlannedJob
+}
+
+// PlannedJob is the serializable form of a [Job]. The fields are as in Job,
+// except that the hash function is identified by its algorithm.
+type PlannedJob struct {
+	DownloadURL              string        `json:"downloadURL"`
+	Mirrors                  []string      `json:"mirrors,omitempty"`
+	UserAgent                string        `json:"userAgent,omitempty"`
+	MigrateFromPath          string        `json:"migrateFromPath,omitempty"`
+	MigrationMode            MigrationMode `json:"migrationMode"`
+	DestinationPath          string        `json:"destinationPath"`
+	SecondaryDestinationPath string        `json:"secondaryDestinationPath,omitempty"`
+	HashAlgorithm            HashAlgorithm `json:"hashAlgorithm"`
+
+	// Sum is the hex-encoded expected hash sum.
+	Sum string `json:"sum"`
+
+	Size      int64     `json:"size"`
+	ModTime   time.Time `json:"mtime"`
+	NoClobber bool      `json:"noClobber,omitempty"`
+}
+
+// planFile is the on-disk format of a plan.
+type planFile struct {
+	Version int          `json:"version"`
+	Roots   []string     `json:"roots,omitempty"`
+	Jobs    []PlannedJob `json:"jobs"`
+}
+
+// NewPlan returns the plan of the given jobs, installed under the given roots.
+// Empty roots are left out.
+// It returns an error if a job's hash function is not one of the known algorithms.
+func NewPlan(jobs []Job, roots []string) (Plan, error) {
+	p := Plan{
+		Roots: slices.DeleteFunc(slices.Clone(roots), func(root string) bool { return root == "" }),
+		Jobs:  make([]PlannedJob, len(jobs)),
+	}
+	for i := range jobs {
+		j := &jobs[i]
+		alg, err := hashAlgorithmOf(j.NewHash)
+		if err != nil {
+			return Plan{}, fmt.Errorf("job for %q: %w", j.DestinationPath, err)
+		}
+		p.Jobs[i] = PlannedJob{
+			DownloadURL:              j.DownloadURL,
+			Mirrors:                  j.Mirrors,
+			UserAgent:                j.UserAgent,
+			MigrateFromPath:          j.MigrateFromPath,
+			MigrationMode:            j.MigrationMode,
+			DestinationPath:          j.DestinationPath,
+			SecondaryDestinationPath: j.SecondaryDestinationPath,
+			HashAlgorithm:            alg,
+			Sum:                      hex.EncodeToString(j.Sum),
+			Size:                     j.Size,
+			ModTime:                  j.ModTime,
+			NoClobber:                j.NoClobber,
+		}
+	}
+	return p, nil
+}
+
+// PrecheckJobs returns the jobs of the plan.
+func (p *Plan) PrecheckJobs() ([]Job, error) {
+	jobs := make([]Job, len(p.Jobs))
+	for i := range p.Jobs {
+		pj := &p.Jobs[i]
+		if pj.DownloadURL == "" || pj.DestinationPath == "" {
+			return nil, fmt.Errorf("job %d: missing download URL or destination path", i)
+		}
+		newHash, err := pj.HashAlgorithm.NewFunc()
+		if err != nil {
+			return nil, fmt.Errorf("job for %q: %w", pj.DestinationPath, err)
+		}
+		sum, err := hex.DecodeString(pj.Sum)
+		if err != nil {
+			return nil, fmt.Errorf("job for %q: failed to decode sum: %w", pj.DestinationPath, err)
+		}
+		jobs[i] = Job{
+			DownloadURL:              pj.DownloadURL,
+			Mirrors:                  pj.Mirrors,
+			UserAgent:                pj.UserAgent,
+			MigrateFromPath:          pj.MigrateFromPath,
+			MigrationMode:            pj.MigrationMode,
+			DestinationPath:          pj.DestinationPath,
+			SecondaryDestinationPath: pj.SecondaryDestinationPath,
+			NewHash:                  newHash,
+			Sum:                      sum,
+			Size:                     pj.Size,
+			ModTime:                  pj.ModTime,
+			NoClobber:                pj.NoClobber,
+		}
+	}
+	return jobs, nil
+}
+
+// LoadPlan loads the plan from the file at path.
+func LoadPlan(path string) (Plan, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return Plan{}, err
+	}
+
+	var pf planFile
+	if err = json.Unmarshal(b, &pf); err != nil {
+		return Plan{}, fmt.Errorf("failed to decode plan: %w", err)
+	}
+	if pf.Version != PlanVersion {
+		return Plan{}, fmt.Errorf("unsupported plan version %d, expected %d", pf.Version, PlanVersion)
+	}
+	return Plan{Roots: pf.Roots, Jobs: pf.Jobs}, nil
+}
+
+// Save writes the plan to the file at path.
+// The plan is written to a temporary file in the same directory first,
+// which is then renamed into place, so that an interrupted save does not corrupt the plan.
+func (p *Plan) Save(path string) error {
+	return p.save(osFileSystem{}, path)
+}
+
+// save writes the plan to the file at path on fsys.
+func (p *Plan) save(fsys fileSystem, path string) error {
+	b, err := json.MarshalIndent(planFile{
+		Version: PlanVersion,
+		Roots:   p.Roots,
+		Jobs:    p.Jobs,
+	}, "", "    ")
+	if err != nil {
+		return err
+	}
+
+	return writeFileAtomic(fsys, path, append(b, '\n'))
+}
@@ -0,0 +1,224 @@
+package precheck
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha256"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newPlanTestJobs returns jobs with every serialized field set, under dir.
+func newPlanTestJobs(dir string) []Job {
+	a := newTestJob(filepath.Join(dir, "client", "mods", "a.jar"), testContent)
+	a.Mirrors = []string{"http://mirror1.example.com/a.jar", "http://mirror2.example.com/a.jar"}
+	a.UserAgent = "test-agent/1.0"
+	a.MigrateFromPath = filepath.Join(dir, "old", "mods", "a.jar")
+	a.MigrationMode = MigrationModeHardlink
+	a.SecondaryDestinationPath = filepath.Join(dir, "server", "mods", "a.jar")
+	a.ModTime = time.Date(2024, 5, 6, 7, 8, 9, 123456789, time.FixedZone("UTC+8", 8*60*60))
+	a.NoClobber = true
+
+	b := newTestJob(filepath.Join(dir, "client", "config", "b.cfg"), testOtherContent)
+	sum := sha256.Sum256(testOtherContent)
+	b.NewHash = sha256.New
+	b.Sum = sum[:]
+
+	return []Job{a, b}
+}
+
+// assertSameJobs fails the test if the serialized fields of the jobs are not the same.
+func assertSameJobs(t *testing.T, got, want []Job) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("got %d jobs, want %d", len(got), len(want))
+	}
+	for i := range want {
+		g, w := got[i], want[i]
+		gotAlg, err := hashAlgorithmOf(g.NewHash)
+		if err != nil {
+			t.Errorf("job %d: hash function of the loaded job: %v", i, err)
+		}
+		wantAlg, _ := hashAlgorithmOf(w.NewHash)
+		if gotAlg != wantAlg {
+			t.Errorf("job %d: hash algorithm = %s, want %s", i, gotAlg, wantAlg)
+		}
+		if !g.ModTime.Equal(w.ModTime) {
+			t.Errorf("job %d: ModTime = %v, want %v", i, g.ModTime, w.ModTime)
+		}
+		g.NewHash, w.NewHash = nil, nil
+		g.ModTime, w.ModTime = time.Time{}, time.Time{}
+		if !reflect.DeepEqual(g, w) {
+			t.Errorf("job %d = %+v, want %+v", i, g, w)
+		}
+	}
+}
+
+func TestPlanRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	jobs := newPlanTestJobs(dir)
+	roots := []string{filepath.Join(dir, "client"), "", filepath.Join(dir, "server")}
+
+	p, err := NewPlan(jobs, roots)
+	if err != nil {
+		t.Fatalf("NewPlan() error = %v", err)
+	}
+	path := filepath.Join(dir, "plan.json")
+	if err = p.Save(path); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	loaded, err := LoadPlan(path)
+	if err != nil {
+		t.Fatalf("LoadPlan() error = %v", err)
+	}
+	if wantRoots := []string{roots[0], roots[2]}; !slices.Equal(loaded.Roots, wantRoots) {
+		t.Errorf("Roots = %q, want %q", loaded.Roots, wantRoots)
+	}
+	got, err := loaded.PrecheckJobs()
+	if err != nil {
+		t.Fatalf("PrecheckJobs() error = %v", err)
+	}
+	assertSameJobs(t, got, jobs)
+}
+
+func TestPlanFileFormat(t *testing.T) {
+	dir := t.TempDir()
+	p, err := NewPlan(newPlanTestJobs(dir), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "plan.json")
+	if err = p.Save(path); err != nil {
+		t.Fatal(err)
+	}
+
+	b := readTestFile(t, path)
+	for _, want := range []string{
+		`"version": 1`,
+		`"hashAlgorithm": "sha1"`,
+		`"hashAlgorithm": "sha256"`,
+		`"migrationMode": "hardlink"`,
+		`"sum": "` + p.Jobs[0].Sum + `"`,
+		`"mtime": "2024-05-06T07:08:09.123456789+08:00"`,
+	} {
+		if !bytes.Contains(b, []byte(want)) {
+			t.Errorf("plan file does not contain %s:\n%s", want, b)
+		}
+	}
+	if bytes.Contains(b, []byte(`"roots"`)) {
+		t.Errorf("plan file without roots has a roots field:\n%s", b)
+	}
+}
+
+func TestResumedPlanRunsLikeOriginal(t *testing.T) {
+	dir := t.TempDir()
+	existing := filepath.Join(dir, "mods", "existing.jar")
+	writeTestFile(t, existing, testContent)
+	jobs := []Job{
+		newTestJob(existing, testContent),
+		newTestJob(filepath.Join(dir, "mods", "missing.jar"), testContent),
+	}
+
+	p, err := NewPlan(jobs, []string{dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "plan.json")
+	if err = p.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadPlan(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resumed, err := loaded.PrecheckJobs()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i, want := range []Outcome{OutcomeSkipped, OutcomeQueued} {
+		outcome, djs := runTestJob(t, &resumed[i])
+		if outcome != want {
+			t.Errorf("outcome of resumed job %d = %s, want %s", i, outcome, want)
+		}
+		if len(djs) == 1 && (djs[0].DownloadURL != jobs[i].DownloadURL || !bytes.Equal(djs[0].Sum, jobs[i].Sum)) {
+			t.Errorf("download job of resumed job %d = %+v, does not match the original job", i, djs[0])
+		}
+	}
+}
+
+func TestNewPlanRejectsUnknownHashFunction(t *testing.T) {
+	j := newTestJob("a.jar", testContent)
+	j.NewHash = md5.New
+	if _, err := NewPlan([]Job{j}, nil); !errors.Is(err, ErrUnknownHashAlgorithm) {
+		t.Errorf("NewPlan() error = %v, want %v", err, ErrUnknownHashAlgorithm)
+	}
+	j.NewHash = nil
+	if _, err := NewPlan([]Job{j}, nil); !errors.Is(err, ErrUnknownHashAlgorithm) {
+		t.Errorf("NewPlan() of job without hash function error = %v, want %v", err, ErrUnknownHashAlgorithm)
+	}
+}
+
+func TestLoadPlanErrors(t *testing.T) {
+	const validJob = `{"downloadURL": "http://example.com/a.jar", "destinationPath": "a.jar", "migrationMode": "move", "hashAlgorithm": "sha1", "sum": "00", "size": 1, "mtime": "0001-01-01T00:00:00Z"}`
+	for _, c := range []struct {
+		name    string
+		content string
+		wantErr string
+		// jobsErr is whether loading succeeds, but PrecheckJobs fails.
+		jobsErr bool
+	}{
+		{"NotJSON", "not json", "failed to decode plan", false},
+		{"Version", `{"version": 2, "jobs": []}`, "unsupported plan version 2", false},
+		{"NoVersion", `{"jobs": []}`, "unsupported plan version 0", false},
+		{"HashAlgorithm", `{"version": 1, "jobs": [` + strings.Replace(validJob, `"sha1"`, `"md5"`, 1) + `]}`, "unknown hash algorithm", false},
+		{"Sum", `{"version": 1, "jobs": [` + strings.Replace(validJob, `"00"`, `"zz"`, 1) + `]}`, "failed to decode sum", true},
+		{"NoURL", `{"version": 1, "jobs": [` + strings.Replace(validJob, `"http://example.com/a.jar"`, `""`, 1) + `]}`, "missing download URL", true},
+		{"NoDestination", `{"version": 1, "jobs": [` + strings.Replace(validJob, `"destinationPath": "a.jar"`, `"destinationPath": ""`, 1) + `]}`, "missing download URL or destination path", true},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "plan.json")
+			writeTestFile(t, path, []byte(c.content))
+			p, err := LoadPlan(path)
+			if c.jobsErr {
+				if err != nil {
+					t.Fatalf("LoadPlan() error = %v", err)
+				}
+				_, err = p.PrecheckJobs()
+			}
+			if err == nil || !strings.Contains(err.Error(), c.wantErr) {
+				t.Errorf("error = %v, want it to contain %q", err, c.wantErr)
+			}
+		})
+	}
+
+	if _, err := LoadPlan(filepath.Join(t.TempDir(), "missing.json")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("LoadPlan() of missing file error = %v, want %v", err, os.ErrNotExist)
+	}
+}
+
+func TestHashAlgorithmText(t *testing.T) {
+	for _, a := range []HashAlgorithm{HashAlgorithmSHA1, HashAlgorithmSHA256} {
+		text, err := a.MarshalText()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got HashAlgorithm
+		if err = got.UnmarshalText(text); err != nil || got != a {
+			t.Errorf("UnmarshalText(%q) = %v, %v, want %v", text, got, err, a)
+		}
+		if _, err = a.NewFunc(); err != nil {
+			t.Errorf("%s.NewFunc() error = %v", a, err)
+		}
+	}
+	if _, err := HashAlgorithm(255).NewFunc(); !errors.Is(err, ErrUnknownHashAlgorithm) {
+		t.Errorf("NewFunc() of unknown algorithm error = %v, want %v", err, ErrUnknownHashAlgorithm)
+	}
+}
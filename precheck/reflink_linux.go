@@ -0,0 +1,47 @@
+package precheck
+
+import (
+	"errors"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// reflinkFile makes dst a copy-on-write clone of src using the FICLONE ioctl.
+// The file offsets are ignored, and the entire content of src is cloned.
+// Only [*os.File]s can be cloned.
+func reflinkFile(dst, src file) error {
+	dstf, ok := dst.(*os.File)
+	if !ok {
+		return errors.ErrUnsupported
+	}
+	srcf, ok := src.(*os.File)
+	if !ok {
+		return errors.ErrUnsupported
+	}
+
+	dstConn, err := dstf.SyscallConn()
+	if err != nil {
+		return err
+	}
+	srcConn, err := srcf.SyscallConn()
+	if err != nil {
+		return err
+	}
+
+	var ioctlErr error
+	if err = dstConn.Control(func(dstFd uintptr) {
+		err = srcConn.Control(func(srcFd uintptr) {
+			ioctlErr = unix.IoctlFileClone(int(dstFd), int(srcFd))
+		})
+	}); err != nil {
+		return err
+	}
+	if err != nil {
+		return err
+	}
+	if ioctlErr != nil {
+		return os.NewSyscallError("ioctl(FICLONE)", ioctlErr)
+	}
+	return nil
+}
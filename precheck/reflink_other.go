@@ -0,0 +1,10 @@
+//go:build !linux
+
+package precheck
+
+import "errors"
+
+// reflinkFile is not supported on this platform.
+func reflinkFile(dst, src file) error {
+	return errors.ErrUnsupported
+}
@@ -0,0 +1,43 @@
+package precheck
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/lmittmann/tint"
+)
+
+// touchExisting sets the modification time of an existing file that passed the check
+// to the job's ModTime, if the fleet is set to touch existing files, the job has a ModTime,
+// and the file's modification time differs. The content is left untouched.
+//
+// The file is recorded again in the journal and the extended attribute cache, if used,
+// as the new modification time invalidates the existing records.
+func (j *Job) touchExisting(ctx context.Context, logger *slog.Logger, f file) {
+	if !j.touch || j.ModTime.IsZero() {
+		return
+	}
+
+	fi, err := f.Stat()
+	if err != nil || fi.ModTime().Equal(j.ModTime) {
+		return
+	}
+
+	if err = j.filesystem().Chtimes(f.Name(), j.ModTime, j.ModTime); err != nil {
+		logger.LogAttrs(ctx, slog.LevelWarn, "Failed to set modification time",
+			slog.String("path", f.Name()),
+			tint.Err(err),
+		)
+		return
+	}
+
+	logger.LogAttrs(ctx, slog.LevelInfo, "Updated modification time of existing file",
+		slog.String("path", f.Name()),
+		slog.Time("oldModTime", fi.ModTime()),
+		slog.Time("modTime", j.ModTime),
+	)
+
+	if fi, err = f.Stat(); err == nil {
+		j.recordVerified(f, fi)
+	}
+}
@@ -0,0 +1,176 @@
+package precheck
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+var (
+	// testStaleModTime is the modification time of restored test files.
+	testStaleModTime = time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	// testManifestModTime is the modification time of test files in the manifest.
+	testManifestModTime = time.Date(2024, 6, 7, 8, 9, 10, 0, time.UTC)
+)
+
+// writeStaleTestFile writes content to the file at path, with the modification time [testStaleModTime].
+func writeStaleTestFile(t *testing.T, path string, content []byte) {
+	t.Helper()
+	writeTestFile(t, path, content)
+	if err := os.Chtimes(path, testStaleModTime, testStaleModTime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// assertModTime fails the test if the modification time of the file at path is not want.
+func assertModTime(t *testing.T, path string, want time.Time) {
+	t.Helper()
+	fi, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := fi.ModTime(); !got.Equal(want) {
+		t.Errorf("modification time of %q = %v, want %v", path, got, want)
+	}
+}
+
+// newTouchTestJob returns a job for a valid file with a stale modification time at client,
+// and at server too if it is not empty, with the modification time [testManifestModTime].
+func newTouchTestJob(t *testing.T, client, server string) Job {
+	t.Helper()
+	writeStaleTestFile(t, client, testContent)
+	j := newTestJob(client, testContent)
+	if server != "" {
+		writeStaleTestFile(t, server, testContent)
+		j.SecondaryDestinationPath = server
+	}
+	j.ModTime = testManifestModTime
+	return j
+}
+
+func TestJobTouchesExistingFiles(t *testing.T) {
+	for _, c := range []struct {
+		name      string
+		secondary bool
+	}{
+		{"OneDestination", false},
+		{"TwoDestinations", true},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			dir := t.TempDir()
+			paths := []string{filepath.Join(dir, "client", "mods", "a.jar")}
+			server := ""
+			if c.secondary {
+				server = filepath.Join(dir, "server", "mods", "a.jar")
+				paths = append(paths, server)
+			}
+
+			j := newTouchTestJob(t, paths[0], server)
+			j.touch = true
+			if outcome, djs := runTestJob(t, &j); outcome != OutcomeSkipped || len(djs) != 0 {
+				t.Fatalf("outcome = %s with %d download jobs, want %s with none", outcome, len(djs), OutcomeSkipped)
+			}
+			for _, path := range paths {
+				assertModTime(t, path, testManifestModTime)
+				if got := readTestFile(t, path); !bytes.Equal(got, testContent) {
+					t.Errorf("content of %q = %q, want %q", path, got, testContent)
+				}
+			}
+		})
+	}
+}
+
+func TestJobLeavesModTimesAlone(t *testing.T) {
+	for _, c := range []struct {
+		name    string
+		touch   bool
+		modTime time.Time
+	}{
+		{"Disabled", false, testManifestModTime},
+		{"NoModTime", true, time.Time{}},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "mods", "a.jar")
+			j := newTouchTestJob(t, path, "")
+			j.touch = c.touch
+			j.ModTime = c.modTime
+			if outcome, _ := runTestJob(t, &j); outcome != OutcomeSkipped {
+				t.Fatalf("outcome = %s, want %s", outcome, OutcomeSkipped)
+			}
+			assertModTime(t, path, testStaleModTime)
+		})
+	}
+}
+
+func TestJobDoesNotTouchInvalidFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "mods", "a.jar")
+	writeStaleTestFile(t, path, testOtherContent)
+
+	j := newTestJob(path, testContent)
+	j.ModTime = testManifestModTime
+	j.touch = true
+	if outcome, _ := runTestJob(t, &j); outcome != OutcomeQueued {
+		t.Fatalf("outcome = %s, want %s", outcome, OutcomeQueued)
+	}
+	assertModTime(t, path, testStaleModTime)
+}
+
+func TestJobTouchRecordsJournal(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "mods", "a.jar")
+	j := newTouchTestJob(t, path, "")
+	j.touch = true
+	j.journal = NewJournal()
+	if outcome, _ := runTestJob(t, &j); outcome != OutcomeSkipped {
+		t.Fatalf("outcome = %s, want %s", outcome, OutcomeSkipped)
+	}
+
+	fi, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !j.journal.verified(path, fi, j.Sum) {
+		t.Error("file with the new modification time is not verified in the journal")
+	}
+}
+
+// failingChtimesFileSystem is the [osFileSystem], except that setting modification times fails.
+type failingChtimesFileSystem struct {
+	osFileSystem
+}
+
+// Chtimes implements [fileSystem.Chtimes].
+func (failingChtimesFileSystem) Chtimes(name string, atime, mtime time.Time) error {
+	return &os.PathError{Op: "chtimes", Path: name, Err: errors.ErrUnsupported}
+}
+
+func TestJobTouchFailureKeepsFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "mods", "a.jar")
+	j := newTouchTestJob(t, path, "")
+	j.touch = true
+	j.fsys = failingChtimesFileSystem{}
+	if outcome, djs := runTestJob(t, &j); outcome != OutcomeSkipped || len(djs) != 0 {
+		t.Fatalf("outcome = %s with %d download jobs, want %s with none", outcome, len(djs), OutcomeSkipped)
+	}
+	assertModTime(t, path, testStaleModTime)
+}
+
+func TestWithTouchExisting(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "mods", "a.jar")
+	j := newTouchTestJob(t, path, "")
+
+	pjch := make(chan Job, 1)
+	pjch <- j
+	close(pjch)
+	wf := NewWorkerFleet(context.Background(), testLogger, 1, pjch, WithTouchExisting())
+	wf.Wait()
+
+	if got := wf.Stats().Skipped; got != 1 {
+		t.Errorf("Stats().Skipped = %d, want 1", got)
+	}
+	assertModTime(t, path, testManifestModTime)
+}
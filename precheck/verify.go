@@ -0,0 +1,93 @@
+package precheck
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log/slog"
+	"os"
+
+	"github.com/lmittmann/tint"
+)
+
+// verify checks the files at the destination paths without modifying them,
+// and logs every file that is missing or does not have the expected size and hash sum.
+// It returns [OutcomeSkipped] if all files are intact, or [OutcomeFailed] otherwise.
+func (j *Job) verify(ctx context.Context, logger *slog.Logger) Outcome {
+	ok := j.verifyFileAtPath(ctx, logger, j.DestinationPath)
+	if j.SecondaryDestinationPath != "" && !j.verifyFileAtPath(ctx, logger, j.SecondaryDestinationPath) {
+		ok = false
+	}
+	if !ok {
+		return OutcomeFailed
+	}
+	return OutcomeSkipped
+}
+
+// verifyFileAtPath checks the file at the given path and logs any mismatch.
+// It returns whether the file is intact.
+func (j *Job) verifyFileAtPath(ctx context.Context, logger *slog.Logger, path string) bool {
+	f, err := j.filesystem().Open(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			logger.LogAttrs(ctx, slog.LevelWarn, "File is missing",
+				slog.String("path", path),
+			)
+		} else {
+			logger.LogAttrs(ctx, slog.LevelWarn, "Failed to open file",
+				slog.String("path", path),
+				tint.Err(err),
+			)
+		}
+		return false
+	}
+	defer f.Close()
+
+	ok, err := j.checkFileSum(f)
+	if err != nil {
+		logger.LogAttrs(ctx, slog.LevelWarn, "Failed to read file",
+			slog.String("path", path),
+			tint.Err(err),
+		)
+		return false
+	}
+	if !ok {
+		j.logMismatch(ctx, logger, f)
+		return false
+	}
+
+	if j.Verify != nil {
+		if err = j.Verify(path); err != nil {
+			logger.LogAttrs(ctx, slog.LevelWarn, "File failed verification",
+				slog.String("path", path),
+				tint.Err(err),
+			)
+			return false
+		}
+	}
+
+	logger.LogAttrs(ctx, slog.LevelDebug, "Verified file",
+		slog.String("path", path),
+	)
+	return true
+}
+
+// logMismatch logs the expected and actual size and hash sum of the given file,
+// which failed the check. The actual sum is only logged if the file has the expected size.
+func (j *Job) logMismatch(ctx context.Context, logger *slog.Logger, f file) {
+	attrs := []slog.Attr{
+		slog.String("path", f.Name()),
+		slog.Int64("expectedSize", j.Size),
+	}
+	if fi, err := f.Stat(); err == nil {
+		attrs = append(attrs, slog.Int64("actualSize", fi.Size()))
+	}
+	attrs = append(attrs, slog.String("expectedSum", hex.EncodeToString(j.Sum)))
+	if _, err := f.Seek(0, io.SeekStart); err == nil {
+		if sum, oversized, err := j.contentSum(f); err == nil && !oversized {
+			attrs = append(attrs, slog.String("actualSum", hex.EncodeToString(sum)))
+		}
+	}
+	logger.LogAttrs(ctx, slog.LevelWarn, "File does not match", attrs...)
+}
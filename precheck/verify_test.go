@@ -0,0 +1,60 @@
+package precheck
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"log/slog"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestJobVerifyFlagsCorruptedFile(t *testing.T) {
+	dir := t.TempDir()
+	intact := filepath.Join(dir, "intact.jar")
+	corrupted := filepath.Join(dir, "corrupted.jar")
+	writeTestFile(t, intact, testContent)
+	corruptedContent := bytes.ToUpper(testContent)
+	writeTestFile(t, corrupted, corruptedContent)
+
+	j := newTestJob(intact, testContent)
+	j.VerifyOnly = true
+	if outcome, _ := runTestJob(t, &j); outcome != OutcomeSkipped {
+		t.Errorf("outcome of intact file = %s, want %s", outcome, OutcomeSkipped)
+	}
+
+	var logs bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&logs, nil))
+	j = newTestJob(corrupted, testContent)
+	j.VerifyOnly = true
+	if outcome := j.Run(context.Background(), logger, nil); outcome != OutcomeFailed {
+		t.Errorf("outcome of corrupted file = %s, want %s", outcome, OutcomeFailed)
+	}
+	for _, want := range []string{
+		`msg="File does not match"`,
+		"expectedSum=" + hex.EncodeToString(sha1Sum(testContent)),
+		"actualSum=" + hex.EncodeToString(sha1Sum(corruptedContent)),
+	} {
+		if !strings.Contains(logs.String(), want) {
+			t.Errorf("logs do not contain %q:\n%s", want, logs.String())
+		}
+	}
+	if got := readTestFile(t, corrupted); !bytes.Equal(got, corruptedContent) {
+		t.Error("verification modified the corrupted file")
+	}
+}
+
+func TestJobVerifyFlagsMissingAndTruncatedFiles(t *testing.T) {
+	dir := t.TempDir()
+	truncated := filepath.Join(dir, "truncated.jar")
+	writeTestFile(t, truncated, testContent[:5])
+
+	for _, path := range []string{filepath.Join(dir, "missing.jar"), truncated} {
+		j := newTestJob(path, testContent)
+		j.VerifyOnly = true
+		if outcome, _ := runTestJob(t, &j); outcome != OutcomeFailed {
+			t.Errorf("outcome of %s = %s, want %s", filepath.Base(path), outcome, OutcomeFailed)
+		}
+	}
+}
@@ -9,8 +9,9 @@ import (
 	"log/slog"
 	"os"
 	"path/filepath"
-	"runtime"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/database64128/modpack-dl-go/download"
 	"github.com/lmittmann/tint"
@@ -25,10 +26,16 @@ import (
 //     In this case, the file is copied to the other path if it's not already there.
 //   - The target file already exists at MigrateFromPath. In this case, the file is
 //     moved/copied to DestinationPath and copied to SecondaryDestinationPath.
+//   - NoClobber is set, and a file that fails the check exists at DestinationPath
+//     or SecondaryDestinationPath. In this case, the file is kept as is.
 type Job struct {
 	// DownloadURL is the target file's download URL.
 	DownloadURL string
 
+	// Mirrors is a list of alternative download URLs.
+	// They are tried in order if downloading from DownloadURL fails.
+	Mirrors []string
+
 	// UserAgent is the user agent to use for the request.
 	// If empty, Go's default behavior is preserved.
 	UserAgent string
@@ -37,9 +44,9 @@ type Job struct {
 	// The path may be empty. The file may not exist or may have different content.
 	MigrateFromPath string
 
-	// PreserveMigrationSource controls whether to preserve the file at
-	// MigrateFromPath should a migration happen.
-	PreserveMigrationSource bool
+	// MigrationMode controls how the file at MigrateFromPath is migrated
+	// should a migration happen.
+	MigrationMode MigrationMode
 
 	// DestinationPath is the destination path for downloading the file
 	// or migrating an existing file to.
@@ -57,40 +64,157 @@ type Job struct {
 
 	// Size is the expected size of the file.
 	Size int64
+
+	// ModTime is the modification time to set on the downloaded file
+	// if the server does not report one. Zero means no fallback.
+	ModTime time.Time
+
+	// Verify, if not nil, is called with the path of each file whose content matches
+	// the expected hash sum, such as to check a detached signature over the file.
+	// Files for which it returns an error are treated as not matching, so they are
+	// downloaded again, and downloads that fail it are rejected.
+	Verify func(path string) error
+
+	// DryRun controls whether to only log the planned action without
+	// creating, migrating, or downloading any files.
+	DryRun bool
+
+	// VerifyOnly controls whether to only verify the files at the destination paths,
+	// reporting any that are missing or corrupt, without creating, migrating, or downloading any files.
+	VerifyOnly bool
+
+	// UsePartFile controls whether the file is downloaded to a temporary file next to
+	// each destination path, named with [PartFileSuffix], which is renamed into place
+	// only after the download has been verified. A destination path then never contains
+	// a partially downloaded file.
+	UsePartFile bool
+
+	// NoClobber controls whether an existing file at a destination path that fails the check
+	// is kept as is, as it may have been edited locally, instead of being downloaded again.
+	// Missing and empty files are still downloaded.
+	//
+	// NoClobber implies UsePartFile, so that partial content left by an interrupted download
+	// is never mistaken for a local edit.
+	NoClobber bool
+
+	// journal, if not nil, is the fleet's journal of verified files.
+	journal *Journal
+
+	// xattrCache controls whether verified files are recorded in, and trusted from, an extended attribute.
+	xattrCache bool
+
+	// verifyCopies controls whether copies between destination paths are hashed after copying.
+	verifyCopies bool
+
+	// touch controls whether existing files that pass the check have their modification times set to ModTime.
+	touch bool
+
+	// fsys, if not nil, replaces the os package for filesystem operations by path.
+	fsys fileSystem
+
+	// fileMode and dirMode, if not zero, are the permission bits of created files and directories.
+	fileMode os.FileMode
+	dirMode  os.FileMode
+
+	// releaseFiles, if not nil, returns the files of the job to the fleet's open file budget.
+	// It is handed off to the download job when one is sent.
+	releaseFiles func()
 }
 
+// Default permission bits of created files and directories, before the umask is applied.
+const (
+	defaultFileMode os.FileMode = 0644
+	defaultDirMode  os.FileMode = 0755
+)
+
 // createFile creates the file at the given path.
 // The parent directory will be created if it doesn't exist.
 // It returns the opened created file or an error.
-func createFile(path string) (*os.File, error) {
-	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+//
+// The file and any parent directories are created with the fleet's permission bits,
+// or the defaults, subject to the umask. Existing files and directories keep their modes.
+func (j *Job) createFile(path string) (file, error) {
+	fileMode, dirMode := defaultFileMode, defaultDirMode
+	if j.fileMode != 0 {
+		fileMode = j.fileMode
+	}
+	if j.dirMode != 0 {
+		dirMode = j.dirMode
+	}
+
+	fsys := j.filesystem()
+	f, err := fsys.OpenFile(path, os.O_RDWR|os.O_CREATE, fileMode)
 	if err != nil {
-		if err = os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		if err = fsys.MkdirAll(filepath.Dir(path), dirMode); err != nil {
 			return nil, err
 		}
-		return os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+		return fsys.OpenFile(path, os.O_RDWR|os.O_CREATE, fileMode)
 	}
 	return f, nil
 }
 
+// copyBufferSize is the size of the buffers in copyBufferPool.
+const copyBufferSize = 64 * 1024
+
+// copyBufferPool is a pool of reusable buffers for hashing file content,
+// shared across all workers.
+var copyBufferPool = sync.Pool{
+	New: func() any {
+		b := make([]byte, copyBufferSize)
+		return &b
+	},
+}
+
 // checkFileContent checks the given file's content.
 // The file offset will be at the end of the file after the check.
 // It returns whether the content matches the expected hash sum or an error.
-func (j *Job) checkFileContent(f *os.File) (bool, error) {
-	h := j.NewHash()
-	if _, err := io.Copy(h, f); err != nil {
+//
+// Reading stops as soon as the content is known to be larger than the expected size.
+func (j *Job) checkFileContent(f file) (bool, error) {
+	sum, oversized, err := j.contentSum(f)
+	if err != nil || oversized {
 		return false, err
 	}
+	return bytes.Equal(sum, j.Sum), nil
+}
+
+// contentSum hashes the given file's content from the current file offset.
+// It returns the hash sum, or whether the content is larger than the expected size,
+// in which case reading stops early and no sum is returned.
+func (j *Job) contentSum(f file) (sum []byte, oversized bool, err error) {
+	bufp := copyBufferPool.Get().(*[]byte)
+	defer copyBufferPool.Put(bufp)
+
+	h := j.NewHash()
+	n, err := io.CopyBuffer(h, io.LimitReader(f, j.Size+1), *bufp)
+	if err != nil {
+		return nil, false, err
+	}
+	if n > j.Size {
+		return nil, true, nil
+	}
+	return h.Sum(make([]byte, 0, h.Size())), false, nil
+}
 
-	b := make([]byte, 0, h.Size())
-	b = h.Sum(b)
-	return bytes.Equal(b, j.Sum), nil
+// checkFile checks the file's size and content, and then calls the job's Verify function, if any.
+// After the check, the file offset will be restored to the start of the file.
+// It returns whether the check succeeded or an error.
+func (j *Job) checkFile(f file) (bool, error) {
+	ok, err := j.checkFileSum(f)
+	if !ok || err != nil || j.Verify == nil {
+		return ok, err
+	}
+	return j.Verify(f.Name()) == nil, nil
 }
 
-// checkFile checks the file's size and content.
+// checkFileSum checks the file's size and content against the expected hash sum.
 // After the check, the file offset will be restored to the start of the file.
 // It returns whether the check succeeded or an error.
-func (j *Job) checkFile(f *os.File) (bool, error) {
+//
+// If the job has a journal, or uses the extended attribute cache, hashing is skipped
+// for files recorded in either as verified and unmodified since, and files that pass
+// the check are recorded.
+func (j *Job) checkFileSum(f file) (bool, error) {
 	fi, err := f.Stat()
 	if err != nil {
 		return false, err
@@ -99,6 +223,13 @@ func (j *Job) checkFile(f *os.File) (bool, error) {
 		return false, nil
 	}
 
+	if j.journal != nil && j.journal.verified(f.Name(), fi, j.Sum) {
+		return true, nil
+	}
+	if j.xattrCache && xattrVerified(f, fi, j.Sum) {
+		return true, nil
+	}
+
 	ok, err := j.checkFileContent(f)
 	if err != nil {
 		return false, err
@@ -107,13 +238,52 @@ func (j *Job) checkFile(f *os.File) (bool, error) {
 	if _, err = f.Seek(0, io.SeekStart); err != nil {
 		return false, err
 	}
+
+	if ok {
+		j.recordVerified(f, fi)
+	}
 	return ok, nil
 }
 
+// recordVerified records the file described by fi as verified in the journal
+// and the extended attribute cache, if used.
+func (j *Job) recordVerified(f file, fi os.FileInfo) {
+	if j.journal != nil {
+		j.journal.record(f.Name(), fi, j.Sum)
+	}
+	if j.xattrCache {
+		recordXattr(f, fi, j.Sum)
+	}
+}
+
+// recordDestinations records the files at the destination paths as verified
+// in the journal and the extended attribute cache, if used.
+// It's called once the files have been put in place from verified content,
+// by a download, a copy, or a migration, so that the next run does not hash them again.
+func (j *Job) recordDestinations() {
+	if j.journal == nil && !j.xattrCache {
+		return
+	}
+	for _, path := range [...]string{j.DestinationPath, j.SecondaryDestinationPath} {
+		if path == "" {
+			continue
+		}
+		f, err := j.filesystem().Open(path)
+		if err != nil {
+			continue
+		}
+		if fi, err := f.Stat(); err == nil && fi.Size() == j.Size {
+			j.recordVerified(f, fi)
+		}
+		f.Close()
+	}
+}
+
 // openAndCheckFile opens the file at the given path for reading and checks it.
 // It returns the opened checked file, whether the check succeeded, or an error.
-func (j *Job) openAndCheckFile(path string) (*os.File, bool, error) {
-	f, err := os.Open(path)
+// If the file does not exist, the returned file is nil.
+func (j *Job) openAndCheckFile(path string) (file, bool, error) {
+	f, err := j.filesystem().Open(path)
 	if err != nil {
 		if errors.Is(err, os.ErrNotExist) {
 			return nil, false, nil
@@ -131,8 +301,8 @@ func (j *Job) openAndCheckFile(path string) (*os.File, bool, error) {
 
 // createAndCheckFile creates and then checks the file at the given path.
 // It returns the opened checked file, whether the check succeeded, or an error.
-func (j *Job) createAndCheckFile(path string) (*os.File, bool, error) {
-	f, err := createFile(path)
+func (j *Job) createAndCheckFile(path string) (file, bool, error) {
+	f, err := j.createFile(path)
 	if err != nil {
 		return nil, false, err
 	}
@@ -145,37 +315,131 @@ func (j *Job) createAndCheckFile(path string) (*os.File, bool, error) {
 	return f, ok, nil
 }
 
+// createAndCheckBothFiles creates and checks the files at the destination path and the
+// secondary destination path concurrently, so that hashing one does not wait on the other.
+// It returns the results of [Job.createAndCheckFile] for each path.
+func (j *Job) createAndCheckBothFiles() (f1 file, ok1 bool, f2 file, ok2 bool, err1, err2 error) {
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		f2, ok2, err2 = j.createAndCheckFile(j.SecondaryDestinationPath)
+	}()
+	f1, ok1, err1 = j.createAndCheckFile(j.DestinationPath)
+	wg.Wait()
+	return
+}
+
+// PartFileSuffix is appended to destination paths to name the temporary files
+// that files are downloaded to when [Job.UsePartFile] is set.
+const PartFileSuffix = ".part"
+
+// openPartFile closes the file at a destination path, removing it if it's empty,
+// which is the case if it has just been created, and opens the part file to download to instead.
+// Any content already in the part file is kept for resuming the download.
+func (j *Job) openPartFile(dst file) (file, error) {
+	path := dst.Name()
+	fi, err := dst.Stat()
+	dst.Close()
+	if err == nil && fi.Size() == 0 {
+		if err = j.filesystem().Remove(path); err != nil {
+			return nil, err
+		}
+	}
+	return j.createFile(path + PartFileSuffix)
+}
+
 // sendDownloadJob sends a download job to the download job channel.
-func (j *Job) sendDownloadJob(djch chan<- download.Job, f1, f2 *os.File) {
-	djch <- download.Job{
+// It returns the outcome of the precheck job.
+//
+// If there is no expected hash sum to verify the existing content of f1 against,
+// but its size is as expected, the download is made conditional on the file's
+// modification time.
+//
+// If UsePartFile or NoClobber is set, the files at the destination paths are replaced with part files.
+// Conditional downloads are not made in this case, as they require downloading in place.
+func (j *Job) sendDownloadJob(ctx context.Context, logger *slog.Logger, djch chan<- download.Job, f1, f2 file) Outcome {
+	dj := download.Job{
 		DownloadURL:         j.DownloadURL,
+		Mirrors:             j.Mirrors,
 		UserAgent:           j.UserAgent,
 		TargetFile:          f1,
 		SecondaryTargetFile: f2,
+		Size:                j.Size,
+		NewHash:             j.NewHash,
+		Sum:                 j.Sum,
+		ModTime:             j.ModTime,
+		Verify:              j.Verify,
 	}
+
+	if j.UsePartFile || j.NoClobber {
+		pf1, err := j.openPartFile(f1)
+		if err != nil {
+			logger.LogAttrs(ctx, slog.LevelWarn, "Failed to open part file",
+				slog.String("path", f1.Name()),
+				tint.Err(err),
+			)
+			if f2 != nil {
+				f2.Close()
+			}
+			return OutcomeFailed
+		}
+		dj.TargetFile = pf1
+		dj.RenameTo = f1.Name()
+
+		if f2 != nil {
+			pf2, err := j.openPartFile(f2)
+			if err != nil {
+				logger.LogAttrs(ctx, slog.LevelWarn, "Failed to open part file",
+					slog.String("path", f2.Name()),
+					tint.Err(err),
+				)
+				pf1.Close()
+				return OutcomeFailed
+			}
+			dj.SecondaryTargetFile = pf2
+			dj.SecondaryRenameTo = f2.Name()
+		}
+	} else if len(j.Sum) == 0 && j.Size > 0 {
+		if fi, err := f1.Stat(); err == nil && fi.Size() == j.Size {
+			dj.IfModifiedSince = fi.ModTime()
+		}
+	}
+
+	dj.OnClose = j.releaseFiles
+	j.releaseFiles = nil
+	if len(j.Sum) > 0 {
+		dj.OnSuccess = j.recordDestinations
+	}
+	djch <- dj
+	return OutcomeQueued
 }
 
 // runWithoutSecondaryDestinationPath runs the job when SecondaryDestinationPath is empty.
-func (j *Job) runWithoutSecondaryDestinationPath(ctx context.Context, logger *slog.Logger, djch chan<- download.Job) {
+func (j *Job) runWithoutSecondaryDestinationPath(ctx context.Context, logger *slog.Logger, djch chan<- download.Job) Outcome {
 	dst, ok, err := j.createAndCheckFile(j.DestinationPath)
 	if err != nil {
 		logger.LogAttrs(ctx, slog.LevelWarn, "Failed to check file at destination path",
 			slog.String("path", j.DestinationPath),
 			tint.Err(err),
 		)
-		return
+		return OutcomeFailed
 	}
 	if ok {
 		logger.LogAttrs(ctx, slog.LevelInfo, "Skipping existing file",
 			slog.String("path", j.DestinationPath),
 		)
+		j.touchExisting(ctx, logger, dst)
 		dst.Close()
-		return
+		return OutcomeSkipped
+	}
+	if j.keepModified(ctx, logger, dst) {
+		dst.Close()
+		return OutcomeSkipped
 	}
 
 	if j.MigrateFromPath == "" {
-		j.sendDownloadJob(djch, dst, nil)
-		return
+		return j.sendDownloadJob(ctx, logger, djch, dst, nil)
 	}
 
 	src, ok, err := j.openAndCheckFile(j.MigrateFromPath)
@@ -185,25 +449,35 @@ func (j *Job) runWithoutSecondaryDestinationPath(ctx context.Context, logger *sl
 			tint.Err(err),
 		)
 		dst.Close()
-		return
+		return OutcomeFailed
 	}
 	if !ok {
-		j.sendDownloadJob(djch, dst, nil)
-		src.Close()
-		return
+		if src != nil {
+			src.Close()
+		}
+		return j.sendDownloadJob(ctx, logger, djch, dst, nil)
 	}
 
-	if !j.PreserveMigrationSource {
+	switch j.MigrationMode {
+	case MigrationModeHardlink:
+		src.Close()
+		dst.Close()
+		if !j.linkMigrationSource(ctx, logger, j.DestinationPath) {
+			return OutcomeFailed
+		}
+		return OutcomeLinked
+
+	case MigrationModeMove:
 		// First close the files and attempt a rename.
 		src.Close()
 		dst.Close()
 
-		if err = os.Rename(j.MigrateFromPath, j.DestinationPath); err == nil {
+		if err = j.filesystem().Rename(j.MigrateFromPath, j.DestinationPath); err == nil {
 			logger.LogAttrs(ctx, slog.LevelInfo, "Moved existing file",
 				slog.String("src", j.MigrateFromPath),
 				slog.String("dst", j.DestinationPath),
 			)
-			return
+			return OutcomeMoved
 		}
 
 		logger.LogAttrs(ctx, slog.LevelDebug, "Rename failed, falling back to copy & remove",
@@ -212,28 +486,13 @@ func (j *Job) runWithoutSecondaryDestinationPath(ctx context.Context, logger *sl
 			tint.Err(err),
 		)
 
-		// Open the files again to fall back to copy & remove.
-		dst, err = os.OpenFile(j.DestinationPath, os.O_RDWR, 0644)
-		if err != nil {
-			logger.LogAttrs(ctx, slog.LevelWarn, "Failed to open file at destination path",
-				slog.String("path", j.DestinationPath),
-				tint.Err(err),
-			)
-			return
-		}
-
-		src, err = os.Open(j.MigrateFromPath)
-		if err != nil {
-			logger.LogAttrs(ctx, slog.LevelWarn, "Failed to open file at migration source path",
-				slog.String("path", j.MigrateFromPath),
-				tint.Err(err),
-			)
-			dst.Close()
-			return
+		if !j.copyMigrationSource(ctx, logger, j.DestinationPath) {
+			return OutcomeFailed
 		}
+		return j.removeMigrationSource(ctx, logger)
 	}
 
-	if _, err = dst.ReadFrom(src); err != nil {
+	if err = j.copyFile(ctx, logger, dst, src); err != nil {
 		logger.LogAttrs(ctx, slog.LevelWarn, "Failed to copy file",
 			slog.String("src", src.Name()),
 			slog.String("dst", dst.Name()),
@@ -241,7 +500,7 @@ func (j *Job) runWithoutSecondaryDestinationPath(ctx context.Context, logger *sl
 		)
 		src.Close()
 		dst.Close()
-		return
+		return OutcomeFailed
 	}
 
 	src.Close()
@@ -251,41 +510,30 @@ func (j *Job) runWithoutSecondaryDestinationPath(ctx context.Context, logger *sl
 		slog.String("src", src.Name()),
 		slog.String("dst", dst.Name()),
 	)
-
-	if j.PreserveMigrationSource {
-		return
-	}
-
-	if err = os.Remove(j.MigrateFromPath); err != nil {
-		logger.LogAttrs(ctx, slog.LevelWarn, "Failed to remove migration source file",
-			slog.String("path", j.MigrateFromPath),
-			tint.Err(err),
-		)
-		return
-	}
-
-	logger.LogAttrs(ctx, slog.LevelInfo, "Removed migration source file", slog.String("path", j.MigrateFromPath))
+	return OutcomeCopied
 }
 
 // runWithSecondaryDestinationPath runs the job when SecondaryDestinationPath is not empty.
-func (j *Job) runWithSecondaryDestinationPath(ctx context.Context, logger *slog.Logger, djch chan<- download.Job) {
-	f1, ok1, err := j.createAndCheckFile(j.DestinationPath)
-	if err != nil {
+func (j *Job) runWithSecondaryDestinationPath(ctx context.Context, logger *slog.Logger, djch chan<- download.Job) Outcome {
+	f1, ok1, f2, ok2, err1, err2 := j.createAndCheckBothFiles()
+	if err1 != nil {
 		logger.LogAttrs(ctx, slog.LevelWarn, "Failed to check file at destination path",
 			slog.String("path", j.DestinationPath),
-			tint.Err(err),
+			tint.Err(err1),
 		)
-		return
+		if f2 != nil {
+			f2.Close()
+		}
+		return OutcomeFailed
 	}
 
-	f2, ok2, err := j.createAndCheckFile(j.SecondaryDestinationPath)
-	if err != nil {
+	if err2 != nil {
 		logger.LogAttrs(ctx, slog.LevelWarn, "Failed to check file at secondary destination path",
 			slog.String("path", j.SecondaryDestinationPath),
-			tint.Err(err),
+			tint.Err(err2),
 		)
 		f1.Close()
-		return
+		return OutcomeFailed
 	}
 
 	// Both files exist and are valid.
@@ -294,14 +542,20 @@ func (j *Job) runWithSecondaryDestinationPath(ctx context.Context, logger *slog.
 			slog.String("path", j.DestinationPath),
 			slog.String("secondaryPath", j.SecondaryDestinationPath),
 		)
+		j.touchExisting(ctx, logger, f1)
+		j.touchExisting(ctx, logger, f2)
 		f1.Close()
 		f2.Close()
-		return
+		return OutcomeSkipped
+	}
+
+	if outcome, ok := j.runKeepingModified(ctx, logger, djch, f1, ok1, f2, ok2); ok {
+		return outcome
 	}
 
 	// Only one of the files exists and is valid.
 	if ok1 || ok2 {
-		var src, dst *os.File
+		var src, dst file
 		if ok1 {
 			src = f1
 			dst = f2
@@ -310,29 +564,36 @@ func (j *Job) runWithSecondaryDestinationPath(ctx context.Context, logger *slog.
 			dst = f1
 		}
 
-		if _, err = dst.ReadFrom(src); err != nil {
+		if err := j.copyFile(ctx, logger, dst, src); err != nil {
 			logger.LogAttrs(ctx, slog.LevelWarn, "Failed to copy file",
 				slog.String("src", src.Name()),
 				slog.String("dst", dst.Name()),
 				tint.Err(err),
 			)
+			src.Close()
+			dst.Close()
+			return OutcomeFailed
+		}
+
+		src.Close()
+
+		if j.verifyCopies && !j.verifyCopy(ctx, logger, dst) {
+			return j.sendDownloadJob(ctx, logger, djch, dst, nil)
 		}
 
+		dst.Close()
+
 		logger.LogAttrs(ctx, slog.LevelInfo, "Copied existing file",
 			slog.String("src", src.Name()),
 			slog.String("dst", dst.Name()),
 		)
-
-		src.Close()
-		dst.Close()
-		return
+		return OutcomeCopied
 	}
 
 	// Neither file exists or is valid.
 	// Check if the migration source exists.
 	if j.MigrateFromPath == "" {
-		j.sendDownloadJob(djch, f1, f2)
-		return
+		return j.sendDownloadJob(ctx, logger, djch, f1, f2)
 	}
 
 	f3, ok3, err := j.openAndCheckFile(j.MigrateFromPath)
@@ -343,18 +604,31 @@ func (j *Job) runWithSecondaryDestinationPath(ctx context.Context, logger *slog.
 		)
 		f1.Close()
 		f2.Close()
-		return
+		return OutcomeFailed
 	}
 	if !ok3 {
-		j.sendDownloadJob(djch, f1, f2)
-		f3.Close()
-		return
+		if f3 != nil {
+			f3.Close()
+		}
+		return j.sendDownloadJob(ctx, logger, djch, f1, f2)
 	}
 
 	// The migration source exists and is valid.
 
+	if j.MigrationMode == MigrationModeHardlink {
+		f1.Close()
+		f2.Close()
+		f3.Close()
+		ok1 := j.linkMigrationSource(ctx, logger, j.DestinationPath)
+		ok2 := j.linkMigrationSource(ctx, logger, j.SecondaryDestinationPath)
+		if !ok1 || !ok2 {
+			return OutcomeFailed
+		}
+		return OutcomeLinked
+	}
+
 	var hasCopyError bool
-	if _, err = f1.ReadFrom(f3); err != nil {
+	if err = j.copyFile(ctx, logger, f1, f3); err != nil {
 		logger.LogAttrs(ctx, slog.LevelWarn, "Failed to copy file",
 			slog.String("src", f3.Name()),
 			slog.String("dst", f1.Name()),
@@ -370,17 +644,20 @@ func (j *Job) runWithSecondaryDestinationPath(ctx context.Context, logger *slog.
 
 	f1.Close()
 
-	if !j.PreserveMigrationSource {
+	if !j.MigrationMode.PreservesSource() {
 		// First close the files and attempt a rename.
 		f2.Close()
 		f3.Close()
 
-		if err = os.Rename(j.MigrateFromPath, j.SecondaryDestinationPath); err == nil {
+		if err = j.filesystem().Rename(j.MigrateFromPath, j.SecondaryDestinationPath); err == nil {
 			logger.LogAttrs(ctx, slog.LevelInfo, "Moved existing file",
 				slog.String("src", j.MigrateFromPath),
 				slog.String("dst", j.SecondaryDestinationPath),
 			)
-			return
+			if hasCopyError {
+				return OutcomeFailed
+			}
+			return OutcomeMoved
 		}
 
 		logger.LogAttrs(ctx, slog.LevelDebug, "Rename failed, falling back to copy & remove",
@@ -389,38 +666,23 @@ func (j *Job) runWithSecondaryDestinationPath(ctx context.Context, logger *slog.
 			tint.Err(err),
 		)
 
-		// Open the files again to fall back to copy & remove.
-		f2, err = os.OpenFile(j.SecondaryDestinationPath, os.O_RDWR, 0644)
-		if err != nil {
-			logger.LogAttrs(ctx, slog.LevelWarn, "Failed to open file at secondary destination path",
-				slog.String("path", j.SecondaryDestinationPath),
-				tint.Err(err),
-			)
-			return
+		if !j.copyMigrationSource(ctx, logger, j.SecondaryDestinationPath) || hasCopyError {
+			return OutcomeFailed
 		}
+		return j.removeMigrationSource(ctx, logger)
+	}
 
-		f3, err = os.Open(j.MigrateFromPath)
-		if err != nil {
-			logger.LogAttrs(ctx, slog.LevelWarn, "Failed to open file at migration source path",
-				slog.String("path", j.MigrateFromPath),
-				tint.Err(err),
-			)
-			f2.Close()
-			return
-		}
-	} else {
-		if _, err = f3.Seek(0, io.SeekStart); err != nil {
-			logger.LogAttrs(ctx, slog.LevelWarn, "Failed to seek to start of file",
-				slog.String("path", f3.Name()),
-				tint.Err(err),
-			)
-			f2.Close()
-			f3.Close()
-			return
-		}
+	if _, err = f3.Seek(0, io.SeekStart); err != nil {
+		logger.LogAttrs(ctx, slog.LevelWarn, "Failed to seek to start of file",
+			slog.String("path", f3.Name()),
+			tint.Err(err),
+		)
+		f2.Close()
+		f3.Close()
+		return OutcomeFailed
 	}
 
-	if _, err = f2.ReadFrom(f3); err != nil {
+	if err = j.copyFile(ctx, logger, f2, f3); err != nil {
 		logger.LogAttrs(ctx, slog.LevelWarn, "Failed to copy file",
 			slog.String("src", f3.Name()),
 			slog.String("dst", f2.Name()),
@@ -437,37 +699,198 @@ func (j *Job) runWithSecondaryDestinationPath(ctx context.Context, logger *slog.
 	f2.Close()
 	f3.Close()
 
-	if hasCopyError || j.PreserveMigrationSource {
-		return
+	if hasCopyError {
+		return OutcomeFailed
 	}
+	return OutcomeCopied
+}
 
-	if err = os.Remove(j.MigrateFromPath); err != nil {
-		logger.LogAttrs(ctx, slog.LevelWarn, "Failed to remove migration source file",
-			slog.String("path", j.MigrateFromPath),
+var errCopyMismatch = errors.New("copied file does not match the expected hash sum")
+
+// verifyCopy hashes the file that has just been copied to, bypassing the journal
+// and the extended attribute cache, and returns whether it matches the expected hash sum.
+// Files without an expected hash sum always match. Files that do not match are truncated.
+func (j *Job) verifyCopy(ctx context.Context, logger *slog.Logger, f file) bool {
+	if len(j.Sum) == 0 {
+		return true
+	}
+
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		logger.LogAttrs(ctx, slog.LevelWarn, "Failed to seek to start of file",
+			slog.String("path", f.Name()),
 			tint.Err(err),
 		)
-		return
+		return false
 	}
 
-	logger.LogAttrs(ctx, slog.LevelInfo, "Removed migration source file", slog.String("path", j.MigrateFromPath))
+	ok, err := j.checkFileContent(f)
+	if err == nil && !ok {
+		err = errCopyMismatch
+	}
+	if err != nil {
+		logger.LogAttrs(ctx, slog.LevelWarn, "Failed to verify copied file, downloading instead",
+			slog.String("path", f.Name()),
+			tint.Err(err),
+		)
+		// Do not resume the download from the bad copy. If truncating fails,
+		// a resumed download that fails the hash check is retried from scratch anyway.
+		_ = f.Truncate(0)
+		return false
+	}
+	return true
 }
 
-// Run runs the job.
-func (j *Job) Run(ctx context.Context, logger *slog.Logger, djch chan<- download.Job) {
+// checkFileAtPath opens and checks the file at the given path without creating it.
+// It returns whether the check succeeded or an error.
+func (j *Job) checkFileAtPath(path string) (bool, error) {
+	f, ok, err := j.openAndCheckFile(path)
+	if err != nil {
+		return false, err
+	}
+	if f != nil {
+		f.Close()
+	}
+	return ok, nil
+}
+
+// dryRun logs the action the job would take without touching the filesystem.
+// It returns the planned outcome of the job.
+func (j *Job) dryRun(ctx context.Context, logger *slog.Logger) Outcome {
+	ok1, err := j.checkFileAtPath(j.DestinationPath)
+	if err != nil {
+		logger.LogAttrs(ctx, slog.LevelWarn, "Failed to check file at destination path",
+			slog.String("path", j.DestinationPath),
+			tint.Err(err),
+		)
+		return OutcomeFailed
+	}
+
+	ok2 := true
+	if j.SecondaryDestinationPath != "" {
+		if ok2, err = j.checkFileAtPath(j.SecondaryDestinationPath); err != nil {
+			logger.LogAttrs(ctx, slog.LevelWarn, "Failed to check file at secondary destination path",
+				slog.String("path", j.SecondaryDestinationPath),
+				tint.Err(err),
+			)
+			return OutcomeFailed
+		}
+	}
+
+	if outcome, ok := j.dryRunKeepingModified(ctx, logger, ok1, ok2); ok {
+		return outcome
+	}
+
+	switch {
+	case ok1 && ok2:
+		logger.LogAttrs(ctx, slog.LevelInfo, "Would skip existing file",
+			slog.String("path", j.DestinationPath),
+			slog.String("secondaryPath", j.SecondaryDestinationPath),
+		)
+		return OutcomeSkipped
+
+	case ok1:
+		logger.LogAttrs(ctx, slog.LevelInfo, "Would copy existing file",
+			slog.String("src", j.DestinationPath),
+			slog.String("dst", j.SecondaryDestinationPath),
+			slog.Int64("size", j.Size),
+		)
+		return OutcomeCopied
+
+	case ok2 && j.SecondaryDestinationPath != "":
+		logger.LogAttrs(ctx, slog.LevelInfo, "Would copy existing file",
+			slog.String("src", j.SecondaryDestinationPath),
+			slog.String("dst", j.DestinationPath),
+			slog.Int64("size", j.Size),
+		)
+		return OutcomeCopied
+	}
+
+	if j.MigrateFromPath != "" {
+		ok3, err := j.checkFileAtPath(j.MigrateFromPath)
+		if err != nil {
+			logger.LogAttrs(ctx, slog.LevelWarn, "Failed to check file at migration source path",
+				slog.String("path", j.MigrateFromPath),
+				tint.Err(err),
+			)
+			return OutcomeFailed
+		}
+		if ok3 {
+			logger.LogAttrs(ctx, slog.LevelInfo, "Would migrate existing file",
+				slog.String("src", j.MigrateFromPath),
+				slog.String("dst", j.DestinationPath),
+				slog.String("secondaryDst", j.SecondaryDestinationPath),
+				slog.String("mode", j.MigrationMode.String()),
+				slog.Int64("size", j.Size),
+			)
+			return j.MigrationMode.outcome()
+		}
+	}
+
+	logger.LogAttrs(ctx, slog.LevelInfo, "Would download file",
+		slog.String("url", j.DownloadURL),
+		slog.String("path", j.DestinationPath),
+		slog.String("secondaryPath", j.SecondaryDestinationPath),
+		slog.Int64("size", j.Size),
+	)
+	return OutcomeQueued
+}
+
+// Run runs the job and returns its outcome.
+//
+// In dry-run mode, the returned outcome is the planned outcome,
+// and no download job is sent.
+//
+// In verify-only mode, the outcome is [OutcomeSkipped] if the files are intact,
+// or [OutcomeFailed] otherwise, and no download job is sent.
+func (j *Job) Run(ctx context.Context, logger *slog.Logger, djch chan<- download.Job) Outcome {
+	switch {
+	case j.VerifyOnly:
+		return j.verify(ctx, logger)
+	case j.DryRun:
+		return j.dryRun(ctx, logger)
+	}
+
+	var outcome Outcome
 	if j.SecondaryDestinationPath == "" {
-		j.runWithoutSecondaryDestinationPath(ctx, logger, djch)
+		outcome = j.runWithoutSecondaryDestinationPath(ctx, logger, djch)
 	} else {
-		j.runWithSecondaryDestinationPath(ctx, logger, djch)
+		outcome = j.runWithSecondaryDestinationPath(ctx, logger, djch)
+	}
+	switch outcome {
+	case OutcomeMoved, OutcomeCopied, OutcomeLinked:
+		j.recordDestinations()
+	}
+	return outcome
+}
+
+// notify notifies h of the outcome of the job.
+func (j *Job) notify(h download.EventHandler, outcome Outcome) {
+	e := download.Event{
+		Path: j.DestinationPath,
+		URL:  j.DownloadURL,
+		Size: j.Size,
+	}
+	switch outcome {
+	case OutcomeQueued:
+		// Reported by the download fleet.
+	case OutcomeFailed:
+		e.Err = ErrPrecheckFailed
+		h.OnError(e)
+	default:
+		e.Reason = outcome.String()
+		h.OnSkip(e)
 	}
 }
 
 // WorkerFleet manages a fleet of workers.
 type WorkerFleet struct {
-	wg   sync.WaitGroup
-	djch chan download.Job
+	wg                 sync.WaitGroup
+	djch               chan download.Job
+	outcomeCounts      [outcomeCount]atomic.Int64
+	queuedDownloadSize atomic.Int64
 }
 
-// NewWorkerFleet creates a fleet of [runtime.NumCPU] workers.
+// NewWorkerFleet creates a fleet of the given number of workers.
 //
 // These workers pick up precheck jobs from the given channel and
 // produce download jobs to a download job channel.
@@ -475,13 +898,13 @@ type WorkerFleet struct {
 // After use, close the precheck job channel to stop the workers.
 // Call the Wait method to wait for all workers to finish, and it
 // will close the download job channel.
-func NewWorkerFleet(ctx context.Context, logger *slog.Logger, pjch <-chan Job) *WorkerFleet {
+func NewWorkerFleet(ctx context.Context, logger *slog.Logger, numWorkers int, pjch <-chan Job, opts ...Option) *WorkerFleet {
 	wf := WorkerFleet{
 		djch: make(chan download.Job),
 	}
-	ncpu := runtime.NumCPU()
-	wf.wg.Add(ncpu)
-	for i := 0; i < ncpu; i++ {
+	cfg := newConfig(opts)
+	wf.wg.Add(numWorkers)
+	for i := 0; i < numWorkers; i++ {
 		go func() {
 			defer wf.wg.Done()
 			done := ctx.Done()
@@ -489,8 +912,38 @@ func NewWorkerFleet(ctx context.Context, logger *slog.Logger, pjch <-chan Job) *
 				select {
 				case <-done:
 					continue
+				case <-cfg.drain:
+					continue
 				default:
-					pj.Run(ctx, logger, wf.djch)
+					pj.journal = cfg.journal
+					pj.xattrCache = cfg.xattrCache
+					pj.verifyCopies = cfg.verifyCopies
+					pj.touch = cfg.touch
+					pj.fileMode = cfg.fileMode
+					pj.dirMode = cfg.dirMode
+					pj.fsys = cfg.fsys
+					if cfg.openFiles != nil && !pj.DryRun && !pj.VerifyOnly {
+						files := 1
+						if pj.SecondaryDestinationPath != "" {
+							files = 2
+						}
+						release, ok := cfg.openFiles.acquire(ctx, files)
+						if !ok {
+							continue
+						}
+						pj.releaseFiles = release
+					}
+					outcome := pj.Run(ctx, logger, wf.djch)
+					if pj.releaseFiles != nil {
+						pj.releaseFiles()
+					}
+					wf.outcomeCounts[outcome].Add(1)
+					if outcome == OutcomeQueued {
+						wf.queuedDownloadSize.Add(pj.Size)
+					}
+					if cfg.eventHandler != nil {
+						pj.notify(cfg.eventHandler, outcome)
+					}
 				}
 			}
 		}()
@@ -503,6 +956,24 @@ func (wf *WorkerFleet) DownloadJobChannel() <-chan download.Job {
 	return wf.djch
 }
 
+// Stats returns a snapshot of the fleet's counters.
+func (wf *WorkerFleet) Stats() Stats {
+	return Stats{
+		Skipped:            wf.outcomeCounts[OutcomeSkipped].Load(),
+		Moved:              wf.outcomeCounts[OutcomeMoved].Load(),
+		Copied:             wf.outcomeCounts[OutcomeCopied].Load(),
+		Linked:             wf.outcomeCounts[OutcomeLinked].Load(),
+		Queued:             wf.outcomeCounts[OutcomeQueued].Load(),
+		Failed:             wf.outcomeCounts[OutcomeFailed].Load(),
+		QueuedDownloadSize: wf.queuedDownloadSize.Load(),
+	}
+}
+
+// Failures returns the number of failed jobs.
+func (wf *WorkerFleet) Failures() int {
+	return int(wf.outcomeCounts[OutcomeFailed].Load())
+}
+
 // Wait waits for all workers to finish and closes the download job channel.
 func (wf *WorkerFleet) Wait() {
 	wf.wg.Wait()
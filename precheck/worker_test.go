@@ -0,0 +1,352 @@
+package precheck
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/database64128/modpack-dl-go/download"
+)
+
+// testLogger discards all logs.
+var testLogger = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+var (
+	testContent      = []byte("expected file content\n")
+	testOtherContent = []byte("some other file content that differs\n")
+)
+
+// sha1Sum returns the SHA-1 sum of b.
+func sha1Sum(b []byte) []byte {
+	sum := sha1.Sum(b)
+	return sum[:]
+}
+
+// newTestJob returns a job for putting content at path.
+func newTestJob(path string, content []byte) Job {
+	return Job{
+		DownloadURL:     "http://example.com/" + filepath.Base(path),
+		DestinationPath: path,
+		NewHash:         sha1.New,
+		Sum:             sha1Sum(content),
+		Size:            int64(len(content)),
+	}
+}
+
+// writeTestFile writes content to the file at path, creating parent directories as needed.
+func writeTestFile(t *testing.T, path string, content []byte) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// readTestFile returns the content of the file at path.
+func readTestFile(t *testing.T, path string) []byte {
+	t.Helper()
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+// runTestJob runs the job, and returns its outcome and the download jobs it sent.
+// The target files of the download jobs are closed when the test ends.
+// No download jobs are expected in dry-run and verify-only modes.
+func runTestJob(t *testing.T, j *Job) (Outcome, []download.Job) {
+	t.Helper()
+	djch := make(chan download.Job, 2)
+	outcome := j.Run(context.Background(), testLogger, djch)
+	close(djch)
+
+	var djs []download.Job
+	for dj := range djch {
+		djs = append(djs, dj)
+		t.Cleanup(func() {
+			dj.TargetFile.Close()
+			if dj.SecondaryTargetFile != nil {
+				dj.SecondaryTargetFile.Close()
+			}
+		})
+	}
+	if j.DryRun || j.VerifyOnly {
+		if len(djs) != 0 {
+			t.Errorf("%d download jobs were sent in dry-run or verify-only mode", len(djs))
+		}
+		return outcome, djs
+	}
+	if outcome == OutcomeQueued && len(djs) != 1 {
+		t.Errorf("outcome is queued, but %d download jobs were sent", len(djs))
+	}
+	if outcome != OutcomeQueued && len(djs) != 0 {
+		t.Errorf("outcome is %s, but %d download jobs were sent", outcome, len(djs))
+	}
+	return outcome, djs
+}
+
+// listFiles returns the paths relative to dir of all files and directories under dir.
+func listFiles(t *testing.T, dir string) []string {
+	t.Helper()
+	var paths []string
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if path != dir {
+			rel, _ := filepath.Rel(dir, path)
+			paths = append(paths, rel)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	slices.Sort(paths)
+	return paths
+}
+
+func TestJobRunSkipsExistingFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "mods", "a.jar")
+	writeTestFile(t, path, testContent)
+
+	j := newTestJob(path, testContent)
+	if outcome, _ := runTestJob(t, &j); outcome != OutcomeSkipped {
+		t.Errorf("outcome = %s, want %s", outcome, OutcomeSkipped)
+	}
+}
+
+func TestJobRunQueuesMissingFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "mods", "a.jar")
+
+	j := newTestJob(path, testContent)
+	outcome, djs := runTestJob(t, &j)
+	if outcome != OutcomeQueued {
+		t.Fatalf("outcome = %s, want %s", outcome, OutcomeQueued)
+	}
+	dj := djs[0]
+	if dj.TargetFile.Name() != path {
+		t.Errorf("target file = %q, want %q", dj.TargetFile.Name(), path)
+	}
+	if dj.DownloadURL != j.DownloadURL || dj.Size != j.Size || !bytes.Equal(dj.Sum, j.Sum) {
+		t.Errorf("download job = %+v, does not match precheck job", dj)
+	}
+}
+
+func TestJobRunCopiesToSecondaryDestination(t *testing.T) {
+	dir := t.TempDir()
+	client := filepath.Join(dir, "client", "a.jar")
+	server := filepath.Join(dir, "server", "a.jar")
+	writeTestFile(t, server, testContent)
+
+	j := newTestJob(client, testContent)
+	j.SecondaryDestinationPath = server
+	if outcome, _ := runTestJob(t, &j); outcome != OutcomeCopied {
+		t.Fatalf("outcome = %s, want %s", outcome, OutcomeCopied)
+	}
+	if got := readTestFile(t, client); !bytes.Equal(got, testContent) {
+		t.Errorf("copied content = %q, want %q", got, testContent)
+	}
+}
+
+func TestJobDryRunTouchesNothing(t *testing.T) {
+	dir := t.TempDir()
+	existing := filepath.Join(dir, "client", "mods", "existing.jar")
+	corrupt := filepath.Join(dir, "client", "mods", "corrupt.jar")
+	secondary := filepath.Join(dir, "server", "mods", "existing.jar")
+	migrateFrom := filepath.Join(dir, "old", "mods", "migrated.jar")
+	writeTestFile(t, existing, testContent)
+	writeTestFile(t, corrupt, testOtherContent)
+	writeTestFile(t, migrateFrom, testContent)
+	before := listFiles(t, dir)
+
+	missing := newTestJob(filepath.Join(dir, "client", "config", "missing.cfg"), testContent)
+	copied := newTestJob(existing, testContent)
+	copied.SecondaryDestinationPath = secondary
+	redownloaded := newTestJob(corrupt, testContent)
+	migrated := newTestJob(filepath.Join(dir, "client", "mods", "migrated.jar"), testContent)
+	migrated.MigrateFromPath = migrateFrom
+	migrated.MigrationMode = MigrationModeMove
+
+	for _, c := range []struct {
+		name string
+		job  Job
+		want Outcome
+	}{
+		{"Missing", missing, OutcomeQueued},
+		{"Copied", copied, OutcomeCopied},
+		{"Corrupt", redownloaded, OutcomeQueued},
+		{"Migrated", migrated, OutcomeMoved},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			c.job.DryRun = true
+			if outcome, _ := runTestJob(t, &c.job); outcome != c.want {
+				t.Errorf("outcome = %s, want %s", outcome, c.want)
+			}
+		})
+	}
+
+	if after := listFiles(t, dir); !slices.Equal(before, after) {
+		t.Errorf("files after dry run = %q, want %q", after, before)
+	}
+	if got := readTestFile(t, corrupt); !bytes.Equal(got, testOtherContent) {
+		t.Errorf("dry run modified %q", corrupt)
+	}
+}
+
+func TestWorkerFleetCountsFailures(t *testing.T) {
+	dir := t.TempDir()
+	blocker := filepath.Join(dir, "blocker")
+	writeTestFile(t, blocker, nil)
+	existing := filepath.Join(dir, "existing.jar")
+	writeTestFile(t, existing, testContent)
+
+	pjch := make(chan Job)
+	wf := NewWorkerFleet(context.Background(), testLogger, 2, pjch)
+	go func() {
+		for range wf.DownloadJobChannel() {
+		}
+	}()
+	pjch <- newTestJob(existing, testContent)
+	// The parent directory cannot be created, because a file is in the way.
+	pjch <- newTestJob(filepath.Join(blocker, "mods", "a.jar"), testContent)
+	close(pjch)
+	wf.Wait()
+
+	if got := wf.Failures(); got != 1 {
+		t.Errorf("Failures() = %d, want 1", got)
+	}
+	if got := wf.Stats().Skipped; got != 1 {
+		t.Errorf("Stats().Skipped = %d, want 1", got)
+	}
+}
+
+func TestCheckFileContentStopsAtExpectedSize(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "big.bin")
+	writeTestFile(t, path, make([]byte, 1<<20))
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	j := newTestJob(path, testContent)
+	ok, err := j.checkFileContent(f)
+	if err != nil {
+		t.Fatalf("checkFileContent() error = %v", err)
+	}
+	if ok {
+		t.Error("checkFileContent() = true for oversized file")
+	}
+	offset, err := f.Seek(0, io.SeekCurrent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if offset > j.Size+1 {
+		t.Errorf("read %d bytes of oversized file, want at most %d", offset, j.Size+1)
+	}
+}
+
+func BenchmarkCheckFileContent(b *testing.B) {
+	content := bytes.Repeat(testContent, 1<<16)
+	path := filepath.Join(b.TempDir(), "file.bin")
+	if err := os.WriteFile(path, content, 0644); err != nil {
+		b.Fatal(err)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer f.Close()
+
+	j := newTestJob(path, content)
+	b.SetBytes(int64(len(content)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err = f.Seek(0, io.SeekStart); err != nil {
+			b.Fatal(err)
+		}
+		ok, err := j.checkFileContent(f)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if !ok {
+			b.Fatal("checkFileContent() = false")
+		}
+	}
+}
+
+func TestWorkerFleetRunsRequestedWorkers(t *testing.T) {
+	const (
+		workers = 3
+		jobs    = 2 * workers
+	)
+	dir := t.TempDir()
+
+	var (
+		mu        sync.Mutex
+		active    int
+		maxActive int
+		allBusy   = make(chan struct{})
+		busyOnce  sync.Once
+	)
+	verify := func(path string) error {
+		mu.Lock()
+		active++
+		maxActive = max(maxActive, active)
+		if active == workers {
+			// Later jobs may all be running at once too.
+			busyOnce.Do(func() { close(allBusy) })
+		}
+		mu.Unlock()
+
+		select {
+		case <-allBusy:
+		case <-time.After(5 * time.Second):
+		}
+
+		mu.Lock()
+		active--
+		mu.Unlock()
+		return nil
+	}
+
+	pjch := make(chan Job)
+	wf := NewWorkerFleet(context.Background(), testLogger, workers, pjch)
+	go func() {
+		for range wf.DownloadJobChannel() {
+		}
+	}()
+	for i := range jobs {
+		path := filepath.Join(dir, fmt.Sprintf("%d.jar", i))
+		writeTestFile(t, path, testContent)
+		j := newTestJob(path, testContent)
+		j.Verify = verify
+		pjch <- j
+	}
+	close(pjch)
+	wf.Wait()
+
+	if maxActive != workers {
+		t.Errorf("max concurrent jobs = %d, want %d", maxActive, workers)
+	}
+	if got := wf.Stats().Skipped; got != jobs {
+		t.Errorf("Stats().Skipped = %d, want %d", got, jobs)
+	}
+}
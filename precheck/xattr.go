@@ -0,0 +1,59 @@
+package precheck
+
+import (
+	"encoding/hex"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// xattrName is the name of the extended attribute that records the verified content of a file.
+//
+// The value is "v1 {size} {mtime} {sum}", where mtime is the modification time in nanoseconds
+// since the Unix epoch, and sum is the hex-encoded hash sum the content was verified against.
+const xattrName = "user.modpackdl.sum"
+
+// xattrRecordVersion is the version of the extended attribute's value format.
+const xattrRecordVersion = "v1"
+
+// encodeXattrRecord returns the extended attribute value recording that the file
+// described by fi has been verified against sum.
+func encodeXattrRecord(fi os.FileInfo, sum []byte) []byte {
+	return []byte(xattrRecordVersion + " " +
+		strconv.FormatInt(fi.Size(), 10) + " " +
+		strconv.FormatInt(fi.ModTime().UnixNano(), 10) + " " +
+		hex.EncodeToString(sum))
+}
+
+// xattrRecordMatches returns whether the extended attribute value records
+// the file described by fi as verified against sum.
+func xattrRecordMatches(value []byte, fi os.FileInfo, sum []byte) bool {
+	fields := strings.Fields(string(value))
+	if len(fields) != 4 || fields[0] != xattrRecordVersion {
+		return false
+	}
+	return fields[1] == strconv.FormatInt(fi.Size(), 10) &&
+		fields[2] == strconv.FormatInt(fi.ModTime().UnixNano(), 10) &&
+		fields[3] == hex.EncodeToString(sum)
+}
+
+// xattrVerified returns whether the file's extended attribute records it as verified against sum,
+// with the size and modification time described by fi.
+// Files other than [*os.File] have no extended attributes.
+func xattrVerified(f file, fi os.FileInfo, sum []byte) bool {
+	osf, ok := f.(*os.File)
+	if !ok {
+		return false
+	}
+	value, err := getXattr(osf, xattrName)
+	return err == nil && xattrRecordMatches(value, fi, sum)
+}
+
+// recordXattr records in the file's extended attribute that the file described by fi
+// has been verified against sum. Errors, such as the filesystem not supporting
+// extended attributes, are ignored, as the file is simply hashed again next time.
+func recordXattr(f file, fi os.FileInfo, sum []byte) {
+	if osf, ok := f.(*os.File); ok {
+		_ = setXattr(osf, xattrName, encodeXattrRecord(fi, sum))
+	}
+}
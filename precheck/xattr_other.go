@@ -0,0 +1,18 @@
+//go:build !linux && !darwin
+
+package precheck
+
+import (
+	"errors"
+	"os"
+)
+
+// getXattr returns [errors.ErrUnsupported], as extended attributes are not supported on this platform.
+func getXattr(f *os.File, name string) ([]byte, error) {
+	return nil, errors.ErrUnsupported
+}
+
+// setXattr returns [errors.ErrUnsupported], as extended attributes are not supported on this platform.
+func setXattr(f *os.File, name string, value []byte) error {
+	return errors.ErrUnsupported
+}
@@ -0,0 +1,121 @@
+package precheck
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// skipIfNoXattrs skips the test if extended attributes cannot be set on files in dir.
+func skipIfNoXattrs(t *testing.T, dir string) {
+	t.Helper()
+	path := filepath.Join(dir, ".xattr-probe")
+	writeTestFile(t, path, nil)
+	defer os.Remove(path)
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if err = setXattr(f, xattrName, []byte("probe")); err != nil {
+		t.Skipf("extended attributes not supported: %v", err)
+	}
+}
+
+// newXattrTestJob returns a test job for path with the extended attribute cache enabled.
+func newXattrTestJob(path string, content []byte) Job {
+	j := newTestJob(path, content)
+	j.xattrCache = true
+	return j
+}
+
+func TestXattrSkipsHashingVerifiedFiles(t *testing.T) {
+	dir := t.TempDir()
+	skipIfNoXattrs(t, dir)
+	path := filepath.Join(dir, "mods", "a.jar")
+	writeTestFile(t, path, testContent)
+
+	j := newXattrTestJob(path, testContent)
+	if outcome, _ := runTestJob(t, &j); outcome != OutcomeSkipped {
+		t.Fatalf("outcome = %s, want %s", outcome, OutcomeSkipped)
+	}
+
+	// The next run trusts the record, as long as the size and modification time are unchanged.
+	tamperPreservingModTime(t, path, bytes.Repeat([]byte{'x'}, len(testContent)))
+	j = newXattrTestJob(path, testContent)
+	if outcome, _ := runTestJob(t, &j); outcome != OutcomeSkipped {
+		t.Errorf("outcome = %s, want %s without hashing", outcome, OutcomeSkipped)
+	}
+}
+
+func TestXattrInvalidatedByModTimeChange(t *testing.T) {
+	dir := t.TempDir()
+	skipIfNoXattrs(t, dir)
+	path := filepath.Join(dir, "mods", "a.jar")
+	writeTestFile(t, path, testContent)
+
+	j := newXattrTestJob(path, testContent)
+	if outcome, _ := runTestJob(t, &j); outcome != OutcomeSkipped {
+		t.Fatalf("outcome = %s, want %s", outcome, OutcomeSkipped)
+	}
+
+	tamperPreservingModTime(t, path, bytes.Repeat([]byte{'x'}, len(testContent)))
+	mtime := time.Now().Add(-time.Hour)
+	if err := os.Chtimes(path, mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+	j = newXattrTestJob(path, testContent)
+	if outcome, _ := runTestJob(t, &j); outcome != OutcomeQueued {
+		t.Errorf("outcome = %s, want %s for a modified file", outcome, OutcomeQueued)
+	}
+}
+
+func TestXattrNotTrustedWhenDisabled(t *testing.T) {
+	dir := t.TempDir()
+	skipIfNoXattrs(t, dir)
+	path := filepath.Join(dir, "mods", "a.jar")
+	writeTestFile(t, path, testContent)
+
+	j := newXattrTestJob(path, testContent)
+	if outcome, _ := runTestJob(t, &j); outcome != OutcomeSkipped {
+		t.Fatalf("outcome = %s, want %s", outcome, OutcomeSkipped)
+	}
+
+	tamperPreservingModTime(t, path, bytes.Repeat([]byte{'x'}, len(testContent)))
+	j = newTestJob(path, testContent)
+	if outcome, _ := runTestJob(t, &j); outcome != OutcomeQueued {
+		t.Errorf("outcome = %s, want %s", outcome, OutcomeQueued)
+	}
+}
+
+func TestXattrRecordMatches(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "a.jar")
+	writeTestFile(t, path, testContent)
+	fi, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum := sha1Sum(testContent)
+	value := encodeXattrRecord(fi, sum)
+	if !xattrRecordMatches(value, fi, sum) {
+		t.Errorf("xattrRecordMatches(%q) = false, want true", value)
+	}
+	if xattrRecordMatches(value, fi, sha1Sum(testOtherContent)) {
+		t.Errorf("xattrRecordMatches(%q) = true for a different sum", value)
+	}
+	for _, bad := range []string{"", "v0 1 2 abcd", "v1 1 2", "garbage"} {
+		if xattrRecordMatches([]byte(bad), fi, sum) {
+			t.Errorf("xattrRecordMatches(%q) = true, want false", bad)
+		}
+	}
+}
+
+func TestXattrRecordsPlacedFiles(t *testing.T) {
+	dir := t.TempDir()
+	skipIfNoXattrs(t, dir)
+	jobs, contents := placeFiles(t, dir, WithXattrCache())
+	assertPlacedFilesTrusted(t, jobs, contents, WithXattrCache())
+}
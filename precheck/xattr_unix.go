@@ -0,0 +1,54 @@
+//go:build linux || darwin
+
+package precheck
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// xattrMaxSize is the maximum size of the extended attribute values read.
+const xattrMaxSize = 256
+
+// getXattr returns the value of the named extended attribute of the file.
+func getXattr(f *os.File, name string) ([]byte, error) {
+	conn, err := f.SyscallConn()
+	if err != nil {
+		return nil, err
+	}
+
+	b := make([]byte, xattrMaxSize)
+	var (
+		n        int
+		xattrErr error
+	)
+	if err = conn.Control(func(fd uintptr) {
+		n, xattrErr = unix.Fgetxattr(int(fd), name, b)
+	}); err != nil {
+		return nil, err
+	}
+	if xattrErr != nil {
+		return nil, os.NewSyscallError("fgetxattr", xattrErr)
+	}
+	return b[:n], nil
+}
+
+// setXattr sets the named extended attribute of the file to value.
+func setXattr(f *os.File, name string, value []byte) error {
+	conn, err := f.SyscallConn()
+	if err != nil {
+		return err
+	}
+
+	var xattrErr error
+	if err = conn.Control(func(fd uintptr) {
+		xattrErr = unix.Fsetxattr(int(fd), name, value, 0)
+	}); err != nil {
+		return err
+	}
+	if xattrErr != nil {
+		return os.NewSyscallError("fsetxattr", xattrErr)
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+// Package prune removes files left over from previous modpack versions.
+package prune
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/lmittmann/tint"
+)
+
+// DefaultManagedDirs is the default list of managed directories.
+var DefaultManagedDirs = []string{"mods"}
+
+// protectedDirs is the list of top-level directories that must never be pruned,
+// as they hold user data that is not part of any modpack.
+var protectedDirs = []string{"saves", "world", "backups", "screenshots", "schematics"}
+
+// ErrUnmanageableDir is returned when a managed directory is not allowed to be pruned.
+var ErrUnmanageableDir = errors.New("directory cannot be managed")
+
+// ValidateManagedDirs checks that each of the given directories is a local path
+// strictly inside the installation root and does not hold user data.
+func ValidateManagedDirs(dirs []string) error {
+	for _, dir := range dirs {
+		if !filepath.IsLocal(dir) {
+			return fmt.Errorf("%w: %q is not a local path", ErrUnmanageableDir, dir)
+		}
+		dir = filepath.Clean(dir)
+		if dir == "." {
+			return fmt.Errorf("%w: the installation root cannot be managed", ErrUnmanageableDir)
+		}
+		top, _, _ := strings.Cut(filepath.ToSlash(dir), "/")
+		for _, protected := range protectedDirs {
+			if strings.EqualFold(top, protected) {
+				return fmt.Errorf("%w: %q holds user data", ErrUnmanageableDir, dir)
+			}
+		}
+	}
+	return nil
+}
+
+// Pruner removes files not in a set of expected paths.
+type Pruner struct {
+	// ManagedDirs is the list of directories, relative to the installation root,
+	// in which files not in the keep set are removed.
+	ManagedDirs []string
+
+	// DryRun controls whether to only log the files that would be removed.
+	DryRun bool
+
+	keep map[string]struct{}
+}
+
+// Keep adds the given path to the set of files to keep.
+func (p *Pruner) Keep(path string) {
+	if p.keep == nil {
+		p.keep = make(map[string]struct{})
+	}
+	p.keep[filepath.Clean(path)] = struct{}{}
+}
+
+// Prune removes files in the managed directories under root that are not in the keep set.
+// Directories that don't exist are skipped. Symbolic links to directories are not followed.
+func (p *Pruner) Prune(ctx context.Context, logger *slog.Logger, root string) error {
+	if err := ValidateManagedDirs(p.ManagedDirs); err != nil {
+		return err
+	}
+
+	for _, dir := range p.ManagedDirs {
+		dirPath := filepath.Join(root, dir)
+		err := filepath.WalkDir(dirPath, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				if errors.Is(err, fs.ErrNotExist) && path == dirPath {
+					return fs.SkipDir
+				}
+				return err
+			}
+			if err = ctx.Err(); err != nil {
+				return err
+			}
+			if d.IsDir() {
+				return nil
+			}
+			if _, ok := p.keep[path]; ok {
+				return nil
+			}
+
+			if p.DryRun {
+				logger.LogAttrs(ctx, slog.LevelInfo, "Would remove extraneous file", slog.String("path", path))
+				return nil
+			}
+
+			if err = os.Remove(path); err != nil {
+				logger.LogAttrs(ctx, slog.LevelWarn, "Failed to remove extraneous file",
+					slog.String("path", path),
+					tint.Err(err),
+				)
+				return nil
+			}
+
+			logger.LogAttrs(ctx, slog.LevelInfo, "Removed extraneous file", slog.String("path", path))
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("failed to prune %q: %w", dirPath, err)
+		}
+	}
+
+	return nil
+}
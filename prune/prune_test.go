@@ -0,0 +1,126 @@
+package prune
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var testLogger = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+// seedFiles creates empty files at the given paths relative to root.
+func seedFiles(t *testing.T, root string, paths ...string) {
+	t.Helper()
+	for _, p := range paths {
+		path := filepath.Join(root, p)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// exists returns whether the file at the given path relative to root exists.
+func exists(root, p string) bool {
+	_, err := os.Stat(filepath.Join(root, p))
+	return err == nil
+}
+
+func TestPrunerPrune(t *testing.T) {
+	root := t.TempDir()
+	seedFiles(t, root,
+		"mods/kept.jar",
+		"mods/stale.jar",
+		"mods/sub/stale.jar",
+		"config/kept.cfg",
+		"config/stale.cfg",
+		"saves/world/level.dat",
+		"options.txt",
+	)
+
+	p := Pruner{ManagedDirs: []string{"mods", "config"}}
+	p.Keep(filepath.Join(root, "mods", "kept.jar"))
+	p.Keep(filepath.Join(root, "config", "kept.cfg"))
+	if err := p.Prune(context.Background(), testLogger, root); err != nil {
+		t.Fatalf("Prune() error = %v", err)
+	}
+
+	for _, c := range []struct {
+		path string
+		kept bool
+	}{
+		{"mods/kept.jar", true},
+		{"mods/stale.jar", false},
+		{"mods/sub/stale.jar", false},
+		{"config/kept.cfg", true},
+		{"config/stale.cfg", false},
+		// Outside the managed directories.
+		{"saves/world/level.dat", true},
+		{"options.txt", true},
+	} {
+		if got := exists(root, c.path); got != c.kept {
+			t.Errorf("%s exists = %v, want %v", c.path, got, c.kept)
+		}
+	}
+}
+
+func TestPrunerPruneDryRun(t *testing.T) {
+	root := t.TempDir()
+	seedFiles(t, root, "mods/stale.jar")
+
+	p := Pruner{ManagedDirs: []string{"mods"}, DryRun: true}
+	if err := p.Prune(context.Background(), testLogger, root); err != nil {
+		t.Fatalf("Prune() error = %v", err)
+	}
+	if !exists(root, "mods/stale.jar") {
+		t.Error("dry run removed mods/stale.jar")
+	}
+}
+
+func TestPrunerPruneMissingDir(t *testing.T) {
+	p := Pruner{ManagedDirs: []string{"mods"}}
+	if err := p.Prune(context.Background(), testLogger, t.TempDir()); err != nil {
+		t.Errorf("Prune() error = %v, want nil for missing managed directory", err)
+	}
+}
+
+func TestPrunerPruneRefusesProtectedDirs(t *testing.T) {
+	root := t.TempDir()
+	seedFiles(t, root, "saves/world/level.dat")
+
+	p := Pruner{ManagedDirs: []string{"saves"}}
+	if err := p.Prune(context.Background(), testLogger, root); !errors.Is(err, ErrUnmanageableDir) {
+		t.Errorf("Prune() error = %v, want %v", err, ErrUnmanageableDir)
+	}
+	if !exists(root, "saves/world/level.dat") {
+		t.Error("Prune() removed a file in a protected directory")
+	}
+}
+
+func TestValidateManagedDirs(t *testing.T) {
+	for _, c := range []struct {
+		dir string
+		ok  bool
+	}{
+		{"mods", true},
+		{"config/sub", true},
+		{".", false},
+		{"", false},
+		{"../mods", false},
+		{"saves", false},
+		{"Saves/sub", false},
+		{"world", false},
+		{"backups", false},
+	} {
+		err := ValidateManagedDirs([]string{c.dir})
+		if (err == nil) != c.ok {
+			t.Errorf("ValidateManagedDirs(%q) error = %v, want ok = %v", c.dir, err, c.ok)
+		}
+	}
+}